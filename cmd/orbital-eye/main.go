@@ -3,17 +3,37 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"image"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/clearclown/orbital-eye/internal/adsb"
+	"github.com/clearclown/orbital-eye/internal/ais"
+	"github.com/clearclown/orbital-eye/internal/annotate"
+	"github.com/clearclown/orbital-eye/internal/cfar"
+	"github.com/clearclown/orbital-eye/internal/change"
 	"github.com/clearclown/orbital-eye/internal/collector"
 	"github.com/clearclown/orbital-eye/internal/config"
 	"github.com/clearclown/orbital-eye/internal/detector"
+	"github.com/clearclown/orbital-eye/internal/equipment"
+	"github.com/clearclown/orbital-eye/internal/facilities"
 	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/landmask"
+	"github.com/clearclown/orbital-eye/internal/osm"
+	"github.com/clearclown/orbital-eye/internal/pol"
+	"github.com/clearclown/orbital-eye/internal/preprocess"
+	"github.com/clearclown/orbital-eye/internal/raster"
 	"github.com/clearclown/orbital-eye/internal/report"
+	"github.com/clearclown/orbital-eye/internal/track"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
 )
 
 var version = "0.1.0"
@@ -31,10 +51,22 @@ func main() {
 		cmdDetect(os.Args[2:])
 	case "report":
 		cmdReport(os.Args[2:])
+	case "change":
+		cmdChange(os.Args[2:])
 	case "monitor":
 		cmdMonitor(os.Args[2:])
 	case "search":
 		cmdSearch(os.Args[2:])
+	case "measure":
+		cmdMeasure(os.Args[2:])
+	case "index":
+		cmdIndex(os.Args[2:])
+	case "pol":
+		cmdPOL(os.Args[2:])
+	case "track":
+		cmdTrack(os.Args[2:])
+	case "import-osm":
+		cmdImportOSM(os.Args[2:])
 	case "health":
 		cmdHealth(os.Args[2:])
 	case "version":
@@ -56,8 +88,14 @@ Commands:
   fetch       Fetch satellite imagery for a location
   detect      Detect objects in satellite imagery
   report      Generate intelligence report from detection results
+  change      Find changed areas between two images of the same place
   monitor     Monitor a location for changes
   search      Search for imagery and detect objects in one step
+  measure     Measure geodesic distances, areas and shadow heights (WGS84)
+  index       Compute spectral indices (NDVI, NDWI, NBR, NDBI) from bands
+  pol         Pattern-of-life baselines and anomalous counts per AOI and class
+  track       Follow objects across a time series of detection results
+  import-osm  Import military, airfield and port areas from an OSM extract
   health      Check AI worker status
   version     Show version`)
 }
@@ -71,18 +109,37 @@ func cmdFetch(args []string) {
 	dateFrom := fs.String("from", "", "Start date (YYYY-MM-DD)")
 	dateTo := fs.String("to", "", "End date (YYYY-MM-DD)")
 	outDir := fs.String("out", "data/cache", "Output directory")
+	bands := fs.String("bands", "visual", "Comma-separated assets to download (e.g. visual,B03,B04,B08,B11,B12)")
+	rank := fs.String("rank", "aoi", "Rank scenes by cloud cover over the AOI (aoi, reads SCL) or the whole tile (tile)")
+	aoiPath := fs.String("aoi", "", "AOI polygon file (GeoJSON or WKT); overrides --lat/--lon/--radius")
+	at := fs.String("at", "", "Location as DD, DMS, UTM or MGRS (e.g. 49QBB4567815678); alternative to --lat/--lon")
+	mosaic := fs.Bool("mosaic", false, "Cover the whole AOI: pick the fewest scenes (same day preferred) and merge each band into one GeoTIFF")
 	fs.Parse(args)
 
-	if *lat == 0 && *lon == 0 {
-		fmt.Fprintln(os.Stderr, "Error: --lat and --lon are required")
+	center, ok := locationFlags(fs, *lat, *lon, *at)
+	if !ok && *aoiPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: --at or --lat/--lon (or --aoi) are required")
 		fs.Usage()
 		os.Exit(1)
 	}
+	if *rank != "aoi" && *rank != "tile" {
+		fmt.Fprintf(os.Stderr, "Error: --rank must be aoi or tile, got %q\n", *rank)
+		os.Exit(1)
+	}
 
 	ctx := context.Background()
 	s2 := collector.NewSentinel2(*outDir)
 
-	bbox := geo.BBoxFromCenter(geo.Point{Lat: *lat, Lon: *lon}, *radius)
+	bbox := geo.BBoxFromCenter(center, *radius)
+	var aoi geo.MultiPolygon
+	if *aoiPath != "" {
+		aoi = loadAOI(*aoiPath)
+		bbox = aoi.BBox()
+	} else {
+		for _, part := range bbox.Normalize().Split() {
+			aoi = append(aoi, geo.Polygon{part.Ring()})
+		}
+	}
 
 	from := time.Now().AddDate(0, -3, 0)
 	to := time.Now()
@@ -94,15 +151,25 @@ func cmdFetch(args []string) {
 	}
 
 	fmt.Printf("🛰️  Searching Sentinel-2 imagery...\n")
-	fmt.Printf("   Location: (%.4f, %.4f), Radius: %.1fkm, Cloud: <%.0f%%\n", *lat, *lon, *radius, *maxCloud)
+	if *aoiPath != "" {
+		fmt.Printf("   AOI: %s [%.4f, %.4f, %.4f, %.4f], Cloud: <%.0f%%\n", *aoiPath, bbox.West, bbox.South, bbox.East, bbox.North, *maxCloud)
+	} else {
+		fmt.Printf("   Location: (%.4f, %.4f), Radius: %.1fkm, Cloud: <%.0f%%\n", center.Lat, center.Lon, *radius, *maxCloud)
+	}
 	fmt.Printf("   Period: %s to %s\n", from.Format("2006-01-02"), to.Format("2006-01-02"))
 
+	maxResults := 10
+	if *mosaic {
+		// The planner needs every tile of a pass to find a same-day set.
+		maxResults = 40
+	}
 	results, err := s2.Search(ctx, collector.SearchParams{
 		BBox:       bbox,
 		DateFrom:   from,
 		DateTo:     to,
 		MaxCloud:   *maxCloud,
-		MaxResults: 10,
+		MaxResults: maxResults,
+		RankByAOI:  *rank == "aoi",
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -111,13 +178,31 @@ func cmdFetch(args []string) {
 
 	fmt.Printf("\n📡 Found %d scenes:\n", len(results))
 	for i, r := range results {
-		fmt.Printf("  [%d] %s  Date: %s  Cloud: %.1f%%  GSD: %.1fm\n",
+		fmt.Printf("  [%d] %s  Date: %s  Cloud: %.1f%%  GSD: %.1fm",
 			i, r.ID, r.Date.Format("2006-01-02"), r.CloudCover, r.GSD)
+		if r.Tile != "" {
+			fmt.Printf("  Tile: %s", r.Tile)
+		}
+		if c := r.AOICloud; c != nil {
+			fmt.Printf("  AOI cloud: %.1f%%  AOI clear: %.1f%%", c.CloudFraction*100, c.ClearFraction*100)
+		}
+		fmt.Println()
 	}
 
+	if *mosaic {
+		fetchMosaic(ctx, s2, aoi, results, strings.Split(*bands, ","), *outDir)
+		return
+	}
 	if len(results) > 0 {
 		fmt.Printf("\n⬇️  Downloading best scene: %s\n", results[0].ID)
-		path, err := s2.Download(ctx, results[0], []string{"visual"})
+		if fp, err := results[0].Footprint(); err == nil {
+			b := fp.BBox()
+			fmt.Printf("   Footprint: [%.4f, %.4f, %.4f, %.4f]\n", b.West, b.South, b.East, b.North)
+		}
+		if sv := results[0].SunView; sv != nil {
+			fmt.Printf("   Sun: azimuth %.1f°, elevation %.1f°\n", sv.SunAzimuth, sv.SunElevation)
+		}
+		path, err := s2.Download(ctx, results[0], strings.Split(*bands, ","))
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Download error: %v\n", err)
 			os.Exit(1)
@@ -126,6 +211,64 @@ func cmdFetch(args []string) {
 	}
 }
 
+// fetchMosaic plans the scenes covering aoi, downloads them and merges
+// each band into <outDir>/mosaic_<date>/<band>.tif.
+func fetchMosaic(ctx context.Context, s2 *collector.Sentinel2, aoi geo.MultiPolygon, results []collector.ImageResult, bands []string, outDir string) {
+	plan, err := collector.PlanCoverage(aoi, results, collector.PlanOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	sameDay := "yes"
+	if !plan.SameDay {
+		sameDay = "no"
+	}
+	fmt.Printf("\n🧩 Coverage plan: %d scene(s) cover %.1f%% of the AOI (same day: %s)\n", len(plan.Scenes), plan.Coverage*100, sameDay)
+
+	dirs := make([]string, len(plan.Scenes))
+	for i, r := range plan.Scenes {
+		fmt.Printf("⬇️  [%d] %s  Date: %s  Tile: %s\n", i, r.ID, r.Date.Format("2006-01-02"), r.Tile)
+		if dirs[i], err = s2.Download(ctx, r, bands); err != nil {
+			fmt.Fprintf(os.Stderr, "Download error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	mosaicDir := filepath.Join(outDir, "mosaic_"+plan.Scenes[0].Date.Format("20060102"))
+	if err := os.MkdirAll(mosaicDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, band := range bands {
+		var sources []*raster.GeoTIFF
+		for _, dir := range dirs {
+			f, err := raster.Open(filepath.Join(dir, band+".tif"))
+			if err != nil {
+				continue // asset missing from this scene
+			}
+			sources = append(sources, f)
+		}
+		if len(sources) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: no scene has band %s\n", band)
+			continue
+		}
+		m, err := raster.Mosaic(sources, raster.MosaicOptions{BBox: aoi.BBox()})
+		for _, f := range sources {
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: mosaic %s: %v\n", band, err)
+			os.Exit(1)
+		}
+		path := filepath.Join(mosaicDir, band+".tif")
+		if err := raster.WriteGeoTIFFFile(path, m); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ %s: %dx%d px → %s\n", band, m.Width, m.Height, path)
+	}
+}
+
 func cmdDetect(args []string) {
 	fs := flag.NewFlagSet("detect", flag.ExitOnError)
 	imagePath := fs.String("image", "", "Path to image file")
@@ -134,6 +277,23 @@ func cmdDetect(args []string) {
 	gsd := fs.Float64("gsd", 10.0, "Ground sample distance in meters")
 	aiAddr := fs.String("ai", "localhost:50051", "AI worker address")
 	outputJSON := fs.Bool("json", false, "Output as JSON")
+	landMask := fs.String("land-mask", "", "Suppress vessels on land: \"ndwi\" (B03/B08 next to the image) or a land polygon file")
+	landAction := fs.String("land-action", "drop", "What to do with vessels on land: drop, flag")
+	prep := fs.String("preprocess", "auto", "Radiometric preprocessing preset: auto (off for 8-bit images, sentinel1 for 1-2 band and sentinel2 for multi-band 16-bit or float GeoTIFFs), off, sentinel2, sentinel1, landsat, planet, none")
+	facilitiesPath := fs.String("facilities", defaultFacilities, "Known-facilities file or directory for tagging detections (\"\" = off)")
+	facilityKm := fs.Float64("facility-km", facilities.DefaultMaxKm, "Tag detections with the nearest facility within this distance in km")
+	equipmentPath := fs.String("equipment", defaultEquipment, "Equipment catalog file or directory for type candidates (\"\" = off)")
+	candidates := fs.Int("candidates", 3, "Number of equipment type candidates to attach per detection")
+	aisPaths := fs.String("ais", "", "Comma-separated AIS files or directories (NMEA 0183 or CSV) to correlate vessel detections with")
+	aisGap := fs.Duration("ais-gap", 10*time.Minute, "Ignore AIS reports further than this from the scene time")
+	adsbPaths := fs.String("adsb", "", "Comma-separated ADS-B files or directories (readsb/tar1090 traces, aircraft.json, CSV) to identify parked aircraft")
+	airfieldPath := fs.String("airfield", "", "Airfield polygon file (GeoJSON or WKT) for --adsb (default: airfield outlines from --facilities)")
+	airfieldElev := fs.Float64("airfield-elev", 0, "Field elevation of --airfield in feet; airframes reporting a barometric altitude near it count as landed (default: only on-ground reports)")
+	sceneTime := fs.String("scene-time", "", "Scene acquisition time (RFC 3339, e.g. 2024-01-15T03:00:59Z); recorded in --json output, required with --ais and --adsb")
+	engine := fs.String("engine", "ai", "Detection engine: ai (gRPC worker), cfar (ships in calibrated SAR backscatter, no worker needed)")
+	cfarMethod := fs.String("cfar", "ca", "CFAR clutter estimator for --engine cfar: ca (cell averaging), os (ordered statistic, for crowded waters)")
+	pfa := fs.Float64("pfa", 1e-6, "Probability of false alarm per pixel for --engine cfar")
+	landBuffer := fs.Float64("land-buffer", 100, "Grow the --land-mask polygons by this many meters for --engine cfar")
 	fs.Parse(args)
 
 	if *imagePath == "" {
@@ -141,31 +301,82 @@ func cmdDetect(args []string) {
 		fs.Usage()
 		os.Exit(1)
 	}
-
-	ctx := context.Background()
-	client, err := detector.NewClient(*aiAddr)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Cannot connect to AI worker: %v\n", err)
-		os.Exit(1)
+	var acquired time.Time
+	if *sceneTime != "" || *aisPaths != "" || *adsbPaths != "" {
+		t, err := time.Parse(time.RFC3339, *sceneTime)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: --scene-time must be RFC 3339 (e.g. 2024-01-15T03:00:59Z); --ais and --adsb require it")
+			os.Exit(1)
+		}
+		acquired = t
 	}
-	defer client.Close()
 
-	targets := strings.Split(*objects, ",")
-	if *objects == "all" {
-		targets = nil
-	}
+	var resp *pb.DetectResponse
+	switch *engine {
+	case "cfar":
+		method, err := cfar.ParseMethod(*cfarMethod)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "🔍 Detecting ships in %s (CFAR)...\n", *imagePath)
+		resp = detectCFAR(*imagePath, *landMask, *landBuffer, float32(*confidence), cfar.Options{Method: method, PFA: *pfa, GSD: *gsd})
+	case "ai":
+		ctx := context.Background()
+		client, err := detector.NewClient(*aiAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot connect to AI worker: %v\n", err)
+			os.Exit(1)
+		}
+		defer client.Close()
 
-	fmt.Printf("🔍 Detecting objects in %s...\n", *imagePath)
-	resp, err := client.DetectFromPath(ctx, *imagePath, targets, float32(*confidence), float32(*gsd), 0, 0)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Detection error: %v\n", err)
+		targets := strings.Split(*objects, ",")
+		if *objects == "all" {
+			targets = nil
+		}
+
+		fmt.Fprintf(os.Stderr, "🔍 Detecting objects in %s...\n", *imagePath)
+		sendPath, applied := preprocessImage(*imagePath, *prep)
+		if applied != nil {
+			defer os.Remove(sendPath)
+		}
+		resp, err = client.DetectFromPath(ctx, sendPath, targets, float32(*confidence), float32(*gsd), 0, 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Detection error: %v\n", err)
+			os.Exit(1)
+		}
+		if applied != nil {
+			preprocess.Annotate(resp.Detections, *applied)
+		}
+		if !georeference(resp, *imagePath) && (*aisPaths != "" || *adsbPaths != "") {
+			fmt.Fprintln(os.Stderr, "Error: --ais and --adsb need a georeferenced GeoTIFF to place detections")
+			os.Exit(1)
+		}
+		if *landMask != "" {
+			applyLandMask(resp, *landMask, *landAction, *imagePath, *gsd)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown engine %q (want ai or cfar)\n", *engine)
 		os.Exit(1)
 	}
+	fdb := loadFacilities(*facilitiesPath)
+	tagFacilities(resp, fdb, *facilityKm)
+	tagEquipment(resp, loadEquipment(*equipmentPath), equipment.MatchOptions{GSD: *gsd, TopK: *candidates})
+	correlateAIS(resp, *aisPaths, acquired, ais.MatchOptions{MaxGap: *aisGap, GSD: *gsd})
+	associateADSB(resp, *adsbPaths, acquired, airfields(fdb, *airfieldPath, flagValue(fs, "airfield-elev", *airfieldElev)))
 
 	if *outputJSON {
+		out := struct {
+			*pb.DetectResponse
+			SceneTime string   `json:"scene_time,omitempty"`
+			Footprint geo.Ring `json:"footprint,omitempty"`
+		}{DetectResponse: resp, Footprint: imageFootprint(*imagePath)}
+		if !acquired.IsZero() {
+			out.SceneTime = acquired.Format(time.RFC3339)
+		}
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
-		enc.Encode(resp)
+		enc.Encode(out)
 	} else {
 		fmt.Printf("✅ Found %d objects (%.0fms)\n\n", len(resp.Detections), resp.InferenceTimeMs)
 		for i, det := range resp.Detections {
@@ -190,24 +401,55 @@ func cmdSearch(args []string) {
 	objects := fs.String("objects", "all", "Object types to detect")
 	confidence := fs.Float64("confidence", 0.3, "Detection confidence")
 	aiAddr := fs.String("ai", "localhost:50051", "AI worker address")
+	aoiPath := fs.String("aoi", "", "AOI polygon file (GeoJSON or WKT); overrides --lat/--lon/--radius")
+	at := fs.String("at", "", "Location as DD, DMS, UTM or MGRS (e.g. 49QBB4567815678); alternative to --lat/--lon")
+	landMask := fs.String("land-mask", "", "Suppress vessels on land: \"ndwi\" (downloads B03/B08) or a land polygon file")
+	landAction := fs.String("land-action", "drop", "What to do with vessels on land: drop, flag")
+	facilitiesPath := fs.String("facilities", defaultFacilities, "Known-facilities file or directory for tagging detections (\"\" = off)")
+	facilityKm := fs.Float64("facility-km", facilities.DefaultMaxKm, "Tag detections with the nearest facility within this distance in km")
+	equipmentPath := fs.String("equipment", defaultEquipment, "Equipment catalog file or directory for type candidates (\"\" = off)")
+	candidates := fs.Int("candidates", 3, "Number of equipment type candidates to attach per detection")
+	aisPaths := fs.String("ais", "", "Comma-separated AIS files or directories (NMEA 0183 or CSV) to correlate vessel detections with")
+	aisGap := fs.Duration("ais-gap", 10*time.Minute, "Ignore AIS reports further than this from the scene time")
+	adsbPaths := fs.String("adsb", "", "Comma-separated ADS-B files or directories (readsb/tar1090 traces, aircraft.json, CSV) to identify parked aircraft")
+	airfieldPath := fs.String("airfield", "", "Airfield polygon file (GeoJSON or WKT) for --adsb (default: airfield outlines from --facilities)")
+	airfieldElev := fs.Float64("airfield-elev", 0, "Field elevation of --airfield in feet; airframes reporting a barometric altitude near it count as landed (default: only on-ground reports)")
 	fs.Parse(args)
 
-	if *lat == 0 && *lon == 0 {
-		fmt.Fprintln(os.Stderr, "Error: --lat and --lon are required")
+	center, ok := locationFlags(fs, *lat, *lon, *at)
+	if !ok && *aoiPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: --at or --lat/--lon (or --aoi) are required")
 		os.Exit(1)
 	}
 
 	ctx := context.Background()
 
+	bbox := geo.BBoxFromCenter(center, *radius)
+	var aoi geo.MultiPolygon
+	if *aoiPath != "" {
+		aoi = loadAOI(*aoiPath)
+		bbox = aoi.BBox()
+		center = bbox.Center()
+	}
+
 	// Step 1: Fetch imagery
 	fmt.Println("🛰️  Step 1: Fetching satellite imagery...")
 	s2 := collector.NewSentinel2("data/cache")
-	result, path, err := s2.FetchBest(ctx, *lat, *lon, *radius, *maxCloud)
+	result, path, err := s2.FetchBestBBox(ctx, bbox, *maxCloud)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Fetch error: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Printf("   Scene: %s (Cloud: %.1f%%, Date: %s)\n", result.ID, result.CloudCover, result.Date.Format("2006-01-02"))
+	if c := result.AOICloud; c != nil {
+		fmt.Printf("   AOI cloud: %.1f%%, AOI clear: %.1f%%\n", c.CloudFraction*100, c.ClearFraction*100)
+	}
+	if *landMask == "ndwi" {
+		if _, err := s2.Download(ctx, *result, []string{"B03", "B08"}); err != nil {
+			fmt.Fprintf(os.Stderr, "Fetch error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	// Step 2: Run detection
 	fmt.Println("🔍 Step 2: Running object detection...")
@@ -224,11 +466,37 @@ func cmdSearch(args []string) {
 		targets = nil
 	}
 
-	resp, err := client.DetectFromPath(ctx, path+"/visual.tif", targets, float32(*confidence), float32(result.GSD), *lat, *lon)
+	sendPath, applied := preprocessImage(path+"/visual.tif", "auto")
+	if applied != nil {
+		defer os.Remove(sendPath)
+	}
+	resp, err := client.DetectFromPath(ctx, sendPath, targets, float32(*confidence), float32(result.GSD), 0, 0)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Detection error: %v\n", err)
 		os.Exit(1)
 	}
+	if applied != nil {
+		preprocess.Annotate(resp.Detections, *applied)
+	}
+	if !georeference(resp, path+"/visual.tif") {
+		fmt.Fprintf(os.Stderr, "Error: %s/visual.tif is not georeferenced\n", path)
+		os.Exit(1)
+	}
+
+	if *landMask != "" {
+		applyLandMask(resp, *landMask, *landAction, path+"/visual.tif", result.GSD)
+	}
+
+	if aoi != nil {
+		if dropped := filterToAOI(resp, aoi); dropped > 0 {
+			fmt.Fprintf(os.Stderr, "   Filtered %d detections outside AOI\n", dropped)
+		}
+	}
+	fdb := loadFacilities(*facilitiesPath)
+	tagFacilities(resp, fdb, *facilityKm)
+	tagEquipment(resp, loadEquipment(*equipmentPath), equipment.MatchOptions{GSD: result.GSD, TopK: *candidates})
+	correlateAIS(resp, *aisPaths, result.Date, ais.MatchOptions{MaxGap: *aisGap, GSD: result.GSD})
+	associateADSB(resp, *adsbPaths, result.Date, airfields(fdb, *airfieldPath, flagValue(fs, "airfield-elev", *airfieldElev)))
 
 	fmt.Printf("\n✅ Results: %d objects detected\n", len(resp.Detections))
 	for i, det := range resp.Detections {
@@ -239,12 +507,33 @@ func cmdSearch(args []string) {
 func cmdReport(args []string) {
 	fs := flag.NewFlagSet("report", flag.ExitOnError)
 	inputFile := fs.String("input", "", "Path to detection results JSON (from detect --json)")
-	location := fs.String("location", "", "Location name for report header")
+	location := fs.String("location", "", "Location name for report header; a known facility's name or ID also sets coordinates and AOI")
 	lat := fs.Float64("lat", 0, "Latitude (for metadata)")
 	lon := fs.Float64("lon", 0, "Longitude (for metadata)")
 	period := fs.String("period", "", "Analysis period (e.g. 30d)")
 	geojson := fs.String("geojson", "", "Output path for GeoJSON file")
 	outFile := fs.String("out", "", "Output path for text report (default: stdout)")
+	csvOut := fs.String("csv", "", "Output path for CSV table of detections")
+	csvBOM := fs.Bool("csv-bom", false, "Prefix CSV with a UTF-8 BOM (for Excel)")
+	xlsxOut := fs.String("xlsx", "", "Output path for Excel workbook (one sheet per class)")
+	aoiPath := fs.String("aoi", "", "AOI polygon file (GeoJSON or WKT); drops detections outside it")
+	groupKm := fs.Float64("group-km", 0, "Group detections within this distance in km (0 = off)")
+	groupMin := fs.Int("group-min", 2, "Minimum detections per grouping")
+	groupMethod := fs.String("group-method", "dbscan", "Grouping method: dbscan, hierarchical")
+	stixOut := fs.String("stix", "", "Output path for STIX 2.1 bundle")
+	sceneID := fs.String("scene", "", "Scene ID the detections came from (for STIX export)")
+	sceneDate := fs.String("date", "", "Scene acquisition date YYYY-MM-DD (for STIX export)")
+	note := fs.String("note", "", "Analyst note to attach to the STIX export")
+	imagePath := fs.String("image", "", "Source scene the detections were run on (PNG/JPEG/GeoTIFF)")
+	chipsDir := fs.String("chips", "", "Output directory for annotated overview and detection chips (requires --image)")
+	gsd := fs.Float64("gsd", 0, "Ground sample distance of --image in meters, for scale bars (default: from the GeoTIFF)")
+	at := fs.String("at", "", "Location as DD, DMS, UTM or MGRS (e.g. 49QBB4567815678); for metadata")
+	coords := fs.String("coords", "dd", "Coordinate format in the text report: dd, dms, utm, mgrs")
+	facilitiesPath := fs.String("facilities", defaultFacilities, "Known-facilities file or directory for --location and tagging (\"\" = off)")
+	facilityKm := fs.Float64("facility-km", facilities.DefaultMaxKm, "Tag detections with the nearest facility within this distance in km")
+	history := fs.String("history", "", "Comma-separated earlier detection results (files or directories) to score class counts against (pattern of life)")
+	polZ := fs.Float64("pol-threshold", 3, "Flag counts at least this many robust standard deviations from the baseline")
+	polMin := fs.Int("pol-min-history", 5, "Earlier scenes needed before a count is scored against --history")
 	fs.Parse(args)
 
 	if *inputFile == "" {
@@ -253,19 +542,93 @@ func cmdReport(args []string) {
 		os.Exit(1)
 	}
 
+	center, hasCenter := locationFlags(fs, *lat, *lon, *at)
+	coordFormat, err := geo.ParseCoordFormat(*coords)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	result, err := report.LoadDetectResult(*inputFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
+	db := loadFacilities(*facilitiesPath)
+	var facility *facilities.Facility
+	if *location != "" && db != nil {
+		facility, err = db.Find(*location)
+		switch {
+		case err == nil:
+			*location = facility.Name
+			if !hasCenter {
+				center, hasCenter = facility.Point(), true
+			}
+		case errors.Is(err, facilities.ErrNotFound):
+			// Free-text location label.
+		default:
+			fmt.Fprintf(os.Stderr, "Error: --location: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var area geo.MultiPolygon
+	if *aoiPath != "" {
+		area = loadAOI(*aoiPath)
+		if dropped := report.FilterToAOI(result, area); dropped > 0 {
+			fmt.Fprintf(os.Stderr, "Filtered %d detections outside AOI\n", dropped)
+		}
+	} else if facility != nil {
+		area = facility.AOI()
+		if dropped := report.FilterToAOI(result, area); dropped > 0 {
+			fmt.Fprintf(os.Stderr, "Filtered %d detections outside %s\n", dropped, facility.Name)
+		}
+	}
+	if db != nil {
+		for i := range result.Detections {
+			d := &result.Detections[i]
+			if d.GeoCenter == nil || (d.GeoCenter.Latitude == 0 && d.GeoCenter.Longitude == 0) {
+				continue
+			}
+			if m, ok := db.Nearest(geo.Point{Lat: d.GeoCenter.Latitude, Lon: d.GeoCenter.Longitude}, *facilityKm); ok {
+				d.Attributes = m.SetAttributes(d.Attributes)
+			}
+		}
+	}
+
 	summary := report.Summarize(result)
+	if *groupKm > 0 {
+		summary.Groupings, err = report.FindGroupings(summary.Detections, report.GroupingOptions{
+			Method:    *groupMethod,
+			EpsKm:     *groupKm,
+			MinPoints: *groupMin,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *history != "" {
+		t, err := track.SceneTime(*inputFile, result)
+		if *sceneDate != "" {
+			t, err = time.Parse("2006-01-02", *sceneDate)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --history needs the scene time: %v (set --date)\n", err)
+			os.Exit(1)
+		}
+		summary.PatternOfLife = scoreCurrent(summary.ClassCounts, t, area, expandDetectInputs(strings.Split(*history, ",")),
+			pol.Options{Threshold: *polZ, MinHistory: *polMin})
+	}
 	meta := report.ReportMeta{
-		Location: *location,
-		Lat:      *lat,
-		Lon:      *lon,
-		Period:   *period,
-		Source:   result.ModelVersion,
+		Location:    *location,
+		Lat:         center.Lat,
+		Lon:         center.Lon,
+		HasCoords:   hasCenter,
+		Period:      *period,
+		Source:      result.ModelVersion,
+		CoordFormat: coordFormat,
 	}
 
 	// Write text report
@@ -293,10 +656,1539 @@ func cmdReport(args []string) {
 		}
 		fmt.Fprintf(os.Stderr, "GeoJSON saved to: %s\n", *geojson)
 	}
+
+	// Write tabular exports if requested
+	if *csvOut != "" {
+		if err := report.WriteCSVFile(summary, *csvOut, report.CSVOptions{BOM: *csvBOM}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "CSV saved to: %s\n", *csvOut)
+	}
+	if *xlsxOut != "" {
+		if err := report.WriteXLSX(summary, *xlsxOut); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing XLSX: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "XLSX saved to: %s\n", *xlsxOut)
+	}
+
+	// Write STIX bundle if requested
+	if *stixOut != "" {
+		exp := report.STIXExport{}
+		scene := report.STIXScene{ID: *sceneID, Summary: summary, Meta: meta}
+		if scene.ID == "" {
+			scene.ID = filepath.Base(*inputFile)
+		}
+		if *sceneDate != "" {
+			scene.Acquired, err = time.Parse("2006-01-02", *sceneDate)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --date: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		exp.Scenes = append(exp.Scenes, scene)
+		if facility != nil {
+			// The facility's own location, not a --lat/--lon override.
+			p := facility.Point()
+			exp.Facilities = append(exp.Facilities, report.STIXFacility{
+				ID:      facility.ID,
+				Name:    facility.Name,
+				Type:    facility.Type,
+				Country: facility.Country,
+				Lat:     p.Lat,
+				Lon:     p.Lon,
+			})
+		} else if *location != "" && hasCenter {
+			exp.Facilities = append(exp.Facilities, report.STIXFacility{
+				ID:   *location,
+				Name: *location,
+				Lat:  center.Lat,
+				Lon:  center.Lon,
+			})
+		}
+		if *note != "" {
+			exp.Notes = append(exp.Notes, report.AnalystNote{Content: *note})
+		}
+
+		bundle, err := report.BuildSTIXBundle(exp)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error building STIX bundle: %v\n", err)
+			os.Exit(1)
+		}
+		if err := report.WriteSTIX(bundle, *stixOut); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing STIX bundle: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "STIX bundle saved to: %s\n", *stixOut)
+	}
+
+	// Render annotated imagery if requested
+	if *chipsDir != "" {
+		if *imagePath == "" {
+			fmt.Fprintln(os.Stderr, "Error: --chips requires --image")
+			os.Exit(1)
+		}
+		scene, err := annotate.OpenScene(*imagePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer scene.Close()
+
+		opts := annotate.DefaultOptions()
+		opts.GSD = *gsd
+		if opts.GSD <= 0 && scene.GSD <= 0 {
+			fmt.Fprintln(os.Stderr, "Warning: no GSD for --image; pass --gsd to draw scale bars")
+		}
+		rendered, err := annotate.Render(scene, summary.Detections, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering imagery: %v\n", err)
+			os.Exit(1)
+		}
+		if n := len(rendered.Skipped); n > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d detections lie outside %s and have no chip: %v\n", n, *imagePath, rendered.Skipped)
+		}
+		paths, err := rendered.WriteDir(*chipsDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing imagery: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Imagery saved to: %s (%d files)\n", *chipsDir, len(paths))
+	}
 }
 
-func cmdMonitor(args []string) {
-	fmt.Println("monitor: coming in Phase 2")
+// locationFlags resolves --at, or else --lat/--lon, into a point, exiting
+// on error. The second result reports whether a location was given at all,
+// so that 0,0 is a usable coordinate.
+func locationFlags(fs *flag.FlagSet, lat, lon float64, at string) (geo.Point, bool) {
+	if at != "" {
+		p, err := geo.ParseCoordinate(at)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --at: %v\n", err)
+			os.Exit(1)
+		}
+		return p, true
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["lat"] != set["lon"] {
+		fmt.Fprintln(os.Stderr, "Error: --lat and --lon must be given together")
+		os.Exit(1)
+	}
+	return geo.Point{Lat: lat, Lon: lon}, set["lat"]
+}
+
+// flagValue returns v if the flag name was given on the command line, or
+// else nil.
+func flagValue(fs *flag.FlagSet, name string, v float64) *float64 {
+	set := false
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	if !set {
+		return nil
+	}
+	return &v
+}
+
+// preprocessImage converts imagePath to the 8-bit image sent to the AI
+// worker using the named preset (overridable in the config file) and
+// returns the path of the temporary PNG and what was applied. "off", and
+// "auto" for input that needs no stretch, return the original path and
+// nil.
+func preprocessImage(imagePath, preset string) (string, *preprocess.Applied) {
+	if preset == "auto" {
+		preset = autoPreset(imagePath)
+	}
+	if preset == "off" {
+		return imagePath, nil
+	}
+	params, err := preprocess.ForProvider(preset, config.Load().Preprocess)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	img, applied, err := preprocess.ProcessFile(imagePath, params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: preprocess %s: %v\n", imagePath, err)
+		os.Exit(1)
+	}
+	applied.Provider = preset
+	out, err := preprocess.WriteTemp(img)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "   Preprocessed: %s\n", applied)
+	return out, &applied
+}
+
+// autoPreset picks the preprocessing preset for an image from its data:
+// "off" for 8-bit images such as PNGs, JPEGs and Sentinel-2 true-colour
+// (TCI) GeoTIFFs, which are display-ready; "sentinel1" for deeper one- or
+// two-band rasters, read as SAR backscatter (VV, VH); and "sentinel2" for
+// deeper multi-band rasters, read as optical reflectance.
+func autoPreset(imagePath string) string {
+	tif, err := raster.Open(imagePath)
+	if err != nil {
+		return "off"
+	}
+	defer tif.Close()
+	switch {
+	case tif.BitsPerSample <= 8:
+		return "off"
+	case tif.SamplesPerPx <= 2:
+		return "sentinel1"
+	default:
+		return "sentinel2"
+	}
+}
+
+// georeference sets the geo center of every detection from its bounding
+// box and the transform of imagePath, the image the detections were made
+// on at full size. The worker can only offset pixels from a point it is
+// given, which is not the corner of a whole scene. It reports false,
+// leaving the detections as they are, if the image is not a georeferenced
+// GeoTIFF.
+func georeference(resp *pb.DetectResponse, imagePath string) bool {
+	g, epsg, ok := imageTransform(imagePath)
+	if !ok {
+		return false
+	}
+	for _, d := range resp.Detections {
+		if d.Bbox == nil {
+			continue
+		}
+		p, err := bboxCenter(g, epsg, d.Bbox)
+		if err != nil {
+			return false
+		}
+		d.GeoCenter = p
+	}
+	return true
+}
+
+// georeferenceRegions sets the geo center of change regions that lack one,
+// as the worker's do, from their bounding boxes and the transform of
+// beforePath, on whose grid the worker reports them. It reports false if
+// the image is not a georeferenced GeoTIFF.
+func georeferenceRegions(resp *pb.ChangeResponse, beforePath string) bool {
+	g, epsg, ok := imageTransform(beforePath)
+	if !ok {
+		return false
+	}
+	for _, r := range resp.Regions {
+		if r.GeoCenter != nil || r.Bbox == nil {
+			continue
+		}
+		p, err := bboxCenter(g, epsg, r.Bbox)
+		if err != nil {
+			return false
+		}
+		r.GeoCenter = p
+	}
+	return true
+}
+
+// imageTransform returns the transform and CRS of a georeferenced GeoTIFF.
+func imageTransform(path string) (raster.GeoTransform, int, bool) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".tif" && ext != ".tiff" {
+		return raster.GeoTransform{}, 0, false
+	}
+	tif, err := raster.Open(path)
+	if err != nil {
+		return raster.GeoTransform{}, 0, false
+	}
+	defer tif.Close()
+	return tif.Geo, tif.EPSG, tif.Geo.Valid()
+}
+
+// imageFootprint returns the outline of a georeferenced GeoTIFF, with a
+// few points along each edge so that it follows the image's projection,
+// or nil for other images.
+func imageFootprint(path string) geo.Ring {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".tif" && ext != ".tiff" {
+		return nil
+	}
+	tif, err := raster.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer tif.Close()
+	if !tif.Geo.Valid() {
+		return nil
+	}
+	const steps = 4
+	w, h := float64(tif.Width), float64(tif.Height)
+	var ring geo.Ring
+	for _, edge := range [4][4]float64{{0, 0, w, 0}, {w, 0, w, h}, {w, h, 0, h}, {0, h, 0, 0}} {
+		for i := 0; i < steps; i++ {
+			f := float64(i) / steps
+			p, err := raster.PixelToPoint(tif.Geo, tif.EPSG, edge[0]+f*(edge[2]-edge[0]), edge[1]+f*(edge[3]-edge[1]))
+			if err != nil {
+				return nil
+			}
+			ring = append(ring, p)
+		}
+	}
+	return ring
+}
+
+func bboxCenter(g raster.GeoTransform, epsg int, b *pb.BoundingBox) (*pb.GeoPoint, error) {
+	p, err := raster.PixelToPoint(g, epsg, float64(b.XMin+b.XMax)/2, float64(b.YMin+b.YMax)/2)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GeoPoint{Latitude: p.Lat, Longitude: p.Lon}, nil
+}
+
+// defaultFacilities is where the known-facilities database lives; it is
+// skipped silently when absent.
+const defaultFacilities = "data/known_facilities"
+
+// loadFacilities loads the facilities database at path, or returns nil
+// if path is empty or is the absent default.
+func loadFacilities(path string) *facilities.DB {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil && path == defaultFacilities {
+		return nil
+	}
+	db, err := facilities.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: facilities: %v\n", err)
+		os.Exit(1)
+	}
+	if db.Len() == 0 {
+		return nil
+	}
+	return db
+}
+
+// tagFacilities annotates detections with their nearest known facility.
+func tagFacilities(resp *pb.DetectResponse, db *facilities.DB, maxKm float64) {
+	if db == nil {
+		return
+	}
+	if n := db.Annotate(resp.Detections, maxKm); n > 0 {
+		fmt.Fprintf(os.Stderr, "   Tagged %d detections with a facility within %.0f km\n", n, maxKm)
+	}
+}
+
+// defaultEquipment is where the equipment catalog lives; it is skipped
+// silently when absent.
+const defaultEquipment = "data/equipment"
+
+// loadEquipment loads the equipment catalog at path, or returns nil if
+// path is empty, the absent default, or holds no entries.
+func loadEquipment(path string) *equipment.Catalog {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil && path == defaultEquipment {
+		return nil
+	}
+	cat, err := equipment.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: equipment: %v\n", err)
+		os.Exit(1)
+	}
+	if cat.Len() == 0 {
+		return nil
+	}
+	return cat
+}
+
+// tagEquipment attaches equipment type candidates to detections.
+func tagEquipment(resp *pb.DetectResponse, cat *equipment.Catalog, opts equipment.MatchOptions) {
+	if cat == nil {
+		return
+	}
+	if n := cat.Annotate(resp.Detections, opts); n > 0 {
+		fmt.Fprintf(os.Stderr, "   Attached equipment candidates to %d detections\n", n)
+	}
+}
+
+// correlateAIS labels vessel detections as matched to an AIS track at the
+// scene time, or unmatched. paths is a comma-separated list; "" is off.
+func correlateAIS(resp *pb.DetectResponse, paths string, sceneTime time.Time, opts ais.MatchOptions) {
+	if paths == "" {
+		return
+	}
+	store, stats, err := ais.Load(strings.Split(paths, ",")...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: AIS: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "   AIS: %d reports from %d vessels", stats.Reports, store.Len())
+	if stats.Untimed > 0 || stats.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, " (%d untimed, %d skipped)", stats.Untimed, stats.Skipped)
+	}
+	fmt.Fprintln(os.Stderr)
+	ms := store.Correlate(resp.Detections, sceneTime, opts)
+	fmt.Fprintf(os.Stderr, "   AIS: %d tracks at %s; %d vessel detections matched, %d unmatched\n",
+		ms.Tracks, sceneTime.Format(time.RFC3339), ms.Matched, ms.Unmatched)
+	if ms.Unplaced > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: AIS: %d vessel detections have no geographic position and were not correlated\n", ms.Unplaced)
+	}
+}
+
+// airfields returns the polygon in path as a single airfield at elevFt,
+// or else the outlines of air facilities in db.
+func airfields(db *facilities.DB, path string, elevFt *float64) []adsb.Airfield {
+	if path != "" {
+		return []adsb.Airfield{{ID: filepath.Base(path), Area: loadAOI(path), ElevationFt: elevFt}}
+	}
+	if db == nil {
+		return nil
+	}
+	var out []adsb.Airfield
+	for _, f := range db.Facilities {
+		if len(f.Area) == 0 {
+			continue
+		}
+		for _, t := range append([]string{f.Type}, f.Subtypes...) {
+			if strings.Contains(t, "air") || strings.Contains(t, "heli") {
+				out = append(out, adsb.Airfield{ID: f.ID, Area: f.Area})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// associateADSB identifies aircraft detections on airfields from ADS-B
+// history. paths is a comma-separated list; "" is off.
+func associateADSB(resp *pb.DetectResponse, paths string, sceneTime time.Time, fields []adsb.Airfield) {
+	if paths == "" {
+		return
+	}
+	if len(fields) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: --adsb needs --airfield or facilities with airfield outlines")
+		os.Exit(1)
+	}
+	store, stats, err := adsb.Load(strings.Split(paths, ",")...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: ADS-B: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "   ADS-B: %d positions from %d airframes\n", stats.Fixes, store.Len())
+	ms := store.Associate(resp.Detections, sceneTime, fields, adsb.MatchOptions{})
+	fmt.Fprintf(os.Stderr, "   ADS-B: %d airframes last seen on %d airfields; %d of %d aircraft detections identified\n",
+		ms.Sightings, len(fields), ms.Matched, ms.Aircraft)
+	if ms.Unplaced > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: ADS-B: %d aircraft detections have no geographic position and were not associated\n", ms.Unplaced)
+	}
+}
+
+// detectCFAR runs the CFAR ship detector on a calibrated SAR GeoTIFF. A
+// land polygon file given as landSpec is rasterized onto the scene and
+// excluded from detection and clutter estimates.
+func detectCFAR(imagePath, landSpec string, bufferM float64, minConf float32, opts cfar.Options) *pb.DetectResponse {
+	start := time.Now()
+	tif, err := raster.Open(imagePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	r, err := tif.ReadAll()
+	tif.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch landSpec {
+	case "":
+	case "ndwi":
+		fmt.Fprintln(os.Stderr, "Error: --land-mask ndwi needs optical bands; use a land polygon file with --engine cfar")
+		os.Exit(1)
+	default:
+		coast, err := landmask.LoadCoastline(landSpec, 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: land mask: %v\n", err)
+			os.Exit(1)
+		}
+		px := r.GSD()
+		if px <= 0 {
+			px = opts.GSD
+		}
+		opts.Land, err = coast.Rasterize(r.Geo, r.EPSG, r.Width, r.Height, int(math.Ceil(bufferM/px)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: land mask: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	dets, st, err := cfar.Detect(r, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Detection error: %v\n", err)
+		os.Exit(1)
+	}
+	if st.Decibels {
+		fmt.Fprintln(os.Stderr, "   Input is in dB; converted to linear power")
+	}
+	if st.LandPixels > 0 {
+		fmt.Fprintf(os.Stderr, "   Masked %.1f%% of the scene as land\n", 100*float64(st.LandPixels)/float64(r.Width*r.Height))
+	}
+
+	resp := &pb.DetectResponse{ModelVersion: "cfar-" + string(opts.Method)}
+	for _, d := range dets {
+		if d.Confidence >= minConf {
+			resp.Detections = append(resp.Detections, protoDetection(d))
+		}
+	}
+	resp.InferenceTimeMs = float32(time.Since(start).Seconds() * 1000)
+	return resp
+}
+
+// protoDetection converts a report detection to the worker's message type
+// so that Go engines feed the same pipeline.
+func protoDetection(d report.Detection) *pb.Detection {
+	out := &pb.Detection{
+		ClassName:        d.ClassName,
+		Confidence:       d.Confidence,
+		Bbox:             &pb.BoundingBox{XMin: d.Bbox.XMin, YMin: d.Bbox.YMin, XMax: d.Bbox.XMax, YMax: d.Bbox.YMax},
+		EstimatedLengthM: d.EstimatedLengthM,
+		EstimatedWidthM:  d.EstimatedWidthM,
+		Attributes:       d.Attributes,
+	}
+	if d.GeoCenter != nil {
+		out.GeoCenter = &pb.GeoPoint{Latitude: d.GeoCenter.Latitude, Longitude: d.GeoCenter.Longitude}
+	}
+	return out
+}
+
+// reportDetection is the inverse of protoDetection.
+func reportDetection(d *pb.Detection) report.Detection {
+	out := report.Detection{
+		ClassName:        d.ClassName,
+		Confidence:       d.Confidence,
+		EstimatedLengthM: d.EstimatedLengthM,
+		EstimatedWidthM:  d.EstimatedWidthM,
+		Attributes:       d.Attributes,
+	}
+	if d.Bbox != nil {
+		out.Bbox = report.BBox{XMin: d.Bbox.XMin, YMin: d.Bbox.YMin, XMax: d.Bbox.XMax, YMax: d.Bbox.YMax}
+	}
+	if d.GeoCenter != nil {
+		out.GeoCenter = &report.GeoPoint{Latitude: d.GeoCenter.Latitude, Longitude: d.GeoCenter.Longitude}
+	}
+	return out
+}
+
+// filterToAOI drops the worker's detections outside aoi with
+// report.FilterToAOI, so that search keeps what report would, and returns
+// the number removed.
+func filterToAOI(resp *pb.DetectResponse, aoi geo.MultiPolygon) int {
+	result := &report.DetectResult{Detections: make([]report.Detection, len(resp.Detections))}
+	for i, d := range resp.Detections {
+		result.Detections[i] = reportDetection(d)
+	}
+	dropped := report.FilterToAOI(result, aoi)
+	resp.Detections = resp.Detections[:0]
+	for _, d := range result.Detections {
+		resp.Detections = append(resp.Detections, protoDetection(d))
+	}
+	return dropped
+}
+
+// applyLandMask drops or flags vessel detections on land, exiting on error.
+// spec is "ndwi", which reads B03.tif and B08.tif from the image's
+// directory, or the path of a land polygon file.
+func applyLandMask(resp *pb.DetectResponse, spec, action, imagePath string, gsd float64) {
+	act, err := landmask.ParseAction(action)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var mask landmask.Mask
+	if spec == "ndwi" {
+		scene, err := annotate.OpenScene(imagePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		scene.Close()
+		dir := filepath.Dir(imagePath)
+		ndwi, err := landmask.OpenNDWI(filepath.Join(dir, "B03.tif"), filepath.Join(dir, "B08.tif"), scene.Width, scene.Height)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: land mask: %v\n", err)
+			os.Exit(1)
+		}
+		defer ndwi.Close()
+		mask = ndwi
+	} else {
+		coast, err := landmask.LoadCoastline(spec, gsd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: land mask: %v\n", err)
+			os.Exit(1)
+		}
+		mask = coast
+	}
+
+	var st landmask.Stats
+	resp.Detections, st = landmask.Apply(resp.Detections, mask, landmask.Options{Action: act})
+	if st.OnLand > 0 {
+		verb := "Dropped"
+		if act == landmask.ActionFlag {
+			verb = "Flagged"
+		}
+		fmt.Fprintf(os.Stderr, "   %s %d of %d vessel detections on land\n", verb, st.OnLand, st.Checked)
+	}
+	if st.Unevaluated > 0 {
+		fmt.Fprintf(os.Stderr, "   Land mask could not judge %d vessel detections\n", st.Unevaluated)
+	}
+}
+
+// loadAOI reads an AOI polygon file, exiting on error.
+func loadAOI(path string) geo.MultiPolygon {
+	aoi, err := geo.LoadAOI(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return aoi
+}
+
+func cmdMeasure(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: orbital-eye measure <distance|area|height> [flags]")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "distance":
+		fs := flag.NewFlagSet("measure distance", flag.ExitOnError)
+		from := fs.String("from", "", "Start point (DD, DMS, UTM or MGRS)")
+		to := fs.String("to", "", "End point (DD, DMS, UTM or MGRS)")
+		fs.Parse(args[1:])
+
+		if *from == "" || *to == "" {
+			fmt.Fprintln(os.Stderr, "Error: --from and --to are required")
+			fs.Usage()
+			os.Exit(1)
+		}
+		a, err := geo.ParseCoordinate(*from)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --from: %v\n", err)
+			os.Exit(1)
+		}
+		b, err := geo.ParseCoordinate(*to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --to: %v\n", err)
+			os.Exit(1)
+		}
+		g := geo.Inverse(a, b)
+		fmt.Printf("📏 Distance:        %.3f m (%.3f km)\n", g.DistanceM, g.DistanceM/1000)
+		fmt.Printf("   Initial bearing: %.4f°\n", g.InitialBearing)
+		fmt.Printf("   Final bearing:   %.4f°\n", g.FinalBearing)
+		if g.Approximate {
+			fmt.Println("   (nearly antipodal points: spherical approximation)")
+		}
+	case "area":
+		fs := flag.NewFlagSet("measure area", flag.ExitOnError)
+		aoiPath := fs.String("aoi", "", "AOI polygon file (GeoJSON or WKT)")
+		fs.Parse(args[1:])
+
+		if *aoiPath == "" {
+			fmt.Fprintln(os.Stderr, "Error: --aoi is required")
+			fs.Usage()
+			os.Exit(1)
+		}
+		aoi := loadAOI(*aoiPath)
+		area := aoi.GeodesicAreaM2()
+		fmt.Printf("📐 Area:      %.0f m² (%.4f km²)\n", area, area/1e6)
+		fmt.Printf("   Perimeter: %.1f m\n", aoi.GeodesicPerimeterM())
+	case "height":
+		measureHeight(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown measure command: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// measureHeight estimates a structure height from its shadow, using the
+// sun angles of a scene or given explicitly.
+func measureHeight(args []string) {
+	fs := flag.NewFlagSet("measure height", flag.ExitOnError)
+	from := fs.String("from", "", "Object base (or imaged top with --from-top), DD, DMS, UTM or MGRS")
+	to := fs.String("to", "", "Shadow tip, DD, DMS, UTM or MGRS")
+	length := fs.Float64("length", 0, "Shadow length in meters, e.g. from a detection; alternative to --from/--to")
+	sceneID := fs.String("scene", "", "Sentinel-2 scene ID to take sun and view angles from")
+	sunAz := fs.Float64("sun-az", 0, "Sun azimuth in degrees (overrides --scene)")
+	sunElev := fs.Float64("sun-elev", 0, "Sun elevation in degrees (overrides --scene)")
+	viewAz := fs.Float64("view-az", 0, "View azimuth in degrees: direction tall objects lean")
+	viewZenith := fs.Float64("view-zenith", 0, "View incidence angle in degrees")
+	gsd := fs.Float64("gsd", 10, "Ground sample distance in meters (endpoint precision)")
+	fromTop := fs.Bool("from-top", false, "--from marks the imaged top of the object, not its base")
+	fs.Parse(args)
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if *length <= 0 && (*from == "" || *to == "") {
+		fmt.Fprintln(os.Stderr, "Error: --from and --to, or --length, are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var sv geo.SunView
+	if *sceneID != "" {
+		scene, err := collector.NewSentinel2("data/cache").Lookup(context.Background(), *sceneID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if scene.SunView == nil {
+			fmt.Fprintf(os.Stderr, "Error: scene %s has no sun angles\n", *sceneID)
+			os.Exit(1)
+		}
+		sv = *scene.SunView
+	} else if !set["sun-az"] || !set["sun-elev"] {
+		fmt.Fprintln(os.Stderr, "Error: --scene or --sun-az/--sun-elev are required")
+		os.Exit(1)
+	}
+	if set["sun-az"] {
+		sv.SunAzimuth = *sunAz
+	}
+	if set["sun-elev"] {
+		sv.SunElevation = *sunElev
+	}
+	if set["view-az"] || set["view-zenith"] {
+		sv.ViewAzimuth, sv.ViewZenith, sv.HasView = *viewAz, *viewZenith, true
+	}
+
+	opts := geo.ShadowOptions{GSD: *gsd, FromTop: *fromTop}
+	var est geo.HeightEstimate
+	var err error
+	if *length > 0 {
+		est, err = geo.ShadowLengthHeight(*length, sv, opts)
+	} else {
+		var a, b geo.Point
+		if a, err = geo.ParseCoordinate(*from); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --from: %v\n", err)
+			os.Exit(1)
+		}
+		if b, err = geo.ParseCoordinate(*to); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --to: %v\n", err)
+			os.Exit(1)
+		}
+		est, err = geo.ShadowHeight(a, b, sv, opts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("🏗️  Height:  %.1f m ± %.1f m (1σ)\n", est.HeightM, est.SigmaM)
+	fmt.Printf("   Sun:     azimuth %.2f°, elevation %.2f°\n", sv.SunAzimuth, sv.SunElevation)
+	if sv.HasView {
+		fmt.Printf("   View:    azimuth %.2f°, incidence %.2f°\n", sv.ViewAzimuth, sv.ViewZenith)
+	}
+	fmt.Printf("   Segment: %.1f m toward %.1f° (expected %.1f°, misfit %.1f°)\n", est.SegmentM, est.SegmentAzimuth, est.ExpectedAzimuth, est.MisfitDeg)
+	if est.MisfitDeg > 15 {
+		fmt.Println("   ⚠️  segment does not follow the shadow direction; check the endpoints")
+	}
+}
+
+func cmdIndex(args []string) {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	sceneDir := fs.String("scene", "", "Scene directory containing band files (B03.tif, B04.tif, ...)")
+	names := fs.String("index", "ndvi", "Comma-separated indices: ndvi, ndwi, nbr, ndbi")
+	res := fs.Float64("res", 0, "Output resolution in CRS units (0 = finest input band)")
+	offset := fs.Float64("offset", -1000, "Offset added to band values (Sentinel-2 L2A baseline 04.00+: -1000)")
+	outDir := fs.String("out", "", "Output directory (default: the scene directory)")
+	fs.Parse(args)
+
+	if *sceneDir == "" {
+		fmt.Fprintln(os.Stderr, "Error: --scene is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *outDir == "" {
+		*outDir = *sceneDir
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, name := range strings.Split(*names, ",") {
+		idx, err := raster.LookupIndex(strings.TrimSpace(name))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("🧮 %s = (%s - %s) / (%s + %s)\n", idx.Name, idx.A, idx.B, idx.A, idx.B)
+		out, err := raster.ComputeIndex(*sceneDir, idx, raster.BandOptions{Resolution: *res, Offset: *offset})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		path := filepath.Join(*outDir, strings.ToLower(idx.Name)+".tif")
+		if err := raster.WriteGeoTIFFFile(path, out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		fmt.Printf("   %dx%d @ %gm → %s\n", out.Width, out.Height, out.Geo.PixelWidth, path)
+	}
+}
+
+func cmdPOL(args []string) {
+	fs := flag.NewFlagSet("pol", flag.ExitOnError)
+	aoiPaths := fs.String("aoi", "", "Comma-separated AOI polygon files (GeoJSON or WKT), each its own series (default: all detections)")
+	byFacility := fs.Bool("by-facility", false, "One series per facility_id attribute instead of per AOI")
+	facilitiesPath := fs.String("facilities", defaultFacilities, "Known-facilities file or directory for --by-facility, to tell which facilities each scene covered")
+	threshold := fs.Float64("threshold", 3, "Flag counts at least this many robust standard deviations from the baseline")
+	minHistory := fs.Int("min-history", 5, "Earlier scenes needed before a count is scored")
+	window := fs.Duration("window", 0, "Only use history this recent, e.g. 8760h (0 = all)")
+	all := fs.Bool("all", false, "List every scored count, not only anomalies")
+	jsonOut := fs.String("json", "", "Output path for baselines and scores as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: orbital-eye pol [flags] <detections.json|dir>...")
+		fmt.Fprintln(os.Stderr, "Scene times come from each file's scene_time (detect --scene-time) or a date in its name.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	files := expandDetectInputs(fs.Args())
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no detection results given")
+		fs.Usage()
+		os.Exit(1)
+	}
+	var aois []namedAOI
+	if *aoiPaths != "" {
+		for _, p := range strings.Split(*aoiPaths, ",") {
+			aois = append(aois, namedAOI{Name: strings.TrimSuffix(filepath.Base(p), filepath.Ext(p)), Area: loadAOI(p)})
+		}
+	}
+	var fdb *facilities.DB
+	if *byFacility {
+		if fdb = loadFacilities(*facilitiesPath); fdb == nil {
+			fmt.Fprintln(os.Stderr, "Error: --by-facility needs --facilities to tell which facilities each scene covered")
+			os.Exit(1)
+		}
+	}
+	samples := pol.Fill(polSamples(files, aois, *byFacility, fdb))
+	opts := pol.Options{Threshold: *threshold, MinHistory: *minHistory, Window: *window}
+	baselines := pol.Baselines(samples, opts)
+	scores := pol.Analyze(samples, opts)
+
+	fmt.Printf("📈 Pattern of life: %d scenes, %d series\n\n", len(files), len(baselines))
+	fmt.Printf("  %-24s %-16s %5s %7s %7s %7s  %s\n", "AOI", "Class", "N", "Mean", "SD", "Median", "Weekday means (Sun..Sat)")
+	for _, b := range baselines {
+		var wd []string
+		for _, w := range b.Weekday {
+			if w.N == 0 {
+				wd = append(wd, "-")
+			} else {
+				wd = append(wd, fmt.Sprintf("%.1f", w.Mean))
+			}
+		}
+		fmt.Printf("  %-24s %-16s %5d %7.1f %7.1f %7.1f  %s\n", b.AOI, b.Class, b.Overall.N, b.Overall.Mean, b.Overall.StdDev, b.Overall.Median, strings.Join(wd, " "))
+	}
+
+	anomalies := 0
+	fmt.Println()
+	for _, sc := range scores {
+		if sc.Anomaly {
+			anomalies++
+		} else if !*all {
+			continue
+		}
+		mark := ""
+		if sc.Anomaly {
+			mark = "  ⚠️  " + sc.Direction()
+		}
+		fmt.Printf("  %s  %-24s %-16s %4d  expected %6.1f (%s)  z=%+.1f%s\n",
+			sc.Time.Format("2006-01-02"), sc.AOI, sc.Class, sc.Count, sc.Expected, sc.Basis, sc.Z, mark)
+	}
+	fmt.Printf("\n%d of %d scored counts anomalous (|z| ≥ %.1f)\n", anomalies, len(scores), *threshold)
+
+	if *jsonOut != "" {
+		data, _ := json.MarshalIndent(struct {
+			Baselines []*pol.Baseline `json:"baselines"`
+			Scores    []pol.Score     `json:"scores"`
+		}{baselines, scores}, "", "  ")
+		if err := os.WriteFile(*jsonOut, append(data, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *jsonOut, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Pattern of life saved to: %s\n", *jsonOut)
+	}
+}
+
+// namedAOI is an area a pattern-of-life series is counted in.
+type namedAOI struct {
+	Name string
+	Area geo.MultiPolygon
+}
+
+// expandDetectInputs returns the files named by paths, listing *.json in
+// directories.
+func expandDetectInputs(paths []string) []string {
+	var files []string
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if st, err := os.Stat(p); err == nil && st.IsDir() {
+			matches, _ := filepath.Glob(filepath.Join(p, "*.json"))
+			sort.Strings(matches)
+			files = append(files, matches...)
+		} else if p != "" {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// polSamples counts detections per class in each result, per AOI (or in
+// one series "all" without AOIs), or per facility_id attribute. A result
+// also records which series its image covered, so that they get zero
+// counts for it: every AOI unless its footprint shows otherwise, and with
+// byFacility the facilities of db whose AOI lies within the footprint.
+func polSamples(files []string, aois []namedAOI, byFacility bool, db *facilities.DB) []pol.Sample {
+	var samples []pol.Sample
+	unknown := 0
+	for _, path := range files {
+		result, err := report.LoadDetectResult(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		t, err := track.SceneTime(path, result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		counts := make(map[pol.Key]int)
+		for _, d := range result.Detections {
+			switch {
+			case byFacility:
+				if id := d.Attributes[facilities.AttrFacilityID]; id != "" {
+					counts[pol.Key{AOI: id, Class: d.ClassName}]++
+				}
+			case len(aois) == 0:
+				counts[pol.Key{AOI: "all", Class: d.ClassName}]++
+			default:
+				if d.GeoCenter == nil {
+					continue
+				}
+				p := geo.Point{Lat: d.GeoCenter.Latitude, Lon: d.GeoCenter.Longitude}
+				for _, a := range aois {
+					if a.Area.Contains(p) {
+						counts[pol.Key{AOI: a.Name, Class: d.ClassName}]++
+					}
+				}
+			}
+		}
+		// A class-less sample records that the scene covered the series,
+		// so Fill gives it zero counts.
+		var covered []string
+		switch {
+		case byFacility && result.Footprint == nil:
+			unknown++
+		case byFacility:
+			if db != nil {
+				for _, f := range db.Facilities {
+					if footprintCovers(result.Footprint, f.AOI()) {
+						covered = append(covered, f.ID)
+					}
+				}
+			}
+		case len(aois) == 0:
+			covered = []string{"all"}
+		default:
+			for _, a := range aois {
+				if result.Footprint == nil || footprintCovers(result.Footprint, a.Area) {
+					covered = append(covered, a.Name)
+				}
+			}
+		}
+		for _, n := range covered {
+			samples = append(samples, pol.Sample{AOI: n, Time: t})
+		}
+		for k, n := range counts {
+			samples = append(samples, pol.Sample{AOI: k.AOI, Class: k.Class, Time: t, Count: n})
+		}
+	}
+	if unknown > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d results have no footprint (detect --json on a GeoTIFF records it); facilities without detections in them are not counted as zero\n", unknown)
+	}
+	return samples
+}
+
+// footprintCovers reports whether every vertex of area's outer rings lies
+// within the footprint.
+func footprintCovers(footprint geo.Ring, area geo.MultiPolygon) bool {
+	for _, p := range area {
+		if len(p) == 0 {
+			continue
+		}
+		for _, v := range p[0] {
+			if !footprint.Contains(v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// scoreCurrent scores class counts seen at t against baselines built from
+// the history files, all restricted to area if set. As in pol.Analyze,
+// classes with fewer than opts.MinHistory earlier scenes are not scored.
+func scoreCurrent(counts map[string]int, t time.Time, area geo.MultiPolygon, history []string, opts pol.Options) []pol.Score {
+	if opts.MinHistory <= 0 {
+		opts.MinHistory = 5
+	}
+	var aois []namedAOI
+	if area != nil {
+		aois = []namedAOI{{Name: "report", Area: area}}
+	}
+	var samples []pol.Sample
+	for _, s := range polSamples(history, aois, false, nil) {
+		// The history may include the scene itself or later ones.
+		if s.Time.Before(t) {
+			samples = append(samples, s)
+		}
+	}
+	name := "all"
+	if area != nil {
+		name = "report"
+	}
+	samples = append(samples, pol.Sample{AOI: name, Time: t})
+	for class, n := range counts {
+		samples = append(samples, pol.Sample{AOI: name, Class: class, Time: t, Count: n})
+	}
+	samples = pol.Fill(samples)
+
+	var past, now []pol.Sample
+	for _, s := range samples {
+		if s.Time.Before(t) {
+			past = append(past, s)
+		} else {
+			now = append(now, s)
+		}
+	}
+	byKey := make(map[pol.Key]*pol.Baseline)
+	for _, b := range pol.Baselines(past, opts) {
+		byKey[b.Key] = b
+	}
+	var scores []pol.Score
+	short := 0
+	for _, s := range now {
+		b := byKey[pol.Key{AOI: s.AOI, Class: s.Class}]
+		switch {
+		case b == nil:
+		case b.Overall.N < opts.MinHistory:
+			short++
+		default:
+			scores = append(scores, b.Score(s.Time, s.Count, opts.Threshold))
+		}
+	}
+	if short > 0 {
+		fmt.Fprintf(os.Stderr, "Pattern of life: %d classes have fewer than %d earlier scenes; not scored\n", short, opts.MinHistory)
+	}
+	return scores
+}
+
+func cmdTrack(args []string) {
+	fs := flag.NewFlagSet("track", flag.ExitOnError)
+	maxDist := fs.Float64("max-distance", 150, "Largest distance in meters between sightings of one object")
+	maxSize := fs.Float64("max-size-diff", 0.35, "Largest relative length difference between sightings of one object")
+	maxMissed := fs.Int("max-missed", 1, "Scenes an object may be missing from and still keep its track (0 = none)")
+	aoiPath := fs.String("aoi", "", "AOI polygon file (GeoJSON or WKT); drops detections outside it")
+	jsonOut := fs.String("json", "", "Output path for tracks and events as JSON")
+	outFile := fs.String("out", "", "Output path for text report (default: stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: orbital-eye track [flags] <detections.json>...")
+		fmt.Fprintln(os.Stderr, "Scene times come from each file's scene_time (detect --scene-time) or a date in its name.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "Error: at least two detection results are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	var aoi geo.MultiPolygon
+	if *aoiPath != "" {
+		aoi = loadAOI(*aoiPath)
+	}
+	var scenes []track.Scene
+	for _, path := range fs.Args() {
+		result, err := report.LoadDetectResult(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		t, err := track.SceneTime(path, result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if aoi != nil {
+			report.FilterToAOI(result, aoi)
+		}
+		scenes = append(scenes, track.Scene{Name: filepath.Base(path), Time: t, Result: result})
+	}
+
+	missed := *maxMissed
+	if missed == 0 {
+		missed = -1 // Options treats 0 as the default
+	}
+	res := track.Run(scenes, track.Options{MaxDistanceM: *maxDist, MaxSizeDiff: *maxSize, MaxMissed: missed})
+
+	w := os.Stdout
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	track.PrintText(res, w)
+	if *jsonOut != "" {
+		if err := track.WriteJSON(res, *jsonOut); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *jsonOut, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Tracks saved to: %s\n", *jsonOut)
+	}
+}
+
+func cmdImportOSM(args []string) {
+	fs := flag.NewFlagSet("import-osm", flag.ExitOnError)
+	in := fs.String("in", "", "OSM extract (.osm XML or .osm.pbf)")
+	out := fs.String("out", "", "Output facilities file (JSON), e.g. data/known_facilities/osm.json")
+	aoiPath := fs.String("aoi", "", "Only import features intersecting this AOI (GeoJSON or WKT)")
+	aeroways := fs.String("aeroway", strings.Join(osm.DefaultAeroways, ","), "Comma-separated aeroway values to import (* for all)")
+	nodes := fs.Bool("nodes", false, "Also import features mapped only as a node")
+	fs.Parse(args)
+
+	if *in == "" || *out == "" {
+		fmt.Fprintln(os.Stderr, "Error: --in and --out are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	opts := osm.ImportOptions{Nodes: *nodes}
+	for _, v := range strings.Split(*aeroways, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			opts.Aeroways = append(opts.Aeroways, v)
+		}
+	}
+	if opts.Aeroways == nil {
+		opts.Aeroways = []string{}
+	}
+	if *aoiPath != "" {
+		aoi, err := geo.LoadAOI(*aoiPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		b := aoi.BBox()
+		opts.BBox = &b
+	}
+
+	fmt.Printf("🗺️  Importing %s\n", *in)
+	list, stats, err := osm.Import(*in, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := facilities.New(list); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: imported facilities: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := facilities.Write(*out, list); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *out, err)
+		os.Exit(1)
+	}
+	fmt.Printf("   %d facilities (%d ways, %d relations, %d nodes) → %s\n", len(list), stats.Ways, stats.Relations, stats.Nodes, *out)
+	if stats.Incomplete > 0 {
+		fmt.Printf("   Skipped %d areas with members or nodes missing from the extract\n", stats.Incomplete)
+	}
+	if stats.OutsideBBox > 0 {
+		fmt.Printf("   Skipped %d features outside the AOI\n", stats.OutsideBBox)
+	}
+}
+
+func cmdChange(args []string) {
+	fs := flag.NewFlagSet("change", flag.ExitOnError)
+	beforePath := fs.String("before", "", "Earlier image (GeoTIFF, PNG or JPEG)")
+	afterPath := fs.String("after", "", "Later image of the same area")
+	engine := fs.String("engine", "auto", "Change engine: auto (AI worker if reachable, else local), ai, local")
+	method := fs.String("method", "auto", "Local engine method: auto (diff for one band, cva for several), diff, cva, ratio (SAR)")
+	sensitivity := fs.Float64("sensitivity", 0.5, "0 = only major changes, 1 = all changes")
+	aiAddr := fs.String("ai", "localhost:50051", "AI worker address")
+	aoiPath := fs.String("aoi", "", "AOI polygon file (GeoJSON or WKT); the local engine compares only its extent, and regions outside it are dropped")
+	maskOut := fs.String("mask", "", "Output path for the change mask PNG")
+	jsonOut := fs.Bool("json", false, "Output regions as JSON")
+	facilitiesPath := fs.String("facilities", defaultFacilities, "Known-facilities file or directory for naming the facility nearest each region (\"\" = off)")
+	facilityKm := fs.Float64("facility-km", facilities.DefaultMaxKm, "Name the nearest facility within this distance in km")
+	beforeDets := fs.String("before-detections", "", "Detections on the earlier image (detect --json output), for classifying regions")
+	afterDets := fs.String("after-detections", "", "Detections on the later image (detect --json output)")
+	beforeBands := fs.String("before-bands", "", "Scene directory of the earlier date with band files (B03.tif, B04.tif, B08.tif, B11.tif), for NDVI, NDWI and NDBI")
+	afterBands := fs.String("after-bands", "", "Scene directory of the later date with band files")
+	offset := fs.Float64("offset", -1000, "Offset added to band values (Sentinel-2 L2A baseline 04.00+: -1000)")
+	fs.Parse(args)
+
+	if *beforePath == "" || *afterPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: --before and --after are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	m, err := change.ParseMethod(*method)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var aoi geo.MultiPolygon
+	if *aoiPath != "" {
+		aoi = loadAOI(*aoiPath)
+	}
+
+	ev := change.Evidence{
+		BeforeDetections: loadChangeDetections(*beforeDets),
+		AfterDetections:  loadChangeDetections(*afterDets),
+		BeforeIndices:    loadChangeIndices(*beforeBands, raster.BandOptions{Offset: *offset}),
+		AfterIndices:     loadChangeIndices(*afterBands, raster.BandOptions{Offset: *offset}),
+	}
+	resp := detectChanges(*beforePath, *afterPath, aoi, *engine, *aiAddr, change.Options{Method: m, Sensitivity: *sensitivity}, ev)
+	printChanges(resp, loadFacilities(*facilitiesPath), *facilityKm, *maskOut, *jsonOut)
+}
+
+// loadChangeDetections reads the detections of a detect --json file, or
+// none for an empty path.
+func loadChangeDetections(path string) []report.Detection {
+	if path == "" {
+		return nil
+	}
+	res, err := report.LoadDetectResult(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return res.Detections
+}
+
+// changeIndices are the spectral indices change classification uses.
+var changeIndices = []string{"ndvi", "ndwi", "ndbi"}
+
+// loadChangeIndices computes those of changeIndices whose bands are in
+// dir, or none for an empty path.
+func loadChangeIndices(dir string, opts raster.BandOptions) map[string]*raster.Raster {
+	if dir == "" {
+		return nil
+	}
+	out := map[string]*raster.Raster{}
+	for _, name := range changeIndices {
+		idx := raster.Indices[name]
+		if _, err := os.Stat(filepath.Join(dir, idx.A+".tif")); err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, idx.B+".tif")); err != nil {
+			continue
+		}
+		r, err := raster.ComputeIndex(dir, idx, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		out[name] = r
+	}
+	if len(out) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s has the bands of none of NDVI, NDWI or NDBI\n", dir)
+		os.Exit(1)
+	}
+	return out
+}
+
+// detectChanges compares two images with the AI worker or the local
+// engine and classifies the regions from the images and ev. With an AOI
+// only its extent is read, to compare with the local engine or classify
+// the worker's regions; regions whose center lies outside it are dropped
+// for either engine.
+func detectChanges(beforePath, afterPath string, aoi geo.MultiPolygon, engine, aiAddr string, opts change.Options, ev change.Evidence) *pb.ChangeResponse {
+	if engine != "auto" && engine != "ai" && engine != "local" {
+		fmt.Fprintf(os.Stderr, "Error: unknown engine %q (want auto, ai or local)\n", engine)
+		os.Exit(1)
+	}
+	if opts.Sensitivity < 0 || opts.Sensitivity > 1 {
+		fmt.Fprintf(os.Stderr, "Error: --sensitivity must be between 0 and 1, got %g\n", opts.Sensitivity)
+		os.Exit(1)
+	}
+
+	var resp *pb.ChangeResponse
+	if engine != "local" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		client, err := detector.NewClient(aiAddr)
+		if err == nil {
+			defer client.Close()
+			hctx, hcancel := context.WithTimeout(ctx, 5*time.Second)
+			_, err = client.Health(hctx)
+			hcancel()
+		}
+		switch {
+		case err == nil:
+			fmt.Fprintf(os.Stderr, "🔄 Comparing %s → %s (AI worker)...\n", beforePath, afterPath)
+			resp, err = client.DetectChangesFromFiles(ctx, beforePath, afterPath, float32(opts.Sensitivity))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Change detection error: %v\n", err)
+				os.Exit(1)
+			}
+			if !georeferenceRegions(resp, beforePath) && aoi != nil {
+				fmt.Fprintln(os.Stderr, "Error: cropping to an AOI needs a georeferenced before GeoTIFF")
+				os.Exit(1)
+			}
+		case engine == "ai":
+			fmt.Fprintf(os.Stderr, "AI worker unavailable: %v\n", err)
+			os.Exit(1)
+		default:
+			fmt.Fprintln(os.Stderr, "   AI worker unavailable; using the local engine")
+		}
+	}
+
+	var bbox *geo.BBox
+	if aoi != nil {
+		b := aoi.BBox()
+		bbox = &b
+	}
+	if resp != nil {
+		// The worker's regions are on the before image's full grid; with
+		// an AOI, classify on just its window of that grid.
+		before, after, err := change.Load(beforePath, afterPath, bbox)
+		if err == nil && bbox != nil {
+			ev.Origin, err = windowOrigin(beforePath, before)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "   Warning: regions left unclassified: %v\n", err)
+			before, after = nil, nil
+		}
+		ev.Before, ev.After = before, after
+	} else {
+		before, after, err := change.Load(beforePath, afterPath, bbox)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "🔄 Comparing %s → %s (local, %s)...\n", beforePath, afterPath, opts.Method)
+		if resp, err = change.Detect(before, after, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Change detection error: %v\n", err)
+			os.Exit(1)
+		}
+		ev.Before, ev.After = before, after
+	}
+	change.Classify(resp, ev)
+
+	if aoi != nil {
+		kept := resp.Regions[:0]
+		for _, r := range resp.Regions {
+			if r.GeoCenter != nil && !aoi.Contains(geo.Point{Lat: r.GeoCenter.Latitude, Lon: r.GeoCenter.Longitude}) {
+				continue
+			}
+			kept = append(kept, r)
+		}
+		resp.Regions = kept
+	}
+	return resp
+}
+
+// windowOrigin returns the pixel of path's grid at the top-left corner of
+// win, a window of it read by change.Load.
+func windowOrigin(path string, win *raster.Raster) (image.Point, error) {
+	g, _, ok := imageTransform(path)
+	if !ok || !win.Geo.Valid() {
+		return image.Point{}, fmt.Errorf("%s is not georeferenced", path)
+	}
+	col, row := g.ToPixel(win.Geo.OriginX, win.Geo.OriginY)
+	return image.Pt(int(math.Round(col)), int(math.Round(row))), nil
+}
+
+// printChanges lists change regions with the facility nearest each, and
+// writes the change mask if maskPath is set.
+func printChanges(resp *pb.ChangeResponse, db *facilities.DB, maxKm float64, maskPath string, asJSON bool) {
+	if maskPath != "" {
+		if err := os.WriteFile(maskPath, resp.ChangeMask, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", maskPath, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Change mask saved to: %s\n", maskPath)
+	}
+
+	if asJSON {
+		mask := resp.ChangeMask
+		resp.ChangeMask = nil // written with --mask
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(resp)
+		resp.ChangeMask = mask
+		return
+	}
+
+	fmt.Printf("✅ %.1f%% changed, %d regions\n", resp.ChangePercentage, len(resp.Regions))
+	counts := map[string]int{}
+	for _, r := range resp.Regions {
+		counts[r.ChangeType]++
+	}
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return counts[types[i]] > counts[types[j]] || counts[types[i]] == counts[types[j]] && types[i] < types[j]
+	})
+	for _, t := range types {
+		fmt.Printf("   %-20s %d\n", t, counts[t])
+	}
+	fmt.Println()
+	for i, r := range resp.Regions {
+		fmt.Printf("  [%d] %s  significance %.2f  px (%.0f,%.0f)-(%.0f,%.0f)", i+1, r.ChangeType, r.Significance,
+			r.Bbox.GetXMin(), r.Bbox.GetYMin(), r.Bbox.GetXMax(), r.Bbox.GetYMax())
+		if c := r.GeoCenter; c != nil {
+			fmt.Printf("  @ (%.4f, %.4f)", c.Latitude, c.Longitude)
+			if db != nil {
+				if m, ok := db.Nearest(geo.Point{Lat: c.Latitude, Lon: c.Longitude}, maxKm); ok {
+					fmt.Printf("  near %s (%.1f km)", m.Facility.Name, m.DistKm)
+				}
+			}
+		}
+		fmt.Println()
+	}
+}
+
+func cmdMonitor(args []string) {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	lat := fs.Float64("lat", 0, "Latitude")
+	lon := fs.Float64("lon", 0, "Longitude")
+	radius := fs.Float64("radius", 5, "Radius in km")
+	at := fs.String("at", "", "Location as DD, DMS, UTM or MGRS (e.g. 49QBB4567815678); alternative to --lat/--lon")
+	aoiPath := fs.String("aoi", "", "AOI polygon file (GeoJSON or WKT); overrides --lat/--lon/--radius")
+	interval := fs.String("interval", "7d", "Minimum time between the compared scenes, e.g. 7d or 36h")
+	lookback := fs.Int("lookback", 90, "Days of imagery to search")
+	maxCloud := fs.Float64("cloud", 20, "Max cloud cover % over the AOI")
+	engine := fs.String("engine", "auto", "Change engine: auto (AI worker if reachable, else local), ai, local")
+	method := fs.String("method", "auto", "Local engine method: auto, diff, cva")
+	sensitivity := fs.Float64("sensitivity", 0.5, "0 = only major changes, 1 = all changes")
+	aiAddr := fs.String("ai", "localhost:50051", "AI worker address")
+	maskOut := fs.String("mask", "", "Output path for the change mask PNG")
+	jsonOut := fs.Bool("json", false, "Output regions as JSON")
+	facilitiesPath := fs.String("facilities", defaultFacilities, "Known-facilities file or directory for naming the facility nearest each region (\"\" = off)")
+	facilityKm := fs.Float64("facility-km", facilities.DefaultMaxKm, "Name the nearest facility within this distance in km")
+	indices := fs.Bool("indices", false, "Also download bands B03, B04, B08 and B11 to classify regions with NDVI, NDWI and NDBI")
+	fs.Parse(args)
+
+	center, ok := locationFlags(fs, *lat, *lon, *at)
+	if !ok && *aoiPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: --at or --lat/--lon (or --aoi) are required")
+		os.Exit(1)
+	}
+	gap, err := parseInterval(*interval)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --interval: %v\n", err)
+		os.Exit(1)
+	}
+	m, err := change.ParseMethod(*method)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	bbox := geo.BBoxFromCenter(center, *radius)
+	var aoi geo.MultiPolygon
+	if *aoiPath != "" {
+		aoi = loadAOI(*aoiPath)
+		bbox = aoi.BBox()
+	} else {
+		for _, part := range bbox.Normalize().Split() {
+			aoi = append(aoi, geo.Polygon{part.Ring()})
+		}
+	}
+
+	ctx := context.Background()
+	s2 := collector.NewSentinel2("data/cache")
+	fmt.Fprintln(os.Stderr, "🛰️  Searching Sentinel-2 imagery...")
+	results, err := s2.Search(ctx, collector.SearchParams{
+		BBox:       bbox,
+		DateFrom:   time.Now().AddDate(0, 0, -*lookback),
+		DateTo:     time.Now(),
+		MaxCloud:   *maxCloud,
+		MaxResults: 20,
+		RankByAOI:  true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no imagery with <%g%% cloud in the last %d days\n", *maxCloud, *lookback)
+		os.Exit(1)
+	}
+
+	// Compare the newest scene with the newest one of the same tile at
+	// least the interval older, so both share a grid.
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Date.After(results[j].Date) })
+	after := results[0]
+	var before *collector.ImageResult
+	for i := range results[1:] {
+		r := &results[i+1]
+		if r.Tile == after.Tile && !r.Date.After(after.Date.Add(-gap)) {
+			before = r
+			break
+		}
+	}
+	if before == nil {
+		fmt.Fprintf(os.Stderr, "Error: no scene of tile %s at least %s before %s; increase --lookback or --cloud\n",
+			after.Tile, *interval, after.Date.Format("2006-01-02"))
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "   Before: %s (%s)\n   After:  %s (%s)\n", before.ID, before.Date.Format("2006-01-02"), after.ID, after.Date.Format("2006-01-02"))
+
+	assets := []string{"visual"}
+	if *indices {
+		assets = append(assets, "B03", "B04", "B08", "B11")
+	}
+	var paths, dirs [2]string
+	for i, r := range []collector.ImageResult{*before, after} {
+		dir, err := s2.Download(ctx, r, assets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Fetch error: %v\n", err)
+			os.Exit(1)
+		}
+		paths[i], dirs[i] = filepath.Join(dir, "visual.tif"), dir
+	}
+
+	var ev change.Evidence
+	if *indices {
+		// 20 m, B11's resolution, is plenty for region means and keeps
+		// full-tile indices small.
+		opts := raster.BandOptions{Resolution: 20, Offset: -1000}
+		ev.BeforeIndices = loadChangeIndices(dirs[0], opts)
+		ev.AfterIndices = loadChangeIndices(dirs[1], opts)
+	}
+	resp := detectChanges(paths[0], paths[1], aoi, *engine, *aiAddr, change.Options{Method: m, Sensitivity: *sensitivity}, ev)
+	printChanges(resp, loadFacilities(*facilitiesPath), *facilityKm, *maskOut, *jsonOut)
+}
+
+// parseInterval parses a duration that may also be given in days ("7d").
+func parseInterval(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid interval %q", s)
+		}
+		return time.Duration(n * 24 * float64(time.Hour)), nil
+	}
+	return time.ParseDuration(s)
 }
 
 func cmdHealth(args []string) {
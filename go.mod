@@ -3,6 +3,7 @@ module github.com/clearclown/orbital-eye
 go 1.22.0
 
 require (
+	golang.org/x/image v0.20.0
 	google.golang.org/grpc v1.67.0
 	google.golang.org/protobuf v1.35.0
 )
@@ -10,6 +11,6 @@ require (
 require (
 	golang.org/x/net v0.28.0 // indirect
 	golang.org/x/sys v0.24.0 // indirect
-	golang.org/x/text v0.17.0 // indirect
+	golang.org/x/text v0.18.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
 )
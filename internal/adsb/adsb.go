@@ -0,0 +1,185 @@
+// Package adsb ingests ADS-B position history from local files and
+// associates airframes with aircraft detections on airfields by their
+// last-known position before the scene time.
+//
+// Supported inputs are readsb/tar1090 trace files (trace_full_<hex>.json,
+// optionally gzip-compressed), readsb aircraft.json snapshots, and CSV
+// exports such as OpenSky state vectors.
+package adsb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+// Fix is one reported position of an airframe.
+type Fix struct {
+	Time     time.Time
+	Point    geo.Point
+	AltFt    float64 // barometric (pressure) altitude, if HasAlt
+	HasAlt   bool
+	OnGround bool
+	Callsign string
+}
+
+// Aircraft identifies an airframe.
+type Aircraft struct {
+	Hex          string // ICAO 24-bit address, lower-case hex
+	Registration string
+	Type         string // ICAO type designator, e.g. "B738"
+	Description  string
+}
+
+// Store holds the fixes of every airframe in time order once sorted.
+type Store struct {
+	Tracks   map[string][]Fix
+	Aircraft map[string]*Aircraft
+	sorted   bool
+}
+
+// NewStore returns an empty store.
+func NewStore() *Store {
+	return &Store{Tracks: make(map[string][]Fix), Aircraft: make(map[string]*Aircraft)}
+}
+
+// normHex canonicalizes an ICAO address. Non-ICAO addresses keep readsb's
+// "~" prefix.
+func normHex(hex string) string {
+	return strings.ToLower(strings.TrimSpace(hex))
+}
+
+// AddFix records a position of the airframe hex.
+func (s *Store) AddFix(hex string, f Fix) {
+	hex = normHex(hex)
+	if hex == "" || f.Time.IsZero() || f.Point.Lat < -90 || f.Point.Lat > 90 || f.Point.Lon < -180 || f.Point.Lon > 180 {
+		return
+	}
+	s.Tracks[hex] = append(s.Tracks[hex], f)
+	s.sorted = false
+	if _, ok := s.Aircraft[hex]; !ok {
+		s.Aircraft[hex] = &Aircraft{Hex: hex}
+	}
+}
+
+// AddAircraft merges identification data; empty fields leave earlier
+// values in place.
+func (s *Store) AddAircraft(a Aircraft) {
+	a.Hex = normHex(a.Hex)
+	if a.Hex == "" {
+		return
+	}
+	cur, ok := s.Aircraft[a.Hex]
+	if !ok {
+		cur = &Aircraft{Hex: a.Hex}
+		s.Aircraft[a.Hex] = cur
+	}
+	if a.Registration != "" {
+		cur.Registration = strings.TrimSpace(a.Registration)
+	}
+	if a.Type != "" {
+		cur.Type = strings.TrimSpace(a.Type)
+	}
+	if a.Description != "" {
+		cur.Description = strings.TrimSpace(a.Description)
+	}
+}
+
+// Len returns the number of airframes with fixes.
+func (s *Store) Len() int { return len(s.Tracks) }
+
+func (s *Store) sort() {
+	if s.sorted {
+		return
+	}
+	for _, track := range s.Tracks {
+		sort.SliceStable(track, func(i, j int) bool { return track[i].Time.Before(track[j].Time) })
+	}
+	s.sorted = true
+}
+
+// LoadStats counts what Load read.
+type LoadStats struct {
+	Files   int
+	Fixes   int
+	Skipped int // rows or trace points without a usable position or time
+}
+
+// Load reads ADS-B files into a new store. A path that is a directory
+// loads every *.json, *.json.gz and *.csv file in it, recursively, since
+// readsb spreads traces over per-hex subdirectories.
+func Load(paths ...string) (*Store, LoadStats, error) {
+	s := NewStore()
+	var stats LoadStats
+	var errs []error
+	for _, path := range paths {
+		var files []string
+		err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			name := strings.ToLower(d.Name())
+			if p == path || strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.gz") || strings.HasSuffix(name, ".csv") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, stats, err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			var err error
+			if strings.HasSuffix(strings.ToLower(file), ".csv") {
+				err = s.readCSVFile(file, &stats)
+			} else {
+				err = s.readJSONFile(file, &stats)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+				continue
+			}
+			stats.Files++
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, stats, err
+	}
+	s.sort()
+	return s, stats, nil
+}
+
+// Sighting is an airframe's last-known position before a given time.
+type Sighting struct {
+	Aircraft *Aircraft
+	Fix      Fix
+	Age      time.Duration // time from the fix to the reference time
+}
+
+// LastKnown returns, for every airframe with a fix at or before t and no
+// older than maxAge, its latest such fix.
+func (s *Store) LastKnown(t time.Time, maxAge time.Duration) []Sighting {
+	s.sort()
+	var out []Sighting
+	for hex, track := range s.Tracks {
+		i := sort.Search(len(track), func(i int) bool { return track[i].Time.After(t) })
+		if i == 0 {
+			continue
+		}
+		f := track[i-1]
+		if age := t.Sub(f.Time); age <= maxAge {
+			out = append(out, Sighting{Aircraft: s.Aircraft[hex], Fix: f, Age: age})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Aircraft.Hex < out[j].Aircraft.Hex })
+	return out
+}
@@ -0,0 +1,108 @@
+package adsb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testdata holds a readsb trace, an aircraft.json snapshot and OpenSky
+// state vectors of the same airframes around Dublin Airport.
+func TestLoadSample(t *testing.T) {
+	s, stats, err := Load("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (LoadStats{Files: 3, Fixes: 7, Skipped: 3}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	fixes := []struct {
+		hex       string
+		i         int
+		unixMilli int64
+		lat, lon  float64
+		altFt     float64
+		hasAlt    bool
+		onGround  bool
+		callsign  string
+	}{
+		{"4ca7b5", 0, 1705287000250, 53.4280, -6.2500, 2500, true, false, "RYR4AB"}, // trace
+		{"4ca7b5", 2, 1705287120250, 53.4215, -6.2701, 0, false, true, "RYR4AB"},    // trace, on the ground
+		{"4ca7b5", 3, 1705287598500, 53.4216, -6.2702, 0, false, true, "RYR4AB"},    // CSV, no altitude
+		{"3c6444", 0, 1705287599000, 53.4300, -6.2500, 500, true, false, "DLH9LF"},  // CSV, meters
+		{"3c6444", 1, 1705289999000, 53.4301, -6.2455, 225, true, false, "DLH9LF"},  // snapshot
+		{"~2a1b3c", 0, 1705289990500, 53.4201, -6.2650, 0, false, true, ""},         // snapshot, non-ICAO
+	}
+	for _, f := range fixes {
+		track := s.Tracks[f.hex]
+		if f.i >= len(track) {
+			t.Errorf("%s: %d fixes, want more than %d", f.hex, len(track), f.i)
+			continue
+		}
+		got := track[f.i]
+		if got.Time.UnixMilli() != f.unixMilli {
+			t.Errorf("%s[%d]: time %v, want %v", f.hex, f.i, got.Time, time.UnixMilli(f.unixMilli).UTC())
+		}
+		if got.Point.Lat != f.lat || got.Point.Lon != f.lon {
+			t.Errorf("%s[%d]: position %v, want %v, %v", f.hex, f.i, got.Point, f.lat, f.lon)
+		}
+		if math.Abs(got.AltFt-f.altFt) > 1e-6 || got.HasAlt != f.hasAlt || got.OnGround != f.onGround || got.Callsign != f.callsign {
+			t.Errorf("%s[%d]: altitude %v (known %v), on ground %v, callsign %q; want %v (%v), %v, %q",
+				f.hex, f.i, got.AltFt, got.HasAlt, got.OnGround, got.Callsign, f.altFt, f.hasAlt, f.onGround, f.callsign)
+		}
+	}
+
+	want := Aircraft{Hex: "4ca7b5", Registration: "EI-DCL", Type: "B738", Description: "BOEING 737-800"}
+	if got := s.Aircraft["4ca7b5"]; got == nil || *got != want {
+		t.Errorf("aircraft 4ca7b5 = %+v, want %+v", got, want)
+	}
+	if _, ok := s.Tracks["a1b2c3"]; ok {
+		t.Error("airframe without a position has a track")
+	}
+}
+
+func TestLoadGzipTrace(t *testing.T) {
+	data, err := os.ReadFile("testdata/traces/trace_full_4ca7b5.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	// readsb writes compressed traces under a .json name.
+	path := filepath.Join(t.TempDir(), "trace_full_4ca7b5.json")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, stats, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (LoadStats{Files: 1, Fixes: 3, Skipped: 1}) || len(s.Tracks["4ca7b5"]) != 3 {
+		t.Errorf("stats = %+v, %d fixes", stats, len(s.Tracks["4ca7b5"]))
+	}
+}
+
+func TestLastKnown(t *testing.T) {
+	s, _, err := Load("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Unix(1705287600, 0)
+	got := s.LastKnown(at, time.Hour)
+	if len(got) != 2 || got[0].Aircraft.Hex != "3c6444" || got[1].Aircraft.Hex != "4ca7b5" {
+		t.Fatalf("LastKnown = %+v, want 3c6444 and 4ca7b5", got)
+	}
+	if got[1].Age != 1500*time.Millisecond || !got[1].Fix.OnGround {
+		t.Errorf("4ca7b5: age %v, fix %+v", got[1].Age, got[1].Fix)
+	}
+	if got := s.LastKnown(at, time.Second); len(got) != 1 {
+		t.Errorf("LastKnown within 1 s = %+v, want 3c6444 only", got)
+	}
+}
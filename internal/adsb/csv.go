@@ -0,0 +1,157 @@
+package adsb
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumns maps normalized header names (lower case, without spaces,
+// underscores or dashes) to fields, covering OpenSky state vectors and
+// common ADS-B exchange exports. Earlier names win when a file has
+// several for one field.
+var csvColumns = []struct{ name, field string }{
+	{"hex", "hex"},
+	{"icao24", "hex"},
+	{"icao", "hex"},
+	{"lastposupdate", "time"},
+	{"timestamp", "time"},
+	{"time", "time"},
+	{"datetime", "time"},
+	{"lat", "lat"},
+	{"latitude", "lat"},
+	{"lon", "lon"},
+	{"lng", "lon"},
+	{"longitude", "lon"},
+	{"altbaro", "alt"},
+	{"baroaltitude", "alt"},
+	{"altitude", "alt"},
+	{"alt", "alt"},
+	{"onground", "ground"},
+	{"ground", "ground"},
+	{"callsign", "callsign"},
+	{"flight", "callsign"},
+	{"registration", "reg"},
+	{"reg", "reg"},
+	{"r", "reg"},
+	{"typecode", "type"},
+	{"aircrafttype", "type"},
+	{"type", "type"},
+	{"t", "type"},
+}
+
+func normalizeHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(h)
+}
+
+// readCSVFile reads a CSV file with a header row and one position per row.
+func (s *Store) readCSVFile(path string, stats *LoadStats) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return err
+	}
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		if _, dup := index[normalizeHeader(h)]; !dup {
+			index[normalizeHeader(h)] = i
+		}
+	}
+	cols := make(map[string]int)
+	meters := false
+	for _, c := range csvColumns {
+		if _, done := cols[c.field]; done {
+			continue
+		}
+		if i, ok := index[c.name]; ok {
+			cols[c.field] = i
+			if c.field == "alt" {
+				meters = c.name == "baroaltitude" // OpenSky reports meters
+			}
+		}
+	}
+	for _, need := range []string{"hex", "time", "lat", "lon"} {
+		if _, ok := cols[need]; !ok {
+			return errors.New("no " + need + " column in header")
+		}
+	}
+
+	get := func(rec []string, field string) string {
+		if i, ok := cols[field]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		hex := get(rec, "hex")
+		s.AddAircraft(Aircraft{Hex: hex, Registration: get(rec, "reg"), Type: get(rec, "type")})
+		t, err := parseTime(get(rec, "time"))
+		lat, err1 := strconv.ParseFloat(get(rec, "lat"), 64)
+		lon, err2 := strconv.ParseFloat(get(rec, "lon"), 64)
+		if hex == "" || err != nil || err1 != nil || err2 != nil {
+			stats.Skipped++
+			continue
+		}
+		fix := Fix{Time: t, Callsign: get(rec, "callsign")}
+		fix.Point.Lat, fix.Point.Lon = lat, lon
+		alt := get(rec, "alt")
+		if alt == "ground" {
+			fix.OnGround = true
+		} else if v, err := strconv.ParseFloat(alt, 64); err == nil {
+			if meters {
+				v /= 0.3048
+			}
+			fix.AltFt, fix.HasAlt = v, true
+		}
+		if g, err := strconv.ParseBool(get(rec, "ground")); err == nil && g {
+			fix.OnGround = true
+		}
+		s.AddFix(hex, fix)
+		stats.Fixes++
+	}
+}
+
+// timeLayouts are the accepted timestamp formats, UTC unless zoned.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// parseTime parses a timestamp in one of timeLayouts or as Unix seconds
+// or milliseconds.
+func parseTime(s string) (time.Time, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		if v > 1e11 {
+			v /= 1000
+		}
+		return unixTime(v), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognized time " + strconv.Quote(s))
+}
@@ -0,0 +1,194 @@
+package adsb
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/assign"
+	"github.com/clearclown/orbital-eye/internal/geo"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// Attribute keys set on aircraft detections inside an airfield.
+const (
+	// AttrStatus is StatusMatched or StatusUnmatched.
+	AttrStatus       = "adsb"
+	AttrHex          = "adsb_hex"
+	AttrRegistration = "adsb_registration"
+	AttrType         = "adsb_type"
+	AttrCallsign     = "adsb_callsign"
+	AttrAgeMin       = "adsb_age_min"
+	AttrDistanceM    = "adsb_distance_m"
+	AttrAirfield     = "adsb_airfield"
+)
+
+// Values of AttrStatus.
+const (
+	StatusMatched   = "matched"
+	StatusUnmatched = "unmatched"
+)
+
+// DefaultAircraftClasses are the class-name substrings treated as aircraft.
+var DefaultAircraftClasses = []string{"aircraft", "plane", "helicopter", "jet"}
+
+// Airfield is an area within which parked aircraft are associated.
+type Airfield struct {
+	ID   string
+	Area geo.MultiPolygon
+	// ElevationFt is the field elevation above mean sea level, if known.
+	// Without it only airframes reporting on-ground count as landed.
+	ElevationFt *float64
+}
+
+// MatchOptions configures Associate.
+type MatchOptions struct {
+	// MaxAge is how old a last-known position may be; defaults to 72
+	// hours, since parked aircraft stop transmitting.
+	MaxAge time.Duration
+	// MaxDistanceM is the largest distance between a detection and the
+	// airframe's last position; defaults to 300 m, allowing for taxiing
+	// after the last fix and for detection geolocation error.
+	MaxDistanceM float64
+	// MaxHeightFt is the highest last-known altitude above the field
+	// elevation of an airframe taken to have landed when it does not
+	// report on-ground; defaults to 500 ft. Barometric altitudes are
+	// pressure altitudes, which differ from the field's by a few hundred
+	// feet with the weather.
+	MaxHeightFt float64
+	// Classes are the class-name substrings treated as aircraft; defaults
+	// to DefaultAircraftClasses.
+	Classes []string
+}
+
+func (o *MatchOptions) defaults() {
+	if o.MaxAge <= 0 {
+		o.MaxAge = 72 * time.Hour
+	}
+	if o.MaxDistanceM <= 0 {
+		o.MaxDistanceM = 300
+	}
+	if o.MaxHeightFt <= 0 {
+		o.MaxHeightFt = 500
+	}
+	if len(o.Classes) == 0 {
+		o.Classes = DefaultAircraftClasses
+	}
+}
+
+// MatchStats summarizes an association.
+type MatchStats struct {
+	Aircraft  int // aircraft detections inside an airfield
+	Matched   int
+	Unplaced  int // aircraft detections without a position, not associated
+	Sightings int // airframes whose last-known position is on an airfield
+}
+
+// Associate pairs aircraft detections inside each airfield one-to-one with
+// the airframes whose last-known position before sceneTime lies on that
+// airfield and which had landed there: as many pairs within MaxDistanceM
+// as possible, and among those the assignment (Hungarian algorithm) with
+// the least total distance. Detections inside an airfield are labeled
+// matched, with the airframe's ICAO hex, registration and type, or
+// unmatched. Aircraft detections without a position are counted in
+// Unplaced.
+func (s *Store) Associate(dets []*pb.Detection, sceneTime time.Time, airfields []Airfield, opts MatchOptions) MatchStats {
+	opts.defaults()
+	var stats MatchStats
+	for _, d := range dets {
+		if d.GeoCenter == nil && isAircraft(d.ClassName, opts.Classes) {
+			stats.Unplaced++
+		}
+	}
+	sightings := s.LastKnown(sceneTime, opts.MaxAge)
+	used := make(map[*pb.Detection]bool)
+
+	for _, af := range airfields {
+		var inside []*pb.Detection
+		for _, d := range dets {
+			if d.GeoCenter == nil || used[d] || !isAircraft(d.ClassName, opts.Classes) {
+				continue
+			}
+			if af.Area.Contains(geo.Point{Lat: d.GeoCenter.Latitude, Lon: d.GeoCenter.Longitude}) {
+				inside = append(inside, d)
+				used[d] = true
+			}
+		}
+		var parked []Sighting
+		for _, sg := range sightings {
+			if landed(sg.Fix, af, opts) && af.Area.Contains(sg.Fix.Point) {
+				parked = append(parked, sg)
+			}
+		}
+		stats.Aircraft += len(inside)
+		stats.Sightings += len(parked)
+
+		// Pairs farther apart than MaxDistanceM are forbidden.
+		cost := make([][]float64, len(inside))
+		for i, d := range inside {
+			p := geo.Point{Lat: d.GeoCenter.Latitude, Lon: d.GeoCenter.Longitude}
+			cost[i] = make([]float64, len(parked))
+			for j, sg := range parked {
+				cost[i][j] = math.Inf(1)
+				if dist := geo.DistanceM(p, sg.Fix.Point); dist <= opts.MaxDistanceM {
+					cost[i][j] = dist
+				}
+			}
+		}
+		assigned := assign.Hungarian(cost)
+		for i, d := range inside {
+			if d.Attributes == nil {
+				d.Attributes = make(map[string]string)
+			}
+			d.Attributes[AttrAirfield] = af.ID
+			if j := assigned[i]; j >= 0 {
+				setMatch(d, parked[j], cost[i][j])
+				stats.Matched++
+				continue
+			}
+			d.Attributes[AttrStatus] = StatusUnmatched
+		}
+	}
+	return stats
+}
+
+// landed reports whether the fix places its airframe on the ground at af:
+// reported on-ground, or at a known altitude within MaxHeightFt of the
+// field elevation.
+func landed(f Fix, af Airfield, opts MatchOptions) bool {
+	if f.OnGround {
+		return true
+	}
+	return f.HasAlt && af.ElevationFt != nil && f.AltFt-*af.ElevationFt <= opts.MaxHeightFt
+}
+
+func setMatch(d *pb.Detection, sg Sighting, distM float64) {
+	if d.Attributes == nil {
+		d.Attributes = make(map[string]string)
+	}
+	a := sg.Aircraft
+	d.Attributes[AttrStatus] = StatusMatched
+	d.Attributes[AttrHex] = a.Hex
+	if a.Registration != "" {
+		d.Attributes[AttrRegistration] = a.Registration
+	}
+	if a.Type != "" {
+		d.Attributes[AttrType] = a.Type
+	}
+	if sg.Fix.Callsign != "" {
+		d.Attributes[AttrCallsign] = sg.Fix.Callsign
+	}
+	d.Attributes[AttrAgeMin] = fmt.Sprintf("%.0f", sg.Age.Minutes())
+	d.Attributes[AttrDistanceM] = fmt.Sprintf("%.0f", distM)
+}
+
+func isAircraft(class string, classes []string) bool {
+	c := strings.ToLower(class)
+	for _, v := range classes {
+		if strings.Contains(c, v) {
+			return true
+		}
+	}
+	return false
+}
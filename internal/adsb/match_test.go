@@ -0,0 +1,102 @@
+package adsb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+var t0 = time.Date(2024, 1, 15, 3, 0, 59, 0, time.UTC)
+
+func detectionAt(class string, p geo.Point) *pb.Detection {
+	return &pb.Detection{ClassName: class, GeoCenter: &pb.GeoPoint{Latitude: p.Lat, Longitude: p.Lon}}
+}
+
+// apron is a 2 km square around p.
+func apron(p geo.Point) geo.MultiPolygon {
+	var r geo.Ring
+	for _, az := range []float64{45, 135, 225, 315} {
+		r = append(r, geo.Destination(p, az, 1414))
+	}
+	return geo.MultiPolygon{{r}}
+}
+
+func TestAssociate(t *testing.T) {
+	field := geo.Point{Lat: 53.4213, Lon: -6.2701}
+	elev := 242.0
+	s := NewStore()
+	fix := func(hex string, f Fix) {
+		f.Time = t0.Add(-time.Hour)
+		s.AddFix(hex, f)
+	}
+	fix("aaaaaa", Fix{Point: field, OnGround: true})
+	fix("bbbbbb", Fix{Point: geo.Destination(field, 90, 200), OnGround: true})
+	fix("cccccc", Fix{Point: geo.Destination(field, 0, 600), AltFt: 400, HasAlt: true}) // 158 ft above the field
+	fix("dddddd", Fix{Point: geo.Destination(field, 180, 600)})                         // altitude unknown
+	fix("eeeeee", Fix{Point: geo.Destination(field, 270, 600), AltFt: 3000, HasAlt: true})
+	s.AddAircraft(Aircraft{Hex: "bbbbbb", Registration: "EI-DCL", Type: "B738"})
+
+	// d1 is nearest aaaaaa but also within reach of bbbbbb; d2 is within
+	// aaaaaa's only. Pairing d1 with its nearest airframe would leave d2
+	// unmatched.
+	newDets := func() []*pb.Detection {
+		return []*pb.Detection{
+			detectionAt("aircraft", geo.Destination(field, 90, 90)),
+			detectionAt("aircraft", geo.Destination(field, 270, 150)),
+			detectionAt("plane", geo.Destination(field, 0, 600)),
+			detectionAt("plane", geo.Destination(field, 180, 600)),
+			detectionAt("plane", geo.Destination(field, 270, 600)),
+			{ClassName: "aircraft"},
+			detectionAt("ship", field),
+			detectionAt("aircraft", geo.Destination(field, 0, 5000)),
+		}
+	}
+
+	for _, c := range []struct {
+		name  string
+		elev  *float64
+		stats MatchStats
+		hexes []string
+	}{
+		{"with elevation", &elev, MatchStats{Aircraft: 5, Matched: 3, Unplaced: 1, Sightings: 3}, []string{"bbbbbb", "aaaaaa", "cccccc", "", ""}},
+		{"without elevation", nil, MatchStats{Aircraft: 5, Matched: 2, Unplaced: 1, Sightings: 2}, []string{"bbbbbb", "aaaaaa", "", "", ""}},
+	} {
+		dets := newDets()
+		stats := s.Associate(dets, t0, []Airfield{{ID: "EIDW", Area: apron(field), ElevationFt: c.elev}}, MatchOptions{})
+		if stats != c.stats {
+			t.Errorf("%s: stats = %+v, want %+v", c.name, stats, c.stats)
+		}
+		for i, hex := range c.hexes {
+			status := StatusMatched
+			if hex == "" {
+				status = StatusUnmatched
+			}
+			a := dets[i].Attributes
+			if a[AttrStatus] != status || a[AttrHex] != hex || a[AttrAirfield] != "EIDW" {
+				t.Errorf("%s: detection %d: %s %q on %q, want %s %q", c.name, i, a[AttrStatus], a[AttrHex], a[AttrAirfield], status, hex)
+			}
+		}
+		if a := dets[0].Attributes; a[AttrRegistration] != "EI-DCL" || a[AttrType] != "B738" || a[AttrDistanceM] != "110" || a[AttrAgeMin] != "60" {
+			t.Errorf("%s: detection 0 attributes %v", c.name, a)
+		}
+		for _, d := range dets[5:] {
+			if d.Attributes != nil {
+				t.Errorf("%s: %s detection outside the airfield or unplaced was labeled: %v", c.name, d.ClassName, d.Attributes)
+			}
+		}
+	}
+}
+
+func TestAssociateAirfieldsClaimOnce(t *testing.T) {
+	field := geo.Point{Lat: 53.4213, Lon: -6.2701}
+	s := NewStore()
+	s.AddFix("aaaaaa", Fix{Time: t0.Add(-time.Minute), Point: field, OnGround: true})
+	d := detectionAt("aircraft", field)
+	// Overlapping airfields: the detection belongs to the first.
+	stats := s.Associate([]*pb.Detection{d}, t0, []Airfield{{ID: "a", Area: apron(field)}, {ID: "b", Area: apron(field)}}, MatchOptions{})
+	if stats != (MatchStats{Aircraft: 1, Matched: 1, Sightings: 2}) || d.Attributes[AttrAirfield] != "a" {
+		t.Errorf("stats = %+v, airfield %q", stats, d.Attributes[AttrAirfield])
+	}
+}
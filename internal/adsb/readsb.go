@@ -0,0 +1,151 @@
+package adsb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// readsbTrace is a readsb/tar1090 trace file. Each trace point is an
+// array: [seconds after timestamp, lat, lon, altitude ft or "ground",
+// ground speed, track, flags, vertical rate, details or null, ...].
+type readsbTrace struct {
+	ICAO         string              `json:"icao"`
+	Registration string              `json:"r"`
+	Type         string              `json:"t"`
+	Desc         string              `json:"desc"`
+	Timestamp    float64             `json:"timestamp"`
+	Trace        [][]json.RawMessage `json:"trace"`
+}
+
+// readsbSnapshot is a readsb aircraft.json file.
+type readsbSnapshot struct {
+	Now      float64          `json:"now"`
+	Aircraft []readsbAircraft `json:"aircraft"`
+}
+
+type readsbAircraft struct {
+	Hex          string          `json:"hex"`
+	Flight       string          `json:"flight"`
+	Registration string          `json:"r"`
+	Type         string          `json:"t"`
+	Desc         string          `json:"desc"`
+	Lat          *float64        `json:"lat"`
+	Lon          *float64        `json:"lon"`
+	AltBaro      json.RawMessage `json:"alt_baro"`
+	SeenPos      float64         `json:"seen_pos"`
+}
+
+// readJSONFile reads a trace file or an aircraft.json snapshot, either of
+// which may be gzip-compressed (readsb writes traces compressed under a
+// .json name).
+func (s *Store) readJSONFile(path string, stats *LoadStats) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return err
+		}
+	}
+
+	var probe struct {
+		ICAO     *string         `json:"icao"`
+		Aircraft json.RawMessage `json:"aircraft"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+	switch {
+	case probe.ICAO != nil:
+		var tr readsbTrace
+		if err := json.Unmarshal(data, &tr); err != nil {
+			return err
+		}
+		s.addTrace(tr, stats)
+	case probe.Aircraft != nil:
+		var snap readsbSnapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return err
+		}
+		s.addSnapshot(snap, stats)
+	default:
+		return errors.New("neither a readsb trace nor an aircraft.json snapshot")
+	}
+	return nil
+}
+
+func (s *Store) addTrace(tr readsbTrace, stats *LoadStats) {
+	s.AddAircraft(Aircraft{Hex: tr.ICAO, Registration: tr.Registration, Type: tr.Type, Description: tr.Desc})
+	callsign := ""
+	for _, pt := range tr.Trace {
+		var dt, lat, lon float64
+		if len(pt) < 4 || string(pt[1]) == "null" || string(pt[2]) == "null" ||
+			json.Unmarshal(pt[0], &dt) != nil || json.Unmarshal(pt[1], &lat) != nil || json.Unmarshal(pt[2], &lon) != nil {
+			stats.Skipped++
+			continue
+		}
+		f := Fix{Time: unixTime(tr.Timestamp + dt)}
+		f.Point.Lat, f.Point.Lon = lat, lon
+		f.AltFt, f.HasAlt, f.OnGround = altitude(pt[3])
+		// Details (index 8) appear when identification changes.
+		if len(pt) > 8 && string(pt[8]) != "null" {
+			var det readsbAircraft
+			if json.Unmarshal(pt[8], &det) == nil {
+				if c := strings.TrimSpace(det.Flight); c != "" {
+					callsign = c
+				}
+				s.AddAircraft(Aircraft{Hex: tr.ICAO, Registration: det.Registration, Type: det.Type, Description: det.Desc})
+			}
+		}
+		f.Callsign = callsign
+		s.AddFix(tr.ICAO, f)
+		stats.Fixes++
+	}
+}
+
+func (s *Store) addSnapshot(snap readsbSnapshot, stats *LoadStats) {
+	for _, a := range snap.Aircraft {
+		s.AddAircraft(Aircraft{Hex: a.Hex, Registration: a.Registration, Type: a.Type, Description: a.Desc})
+		if a.Lat == nil || a.Lon == nil {
+			stats.Skipped++
+			continue
+		}
+		f := Fix{Time: unixTime(snap.Now - a.SeenPos), Callsign: strings.TrimSpace(a.Flight)}
+		f.Point.Lat, f.Point.Lon = *a.Lat, *a.Lon
+		f.AltFt, f.HasAlt, f.OnGround = altitude(a.AltBaro)
+		s.AddFix(a.Hex, f)
+		stats.Fixes++
+	}
+}
+
+// altitude decodes a readsb altitude: feet, "ground", or null.
+func altitude(raw json.RawMessage) (ft float64, known, onGround bool) {
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		return 0, false, str == "ground"
+	}
+	if string(raw) == "null" || json.Unmarshal(raw, &ft) != nil {
+		return 0, false, false
+	}
+	return ft, true, false
+}
+
+func unixTime(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
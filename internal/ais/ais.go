@@ -0,0 +1,171 @@
+// Package ais ingests AIS vessel reports from NMEA 0183 logs and CSV
+// exports, estimates vessel positions at a scene's acquisition time, and
+// correlates them with vessel detections so that ships without a
+// transmitting transponder stand out.
+package ais
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+// Report is one AIS position report.
+type Report struct {
+	MMSI    uint32
+	Time    time.Time
+	Point   geo.Point
+	SOG     float64 // speed over ground in knots; NaN if not available
+	COG     float64 // course over ground in degrees; NaN if not available
+	Heading float64 // true heading in degrees; NaN if not available
+}
+
+// Vessel is the static data a vessel broadcasts about itself.
+type Vessel struct {
+	MMSI     uint32
+	Name     string
+	CallSign string
+	ShipType int
+	LengthM  float64 // 0 if unknown
+	BeamM    float64 // 0 if unknown
+}
+
+// Store holds position reports per vessel, in time order once sorted, and
+// the latest static data of each vessel.
+type Store struct {
+	Tracks  map[uint32][]Report
+	Vessels map[uint32]*Vessel
+	sorted  bool
+}
+
+// NewStore returns an empty store.
+func NewStore() *Store {
+	return &Store{Tracks: make(map[uint32][]Report), Vessels: make(map[uint32]*Vessel)}
+}
+
+// AddReport records a position report. Reports without a valid position
+// or time are ignored.
+func (s *Store) AddReport(r Report) {
+	if r.MMSI == 0 || r.Time.IsZero() || math.Abs(r.Point.Lat) > 90 || math.Abs(r.Point.Lon) > 180 {
+		return
+	}
+	s.Tracks[r.MMSI] = append(s.Tracks[r.MMSI], r)
+	s.sorted = false
+}
+
+// AddVessel merges static data into what is known about a vessel; empty
+// fields leave earlier values in place.
+func (s *Store) AddVessel(v Vessel) {
+	if v.MMSI == 0 {
+		return
+	}
+	cur, ok := s.Vessels[v.MMSI]
+	if !ok {
+		cur = &Vessel{MMSI: v.MMSI}
+		s.Vessels[v.MMSI] = cur
+	}
+	if v.Name != "" {
+		cur.Name = v.Name
+	}
+	if v.CallSign != "" {
+		cur.CallSign = v.CallSign
+	}
+	if v.ShipType != 0 {
+		cur.ShipType = v.ShipType
+	}
+	if v.LengthM > 0 {
+		cur.LengthM = v.LengthM
+	}
+	if v.BeamM > 0 {
+		cur.BeamM = v.BeamM
+	}
+}
+
+// Vessel returns the static data of mmsi, or a record holding only the
+// MMSI if none was received.
+func (s *Store) Vessel(mmsi uint32) *Vessel {
+	if v, ok := s.Vessels[mmsi]; ok {
+		return v
+	}
+	return &Vessel{MMSI: mmsi}
+}
+
+// Len returns the number of vessels with position reports.
+func (s *Store) Len() int { return len(s.Tracks) }
+
+// sort orders every track by time and drops duplicate timestamps.
+func (s *Store) sort() {
+	if s.sorted {
+		return
+	}
+	for mmsi, track := range s.Tracks {
+		sort.SliceStable(track, func(i, j int) bool { return track[i].Time.Before(track[j].Time) })
+		out := track[:0]
+		for _, r := range track {
+			if len(out) > 0 && out[len(out)-1].Time.Equal(r.Time) {
+				out[len(out)-1] = r
+				continue
+			}
+			out = append(out, r)
+		}
+		s.Tracks[mmsi] = out
+	}
+	s.sorted = true
+}
+
+// LoadStats counts what Load read.
+type LoadStats struct {
+	Files     int
+	Reports   int // position reports stored
+	Static    int // static data messages or rows applied
+	Skipped   int // malformed lines, bad checksums, unsupported messages
+	Untimed   int // messages without a usable timestamp
+	Fragments int // multi-part messages left incomplete
+}
+
+// Load reads AIS files into a new store. A path that is a directory loads
+// every *.nmea, *.txt, *.log, *.ais and *.csv file in it. Files ending in
+// .csv are read as CSV exports, anything else as NMEA 0183.
+func Load(paths ...string) (*Store, LoadStats, error) {
+	s := NewStore()
+	var stats LoadStats
+	var errs []error
+	for _, path := range paths {
+		files := []string{path}
+		if st, err := os.Stat(path); err != nil {
+			return nil, stats, err
+		} else if st.IsDir() {
+			files = nil
+			for _, ext := range []string{"*.nmea", "*.txt", "*.log", "*.ais", "*.csv"} {
+				m, _ := filepath.Glob(filepath.Join(path, ext))
+				files = append(files, m...)
+			}
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			var err error
+			if strings.EqualFold(filepath.Ext(file), ".csv") {
+				err = s.readCSVFile(file, &stats)
+			} else {
+				err = s.readNMEAFile(file, &stats)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+				continue
+			}
+			stats.Files++
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, stats, err
+	}
+	s.sort()
+	return s, stats, nil
+}
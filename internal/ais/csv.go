@@ -0,0 +1,149 @@
+package ais
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// csvColumns maps normalized header names (lower case, without spaces,
+// underscores or dashes) to fields. They cover the MarineCadastre/NOAA,
+// Danish Maritime Authority and common aggregator exports.
+var csvColumns = map[string]string{
+	"mmsi":         "mmsi",
+	"basedatetime": "time",
+	"timestamp":    "time",
+	"time":         "time",
+	"datetime":     "time",
+	"#timestamp":   "time",
+	"lat":          "lat",
+	"latitude":     "lat",
+	"lon":          "lon",
+	"long":         "lon",
+	"longitude":    "lon",
+	"sog":          "sog",
+	"speed":        "sog",
+	"cog":          "cog",
+	"course":       "cog",
+	"heading":      "heading",
+	"vesselname":   "name",
+	"name":         "name",
+	"shipname":     "name",
+	"callsign":     "callsign",
+	"vesseltype":   "shiptype",
+	"shiptype":     "shiptype",
+	"length":       "length",
+	"lengthm":      "length",
+	"width":        "beam",
+	"beam":         "beam",
+}
+
+func normalizeHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(h)
+}
+
+// readCSVFile reads a CSV export with a header row. Rows need mmsi and
+// time; lat/lon add a position report and name, length, etc. add static
+// data.
+func (s *Store) readCSVFile(path string, stats *LoadStats) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return err
+	}
+	cols := make(map[string]int)
+	for i, h := range header {
+		if field := csvColumns[normalizeHeader(h)]; field != "" {
+			if _, dup := cols[field]; !dup {
+				cols[field] = i
+			}
+		}
+	}
+	if _, ok := cols["mmsi"]; !ok {
+		return errors.New("no MMSI column in header")
+	}
+
+	get := func(rec []string, field string) string {
+		if i, ok := cols[field]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	num := func(rec []string, field string) float64 {
+		v, err := strconv.ParseFloat(get(rec, field), 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return v
+	}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		mmsi, err := strconv.ParseUint(get(rec, "mmsi"), 10, 32)
+		if err != nil || mmsi == 0 {
+			stats.Skipped++
+			continue
+		}
+
+		v := Vessel{MMSI: uint32(mmsi), Name: get(rec, "name"), CallSign: get(rec, "callsign")}
+		if t, err := strconv.Atoi(get(rec, "shiptype")); err == nil {
+			v.ShipType = t
+		}
+		if l := num(rec, "length"); l > 0 {
+			v.LengthM = l
+		}
+		if b := num(rec, "beam"); b > 0 {
+			v.BeamM = b
+		}
+		if v.Name != "" || v.CallSign != "" || v.LengthM > 0 {
+			s.AddVessel(v)
+			stats.Static++
+		}
+
+		lat, lon := num(rec, "lat"), num(rec, "lon")
+		if math.IsNaN(lat) || math.IsNaN(lon) {
+			continue
+		}
+		t, err := parseTime(get(rec, "time"))
+		if err != nil {
+			stats.Untimed++
+			continue
+		}
+		rep := Report{MMSI: uint32(mmsi), Time: t, SOG: num(rec, "sog"), COG: num(rec, "cog"), Heading: num(rec, "heading")}
+		rep.Point.Lat, rep.Point.Lon = lat, lon
+		// Exports carry the AIS "not available" values through as numbers.
+		if rep.SOG >= 102.3 {
+			rep.SOG = math.NaN()
+		}
+		if rep.COG >= 360 {
+			rep.COG = math.NaN()
+		}
+		if rep.Heading >= 360 {
+			rep.Heading = math.NaN()
+		}
+		if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+			stats.Skipped++
+			continue
+		}
+		s.AddReport(rep)
+		stats.Reports++
+	}
+}
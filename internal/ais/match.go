@@ -0,0 +1,195 @@
+package ais
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/assign"
+	"github.com/clearclown/orbital-eye/internal/geo"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// Attribute keys set on vessel detections.
+const (
+	// AttrStatus is StatusMatched or StatusUnmatched.
+	AttrStatus     = "ais"
+	AttrMMSI       = "ais_mmsi"
+	AttrName       = "ais_name"
+	AttrLengthM    = "ais_length_m"
+	AttrDistanceM  = "ais_distance_m"
+	AttrPositionAt = "ais_position" // "interpolated" or "extrapolated"
+)
+
+// Values of AttrStatus. An unmatched vessel had no AIS track in the loaded
+// data that could explain it: it was not transmitting, or was outside the
+// data's coverage.
+const (
+	StatusMatched   = "matched"
+	StatusUnmatched = "unmatched"
+)
+
+// DefaultVesselClasses are the class-name substrings treated as vessels.
+var DefaultVesselClasses = []string{"boat", "ship", "vessel"}
+
+// MatchOptions configures Correlate.
+type MatchOptions struct {
+	// MaxGap is how far from the scene time a report may be to place a
+	// vessel; defaults to 10 minutes.
+	MaxGap time.Duration
+	// GeolocationM is the 1σ geolocation error of detections; defaults to
+	// 50 m.
+	GeolocationM float64
+	// MaxSigma rejects pairs whose position or length differ by more than
+	// this many standard deviations; defaults to 3.
+	MaxSigma float64
+	// GSD is the image pixel size in meters, used for the length error.
+	GSD float64
+	// LengthError is the relative 1σ error of detected lengths; defaults
+	// to 0.25.
+	LengthError float64
+	// Classes are the class-name substrings treated as vessels; defaults
+	// to DefaultVesselClasses.
+	Classes []string
+}
+
+func (o *MatchOptions) defaults() {
+	if o.MaxGap <= 0 {
+		o.MaxGap = 10 * time.Minute
+	}
+	if o.GeolocationM <= 0 {
+		o.GeolocationM = 50
+	}
+	if o.MaxSigma <= 0 {
+		o.MaxSigma = 3
+	}
+	if o.GSD <= 0 {
+		o.GSD = 10
+	}
+	if o.LengthError <= 0 {
+		o.LengthError = 0.25
+	}
+	if len(o.Classes) == 0 {
+		o.Classes = DefaultVesselClasses
+	}
+}
+
+// MatchStats summarizes a correlation.
+type MatchStats struct {
+	Vessels   int // vessel detections considered
+	Matched   int
+	Unmatched int
+	Unplaced  int // vessel detections without a position, left unlabeled
+	Tracks    int // AIS vessels placed at the scene time
+}
+
+// Correlate places AIS vessels at the scene time and pairs them one-to-one
+// with vessel detections: as many gated pairs as possible, and among those
+// the assignment (Hungarian algorithm) whose pairs best agree in position
+// and length overall. Every vessel detection with a position is labeled
+// matched, with the vessel's MMSI and name, or unmatched; those without
+// one are counted in Unplaced, since they cannot be told apart from
+// vessels with AIS off.
+func (s *Store) Correlate(dets []*pb.Detection, sceneTime time.Time, opts MatchOptions) MatchStats {
+	opts.defaults()
+	ests := s.At(sceneTime, opts.MaxGap)
+	stats := MatchStats{Tracks: len(ests)}
+
+	var vessels []*pb.Detection
+	for _, d := range dets {
+		if !isVessel(d.ClassName, opts.Classes) {
+			continue
+		}
+		if d.GeoCenter == nil {
+			stats.Unplaced++
+			continue
+		}
+		vessels = append(vessels, d)
+	}
+	stats.Vessels = len(vessels)
+
+	// Gated pairs cost their squared position and length residuals; pairs
+	// outside the gate are forbidden. Only vessels within some detection's
+	// gate take part, which keeps the problem small for regional feeds.
+	var cands []int
+	for j, e := range ests {
+		for _, d := range vessels {
+			if !math.IsInf(pairCost(d, e, opts), 1) {
+				cands = append(cands, j)
+				break
+			}
+		}
+	}
+	cost := make([][]float64, len(vessels))
+	for i, d := range vessels {
+		cost[i] = make([]float64, len(cands))
+		for k, j := range cands {
+			cost[i][k] = pairCost(d, ests[j], opts)
+		}
+	}
+	assigned := assign.Hungarian(cost)
+	for i, d := range vessels {
+		if k := assigned[i]; k >= 0 {
+			setMatch(d, ests[cands[k]])
+			stats.Matched++
+			continue
+		}
+		if d.Attributes == nil {
+			d.Attributes = make(map[string]string)
+		}
+		d.Attributes[AttrStatus] = StatusUnmatched
+		stats.Unmatched++
+	}
+	return stats
+}
+
+// pairCost is the squared Mahalanobis distance between a detection and a
+// placed vessel over position and, where both are known, length, or +Inf
+// if either lies outside the gate.
+func pairCost(d *pb.Detection, e Estimate, opts MatchOptions) float64 {
+	zd := distanceM(d, e) / math.Hypot(opts.GeolocationM, e.UncertaintyM)
+	if zd > opts.MaxSigma {
+		return math.Inf(1)
+	}
+	cost := zd * zd
+	if obs, ais := float64(d.EstimatedLengthM), e.Vessel.LengthM; obs > 0 && ais > 0 {
+		zl := (obs - ais) / math.Hypot(opts.GSD, opts.LengthError*ais)
+		if math.Abs(zl) > opts.MaxSigma {
+			return math.Inf(1)
+		}
+		cost += zl * zl
+	}
+	return cost
+}
+
+func distanceM(d *pb.Detection, e Estimate) float64 {
+	return geo.DistanceM(geo.Point{Lat: d.GeoCenter.Latitude, Lon: d.GeoCenter.Longitude}, e.Point)
+}
+
+func setMatch(d *pb.Detection, e Estimate) {
+	if d.Attributes == nil {
+		d.Attributes = make(map[string]string)
+	}
+	d.Attributes[AttrStatus] = StatusMatched
+	d.Attributes[AttrMMSI] = strconv.FormatUint(uint64(e.Vessel.MMSI), 10)
+	if e.Vessel.Name != "" {
+		d.Attributes[AttrName] = e.Vessel.Name
+	}
+	if e.Vessel.LengthM > 0 {
+		d.Attributes[AttrLengthM] = fmt.Sprintf("%.0f", e.Vessel.LengthM)
+	}
+	d.Attributes[AttrDistanceM] = fmt.Sprintf("%.0f", distanceM(d, e))
+	d.Attributes[AttrPositionAt] = e.Method
+}
+
+func isVessel(class string, classes []string) bool {
+	c := strings.ToLower(class)
+	for _, v := range classes {
+		if strings.Contains(c, v) {
+			return true
+		}
+	}
+	return false
+}
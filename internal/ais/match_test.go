@@ -0,0 +1,68 @@
+package ais
+
+import (
+	"testing"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+func detectionAt(class string, p geo.Point, lengthM float32) *pb.Detection {
+	return &pb.Detection{
+		ClassName:        class,
+		GeoCenter:        &pb.GeoPoint{Latitude: p.Lat, Longitude: p.Lon},
+		EstimatedLengthM: lengthM,
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	s := NewStore()
+	port := geo.Point{Lat: 1.25, Lon: 103.8}
+	x, y := port, geo.Destination(port, 90, 200)
+	s.AddReport(Report{MMSI: 100, Time: t0, Point: x, SOG: 0, COG: 0})
+	s.AddReport(Report{MMSI: 200, Time: t0, Point: y, SOG: 0, COG: 0})
+	s.AddVessel(Vessel{MMSI: 100, Name: "ALPHA", LengthM: 180})
+	s.AddVessel(Vessel{MMSI: 200, Name: "BRAVO", LengthM: 150})
+
+	// a is within the gate of both vessels, nearest x; b is within x's
+	// only. Pairing a with its nearest vessel would leave b unmatched.
+	a := detectionAt("ship", geo.Destination(port, 90, 20), 0)
+	b := detectionAt("ship", geo.Destination(port, 270, 60), 175)
+	dark := detectionAt("vessel", geo.Destination(port, 0, 5000), 0)
+	tooShort := detectionAt("boat", geo.Destination(port, 90, 205), 20)
+	unplaced := &pb.Detection{ClassName: "ship"}
+	plane := detectionAt("airplane", port, 0)
+
+	stats := s.Correlate([]*pb.Detection{a, b, dark, tooShort, unplaced, plane}, t0, MatchOptions{GSD: 10})
+	want := MatchStats{Vessels: 4, Matched: 2, Unmatched: 2, Unplaced: 1, Tracks: 2}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	for _, c := range []struct {
+		name string
+		d    *pb.Detection
+		mmsi string
+	}{{"a", a, "200"}, {"b", b, "100"}, {"dark", dark, ""}, {"tooShort", tooShort, ""}} {
+		status := StatusMatched
+		if c.mmsi == "" {
+			status = StatusUnmatched
+		}
+		if got := c.d.Attributes[AttrStatus]; got != status || c.d.Attributes[AttrMMSI] != c.mmsi {
+			t.Errorf("%s: %s %q, want %s %q", c.name, got, c.d.Attributes[AttrMMSI], status, c.mmsi)
+		}
+	}
+	if b.Attributes[AttrName] != "ALPHA" || b.Attributes[AttrDistanceM] != "60" || b.Attributes[AttrPositionAt] != "extrapolated" {
+		t.Errorf("b attributes %v", b.Attributes)
+	}
+	if unplaced.Attributes != nil || plane.Attributes != nil {
+		t.Errorf("unplaced or non-vessel detections were labeled: %v, %v", unplaced.Attributes, plane.Attributes)
+	}
+}
+
+func TestCorrelateNoTracks(t *testing.T) {
+	d := detectionAt("ship", geo.Point{Lat: 1, Lon: 1}, 0)
+	stats := NewStore().Correlate([]*pb.Detection{d}, t0, MatchOptions{})
+	if stats != (MatchStats{Vessels: 1, Unmatched: 1}) || d.Attributes[AttrStatus] != StatusUnmatched {
+		t.Errorf("stats %+v, status %q", stats, d.Attributes[AttrStatus])
+	}
+}
@@ -0,0 +1,349 @@
+package ais
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readNMEAFile reads an NMEA 0183 log of !AIVDM/!AIVDO sentences. Each
+// sentence needs a receive time, taken from an IEC 61162-450 tag block
+// ("\c:1705287600*hh\!AIVDM,..."), a leading timestamp
+// ("2024-01-15T03:00:00Z !AIVDM,..."), or a trailing Unix time after the
+// checksum ("!AIVDM,...*hh,1705287600"), as written by common receivers
+// and archives.
+func (s *Store) readNMEAFile(path string, stats *LoadStats) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d := &nmeaDecoder{store: s, stats: stats, parts: make(map[string]*fragments)}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		d.line(strings.TrimSpace(sc.Text()))
+	}
+	stats.Fragments += len(d.parts)
+	return sc.Err()
+}
+
+type fragments struct {
+	total   int
+	payload []string
+	fill    int
+	t       time.Time
+}
+
+type nmeaDecoder struct {
+	store *Store
+	stats *LoadStats
+	parts map[string]*fragments
+}
+
+func (d *nmeaDecoder) line(line string) {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	var t time.Time
+	// Tag block.
+	if strings.HasPrefix(line, `\`) {
+		end := strings.Index(line[1:], `\`)
+		if end < 0 {
+			d.stats.Skipped++
+			return
+		}
+		t = tagBlockTime(line[1 : end+1])
+		line = line[end+2:]
+	}
+	// Leading timestamp.
+	start := strings.IndexAny(line, "!$")
+	if start < 0 {
+		d.stats.Skipped++
+		return
+	}
+	if prefix := strings.Trim(line[:start], " \t,;"); prefix != "" && t.IsZero() {
+		t, _ = parseTime(prefix)
+	}
+	sentence := line[start:]
+	// Trailing Unix time after the checksum.
+	if star := strings.IndexByte(sentence, '*'); star >= 0 && star+3 < len(sentence) {
+		if t.IsZero() {
+			t, _ = parseTime(strings.Trim(sentence[star+3:], " ,\t"))
+		}
+		sentence = sentence[:star+3]
+	}
+
+	fields, ok := checkSentence(sentence)
+	if !ok {
+		d.stats.Skipped++
+		return
+	}
+	if len(fields) < 7 || !(strings.HasSuffix(fields[0], "VDM") || strings.HasSuffix(fields[0], "VDO")) {
+		d.stats.Skipped++
+		return
+	}
+	total, err1 := strconv.Atoi(fields[1])
+	num, err2 := strconv.Atoi(fields[2])
+	fill, _ := strconv.Atoi(fields[6])
+	if err1 != nil || err2 != nil || total < 1 || num < 1 || num > total {
+		d.stats.Skipped++
+		return
+	}
+
+	payload := fields[5]
+	if total > 1 {
+		key := fields[3] + "/" + fields[4]
+		p, ok := d.parts[key]
+		if num == 1 || !ok {
+			p = &fragments{total: total, t: t}
+			d.parts[key] = p
+		}
+		if len(p.payload) != num-1 || p.total != total {
+			delete(d.parts, key) // out of order
+			d.stats.Skipped++
+			return
+		}
+		p.payload = append(p.payload, payload)
+		p.fill = fill
+		if num < total {
+			return
+		}
+		delete(d.parts, key)
+		payload, t = strings.Join(p.payload, ""), p.t
+	}
+
+	bits, ok := unarmor(payload, fill)
+	if !ok {
+		d.stats.Skipped++
+		return
+	}
+	d.message(bits, t)
+}
+
+// message decodes one complete AIS message.
+func (d *nmeaDecoder) message(b bitReader, t time.Time) {
+	typ := b.uint(0, 6)
+	mmsi := uint32(b.uint(8, 30))
+	switch typ {
+	case 1, 2, 3:
+		if t.IsZero() {
+			d.stats.Untimed++
+			return
+		}
+		if b.len() < 137 {
+			d.stats.Skipped++
+			return
+		}
+		d.report(mmsi, t, b.uint(50, 10), b.int(61, 28), b.int(89, 27), b.uint(116, 12), b.uint(128, 9))
+	case 18, 19:
+		if t.IsZero() {
+			d.stats.Untimed++
+			return
+		}
+		if b.len() < 133 {
+			d.stats.Skipped++
+			return
+		}
+		d.report(mmsi, t, b.uint(46, 10), b.int(57, 28), b.int(85, 27), b.uint(112, 12), b.uint(124, 9))
+		if typ == 19 && b.len() >= 301 {
+			d.store.AddVessel(Vessel{
+				MMSI:     mmsi,
+				Name:     b.str(143, 120),
+				ShipType: int(b.uint(263, 8)),
+				LengthM:  float64(b.uint(271, 9) + b.uint(280, 9)),
+				BeamM:    float64(b.uint(289, 6) + b.uint(295, 6)),
+			})
+			d.stats.Static++
+		}
+	case 5:
+		if b.len() < 270 {
+			d.stats.Skipped++
+			return
+		}
+		d.store.AddVessel(Vessel{
+			MMSI:     mmsi,
+			CallSign: b.str(70, 42),
+			Name:     b.str(112, 120),
+			ShipType: int(b.uint(232, 8)),
+			LengthM:  float64(b.uint(240, 9) + b.uint(249, 9)),
+			BeamM:    float64(b.uint(258, 6) + b.uint(264, 6)),
+		})
+		d.stats.Static++
+	case 24:
+		switch part := b.uint(38, 2); {
+		case part == 0 && b.len() >= 160:
+			d.store.AddVessel(Vessel{MMSI: mmsi, Name: b.str(40, 120)})
+		case part == 1 && b.len() >= 162:
+			d.store.AddVessel(Vessel{
+				MMSI:     mmsi,
+				ShipType: int(b.uint(40, 8)),
+				CallSign: b.str(90, 42),
+				LengthM:  float64(b.uint(132, 9) + b.uint(141, 9)),
+				BeamM:    float64(b.uint(150, 6) + b.uint(156, 6)),
+			})
+		default:
+			d.stats.Skipped++
+			return
+		}
+		d.stats.Static++
+	default:
+		d.stats.Skipped++
+	}
+}
+
+// report stores a position report from raw field values, applying the
+// "not available" sentinels of ITU-R M.1371.
+func (d *nmeaDecoder) report(mmsi uint32, t time.Time, sog uint64, lon, lat int64, cog, hdg uint64) {
+	r := Report{
+		MMSI:    mmsi,
+		Time:    t,
+		SOG:     math.NaN(),
+		COG:     math.NaN(),
+		Heading: math.NaN(),
+	}
+	r.Point.Lon, r.Point.Lat = float64(lon)/600000, float64(lat)/600000
+	if math.Abs(r.Point.Lon) > 180 || math.Abs(r.Point.Lat) > 90 {
+		d.stats.Skipped++ // 181/91: position not available
+		return
+	}
+	if sog < 1023 {
+		r.SOG = float64(sog) / 10
+	}
+	if cog < 3600 {
+		r.COG = float64(cog) / 10
+	}
+	if hdg < 360 {
+		r.Heading = float64(hdg)
+	}
+	d.store.AddReport(r)
+	d.stats.Reports++
+}
+
+// checkSentence verifies the checksum, if present, and splits the fields.
+func checkSentence(s string) ([]string, bool) {
+	if len(s) < 2 {
+		return nil, false
+	}
+	body := s[1:]
+	if star := strings.IndexByte(body, '*'); star >= 0 {
+		want, err := strconv.ParseUint(body[star+1:], 16, 8)
+		if err != nil {
+			return nil, false
+		}
+		body = body[:star]
+		var sum byte
+		for i := 0; i < len(body); i++ {
+			sum ^= body[i]
+		}
+		if sum != byte(want) {
+			return nil, false
+		}
+	}
+	return strings.Split(body, ","), true
+}
+
+// tagBlockTime returns the c: (source time) parameter of a tag block.
+func tagBlockTime(block string) time.Time {
+	if star := strings.IndexByte(block, '*'); star >= 0 {
+		block = block[:star]
+	}
+	for _, kv := range strings.Split(block, ",") {
+		if v, ok := strings.CutPrefix(kv, "c:"); ok {
+			t, _ := parseTime(v)
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// timeLayouts are the timestamp formats accepted in logs and CSV files,
+// interpreted as UTC when they carry no zone.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999",
+	"2006/01/02 15:04:05",
+	"02/01/2006 15:04:05", // Danish Maritime Authority
+}
+
+// parseTime parses a timestamp in one of timeLayouts or as Unix seconds
+// or milliseconds.
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		if v > 1e11 {
+			v /= 1000 // milliseconds
+		}
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+// bitReader reads fields from a de-armored AIS payload.
+type bitReader []byte // one bit per byte
+
+// unarmor decodes the 6-bit ASCII payload armoring.
+func unarmor(payload string, fill int) (bitReader, bool) {
+	bits := make(bitReader, 0, len(payload)*6)
+	for i := 0; i < len(payload); i++ {
+		c := int(payload[i]) - 48
+		if c < 0 || c > 71 || (c > 39 && c < 48) {
+			return nil, false
+		}
+		if c > 40 {
+			c -= 8
+		}
+		for j := 5; j >= 0; j-- {
+			bits = append(bits, byte(c>>j)&1)
+		}
+	}
+	if fill < 0 || fill > 5 || fill > len(bits) {
+		return nil, false
+	}
+	return bits[:len(bits)-fill], len(bits) >= 38
+}
+
+func (b bitReader) len() int { return len(b) }
+
+func (b bitReader) uint(start, n int) uint64 {
+	var v uint64
+	for i := start; i < start+n; i++ {
+		v <<= 1
+		if i < len(b) {
+			v |= uint64(b[i])
+		}
+	}
+	return v
+}
+
+func (b bitReader) int(start, n int) int64 {
+	v := b.uint(start, n)
+	if v&(1<<(n-1)) != 0 {
+		return int64(v) - 1<<n
+	}
+	return int64(v)
+}
+
+// sixbitASCII is the AIS 6-bit character set.
+const sixbitASCII = "@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_ !\"#$%&'()*+,-./0123456789:;<=>?"
+
+func (b bitReader) str(start, n int) string {
+	var sb strings.Builder
+	for i := start; i+6 <= start+n && i+6 <= len(b); i += 6 {
+		sb.WriteByte(sixbitASCII[b.uint(i, 6)])
+	}
+	return strings.TrimSpace(strings.TrimRight(sb.String(), "@ "))
+}
@@ -0,0 +1,91 @@
+package ais
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testdata/aivdm.nmea holds messages recorded from shore stations, with
+// their published decodes, behind IEC 61162-450 tag blocks.
+func TestLoadNMEASample(t *testing.T) {
+	s, stats, err := Load("testdata/aivdm.nmea")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LoadStats{Files: 1, Reports: 3, Static: 4, Skipped: 1, Untimed: 1, Fragments: 1}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	reports := []struct {
+		mmsi     uint32
+		unix     int64
+		lat, lon float64
+		sog, cog float64
+	}{
+		{227006760, 1705287600, 49.475577, 0.131380, 0, 36.7},      // type 1
+		{338088483, 1705287605, 43.115558, -70.811197, 0, 171.6},   // type 18
+		{367059850, 1705287612, 29.543695, -88.810392, 8.7, 335.9}, // type 19
+	}
+	for _, r := range reports {
+		track := s.Tracks[r.mmsi]
+		if len(track) != 1 {
+			t.Errorf("%d: %d reports, want 1", r.mmsi, len(track))
+			continue
+		}
+		got := track[0]
+		if !got.Time.Equal(time.Unix(r.unix, 0)) {
+			t.Errorf("%d: time %v, want the tag block's %v", r.mmsi, got.Time, time.Unix(r.unix, 0).UTC())
+		}
+		if math.Abs(got.Point.Lat-r.lat) > 1e-6 || math.Abs(got.Point.Lon-r.lon) > 1e-6 {
+			t.Errorf("%d: position %v, want %.6f, %.6f", r.mmsi, got.Point, r.lat, r.lon)
+		}
+		if got.SOG != r.sog || got.COG != r.cog || !math.IsNaN(got.Heading) {
+			t.Errorf("%d: SOG %v COG %v heading %v, want %v %v NaN", r.mmsi, got.SOG, got.COG, got.Heading, r.sog, r.cog)
+		}
+	}
+
+	vessels := []Vessel{
+		{MMSI: 351759000, Name: "EVER DIADEM", CallSign: "3FOF8", ShipType: 70, LengthM: 295, BeamM: 32}, // type 5, two parts
+		{MMSI: 367059850, Name: "CAPT.J.RIMES", ShipType: 70, LengthM: 26, BeamM: 8},                     // type 19
+		{MMSI: 271041815, Name: "PROGUY", CallSign: "TC6163", ShipType: 60, LengthM: 15, BeamM: 5},       // type 24 A and B
+	}
+	for _, v := range vessels {
+		if got := s.Vessels[v.MMSI]; got == nil || *got != v {
+			t.Errorf("vessel %d = %+v, want %+v", v.MMSI, got, v)
+		}
+	}
+}
+
+func TestNMEATimestamps(t *testing.T) {
+	const sentence = "!AIVDM,1,1,,A,13HOI:0P0000VOHLCnHQKwvL05Ip,0*23"
+	want := time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC)
+	for _, line := range []string{
+		`\s:r003669945,c:1705287600*74\` + sentence,
+		`\c:1705287600000*xx\` + sentence, // milliseconds; tag checksum not checked
+		"2024-01-15T03:00:00Z " + sentence,
+		"2024-01-15 03:00:00," + sentence,
+		sentence + ",1705287600",
+	} {
+		s := NewStore()
+		var stats LoadStats
+		d := &nmeaDecoder{store: s, stats: &stats, parts: make(map[string]*fragments)}
+		d.line(line)
+		track := s.Tracks[227006760]
+		if len(track) != 1 || !track[0].Time.Equal(want) {
+			t.Errorf("%q: reports %v, want one at %v (stats %+v)", line, track, want, stats)
+		}
+	}
+}
+
+func TestNMEAFragmentsOutOfOrder(t *testing.T) {
+	s := NewStore()
+	var stats LoadStats
+	d := &nmeaDecoder{store: s, stats: &stats, parts: make(map[string]*fragments)}
+	d.line("!AIVDM,2,2,1,A,88888888880,2*25")
+	d.line("!AIVDM,2,1,1,A,55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp8,0*1C")
+	if len(s.Vessels) != 0 || stats.Skipped != 1 {
+		t.Errorf("vessels %v, stats %+v: want the second part alone skipped and nothing decoded", s.Vessels, stats)
+	}
+}
@@ -0,0 +1,110 @@
+package ais
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+const (
+	knotToMS = 1852.0 / 3600
+	// reportErrorM is the typical GNSS error of a reported position.
+	reportErrorM = 10.0
+)
+
+// Estimate is a vessel's estimated position at a given time.
+type Estimate struct {
+	Vessel *Vessel
+	Point  geo.Point
+	// UncertaintyM is a rough 1σ radius of the estimate: the GNSS error
+	// plus how far the vessel could have strayed from the assumed path.
+	UncertaintyM float64
+	// Method is "interpolated" between two reports or "extrapolated"
+	// (dead-reckoned) from the nearest one.
+	Method string
+	// Gap is the time to the nearest report.
+	Gap time.Duration
+}
+
+// At estimates the position at t of every vessel with a report within
+// maxGap of t. Between reports the position is interpolated along the
+// geodesic; beyond the last (or before the first) report it is
+// dead-reckoned from speed and course, or held if the vessel was not
+// reported moving.
+func (s *Store) At(t time.Time, maxGap time.Duration) []Estimate {
+	s.sort()
+	var out []Estimate
+	for mmsi, track := range s.Tracks {
+		i := sort.Search(len(track), func(i int) bool { return track[i].Time.After(t) })
+		var before, after *Report
+		if i > 0 && t.Sub(track[i-1].Time) <= maxGap {
+			before = &track[i-1]
+		}
+		if i < len(track) && track[i].Time.Sub(t) <= maxGap {
+			after = &track[i]
+		}
+
+		e := Estimate{Vessel: s.Vessel(mmsi)}
+		switch {
+		case before != nil && after != nil:
+			e.Point, e.UncertaintyM = interpolate(*before, *after, t)
+			e.Method = "interpolated"
+			e.Gap = min(t.Sub(before.Time), after.Time.Sub(t))
+		case before != nil:
+			e.Point, e.UncertaintyM = deadReckon(*before, t.Sub(before.Time))
+			e.Method = "extrapolated"
+			e.Gap = t.Sub(before.Time)
+		case after != nil:
+			e.Point, e.UncertaintyM = deadReckon(*after, -after.Time.Sub(t))
+			e.Method = "extrapolated"
+			e.Gap = after.Time.Sub(t)
+		default:
+			continue
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Vessel.MMSI < out[j].Vessel.MMSI })
+	return out
+}
+
+// interpolate places the vessel on the geodesic between two reports in
+// proportion to elapsed time.
+func interpolate(a, b Report, t time.Time) (geo.Point, float64) {
+	span := b.Time.Sub(a.Time).Seconds()
+	if span <= 0 {
+		return a.Point, reportErrorM
+	}
+	f := t.Sub(a.Time).Seconds() / span
+	g := geo.Inverse(a.Point, b.Point)
+	p := geo.Destination(a.Point, g.InitialBearing, f*g.DistanceM)
+	// A vessel may have turned between reports: allow a share of the
+	// distance it covers from the nearer report, at the faster of its
+	// reported and its average speed.
+	speed := g.DistanceM / span
+	for _, r := range []Report{a, b} {
+		if !math.IsNaN(r.SOG) {
+			speed = math.Max(speed, r.SOG*knotToMS)
+		}
+	}
+	nearest := math.Min(f, 1-f) * span
+	return p, reportErrorM + 0.1*speed*nearest
+}
+
+// deadReckon advances r by dt (negative to go back) along its course. The
+// uncertainty grows with distance run to cover course and speed changes.
+func deadReckon(r Report, dt time.Duration) (geo.Point, float64) {
+	sec := math.Abs(dt.Seconds())
+	if math.IsNaN(r.SOG) || math.IsNaN(r.COG) || r.SOG < 0.5 {
+		// Moored, anchored or unknown: hold the position, allowing for
+		// swinging at anchor and slow drift.
+		return r.Point, reportErrorM + 50 + 0.05*sec
+	}
+	dist := r.SOG * knotToMS * sec
+	course := r.COG
+	if dt < 0 {
+		course += 180
+	}
+	return geo.Destination(r.Point, course, dist), reportErrorM + 0.3*dist
+}
@@ -0,0 +1,85 @@
+package ais
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+var t0 = time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC)
+
+func TestInterpolate(t *testing.T) {
+	a := Report{MMSI: 1, Time: t0, Point: geo.Point{Lat: 35, Lon: 139.7}, SOG: 12, COG: 45}
+	b := Report{MMSI: 1, Time: t0.Add(10 * time.Minute), Point: geo.Destination(a.Point, 45, 3700), SOG: 12, COG: 45}
+
+	p, unc := interpolate(a, b, t0.Add(4*time.Minute))
+	if d := geo.DistanceM(a.Point, p); math.Abs(d-0.4*3700) > 0.01 {
+		t.Errorf("%.2f m from the first report, want %.2f m", d, 0.4*3700)
+	}
+	if d := geo.DistanceM(p, b.Point); math.Abs(d-0.6*3700) > 0.01 {
+		t.Errorf("%.2f m from the second report, want %.2f m", d, 0.6*3700)
+	}
+	// 12 kn beats the average 6.17 m/s; the nearer report is 240 s away.
+	if want := reportErrorM + 0.1*12*knotToMS*240; math.Abs(unc-want) > 1e-9 {
+		t.Errorf("uncertainty %.3f m, want %.3f m", unc, want)
+	}
+
+	if p, unc := interpolate(a, b, t0); geo.DistanceM(p, a.Point) > 1e-6 || unc != reportErrorM {
+		t.Errorf("at the first report: %v ± %.1f m, want %v ± %.1f m", p, unc, a.Point, reportErrorM)
+	}
+	if p, unc := interpolate(a, a, t0); p != a.Point || unc != reportErrorM {
+		t.Errorf("zero span: %v ± %.1f m, want %v ± %.1f m", p, unc, a.Point, reportErrorM)
+	}
+}
+
+func TestDeadReckon(t *testing.T) {
+	r := Report{MMSI: 1, Time: t0, Point: geo.Point{Lat: 10, Lon: 100}, SOG: 10, COG: 90, Heading: math.NaN()}
+	run := 10 * knotToMS * 600
+
+	p, unc := deadReckon(r, 10*time.Minute)
+	if want := geo.Destination(r.Point, 90, run); geo.DistanceM(p, want) > 1e-6 {
+		t.Errorf("forward: %v, want %v", p, want)
+	}
+	if want := reportErrorM + 0.3*run; math.Abs(unc-want) > 1e-9 {
+		t.Errorf("forward uncertainty %.3f m, want %.3f m", unc, want)
+	}
+	p, _ = deadReckon(r, -10*time.Minute)
+	if want := geo.Destination(r.Point, 270, run); geo.DistanceM(p, want) > 1e-6 {
+		t.Errorf("backward: %v, want %v", p, want)
+	}
+
+	for _, held := range []Report{
+		{Point: r.Point, SOG: 0.2, COG: 90},
+		{Point: r.Point, SOG: math.NaN(), COG: 90},
+		{Point: r.Point, SOG: 10, COG: math.NaN()},
+	} {
+		p, unc := deadReckon(held, 10*time.Minute)
+		if p != r.Point || unc != reportErrorM+50+0.05*600 {
+			t.Errorf("SOG %v COG %v: %v ± %.1f m, want the position held", held.SOG, held.COG, p, unc)
+		}
+	}
+}
+
+func TestAt(t *testing.T) {
+	s := NewStore()
+	p := geo.Point{Lat: 51.9, Lon: 4.1}
+	s.AddReport(Report{MMSI: 1, Time: t0.Add(-time.Minute), Point: p, SOG: 10, COG: 0})
+	s.AddReport(Report{MMSI: 1, Time: t0.Add(time.Minute), Point: geo.Destination(p, 0, 600), SOG: 10, COG: 0})
+	s.AddReport(Report{MMSI: 2, Time: t0.Add(-5 * time.Minute), Point: p, SOG: 10, COG: 0})
+	s.AddReport(Report{MMSI: 3, Time: t0.Add(-time.Hour), Point: p, SOG: 10, COG: 0})
+
+	ests := s.At(t0, 10*time.Minute)
+	if len(ests) != 2 {
+		t.Fatalf("%d estimates, want 2 (vessel 3 is too old)", len(ests))
+	}
+	if e := ests[0]; e.Vessel.MMSI != 1 || e.Method != "interpolated" || e.Gap != time.Minute ||
+		math.Abs(geo.DistanceM(p, e.Point)-300) > 0.01 {
+		t.Errorf("vessel 1: %+v, want interpolated 300 m north", e)
+	}
+	if e := ests[1]; e.Vessel.MMSI != 2 || e.Method != "extrapolated" || e.Gap != 5*time.Minute ||
+		math.Abs(geo.DistanceM(p, e.Point)-10*knotToMS*300) > 0.01 {
+		t.Errorf("vessel 2: %+v, want dead-reckoned %.0f m north", e, 10*knotToMS*300)
+	}
+}
@@ -0,0 +1,215 @@
+package annotate
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/clearclown/orbital-eye/internal/raster"
+	"github.com/clearclown/orbital-eye/internal/report"
+)
+
+// Options controls quicklook and chip rendering.
+type Options struct {
+	MaxOverviewSize int     // longest side of the overview in pixels
+	ChipPadding     float64 // context around a bbox as a fraction of its size
+	MinChipSize     int     // chips are upscaled to at least this many pixels
+	GSD             float64 // overrides the scene GSD when > 0
+}
+
+// DefaultOptions returns sensible rendering defaults.
+func DefaultOptions() Options {
+	return Options{
+		MaxOverviewSize: 2048,
+		ChipPadding:     0.5,
+		MinChipSize:     256,
+	}
+}
+
+// Chip is a cropped, annotated view of a single detection.
+type Chip struct {
+	Index     int // 1-based detection index, matching text reports
+	Detection report.Detection
+	Window    raster.Window // source pixels covered by the chip
+	Image     *image.RGBA
+}
+
+// Result holds the rendered imagery for a detection result.
+type Result struct {
+	Overview *image.RGBA
+	Chips    []Chip
+	// Skipped are the 1-based indices of detections that lie entirely
+	// outside the scene and so have no chip.
+	Skipped []int
+}
+
+// ErrOutsideScene is returned by CutChip for a detection that does not
+// overlap the scene at all.
+var ErrOutsideScene = errors.New("bbox outside scene bounds")
+
+// Render draws all detections on a downsampled overview of the scene and
+// cuts one annotated chip per detection. Chips of detections partly
+// outside the scene are clipped to it; detections wholly outside are
+// listed in Skipped.
+func Render(scene *Scene, dets []report.Detection, opts Options) (*Result, error) {
+	overview, err := Overview(scene, dets, opts)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Overview: overview}
+	for i, d := range dets {
+		chip, err := CutChip(scene, d, opts)
+		if errors.Is(err, ErrOutsideScene) {
+			res.Skipped = append(res.Skipped, i+1)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("chip %d: %w", i+1, err)
+		}
+		chip.Index = i + 1
+		res.Chips = append(res.Chips, *chip)
+	}
+	return res, nil
+}
+
+// Overview renders the full scene, downsampled to fit MaxOverviewSize,
+// with every detection outlined and labeled.
+func Overview(scene *Scene, dets []report.Detection, opts Options) (*image.RGBA, error) {
+	step := 1
+	if opts.MaxOverviewSize > 0 {
+		longest := scene.Width
+		if scene.Height > longest {
+			longest = scene.Height
+		}
+		step = (longest + opts.MaxOverviewSize - 1) / opts.MaxOverviewSize
+	}
+
+	img, err := scene.read(raster.Window{Width: scene.Width, Height: scene.Height}, step)
+	if err != nil {
+		return nil, fmt.Errorf("read overview: %w", err)
+	}
+
+	s := 1 / float64(step)
+	for _, d := range dets {
+		drawDetection(img, d, 0, 0, s)
+	}
+	drawScaleBar(img, gsdOf(scene, opts)*float64(step))
+	return img, nil
+}
+
+// CutChip crops the scene around a detection, upscales small crops for
+// legibility and annotates the result.
+func CutChip(scene *Scene, d report.Detection, opts Options) (*Chip, error) {
+	w := float64(d.Bbox.XMax - d.Bbox.XMin)
+	h := float64(d.Bbox.YMax - d.Bbox.YMin)
+	pad := math.Max(math.Max(w, h)*opts.ChipPadding, 16)
+
+	win := raster.Window{
+		X:      int(math.Floor(float64(d.Bbox.XMin) - pad)),
+		Y:      int(math.Floor(float64(d.Bbox.YMin) - pad)),
+		Width:  int(math.Ceil(w + 2*pad)),
+		Height: int(math.Ceil(h + 2*pad)),
+	}
+	win = win.Intersect(scene.Width, scene.Height)
+	if win.Empty() {
+		return nil, ErrOutsideScene
+	}
+
+	crop, err := scene.read(win, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	zoom := 1
+	if opts.MinChipSize > 0 {
+		shortest := win.Width
+		if win.Height < shortest {
+			shortest = win.Height
+		}
+		zoom = (opts.MinChipSize + shortest - 1) / shortest
+	}
+	if zoom > 1 {
+		crop = upscale(crop, zoom)
+	}
+
+	drawDetection(crop, d, float64(win.X), float64(win.Y), float64(zoom))
+	drawScaleBar(crop, gsdOf(scene, opts)/float64(zoom))
+	return &Chip{Detection: d, Window: win, Image: crop}, nil
+}
+
+// WriteDir saves the overview and chips as PNG files and returns the paths
+// written, overview first.
+func (r *Result) WriteDir(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	p := filepath.Join(dir, "overview.png")
+	if err := WritePNG(p, r.Overview); err != nil {
+		return nil, err
+	}
+	paths = append(paths, p)
+	for _, c := range r.Chips {
+		p := filepath.Join(dir, ChipFilename(c))
+		if err := WritePNG(p, c.Image); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// ChipFilename returns the file name WriteDir uses for a chip.
+func ChipFilename(c Chip) string {
+	return fmt.Sprintf("chip_%03d_%s.png", c.Index, sanitize(c.Detection.ClassName))
+}
+
+// WritePNG encodes img to path.
+func WritePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("encode %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func gsdOf(scene *Scene, opts Options) float64 {
+	if opts.GSD > 0 {
+		return opts.GSD
+	}
+	return scene.GSD
+}
+
+func upscale(src *image.RGBA, k int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()*k, b.Dy()*k))
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			si := src.PixOffset(b.Min.X+x/k, b.Min.Y+y/k)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+func sanitize(s string) string {
+	out := []byte(s)
+	for i, c := range out {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			out[i] = '_'
+		}
+	}
+	if len(out) == 0 {
+		return "object"
+	}
+	return string(out)
+}
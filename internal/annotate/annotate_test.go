@@ -0,0 +1,30 @@
+package annotate
+
+import (
+	"image"
+	"testing"
+
+	"github.com/clearclown/orbital-eye/internal/report"
+)
+
+func TestRenderSkipsDetectionsOutsideScene(t *testing.T) {
+	scene := NewScene(image.NewGray(image.Rect(0, 0, 200, 100)), 10)
+	dets := []report.Detection{
+		{ClassName: "ship", Bbox: report.BBox{XMin: 20, YMin: 20, XMax: 40, YMax: 30}},
+		{ClassName: "ship", Bbox: report.BBox{XMin: 500, YMin: 20, XMax: 520, YMax: 30}},
+		{ClassName: "ship", Bbox: report.BBox{XMin: 190, YMin: 90, XMax: 230, YMax: 110}}, // partly outside
+	}
+	res, err := Render(scene, dets, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Chips) != 2 || res.Chips[0].Index != 1 || res.Chips[1].Index != 3 {
+		t.Fatalf("chips %+v, want detections 1 and 3", res.Chips)
+	}
+	if len(res.Skipped) != 1 || res.Skipped[0] != 2 {
+		t.Errorf("Skipped = %v, want [2]", res.Skipped)
+	}
+	if w := res.Chips[1].Window; w.X+w.Width > 200 || w.Y+w.Height > 100 {
+		t.Errorf("chip 3 window %+v extends past the scene", w)
+	}
+}
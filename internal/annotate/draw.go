@@ -0,0 +1,158 @@
+package annotate
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/clearclown/orbital-eye/internal/report"
+)
+
+var palette = []color.RGBA{
+	{230, 25, 75, 255},   // red
+	{60, 180, 75, 255},   // green
+	{255, 225, 25, 255},  // yellow
+	{0, 130, 200, 255},   // blue
+	{245, 130, 48, 255},  // orange
+	{145, 30, 180, 255},  // purple
+	{70, 240, 240, 255},  // cyan
+	{240, 50, 230, 255},  // magenta
+	{210, 245, 60, 255},  // lime
+	{250, 190, 212, 255}, // pink
+}
+
+var (
+	labelFace = basicfont.Face7x13
+	black     = color.RGBA{0, 0, 0, 255}
+	white     = color.RGBA{255, 255, 255, 255}
+)
+
+// ClassColor returns a stable outline color for a class name.
+func ClassColor(class string) color.RGBA {
+	h := fnv.New32a()
+	h.Write([]byte(class))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// drawDetection outlines a detection and labels it with class and
+// confidence. (offX, offY) is the source pixel at the image origin and
+// scale the number of image pixels per source pixel.
+func drawDetection(img *image.RGBA, d report.Detection, offX, offY, scale float64) {
+	r := image.Rect(
+		int(math.Round((float64(d.Bbox.XMin)-offX)*scale)),
+		int(math.Round((float64(d.Bbox.YMin)-offY)*scale)),
+		int(math.Round((float64(d.Bbox.XMax)-offX)*scale)),
+		int(math.Round((float64(d.Bbox.YMax)-offY)*scale)),
+	)
+	c := ClassColor(d.ClassName)
+	strokeRect(img, r, 2, c)
+	drawLabel(img, fmt.Sprintf("%s %.0f%%", d.ClassName, d.Confidence*100), r.Min.X, r.Min.Y, c)
+}
+
+func strokeRect(img *image.RGBA, r image.Rectangle, width int, c color.RGBA) {
+	if r.Dx() < 2*width || r.Dy() < 2*width {
+		r = image.Rect(r.Min.X-width, r.Min.Y-width, r.Max.X+width, r.Max.Y+width)
+	}
+	u := image.NewUniform(c)
+	for _, side := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width),
+		image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y),
+		image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(img, side.Intersect(img.Rect), u, image.Point{}, draw.Src)
+	}
+}
+
+// drawLabel draws text on a filled background just above (x, y), or
+// inside the image if there is no room above.
+func drawLabel(img *image.RGBA, text string, x, y int, bg color.RGBA) {
+	const padX, padY = 3, 2
+	m := labelFace.Metrics()
+	tw := font.MeasureString(labelFace, text).Ceil()
+	th := (m.Ascent + m.Descent).Ceil()
+
+	box := image.Rect(x, y-th-2*padY, x+tw+2*padX, y)
+	if box.Min.Y < img.Rect.Min.Y {
+		box = box.Add(image.Pt(0, img.Rect.Min.Y-box.Min.Y))
+	}
+	if box.Max.X > img.Rect.Max.X {
+		box = box.Sub(image.Pt(box.Max.X-img.Rect.Max.X, 0))
+	}
+	draw.Draw(img, box.Intersect(img.Rect), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	fg := black
+	if luminance(bg) < 128 {
+		fg = white
+	}
+	drawText(img, text, box.Min.X+padX, box.Min.Y+padY+m.Ascent.Ceil(), fg)
+}
+
+func drawText(img *image.RGBA, text string, x, baseline int, c color.RGBA) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: labelFace,
+		Dot:  fixed.P(x, baseline),
+	}
+	d.DrawString(text)
+}
+
+func luminance(c color.RGBA) float64 {
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
+
+// drawScaleBar draws a bar of a round ground length in the bottom-left
+// corner. metersPerPixel is the GSD of img; nothing is drawn if unknown.
+func drawScaleBar(img *image.RGBA, metersPerPixel float64) {
+	if metersPerPixel <= 0 {
+		return
+	}
+	w := img.Rect.Dx()
+	meters := niceLength(float64(w) / 4 * metersPerPixel)
+	px := int(math.Round(meters / metersPerPixel))
+	if px < 4 {
+		return
+	}
+
+	const margin, height = 8, 5
+	x0 := img.Rect.Min.X + margin
+	y1 := img.Rect.Max.Y - margin
+	label := formatLength(meters)
+	tw := font.MeasureString(labelFace, label).Ceil()
+	th := (labelFace.Metrics().Ascent + labelFace.Metrics().Descent).Ceil()
+
+	bg := image.Rect(x0-4, y1-height-th-8, x0+max(px, tw)+4, y1+4).Intersect(img.Rect)
+	draw.Draw(img, bg, image.NewUniform(color.RGBA{0, 0, 0, 160}), image.Point{}, draw.Over)
+	draw.Draw(img, image.Rect(x0, y1-height, x0+px, y1).Intersect(img.Rect), image.NewUniform(white), image.Point{}, draw.Src)
+	drawText(img, label, x0, y1-height-4, white)
+}
+
+// niceLength rounds v down to 1, 2 or 5 times a power of ten.
+func niceLength(v float64) float64 {
+	if v <= 0 {
+		return 0
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	switch f := v / exp; {
+	case f >= 5:
+		return 5 * exp
+	case f >= 2:
+		return 2 * exp
+	}
+	return exp
+}
+
+func formatLength(m float64) string {
+	if m >= 1000 {
+		return fmt.Sprintf("%g km", m/1000)
+	}
+	return fmt.Sprintf("%g m", m)
+}
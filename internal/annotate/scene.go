@@ -0,0 +1,109 @@
+package annotate
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clearclown/orbital-eye/internal/raster"
+)
+
+// Scene is the source image a detection result was computed on. Pixel
+// coordinates of detection bboxes refer to this image at full resolution.
+type Scene struct {
+	Width  int
+	Height int
+	GSD    float64 // meters per pixel, 0 if unknown
+
+	tiff *raster.GeoTIFF
+	img  image.Image
+}
+
+// OpenScene opens a PNG, JPEG or GeoTIFF scene. GeoTIFFs are read lazily so
+// chips can be cut from full-size Sentinel-2 tiles without decoding the
+// whole file.
+func OpenScene(path string) (*Scene, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tif", ".tiff":
+		g, err := raster.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open scene: %w", err)
+		}
+		return &Scene{Width: g.Width, Height: g.Height, GSD: g.GSD(), tiff: g}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open scene: %w", err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode scene %s: %w", path, err)
+	}
+	b := img.Bounds()
+	return &Scene{Width: b.Dx(), Height: b.Dy(), img: img}, nil
+}
+
+// NewScene wraps an already decoded image.
+func NewScene(img image.Image, gsd float64) *Scene {
+	b := img.Bounds()
+	return &Scene{Width: b.Dx(), Height: b.Dy(), GSD: gsd, img: img}
+}
+
+// Close releases the underlying file, if any.
+func (s *Scene) Close() error {
+	if s.tiff != nil {
+		return s.tiff.Close()
+	}
+	return nil
+}
+
+// read returns an RGBA copy of a window of the scene, keeping every
+// step-th pixel.
+func (s *Scene) read(win raster.Window, step int) (*image.RGBA, error) {
+	win = win.Intersect(s.Width, s.Height)
+	if win.Empty() {
+		return nil, fmt.Errorf("window %+v outside scene", win)
+	}
+	if step < 1 {
+		step = 1
+	}
+
+	if s.tiff != nil {
+		r, err := s.tiff.Read(win, step)
+		if err != nil {
+			return nil, err
+		}
+		return toRGBA(r.Image()), nil
+	}
+
+	outW := (win.Width + step - 1) / step
+	outH := (win.Height + step - 1) / step
+	out := image.NewRGBA(image.Rect(0, 0, outW, outH))
+	b := s.img.Bounds()
+	if step == 1 {
+		draw.Draw(out, out.Bounds(), s.img, image.Pt(b.Min.X+win.X, b.Min.Y+win.Y), draw.Src)
+		return out, nil
+	}
+	for y := 0; y < outH; y++ {
+		for x := 0; x < outW; x++ {
+			out.Set(x, y, s.img.At(b.Min.X+win.X+x*step, b.Min.Y+win.Y+y*step))
+		}
+	}
+	return out, nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+	return out
+}
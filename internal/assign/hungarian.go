@@ -0,0 +1,100 @@
+// Package assign solves minimum-cost one-to-one matching problems, such as
+// pairing tracks with new detections or AIS vessels with ship detections.
+package assign
+
+import "math"
+
+// Hungarian solves the rectangular assignment problem for cost (rows ×
+// columns) with the Hungarian algorithm in O(n²m), returning for each row
+// the assigned column or -1. Entries of +Inf are forbidden pairs and are
+// never returned as assignments; as many rows as possible are assigned,
+// and among such assignments the total cost is minimal.
+func Hungarian(cost [][]float64) []int {
+	n := len(cost)
+	if n == 0 {
+		return nil
+	}
+	m := len(cost[0])
+	out := make([]int, n)
+	for i := range out {
+		out[i] = -1
+	}
+	if m == 0 {
+		return out
+	}
+
+	// Work on a square matrix padded with dummy rows/columns of cost
+	// "forbidden", a finite stand-in for +Inf larger than any real total.
+	size := max(n, m)
+	forbidden := 1.0
+	for _, row := range cost {
+		for _, c := range row {
+			if !math.IsInf(c, 1) {
+				forbidden += math.Abs(c)
+			}
+		}
+	}
+	at := func(i, j int) float64 {
+		if i >= n || j >= m || math.IsInf(cost[i][j], 1) {
+			return forbidden
+		}
+		return cost[i][j]
+	}
+
+	// Potentials and matching, 1-based with column 0 as the sentinel
+	// (e-maxx formulation).
+	u := make([]float64, size+1)
+	v := make([]float64, size+1)
+	p := make([]int, size+1) // p[j]: row matched to column j
+	way := make([]int, size+1)
+	for i := 1; i <= size; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, size+1)
+		used := make([]bool, size+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= size; j++ {
+				if used[j] {
+					continue
+				}
+				cur := at(i0-1, j-1) - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j], way[j] = cur, j0
+				}
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+			for j := 0; j <= size; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	for j := 1; j <= size; j++ {
+		i := p[j] - 1
+		if i < n && j-1 < m && !math.IsInf(cost[i][j-1], 1) {
+			out[i] = j - 1
+		}
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package assign
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// bruteForce returns the largest number of rows that can be assigned and
+// the least total cost among assignments of that many rows.
+func bruteForce(cost [][]float64) (int, float64) {
+	m := len(cost[0])
+	used := make([]bool, m)
+	bestN, bestC := 0, 0.0
+	var walk func(i, n int, c float64)
+	walk = func(i, n int, c float64) {
+		if i == len(cost) {
+			if n > bestN || n == bestN && c < bestC {
+				bestN, bestC = n, c
+			}
+			return
+		}
+		walk(i+1, n, c)
+		for j := 0; j < m; j++ {
+			if !used[j] && !math.IsInf(cost[i][j], 1) {
+				used[j] = true
+				walk(i+1, n+1, c+cost[i][j])
+				used[j] = false
+			}
+		}
+	}
+	walk(0, 0, 0)
+	return bestN, bestC
+}
+
+func TestHungarianMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 500; trial++ {
+		n, m := 1+rng.Intn(6), 1+rng.Intn(6)
+		cost := make([][]float64, n)
+		for i := range cost {
+			cost[i] = make([]float64, m)
+			for j := range cost[i] {
+				if rng.Float64() < 0.4 {
+					cost[i][j] = math.Inf(1)
+				} else {
+					cost[i][j] = rng.Float64() * 10
+				}
+			}
+		}
+		got := Hungarian(cost)
+		if len(got) != n {
+			t.Fatalf("%v: %d assignments, want %d", cost, len(got), n)
+		}
+		used := make(map[int]bool)
+		gotN, gotC := 0, 0.0
+		for i, j := range got {
+			if j < 0 {
+				continue
+			}
+			if used[j] || math.IsInf(cost[i][j], 1) {
+				t.Fatalf("%v: invalid assignment %v", cost, got)
+			}
+			used[j] = true
+			gotN++
+			gotC += cost[i][j]
+		}
+		wantN, wantC := bruteForce(cost)
+		if gotN != wantN || math.Abs(gotC-wantC) > 1e-9 {
+			t.Fatalf("%v: %d pairs costing %.6f, want %d costing %.6f", cost, gotN, gotC, wantN, wantC)
+		}
+	}
+}
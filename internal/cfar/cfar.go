@@ -0,0 +1,442 @@
+// Package cfar detects ships in calibrated SAR backscatter with a
+// constant false alarm rate (CFAR) detector, as an alternative to the AI
+// worker that needs no GPU and works through cloud and at night.
+//
+// Ships are bright, compact returns on a dark, speckled sea. For every sea
+// pixel the detector estimates the local clutter level from a square ring
+// of background pixels, leaving out a guard area large enough to hold the
+// ship itself, and flags the pixel if it exceeds that level times a factor
+// chosen for the requested probability of false alarm under
+// gamma-distributed (multi-look) clutter. Flagged pixels are grouped into
+// 8-connected components; each becomes one detection whose length and
+// width are its extents along its principal axes.
+//
+// Two clutter estimators are offered. Cell averaging (CA-CFAR) uses the
+// mean of the ring and is evaluated per pixel from integral images.
+// Ordered statistic (OS-CFAR) uses a quantile of the ring, which other
+// ships in the background (anchorages, convoys) do not inflate; it is
+// evaluated once per block of pixels to stay affordable. Land pixels, given
+// as a mask, take part in neither. Fixed bright objects at sea (platforms,
+// wind turbines, buoys) are detected like ships.
+package cfar
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/clearclown/orbital-eye/internal/raster"
+	"github.com/clearclown/orbital-eye/internal/report"
+)
+
+// Attribute keys set on detections.
+const (
+	AttrEngine      = "engine"          // always Engine
+	AttrSNR         = "cfar_snr_db"     // peak pixel over its clutter level, in dB
+	AttrPixels      = "cfar_pixels"     // pixels in the detection
+	AttrOrientation = "orientation_deg" // major axis, degrees clockwise from north (0–180), for north-up rasters
+)
+
+// Engine is the AttrEngine value of CFAR detections.
+const Engine = "cfar"
+
+// Method selects the clutter estimator.
+type Method string
+
+const (
+	MethodCA Method = "ca" // cell averaging
+	MethodOS Method = "os" // ordered statistic
+)
+
+// ParseMethod validates a method name; the empty string means MethodCA.
+func ParseMethod(s string) (Method, error) {
+	switch m := Method(strings.ToLower(s)); m {
+	case "":
+		return MethodCA, nil
+	case MethodCA, MethodOS:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown CFAR method %q (want ca or os)", s)
+}
+
+// maxRingSamples bounds the background samples sorted per OS-CFAR block;
+// larger rings are decimated.
+const maxRingSamples = 512
+
+// Options configures Detect.
+type Options struct {
+	Method Method // defaults to MethodCA
+	// PFA is the probability that a clutter pixel exceeds the threshold;
+	// defaults to 1e-6.
+	PFA float64
+	// Looks is the equivalent number of looks of the clutter; defaults to
+	// 1 (exponential clutter), the conservative choice for sea clutter
+	// that is heavier-tailed than its nominal ENL (4.4 for Sentinel-1 IW
+	// GRDH) suggests.
+	Looks float64
+	// GuardM is the half-width of the guard area around the tested pixel;
+	// defaults to 200 m.
+	GuardM float64
+	// BackgroundM is the half-width of the outer edge of the background
+	// ring; defaults to twice GuardM.
+	BackgroundM float64
+	// Rank is the quantile of the background used by OS-CFAR; defaults to
+	// 0.75.
+	Rank float64
+	// Band is the raster band to use, e.g. 0 for VV in a VV+VH stack.
+	Band int
+	// GSD is the pixel size in meters for rasters that are not
+	// georeferenced.
+	GSD float64
+	// Land marks land pixels (row-major, Width×Height) to exclude; nil
+	// treats every pixel as sea.
+	Land []bool
+	// MinPixels is the smallest component kept; defaults to 2, which
+	// discards most isolated speckle hits.
+	MinPixels int
+	// MaxLengthM is the largest length kept; longer components are
+	// usually coastline or ambiguities. Defaults to 500 m.
+	MaxLengthM float64
+	// Class is the class name of detections; defaults to "ship".
+	Class string
+}
+
+func (o *Options) defaults() {
+	if o.Method == "" {
+		o.Method = MethodCA
+	}
+	if o.PFA <= 0 {
+		o.PFA = 1e-6
+	}
+	if o.Looks <= 0 {
+		o.Looks = 1
+	}
+	if o.GuardM <= 0 {
+		o.GuardM = 200
+	}
+	if o.BackgroundM <= o.GuardM {
+		o.BackgroundM = 2 * o.GuardM
+	}
+	if o.Rank <= 0 || o.Rank >= 1 {
+		o.Rank = 0.75
+	}
+	if o.MinPixels <= 0 {
+		o.MinPixels = 2
+	}
+	if o.MaxLengthM <= 0 {
+		o.MaxLengthM = 500
+	}
+	if o.Class == "" {
+		o.Class = "ship"
+	}
+}
+
+// Stats summarizes a Detect run.
+type Stats struct {
+	Decibels   bool // input was in dB and converted to linear power
+	SeaPixels  int  // pixels tested
+	LandPixels int  // pixels excluded by the land mask
+	Unjudged   int  // sea pixels with too little valid background
+	Hits       int  // pixels above their threshold
+	Rejected   int  // components too small or too long
+}
+
+// Detect runs the detector on one band of r, which must hold calibrated
+// backscatter (σ⁰ or γ⁰) as linear power or in dB; dB input is recognized
+// by mostly negative values. Nodata, NaN and non-positive samples are
+// ignored. Detections are returned strongest first, with a geo center if
+// r is georeferenced.
+func Detect(r *raster.Raster, opts Options) ([]report.Detection, Stats, error) {
+	opts.defaults()
+	var st Stats
+	if opts.Band < 0 || opts.Band >= len(r.Bands) {
+		return nil, st, fmt.Errorf("band %d out of range (raster has %d)", opts.Band+1, len(r.Bands))
+	}
+	if opts.Land != nil && len(opts.Land) != r.Width*r.Height {
+		return nil, st, errors.New("land mask does not match the raster size")
+	}
+	gsd := r.GSD()
+	if gsd <= 0 {
+		gsd = opts.GSD
+	}
+	if gsd <= 0 {
+		return nil, st, errors.New("pixel size unknown: raster is not georeferenced and no GSD was given")
+	}
+	guard := max(1, int(math.Ceil(opts.GuardM/gsd)))
+	outer := max(guard+1, int(math.Ceil(opts.BackgroundM/gsd)))
+
+	vals := intensity(r, opts, &st)
+	factor := thresholdFactor(opts.Looks, opts.PFA)
+
+	// ratio holds value/clutter for pixels above the threshold, 0 elsewhere.
+	var ratio []float32
+	switch opts.Method {
+	case MethodOS:
+		ratio = osCFAR(vals, r.Width, r.Height, guard, outer, factor, opts, &st)
+	default:
+		ratio = caCFAR(vals, r.Width, r.Height, guard, outer, factor, &st)
+	}
+
+	hits := make([]bool, len(ratio))
+	for i, v := range ratio {
+		hits[i] = v > 0
+	}
+	var dets []report.Detection
+	for _, comp := range raster.Components(hits, r.Width, r.Height) {
+		d, ok := detection(comp, vals, ratio, r, gsd, factor, opts)
+		if !ok {
+			st.Rejected++
+			continue
+		}
+		dets = append(dets, d)
+	}
+	sort.SliceStable(dets, func(i, j int) bool { return dets[i].Confidence > dets[j].Confidence })
+	return dets, st, nil
+}
+
+// intensity returns the band as linear power with NaN for pixels to skip.
+func intensity(r *raster.Raster, opts Options, st *Stats) []float32 {
+	src := r.Bands[opts.Band]
+	vals := make([]float32, len(src))
+	var neg, pos int
+	for i, v := range src {
+		switch {
+		case opts.Land != nil && opts.Land[i]:
+			st.LandPixels++
+			v = float32(math.NaN())
+		case r.IsNoData(v):
+			v = float32(math.NaN())
+		case v < 0:
+			neg++
+		case v > 0:
+			pos++
+		}
+		vals[i] = v
+	}
+	st.Decibels = neg > pos
+	for i, v := range vals {
+		if st.Decibels && !math.IsNaN(float64(v)) {
+			v = float32(math.Pow(10, float64(v)/10))
+		}
+		if !(v > 0) {
+			v = float32(math.NaN())
+		}
+		vals[i] = v
+	}
+	return vals
+}
+
+// caCFAR thresholds each pixel against the mean of its background ring.
+func caCFAR(vals []float32, w, h, guard, outer int, factor float64, st *Stats) []float32 {
+	// Integral images of valid values and their count.
+	sum := make([]float64, (w+1)*(h+1))
+	cnt := make([]int32, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var rs float64
+		var rc int32
+		for x := 0; x < w; x++ {
+			if v := vals[y*w+x]; !math.IsNaN(float64(v)) {
+				rs += float64(v)
+				rc++
+			}
+			i := (y+1)*(w+1) + x + 1
+			sum[i] = sum[i-(w+1)] + rs
+			cnt[i] = cnt[i-(w+1)] + rc
+		}
+	}
+	box := func(cx, cy, r int) (float64, int32) {
+		x0, y0 := max(cx-r, 0), max(cy-r, 0)
+		x1, y1 := min(cx+r+1, w), min(cy+r+1, h)
+		a, b, c, d := y0*(w+1)+x0, y0*(w+1)+x1, y1*(w+1)+x0, y1*(w+1)+x1
+		return sum[d] - sum[b] - sum[c] + sum[a], cnt[d] - cnt[b] - cnt[c] + cnt[a]
+	}
+	minRing := int32(((2*outer+1)*(2*outer+1) - (2*guard+1)*(2*guard+1)) / 4)
+
+	ratio := make([]float32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := vals[y*w+x]
+			if math.IsNaN(float64(v)) {
+				continue
+			}
+			st.SeaPixels++
+			so, no := box(x, y, outer)
+			sg, ng := box(x, y, guard)
+			n := no - ng
+			if n < minRing || so-sg <= 0 {
+				st.Unjudged++
+				continue
+			}
+			clutter := (so - sg) / float64(n)
+			if float64(v) > factor*clutter {
+				ratio[y*w+x] = float32(float64(v) / clutter)
+				st.Hits++
+			}
+		}
+	}
+	return ratio
+}
+
+// osCFAR thresholds each pixel against a quantile of the background ring
+// around the center of its block. Blocks are half the guard width, so the
+// guard still covers at least three quarters of its nominal size.
+func osCFAR(vals []float32, w, h, guard, outer int, factor float64, opts Options, st *Stats) []float32 {
+	block := max(1, guard/2)
+	ringArea := (2*outer+1)*(2*outer+1) - (2*guard+1)*(2*guard+1)
+	step := max(1, int(math.Ceil(math.Sqrt(float64(ringArea)/maxRingSamples))))
+	qf := quantileFactor(opts.Looks, opts.Rank)
+
+	ratio := make([]float32, w*h)
+	samples := make([]float32, 0, maxRingSamples*2)
+	for by := 0; by < h; by += block {
+		for bx := 0; bx < w; bx += block {
+			cx, cy := min(bx+block/2, w-1), min(by+block/2, h-1)
+			samples = samples[:0]
+			for y := max(cy-outer, 0); y <= min(cy+outer, h-1); y += step {
+				for x := max(cx-outer, 0); x <= min(cx+outer, w-1); x += step {
+					if abs(x-cx) <= guard && abs(y-cy) <= guard {
+						continue
+					}
+					if v := vals[y*w+x]; !math.IsNaN(float64(v)) {
+						samples = append(samples, v)
+					}
+				}
+			}
+			judged := len(samples) >= ringArea/(4*step*step)
+			var clutter float64
+			if judged {
+				clutter = float64(selectK(samples, int(opts.Rank*float64(len(samples)-1)))) / qf
+				judged = clutter > 0
+			}
+			for y := by; y < min(by+block, h); y++ {
+				for x := bx; x < min(bx+block, w); x++ {
+					v := vals[y*w+x]
+					if math.IsNaN(float64(v)) {
+						continue
+					}
+					st.SeaPixels++
+					if !judged {
+						st.Unjudged++
+						continue
+					}
+					if float64(v) > factor*clutter {
+						ratio[y*w+x] = float32(float64(v) / clutter)
+						st.Hits++
+					}
+				}
+			}
+		}
+	}
+	return ratio
+}
+
+// selectK returns the k-th smallest value, reordering xs.
+func selectK(xs []float32, k int) float32 {
+	lo, hi := 0, len(xs)-1
+	for lo < hi {
+		pivot := xs[(lo+hi)/2]
+		i, j := lo, hi
+		for i <= j {
+			for xs[i] < pivot {
+				i++
+			}
+			for xs[j] > pivot {
+				j--
+			}
+			if i <= j {
+				xs[i], xs[j] = xs[j], xs[i]
+				i++
+				j--
+			}
+		}
+		switch {
+		case k <= j:
+			hi = j
+		case k >= i:
+			lo = i
+		default:
+			return xs[k]
+		}
+	}
+	return xs[k]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// detection measures one component. ok is false if its size is outside
+// the accepted range.
+func detection(comp []int, vals, ratio []float32, r *raster.Raster, gsd, factor float64, opts Options) (report.Detection, bool) {
+	if len(comp) < opts.MinPixels {
+		return report.Detection{}, false
+	}
+	w := r.Width
+	minX, minY, maxX, maxY := w, r.Height, -1, -1
+	var sw, wx, wy, mx, my, peak float64
+	for _, i := range comp {
+		x, y := float64(i%w), float64(i/w)
+		minX, maxX = min(minX, i%w), max(maxX, i%w)
+		minY, maxY = min(minY, i/w), max(maxY, i/w)
+		v := float64(vals[i])
+		sw += v
+		wx += v * x
+		wy += v * y
+		mx += x
+		my += y
+		peak = math.Max(peak, float64(ratio[i]))
+	}
+	n := float64(len(comp))
+	mx, my = mx/n, my/n
+	var sxx, syy, sxy float64
+	for _, i := range comp {
+		dx, dy := float64(i%w)-mx, float64(i/w)-my
+		sxx += dx * dx
+		syy += dy * dy
+		sxy += dx * dy
+	}
+	theta := 0.5 * math.Atan2(2*sxy, sxx-syy) // major axis, from +x towards +y (down)
+	ux, uy := math.Cos(theta), math.Sin(theta)
+	lo1, hi1, lo2, hi2 := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, i := range comp {
+		x, y := float64(i%w), float64(i/w)
+		a, b := x*ux+y*uy, -x*uy+y*ux
+		lo1, hi1 = math.Min(lo1, a), math.Max(hi1, a)
+		lo2, hi2 = math.Min(lo2, b), math.Max(hi2, b)
+	}
+	length := (hi1 - lo1 + 1) * gsd
+	width := (hi2 - lo2 + 1) * gsd
+	if length > opts.MaxLengthM {
+		return report.Detection{}, false
+	}
+
+	// A pixel just at the threshold scores 0.5, rising towards 1 with its
+	// margin over the threshold.
+	conf := math.Min(1-0.5*factor/peak, 0.99)
+	cx, cy := wx/sw, wy/sw
+	bearing := math.Mod(90+theta*180/math.Pi+180, 180)
+	d := report.Detection{
+		ClassName:        opts.Class,
+		Confidence:       float32(conf),
+		Bbox:             report.BBox{XMin: float32(minX), YMin: float32(minY), XMax: float32(maxX + 1), YMax: float32(maxY + 1)},
+		EstimatedLengthM: float32(length),
+		EstimatedWidthM:  float32(width),
+		Attributes: map[string]string{
+			AttrEngine:      Engine,
+			AttrSNR:         fmt.Sprintf("%.1f", 10*math.Log10(peak)),
+			AttrPixels:      fmt.Sprintf("%d", len(comp)),
+			AttrOrientation: fmt.Sprintf("%.0f", bearing),
+		},
+	}
+	if r.Geo.Valid() {
+		if p, err := raster.PixelToPoint(r.Geo, r.EPSG, cx+0.5, cy+0.5); err == nil {
+			d.GeoCenter = &report.GeoPoint{Latitude: p.Lat, Longitude: p.Lon}
+		}
+	}
+	return d, true
+}
@@ -0,0 +1,89 @@
+package cfar
+
+import "math"
+
+// Multi-look SAR intensity over homogeneous sea is gamma distributed with
+// shape L (the equivalent number of looks) and mean μ. The helpers below
+// work on the normalized variable x/μ, whose distribution is
+// Gamma(L, 1/L).
+
+// thresholdFactor returns T such that P(x > T·μ) = pfa.
+func thresholdFactor(looks, pfa float64) float64 {
+	// Q(L, L·T) falls monotonically in T; bisect.
+	lo, hi := 0.0, 1.0
+	for gammaQ(looks, looks*hi) > pfa {
+		hi *= 2
+	}
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if gammaQ(looks, looks*mid) > pfa {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// quantileFactor returns the q-quantile of x/μ, used to turn an order
+// statistic of the background into a mean estimate.
+func quantileFactor(looks, q float64) float64 {
+	lo, hi := 0.0, 1.0
+	for 1-gammaQ(looks, looks*hi) < q {
+		hi *= 2
+	}
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if 1-gammaQ(looks, looks*mid) < q {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// gammaQ is the regularized upper incomplete gamma function Q(a, x), by
+// its series below a+1 and its continued fraction above (Numerical
+// Recipes §6.2).
+func gammaQ(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	lg, _ := math.Lgamma(a)
+	if x < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1.0; n < 1000; n++ {
+			term *= x / (a + n)
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+		return 1 - sum*math.Exp(-x+a*math.Log(x)-lg)
+	}
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1.0; i < 1000; i++ {
+		an := -i * (i - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-15 {
+			break
+		}
+	}
+	return math.Exp(-x+a*math.Log(x)-lg) * h
+}
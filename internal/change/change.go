@@ -0,0 +1,350 @@
+// Package change finds changed areas between two co-registered images of
+// the same place. It is the local counterpart of the AI worker's
+// DetectChanges: it needs no worker, returns the same ChangeResponse, and,
+// being deterministic, is the baseline the learned model is judged
+// against.
+//
+// A per-pixel change magnitude is computed by one of three methods: the
+// difference of standardized bands for single-band optical images; change
+// vector analysis (CVA), the length of the difference vector across
+// standardized bands, for multi-band images; and the log ratio for SAR,
+// whose speckle is multiplicative and would swamp a plain difference.
+// Pixels whose magnitude lies more than k robust standard deviations above
+// the scene median are changed, with k set by the sensitivity. The mask is
+// cleaned with a morphological opening and closing and split into
+// 8-connected regions.
+package change
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/clearclown/orbital-eye/internal/raster"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// TypeActivity is the change type of regions that have not been
+// classified, as the AI worker reports them.
+const TypeActivity = "activity_change"
+
+// Method selects how the change magnitude is computed.
+type Method string
+
+const (
+	MethodAuto  Method = "auto"  // diff for one band, cva for several
+	MethodDiff  Method = "diff"  // mean absolute difference of standardized bands
+	MethodCVA   Method = "cva"   // change vector magnitude over standardized bands
+	MethodRatio Method = "ratio" // absolute log ratio in dB, for SAR backscatter
+)
+
+// ParseMethod validates a method name; the empty string means MethodAuto.
+func ParseMethod(s string) (Method, error) {
+	switch m := Method(strings.ToLower(s)); m {
+	case "":
+		return MethodAuto, nil
+	case MethodAuto, MethodDiff, MethodCVA, MethodRatio:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown change method %q (want auto, diff, cva or ratio)", s)
+}
+
+// Options configures Detect.
+type Options struct {
+	Method Method // defaults to MethodAuto
+	// Sensitivity runs from 0 (only major changes) to 1 (all changes) as in
+	// the worker's ChangeRequest; values outside that range mean 0.5. It
+	// sets the threshold at 5 - 4·Sensitivity robust standard deviations
+	// above the median.
+	Sensitivity float64
+	// Radius of the square structuring element of the morphological
+	// cleanup; defaults to 2 (5×5, the worker's kernel size).
+	Radius int
+	// MinPixels is the smallest region reported; defaults to 100, as the
+	// worker.
+	MinPixels int
+	// SpeckleRadius is the radius of the box filter applied to SAR images
+	// before the ratio; defaults to 2.
+	SpeckleRadius int
+}
+
+func (o *Options) defaults() {
+	if o.Method == "" {
+		o.Method = MethodAuto
+	}
+	if o.Sensitivity < 0 || o.Sensitivity > 1 {
+		o.Sensitivity = 0.5
+	}
+	if o.Radius <= 0 {
+		o.Radius = 2
+	}
+	if o.MinPixels <= 0 {
+		o.MinPixels = 100
+	}
+	if o.SpeckleRadius <= 0 {
+		o.SpeckleRadius = 2
+	}
+}
+
+// madScale converts a MAD to a standard deviation for normal data.
+const madScale = 1.4826
+
+// Detect compares two rasters on the same grid. Only the bands both have
+// are used; pixels that are nodata in either are never changed. Regions
+// get a geo center when the rasters are georeferenced; their change type
+// is TypeActivity and their significance grows with area up to 1 at
+// 10 000 pixels, as the worker reports them.
+func Detect(before, after *raster.Raster, opts Options) (*pb.ChangeResponse, error) {
+	opts.defaults()
+	if before.Width != after.Width || before.Height != after.Height {
+		return nil, fmt.Errorf("images differ in size: %dx%d and %dx%d", before.Width, before.Height, after.Width, after.Height)
+	}
+	bands := min(len(before.Bands), len(after.Bands))
+	if bands == 0 {
+		return nil, errors.New("images have no bands")
+	}
+	method := opts.Method
+	if method == MethodAuto {
+		method = MethodCVA
+		if bands == 1 {
+			method = MethodDiff
+		}
+	}
+
+	w, h := before.Width, before.Height
+	valid := make([]bool, w*h)
+	for i := range valid {
+		valid[i] = true
+		for b := 0; b < bands; b++ {
+			if before.IsNoData(before.Bands[b][i]) || after.IsNoData(after.Bands[b][i]) {
+				valid[i] = false
+				break
+			}
+		}
+	}
+
+	var mag []float64
+	if method == MethodRatio {
+		mag = logRatio(before, after, bands, valid, opts.SpeckleRadius)
+	} else {
+		mag = standardizedDiff(before, after, bands, valid, method == MethodCVA)
+	}
+
+	var vals []float64
+	for i, m := range mag {
+		if valid[i] {
+			vals = append(vals, m)
+		}
+	}
+	if len(vals) == 0 {
+		return nil, errors.New("the images have no valid pixels in common")
+	}
+	med := median(vals)
+	for i, v := range vals {
+		vals[i] = math.Abs(v - med)
+	}
+	sigma := madScale * median(vals)
+	if sigma == 0 {
+		sigma = 1e-6 // identical images: only exact differences count
+	}
+	threshold := med + (5-4*opts.Sensitivity)*sigma
+
+	mask := make([]bool, w*h)
+	for i, m := range mag {
+		mask[i] = valid[i] && m > threshold
+	}
+	mask = raster.Closing(raster.Opening(mask, w, h, opts.Radius), w, h, opts.Radius)
+
+	resp := &pb.ChangeResponse{}
+	changed := 0
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i, c := range mask {
+		if c && valid[i] {
+			changed++
+			img.Pix[i] = 255
+		} else {
+			mask[i] = false
+		}
+	}
+	resp.ChangePercentage = float32(100 * float64(changed) / float64(len(vals)))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	resp.ChangeMask = buf.Bytes()
+
+	for _, comp := range raster.Components(mask, w, h) {
+		if len(comp) < opts.MinPixels {
+			continue
+		}
+		resp.Regions = append(resp.Regions, region(comp, before))
+	}
+	sort.SliceStable(resp.Regions, func(i, j int) bool { return resp.Regions[i].Significance > resp.Regions[j].Significance })
+	return resp, nil
+}
+
+// standardizedDiff returns per-pixel change magnitudes from bands scaled
+// to zero mean and unit variance in each image, which cancels overall
+// brightness and contrast differences between the dates.
+func standardizedDiff(before, after *raster.Raster, bands int, valid []bool, cva bool) []float64 {
+	mag := make([]float64, len(valid))
+	for b := 0; b < bands; b++ {
+		mb, sb := meanStd(before.Bands[b], valid)
+		ma, sa := meanStd(after.Bands[b], valid)
+		for i := range mag {
+			if !valid[i] {
+				continue
+			}
+			d := (float64(after.Bands[b][i])-ma)/sa - (float64(before.Bands[b][i])-mb)/sb
+			if cva {
+				mag[i] += d * d
+			} else {
+				mag[i] += math.Abs(d) / float64(bands)
+			}
+		}
+	}
+	if cva {
+		for i := range mag {
+			mag[i] = math.Sqrt(mag[i])
+		}
+	}
+	return mag
+}
+
+// logRatio returns the mean absolute log ratio, in dB, of speckle-filtered
+// backscatter. Bands in dB (mostly negative values) are converted to
+// linear power first.
+func logRatio(before, after *raster.Raster, bands int, valid []bool, radius int) []float64 {
+	w, h := before.Width, before.Height
+	mag := make([]float64, len(valid))
+	for b := 0; b < bands; b++ {
+		pBefore := boxMean(linear(before.Bands[b], valid), valid, w, h, radius)
+		pAfter := boxMean(linear(after.Bands[b], valid), valid, w, h, radius)
+		for i := range mag {
+			if !valid[i] {
+				continue
+			}
+			if pBefore[i] <= 0 || pAfter[i] <= 0 {
+				valid[i] = false
+				continue
+			}
+			mag[i] += math.Abs(10*math.Log10(pAfter[i]/pBefore[i])) / float64(bands)
+		}
+	}
+	return mag
+}
+
+// linear returns a band as linear power.
+func linear(band []float32, valid []bool) []float64 {
+	var neg, pos int
+	for i, v := range band {
+		if valid[i] {
+			if v < 0 {
+				neg++
+			} else if v > 0 {
+				pos++
+			}
+		}
+	}
+	out := make([]float64, len(band))
+	for i, v := range band {
+		out[i] = float64(v)
+		if neg > pos {
+			out[i] = math.Pow(10, float64(v)/10)
+		}
+	}
+	return out
+}
+
+// boxMean averages the valid values in a (2r+1)² window around each pixel.
+func boxMean(vals []float64, valid []bool, w, h, r int) []float64 {
+	sum := make([]float64, (w+1)*(h+1))
+	cnt := make([]int, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var rs float64
+		var rc int
+		for x := 0; x < w; x++ {
+			if valid[y*w+x] {
+				rs += vals[y*w+x]
+				rc++
+			}
+			i := (y+1)*(w+1) + x + 1
+			sum[i] = sum[i-(w+1)] + rs
+			cnt[i] = cnt[i-(w+1)] + rc
+		}
+	}
+	out := make([]float64, len(vals))
+	for y := 0; y < h; y++ {
+		y0, y1 := max(y-r, 0), min(y+r+1, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(x-r, 0), min(x+r+1, w)
+			a, b, c, d := y0*(w+1)+x0, y0*(w+1)+x1, y1*(w+1)+x0, y1*(w+1)+x1
+			if n := cnt[d] - cnt[b] - cnt[c] + cnt[a]; n > 0 {
+				out[y*w+x] = (sum[d] - sum[b] - sum[c] + sum[a]) / float64(n)
+			}
+		}
+	}
+	return out
+}
+
+func meanStd(band []float32, valid []bool) (float64, float64) {
+	var sum, sq float64
+	var n int
+	for i, v := range band {
+		if valid[i] {
+			sum += float64(v)
+			sq += float64(v) * float64(v)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 1
+	}
+	mean := sum / float64(n)
+	sd := math.Sqrt(math.Max(sq/float64(n)-mean*mean, 0))
+	if sd == 0 {
+		sd = 1
+	}
+	return mean, sd
+}
+
+func median(xs []float64) float64 {
+	s := append([]float64(nil), xs...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+// region describes one connected group of changed pixels.
+func region(comp []int, r *raster.Raster) *pb.ChangeRegion {
+	w := r.Width
+	minX, minY, maxX, maxY := w, r.Height, -1, -1
+	var sx, sy float64
+	for _, i := range comp {
+		x, y := i%w, i/w
+		minX, maxX = min(minX, x), max(maxX, x)
+		minY, maxY = min(minY, y), max(maxY, y)
+		sx += float64(x)
+		sy += float64(y)
+	}
+	reg := &pb.ChangeRegion{
+		Bbox:         &pb.BoundingBox{XMin: float32(minX), YMin: float32(minY), XMax: float32(maxX + 1), YMax: float32(maxY + 1)},
+		ChangeType:   TypeActivity,
+		Significance: float32(math.Min(1, float64(len(comp))/10000)),
+	}
+	if r.Geo.Valid() {
+		n := float64(len(comp))
+		if p, err := raster.PixelToPoint(r.Geo, r.EPSG, sx/n+0.5, sy/n+0.5); err == nil {
+			reg.GeoCenter = &pb.GeoPoint{Latitude: p.Lat, Longitude: p.Lon}
+		}
+	}
+	return reg
+}
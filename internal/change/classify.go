@@ -0,0 +1,394 @@
+package change
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/raster"
+	"github.com/clearclown/orbital-eye/internal/report"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// Change types assigned by Classify. Regions without evidence for any of
+// them keep TypeActivity.
+const (
+	TypeConstruction = "new_construction"
+	TypeDemolition   = "demolition"
+	TypeArrival      = "arrival"   // vehicle, vessel or aircraft appeared
+	TypeDeparture    = "departure" // vehicle, vessel or aircraft left
+	TypeVegetation   = "vegetation_clearing"
+	TypeWater        = "water_change" // flooding, drying or land reclamation
+)
+
+// DefaultMovableClasses are the class-name substrings of objects whose
+// appearance is an arrival rather than construction.
+var DefaultMovableClasses = []string{"boat", "ship", "vessel", "vehicle", "car", "truck", "bus", "aircraft", "airplane", "plane", "helicopter"}
+
+// Evidence is what Classify knows about the two dates besides the change
+// regions. Only Before is required.
+type Evidence struct {
+	// Before and After are the compared images on the regions' pixel grid,
+	// as returned by Load. Without indices, their brightness and, for three
+	// or more bands read as RGB, their greenness are used.
+	Before, After *raster.Raster
+	// BeforeDetections and AfterDetections are object detections of each
+	// date, placed by geo center on georeferenced grids and by bounding box
+	// otherwise.
+	BeforeDetections, AfterDetections []report.Detection
+	// BeforeIndices and AfterIndices are spectral index rasters of each
+	// date keyed by lower-case name: "ndvi", "ndwi" and "ndbi", e.g. from
+	// raster.ComputeIndex. They may be on any georeferenced grid, or on the
+	// regions' grid.
+	BeforeIndices, AfterIndices map[string]*raster.Raster
+	// MovableClasses are the class-name substrings of movable objects;
+	// defaults to DefaultMovableClasses.
+	MovableClasses []string
+	// Origin is the pixel of the regions' grid at the top-left corner of
+	// Before and After when they are a window of it, such as the AOI of a
+	// full-scene comparison; zero when they are the whole grid.
+	Origin image.Point
+}
+
+// Decision thresholds.
+const (
+	minWaterDelta      = 0.2  // NDWI change across 0 for a water change
+	minVegetationNDVI  = 0.3  // NDVI of vegetation before clearing
+	minVegetationDelta = 0.2  // NDVI drop for clearing
+	minBuiltDelta      = 0.1  // NDBI change for construction or demolition
+	minGreenness       = 0.05 // excess-green chromaticity of vegetation in RGB
+	minBrightnessZ     = 1.0  // standardized brightness change without NDBI
+	fullAreaM2         = 10000
+	maxRegionSamples   = 4096 // pixels sampled per region for index means
+)
+
+// Classify sets the change type and significance of every region from the
+// evidence, testing in turn: a change in the number of detected objects
+// (arrival or departure of movable objects, construction or demolition of
+// others); a water change (NDWI crossing 0); vegetation clearing (an NDVI
+// drop from vegetated, or lost greenness in RGB); construction or
+// demolition (NDBI, or brightness, rising or falling); and, on water that
+// stays water, arrival or departure by brightness. Significance is the mean
+// of an area term, reaching 1 at one hectare (10 000 pixels without a
+// pixel size), and the strength of the evidence, 0 for TypeActivity.
+// Regions are re-sorted by significance.
+func Classify(resp *pb.ChangeResponse, ev Evidence) {
+	grid := ev.Before
+	if grid == nil {
+		return
+	}
+	if len(ev.MovableClasses) == 0 {
+		ev.MovableClasses = DefaultMovableClasses
+	}
+	w, h := grid.Width, grid.Height
+	mask := decodeMask(resp.ChangeMask, ev.Origin, w, h)
+	before := place(ev.BeforeDetections, grid, ev.Origin, ev.MovableClasses)
+	after := place(ev.AfterDetections, grid, ev.Origin, ev.MovableClasses)
+	var bs, as brightness
+	if ev.After != nil && ev.After.Width == w && ev.After.Height == h {
+		bs, as = newBrightness(ev.Before), newBrightness(ev.After)
+	}
+
+	for _, r := range resp.Regions {
+		if r.Bbox == nil {
+			continue
+		}
+		x0, y0 := max(int(r.Bbox.XMin)-ev.Origin.X, 0), max(int(r.Bbox.YMin)-ev.Origin.Y, 0)
+		x1 := min(int(math.Ceil(float64(r.Bbox.XMax)))-ev.Origin.X, w)
+		y1 := min(int(math.Ceil(float64(r.Bbox.YMax)))-ev.Origin.Y, h)
+		var px []int
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				if mask == nil || mask[y*w+x] {
+					px = append(px, y*w+x)
+				}
+			}
+		}
+		if len(px) == 0 {
+			continue
+		}
+
+		kind, strength := TypeActivity, 0.0
+		idx := func(name string) (b, a float64, ok bool) {
+			b, okb := indexMean(ev.BeforeIndices[name], grid, px)
+			a, oka := indexMean(ev.AfterIndices[name], grid, px)
+			return b, a, okb && oka
+		}
+		ndwiB, ndwiA, hasNDWI := idx("ndwi")
+		ndviB, ndviA, hasNDVI := idx("ndvi")
+		ndbiB, ndbiA, hasNDBI := idx("ndbi")
+		dz, greenB, greenA, hasRGB := 0.0, 0.0, 0.0, false
+		if bs.valid != nil {
+			dz = as.z(px) - bs.z(px)
+			greenB, hasRGB = bs.greenness(px)
+			greenA, _ = as.greenness(px)
+		}
+
+		movable, fixed := countIn(after, x0, y0, x1, y1)
+		mb, fb := countIn(before, x0, y0, x1, y1)
+		dm, df := movable-mb, fixed-fb
+		switch {
+		case dm != 0 && abs(dm) >= abs(df):
+			kind, strength = TypeArrival, math.Min(1, float64(abs(dm))/2)
+			if dm < 0 {
+				kind = TypeDeparture
+			}
+		case df != 0:
+			kind, strength = TypeConstruction, math.Min(1, float64(abs(df))/2)
+			if df < 0 {
+				kind = TypeDemolition
+			}
+		case hasNDWI && math.Abs(ndwiA-ndwiB) >= minWaterDelta && (ndwiB > 0) != (ndwiA > 0):
+			kind, strength = TypeWater, math.Min(1, math.Abs(ndwiA-ndwiB)/0.5)
+		case hasNDVI && ndviB >= minVegetationNDVI && ndviB-ndviA >= minVegetationDelta:
+			kind, strength = TypeVegetation, math.Min(1, (ndviB-ndviA)/0.5)
+		case !hasNDVI && hasRGB && greenB >= minGreenness && greenB-greenA >= minGreenness:
+			kind, strength = TypeVegetation, math.Min(1, (greenB-greenA)/0.15)
+		case hasNDWI && ndwiB > 0 && ndwiA > 0 && math.Abs(dz) >= minBrightnessZ:
+			// Still water: something bright came or went.
+			kind, strength = TypeArrival, math.Min(1, math.Abs(dz)/3)
+			if dz < 0 {
+				kind = TypeDeparture
+			}
+		case hasNDBI && math.Abs(ndbiA-ndbiB) >= minBuiltDelta:
+			kind, strength = TypeConstruction, math.Min(1, math.Abs(ndbiA-ndbiB)/0.3)
+			if ndbiA < ndbiB {
+				kind = TypeDemolition
+			}
+		case !hasNDBI && bs.valid != nil && math.Abs(dz) >= minBrightnessZ:
+			kind, strength = TypeConstruction, math.Min(1, math.Abs(dz)/3)
+			if dz < 0 {
+				kind = TypeDemolition
+			}
+		}
+
+		area := float64(len(px))
+		if gsd := grid.GSD(); gsd > 0 {
+			area *= gsd * gsd
+		}
+		r.ChangeType = kind
+		r.Significance = float32((math.Min(1, area/fullAreaM2) + strength) / 2)
+	}
+	sort.SliceStable(resp.Regions, func(i, j int) bool { return resp.Regions[i].Significance > resp.Regions[j].Significance })
+}
+
+// decodeMask returns the w×h window at origin of the PNG change mask, or
+// nil if the mask is missing or does not cover it. Without an origin the
+// mask must be exactly w×h.
+func decodeMask(data []byte, origin image.Point, w, h int) []bool {
+	if len(data) == 0 {
+		return nil
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	b := img.Bounds()
+	if origin == (image.Point{}) && (b.Dx() != w || b.Dy() != h) ||
+		origin.X < 0 || origin.Y < 0 || b.Dx() < origin.X+w || b.Dy() < origin.Y+h {
+		return nil
+	}
+	b.Min = b.Min.Add(origin)
+	mask := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if g, ok := img.(*image.Gray); ok {
+				mask[y*w+x] = g.GrayAt(b.Min.X+x, b.Min.Y+y).Y > 0
+				continue
+			}
+			r, _, _, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			mask[y*w+x] = r > 0
+		}
+	}
+	return mask
+}
+
+// placed is a detection's position on the regions' grid.
+type placed struct {
+	x, y    float64
+	movable bool
+}
+
+func place(dets []report.Detection, grid *raster.Raster, origin image.Point, movable []string) []placed {
+	var out []placed
+	for _, d := range dets {
+		p := placed{
+			x:       float64(d.Bbox.XMin+d.Bbox.XMax)/2 - float64(origin.X),
+			y:       float64(d.Bbox.YMin+d.Bbox.YMax)/2 - float64(origin.Y),
+			movable: hasAny(d.ClassName, movable),
+		}
+		if c := d.GeoCenter; c != nil && grid.Geo.Valid() && !(c.Latitude == 0 && c.Longitude == 0) {
+			x, y, err := raster.Project(grid.EPSG, geo.Point{Lat: c.Latitude, Lon: c.Longitude})
+			if err != nil {
+				continue
+			}
+			p.x, p.y = grid.Geo.ToPixel(x, y)
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// countIn counts the movable and other objects within a pixel box, widened
+// by two pixels for co-registration error.
+func countIn(objs []placed, x0, y0, x1, y1 int) (movable, fixed int) {
+	const margin = 2
+	for _, o := range objs {
+		if o.x < float64(x0-margin) || o.x >= float64(x1+margin) || o.y < float64(y0-margin) || o.y >= float64(y1+margin) {
+			continue
+		}
+		if o.movable {
+			movable++
+		} else {
+			fixed++
+		}
+	}
+	return movable, fixed
+}
+
+func hasAny(class string, subs []string) bool {
+	class = strings.ToLower(class)
+	for _, s := range subs {
+		if strings.Contains(class, strings.ToLower(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// indexMean averages an index raster over grid pixels, sampling at most
+// maxRegionSamples of them.
+func indexMean(idx, grid *raster.Raster, px []int) (float64, bool) {
+	if idx == nil || len(idx.Bands) == 0 {
+		return 0, false
+	}
+	sameGrid := idx.Width == grid.Width && idx.Height == grid.Height && (!idx.Geo.Valid() || idx.Geo == grid.Geo)
+	if !sameGrid && (!idx.Geo.Valid() || !grid.Geo.Valid()) {
+		return 0, false
+	}
+	step := max(1, len(px)/maxRegionSamples)
+	var sum float64
+	var n int
+	for k := 0; k < len(px); k += step {
+		i := px[k]
+		if !sameGrid {
+			gx, gy := grid.Geo.ToCRS(float64(i%grid.Width)+0.5, float64(i/grid.Width)+0.5)
+			if idx.EPSG != grid.EPSG {
+				p, err := raster.Unproject(grid.EPSG, gx, gy)
+				if err != nil {
+					return 0, false
+				}
+				if gx, gy, err = raster.Project(idx.EPSG, p); err != nil {
+					return 0, false
+				}
+			}
+			c, r := idx.Geo.ToPixel(gx, gy)
+			x, y := int(math.Floor(c)), int(math.Floor(r))
+			if x < 0 || y < 0 || x >= idx.Width || y >= idx.Height {
+				continue
+			}
+			i = y*idx.Width + x
+		}
+		if v := idx.Bands[0][i]; !idx.IsNoData(v) {
+			sum += float64(v)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// brightness holds an image's per-pixel brightness, standardized over the
+// scene so that dates with different illumination compare.
+type brightness struct {
+	r          *raster.Raster
+	valid      []bool
+	mean, std  float64
+	brightness []float64
+}
+
+func newBrightness(r *raster.Raster) brightness {
+	b := brightness{r: r, valid: make([]bool, r.Width*r.Height), brightness: make([]float64, r.Width*r.Height)}
+	var sum, sq float64
+	var n int
+	for i := range b.valid {
+		var v float64
+		ok := true
+		for _, band := range r.Bands {
+			if r.IsNoData(band[i]) {
+				ok = false
+				break
+			}
+			v += float64(band[i])
+		}
+		if !ok {
+			continue
+		}
+		v /= float64(len(r.Bands))
+		b.valid[i], b.brightness[i] = true, v
+		sum += v
+		sq += v * v
+		n++
+	}
+	if n > 0 {
+		b.mean = sum / float64(n)
+		b.std = math.Sqrt(math.Max(sq/float64(n)-b.mean*b.mean, 0))
+	}
+	if b.std == 0 {
+		b.std = 1
+	}
+	return b
+}
+
+// z returns the mean standardized brightness of the pixels.
+func (b brightness) z(px []int) float64 {
+	var sum float64
+	var n int
+	for _, i := range px {
+		if b.valid[i] {
+			sum += (b.brightness[i] - b.mean) / b.std
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// greenness returns the mean excess-green chromaticity (2G - R - B) /
+// (R + G + B) of the pixels, reading the first three bands as RGB.
+func (b brightness) greenness(px []int) (float64, bool) {
+	if len(b.r.Bands) < 3 {
+		return 0, false
+	}
+	red, green, blue := b.r.Bands[0], b.r.Bands[1], b.r.Bands[2]
+	var sum float64
+	var n int
+	for _, i := range px {
+		if !b.valid[i] {
+			continue
+		}
+		if t := float64(red[i] + green[i] + blue[i]); t > 0 {
+			sum += (2*float64(green[i]) - float64(red[i]) - float64(blue[i])) / t
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
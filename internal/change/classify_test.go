@@ -0,0 +1,228 @@
+package change
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/clearclown/orbital-eye/internal/raster"
+	"github.com/clearclown/orbital-eye/internal/report"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+func changeRegion(x0, y0, x1, y1 float32) *pb.ChangeRegion {
+	return &pb.ChangeRegion{Bbox: &pb.BoundingBox{XMin: x0, YMin: y0, XMax: x1, YMax: y1}, ChangeType: TypeActivity}
+}
+
+func pngMask(t *testing.T, w, h int, on image.Rectangle) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := on.Min.Y; y < on.Max.Y; y++ {
+		for x := on.Min.X; x < on.Max.X; x++ {
+			img.Pix[y*img.Stride+x] = 255
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func detection(class string, x0, y0, x1, y1 float32) report.Detection {
+	return report.Detection{ClassName: class, Bbox: report.BBox{XMin: x0, YMin: y0, XMax: x1, YMax: y1}}
+}
+
+// TestClassifyWindow classifies regions of a 100×100 grid on its 20×20
+// window at (60, 40), as for worker regions cropped to an AOI.
+func TestClassifyWindow(t *testing.T) {
+	before, after := raster.New(20, 20, 1), raster.New(20, 20, 1)
+	resp := &pb.ChangeResponse{
+		Regions:    []*pb.ChangeRegion{changeRegion(62, 42, 66, 46), changeRegion(10, 10, 14, 14)},
+		ChangeMask: pngMask(t, 100, 100, image.Rect(62, 42, 66, 46)),
+	}
+	ev := Evidence{
+		Before:          before,
+		After:           after,
+		AfterDetections: []report.Detection{detection("ship", 63, 43, 65, 45)},
+		Origin:          image.Pt(60, 40),
+	}
+	Classify(resp, ev)
+	if r := resp.Regions[0]; r.Bbox.XMin != 62 || r.ChangeType != TypeArrival {
+		t.Errorf("region in the window: %s at %v, want %s", r.ChangeType, r.Bbox, TypeArrival)
+	}
+	if r := resp.Regions[1]; r.ChangeType != TypeActivity || r.Significance != 0 {
+		t.Errorf("region outside the window: %s, significance %v; want it left alone", r.ChangeType, r.Significance)
+	}
+}
+
+// filled returns a 40×40 raster whose bands hold bg, and fg inside r.
+func filled(r image.Rectangle, bg, fg []float32) *raster.Raster {
+	out := raster.New(40, 40, len(bg))
+	for b := range out.Bands {
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 40; x++ {
+				v := bg[b]
+				if image.Pt(x, y).In(r) {
+					v = fg[b]
+				}
+				out.Bands[b][y*40+x] = v
+			}
+		}
+	}
+	return out
+}
+
+func uniform(v float32) *raster.Raster {
+	return filled(image.Rectangle{}, []float32{v}, []float32{v})
+}
+
+func TestClassify(t *testing.T) {
+	area := image.Rect(10, 10, 20, 20) // the changed region, 100 pixels
+	grey := uniform(100)
+	brighter := filled(area, []float32{100}, []float32{200})
+	darker := filled(area, []float32{100}, []float32{20})
+	green := filled(area, []float32{100, 100, 100}, []float32{50, 150, 50})
+	rgbGrey := filled(area, []float32{100, 100, 100}, []float32{100, 100, 100})
+	idx := func(name string, v float32) map[string]*raster.Raster {
+		return map[string]*raster.Raster{name: uniform(v)}
+	}
+	ship := []report.Detection{detection("Cargo Ship", 14, 14, 16, 16)}
+	tank := []report.Detection{detection("storage-tank", 12, 12, 16, 16)}
+	twoTanks := []report.Detection{detection("storage-tank", 12, 12, 14, 14), detection("storage-tank", 17, 17, 19, 19)}
+	farShip := []report.Detection{detection("ship", 30, 30, 32, 32)}
+
+	for _, c := range []struct {
+		name     string
+		ev       Evidence
+		want     string
+		strength float64
+	}{
+		{"ship appears", Evidence{Before: grey, After: grey, AfterDetections: ship}, TypeArrival, 0.5},
+		{"ship leaves", Evidence{Before: grey, After: grey, BeforeDetections: ship}, TypeDeparture, 0.5},
+		{"custom movable class", Evidence{Before: grey, After: grey, AfterDetections: tank, MovableClasses: []string{"tank"}}, TypeArrival, 0.5},
+		{"tank built", Evidence{Before: grey, After: grey, AfterDetections: twoTanks}, TypeConstruction, 1},
+		{"tank removed", Evidence{Before: grey, After: grey, BeforeDetections: tank}, TypeDemolition, 0.5},
+		{"ship elsewhere", Evidence{Before: grey, After: grey, AfterDetections: farShip}, TypeActivity, 0},
+		{"flooding", Evidence{Before: grey, After: grey,
+			BeforeIndices: idx("ndwi", -0.3), AfterIndices: idx("ndwi", 0.3)}, TypeWater, 1},
+		{"wet land", Evidence{Before: grey, After: grey,
+			BeforeIndices: idx("ndwi", -0.3), AfterIndices: idx("ndwi", -0.05)}, TypeActivity, 0},
+		{"clearing", Evidence{Before: grey, After: grey,
+			BeforeIndices: idx("ndvi", 0.6), AfterIndices: idx("ndvi", 0.2)}, TypeVegetation, 0.8},
+		{"sparse vegetation thinning", Evidence{Before: grey, After: grey,
+			BeforeIndices: idx("ndvi", 0.25), AfterIndices: idx("ndvi", 0)}, TypeActivity, 0},
+		{"clearing in RGB", Evidence{Before: green, After: rgbGrey}, TypeVegetation, 1},
+		{"vessel on water", Evidence{Before: grey, After: brighter,
+			BeforeIndices: idx("ndwi", 0.5), AfterIndices: idx("ndwi", 0.5)}, TypeArrival, -1},
+		{"vessel gone", Evidence{Before: brighter, After: grey,
+			BeforeIndices: idx("ndwi", 0.5), AfterIndices: idx("ndwi", 0.5)}, TypeDeparture, -1},
+		{"built up", Evidence{Before: grey, After: grey,
+			BeforeIndices: idx("ndbi", -0.1), AfterIndices: idx("ndbi", 0.2)}, TypeConstruction, 1},
+		{"torn down", Evidence{Before: grey, After: brighter,
+			BeforeIndices: idx("ndbi", 0.2), AfterIndices: idx("ndbi", 0.05)}, TypeDemolition, 0.5},
+		{"brighter", Evidence{Before: grey, After: brighter}, TypeConstruction, -1},
+		{"darker", Evidence{Before: grey, After: darker}, TypeDemolition, -1},
+		{"no evidence", Evidence{Before: grey, After: grey}, TypeActivity, 0},
+		{"no after image", Evidence{Before: grey}, TypeActivity, 0},
+	} {
+		resp := &pb.ChangeResponse{Regions: []*pb.ChangeRegion{changeRegion(10, 10, 20, 20)}}
+		Classify(resp, c.ev)
+		r := resp.Regions[0]
+		if r.ChangeType != c.want {
+			t.Errorf("%s: %s, want %s", c.name, r.ChangeType, c.want)
+			continue
+		}
+		// 100 pixels without a pixel size: area term 0.01.
+		if c.strength < 0 {
+			if r.Significance <= 0.005 || r.Significance > 0.505 {
+				t.Errorf("%s: significance %v out of range", c.name, r.Significance)
+			}
+		} else if want := (0.01 + c.strength) / 2; math.Abs(float64(r.Significance)-want) > 1e-6 {
+			t.Errorf("%s: significance %v, want %v", c.name, r.Significance, want)
+		}
+	}
+}
+
+func TestClassifyMaskAndOrder(t *testing.T) {
+	before := uniform(100)
+	after := filled(image.Rect(0, 0, 5, 5), []float32{100}, []float32{250})
+	resp := &pb.ChangeResponse{Regions: []*pb.ChangeRegion{
+		changeRegion(20, 20, 40, 40), // large, no evidence
+		changeRegion(0, 0, 10, 10),   // brightened corner
+	}}
+	Classify(resp, Evidence{Before: before, After: after})
+	if resp.Regions[0].ChangeType != TypeConstruction || resp.Regions[1].ChangeType != TypeActivity {
+		t.Errorf("regions not re-sorted by significance: %s %v, %s %v", resp.Regions[0].ChangeType, resp.Regions[0].Significance,
+			resp.Regions[1].ChangeType, resp.Regions[1].Significance)
+	}
+
+	// Masked: only the brightened 5×5 corner is sampled.
+	masked := &pb.ChangeResponse{
+		Regions:    []*pb.ChangeRegion{changeRegion(0, 0, 10, 10)},
+		ChangeMask: pngMask(t, 40, 40, image.Rect(0, 0, 5, 5)),
+	}
+	unmasked := &pb.ChangeResponse{Regions: []*pb.ChangeRegion{changeRegion(0, 0, 10, 10)}}
+	Classify(masked, Evidence{Before: before, After: after})
+	Classify(unmasked, Evidence{Before: before, After: after})
+	if masked.Regions[0].Significance <= unmasked.Regions[0].Significance {
+		t.Errorf("masked significance %v, want above the unmasked %v", masked.Regions[0].Significance, unmasked.Regions[0].Significance)
+	}
+
+	// A mask that does not match the grid is ignored.
+	wrong := &pb.ChangeResponse{
+		Regions:    []*pb.ChangeRegion{changeRegion(0, 0, 10, 10)},
+		ChangeMask: pngMask(t, 30, 30, image.Rect(0, 0, 5, 5)),
+	}
+	Classify(wrong, Evidence{Before: before, After: after})
+	if wrong.Regions[0].Significance != unmasked.Regions[0].Significance {
+		t.Errorf("mismatched mask: significance %v, want %v", wrong.Regions[0].Significance, unmasked.Regions[0].Significance)
+	}
+}
+
+func TestClassifyGeoreferenced(t *testing.T) {
+	// 10 m UTM pixels: a 10×10 region is one hectare.
+	g := raster.GeoTransform{OriginX: 500000, OriginY: 4200000, PixelWidth: 10, PixelHeight: 10}
+	grey := uniform(100)
+	grey.Geo, grey.EPSG = g, 32633
+
+	// A 20 m NDVI raster of the same area, vegetated in the region only.
+	ndviBefore := raster.New(20, 20, 1)
+	ndviBefore.Geo = raster.GeoTransform{OriginX: 500000, OriginY: 4200000, PixelWidth: 20, PixelHeight: 20}
+	ndviBefore.EPSG = 32633
+	for y := 5; y < 10; y++ {
+		for x := 5; x < 10; x++ {
+			ndviBefore.Bands[0][y*20+x] = 0.7
+		}
+	}
+	ndviAfter := raster.New(20, 20, 1)
+	ndviAfter.Geo, ndviAfter.EPSG = ndviBefore.Geo, ndviBefore.EPSG
+
+	// A ship placed by its geo center in the region, whatever its box says.
+	shipAt, err := raster.PixelToPoint(g, 32633, 15, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ship := detection("ship", 35, 35, 37, 37)
+	ship.GeoCenter = &report.GeoPoint{Latitude: shipAt.Lat, Longitude: shipAt.Lon}
+
+	for _, c := range []struct {
+		name string
+		ev   Evidence
+		want string
+	}{
+		{"index on another grid", Evidence{Before: grey, After: grey,
+			BeforeIndices: map[string]*raster.Raster{"ndvi": ndviBefore},
+			AfterIndices:  map[string]*raster.Raster{"ndvi": ndviAfter}}, TypeVegetation},
+		{"ship by geo center", Evidence{Before: grey, After: grey,
+			AfterDetections: []report.Detection{ship}}, TypeArrival},
+	} {
+		resp := &pb.ChangeResponse{Regions: []*pb.ChangeRegion{changeRegion(10, 10, 20, 20)}}
+		Classify(resp, c.ev)
+		if r := resp.Regions[0]; r.ChangeType != c.want || r.Significance < 0.75 {
+			t.Errorf("%s: %s, significance %v; want %s with the full area term", c.name, r.ChangeType, r.Significance, c.want)
+		}
+	}
+}
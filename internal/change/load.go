@@ -0,0 +1,97 @@
+package change
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/raster"
+)
+
+// Load reads the before and after images of a comparison, GeoTIFF, PNG or
+// JPEG, onto the before image's pixel grid. For two georeferenced
+// GeoTIFFs the after image is resampled onto that grid, so scenes from
+// different tiles or passes line up, and bbox, if set, limits the
+// comparison to a window of the before image. Other images are compared
+// pixel for pixel, the after image resized to the before image if
+// needed, as the AI worker does.
+func Load(beforePath, afterPath string, bbox *geo.BBox) (before, after *raster.Raster, err error) {
+	bt, err := openTIFF(beforePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bt != nil {
+		defer bt.Close()
+	}
+	at, err := openTIFF(afterPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if at != nil {
+		defer at.Close()
+	}
+
+	if bt != nil && at != nil && bt.Geo.Valid() && at.Geo.Valid() {
+		win := raster.Window{Width: bt.Width, Height: bt.Height}
+		if bbox != nil {
+			if win, _, err = bt.BBoxWindow(*bbox); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", beforePath, err)
+			}
+			if win.Empty() {
+				return nil, nil, fmt.Errorf("%s does not cover the AOI", beforePath)
+			}
+		}
+		if before, err = bt.Read(win, 1); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", beforePath, err)
+		}
+		if after, err = raster.MosaicGrid([]*raster.GeoTIFF{at}, before.Geo, before.EPSG, before.Width, before.Height); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", afterPath, err)
+		}
+		return before, after, nil
+	}
+
+	if bbox != nil {
+		return nil, nil, errors.New("cropping to an AOI needs two georeferenced GeoTIFFs")
+	}
+	if before, err = readAll(beforePath, bt); err != nil {
+		return nil, nil, err
+	}
+	if after, err = readAll(afterPath, at); err != nil {
+		return nil, nil, err
+	}
+	if after.Width != before.Width || after.Height != before.Height {
+		after = raster.Resample(after, before.Width, before.Height, raster.Bilinear)
+	}
+	return before, after, nil
+}
+
+// openTIFF opens path if it is a GeoTIFF and returns nil otherwise.
+func openTIFF(path string) (*raster.GeoTIFF, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tif", ".tiff":
+		return raster.Open(path)
+	}
+	return nil, nil
+}
+
+func readAll(path string, t *raster.GeoTIFF) (*raster.Raster, error) {
+	if t != nil {
+		return t.ReadAll()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return raster.FromImage(img), nil
+}
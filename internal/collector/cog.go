@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	cogBlockSize = 256 << 10
+	cogMaxBlocks = 64
+)
+
+// httpReaderAt reads a remote file with HTTP range requests, so a windowed
+// read of a cloud-optimized GeoTIFF only transfers the header and the
+// tiles it touches. Fetched blocks are cached.
+type httpReaderAt struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+
+	mu     sync.Mutex
+	blocks map[int64][]byte
+	whole  []byte // set if the server ignored the range request
+}
+
+func newHTTPReaderAt(ctx context.Context, client *http.Client, url string) *httpReaderAt {
+	return &httpReaderAt{ctx: ctx, client: client, url: url, blocks: make(map[int64][]byte)}
+}
+
+func (h *httpReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		block, err := h.block(pos / cogBlockSize)
+		if err != nil {
+			return n, err
+		}
+		start := pos % cogBlockSize
+		if start >= int64(len(block)) {
+			return n, io.EOF
+		}
+		c := copy(p[n:], block[start:])
+		n += c
+		if len(block) < cogBlockSize && n < len(p) {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+func (h *httpReaderAt) block(idx int64) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.whole != nil {
+		return h.wholeBlock(idx), nil
+	}
+	if b, ok := h.blocks[idx]; ok {
+		return b, nil
+	}
+
+	req, err := http.NewRequestWithContext(h.ctx, "GET", h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", idx*cogBlockSize, (idx+1)*cogBlockSize-1))
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("range request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(h.blocks) >= cogMaxBlocks {
+			for k := range h.blocks {
+				delete(h.blocks, k)
+				break
+			}
+		}
+		h.blocks[idx] = b
+		return b, nil
+	case http.StatusOK:
+		if h.whole, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+		return h.wholeBlock(idx), nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("range request returned %d", resp.StatusCode)
+	}
+}
+
+// wholeBlock slices block idx out of a fully downloaded file.
+func (h *httpReaderAt) wholeBlock(idx int64) []byte {
+	start := idx * cogBlockSize
+	if start >= int64(len(h.whole)) {
+		return nil
+	}
+	return h.whole[start:min(start+cogBlockSize, int64(len(h.whole)))]
+}
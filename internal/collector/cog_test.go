@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestHTTPReaderAt(t *testing.T) {
+	data := make([]byte, 2*cogBlockSize+1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	var requests atomic.Int32
+	ranged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.ServeContent(w, r, "f", testTime, bytes.NewReader(data))
+	}))
+	defer ranged.Close()
+	whole := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(data) // ignores Range
+	}))
+	defer whole.Close()
+
+	for _, c := range []struct {
+		name     string
+		url      string
+		requests int32
+	}{{"ranged", ranged.URL, 2}, {"whole", whole.URL, 1}} {
+		requests.Store(0)
+		h := newHTTPReaderAt(context.Background(), http.DefaultClient, c.url)
+
+		// Spans the first block boundary.
+		p := make([]byte, 100)
+		off := int64(cogBlockSize - 50)
+		if n, err := h.ReadAt(p, off); n != 100 || err != nil || !bytes.Equal(p, data[off:off+100]) {
+			t.Errorf("%s: across blocks: %d, %v", c.name, n, err)
+		}
+		// Cached: no further requests.
+		if n, err := h.ReadAt(p[:10], 20); n != 10 || err != nil || !bytes.Equal(p[:10], data[20:30]) {
+			t.Errorf("%s: cached read: %d, %v", c.name, n, err)
+		}
+		if got := requests.Load(); got != c.requests {
+			t.Errorf("%s: %d requests, want %d", c.name, got, c.requests)
+		}
+
+		// Runs past the end of the file.
+		off = int64(len(data) - 10)
+		if n, err := h.ReadAt(p, off); n != 10 || err != io.EOF || !bytes.Equal(p[:10], data[off:]) {
+			t.Errorf("%s: at end: %d, %v", c.name, n, err)
+		}
+		if n, err := h.ReadAt(p, int64(len(data)+cogBlockSize)); n != 0 || err != io.EOF {
+			t.Errorf("%s: past end: %d, %v", c.name, n, err)
+		}
+	}
+}
+
+func TestHTTPReaderAtError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	h := newHTTPReaderAt(context.Background(), http.DefaultClient, srv.URL)
+	if _, err := h.ReadAt(make([]byte, 8), 0); err == nil {
+		t.Error("403: want an error")
+	}
+}
@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+// coverageGrid is the number of sample points per axis laid over the AOI's
+// bounding box to measure coverage.
+const coverageGrid = 64
+
+// PlanOptions configures PlanCoverage.
+type PlanOptions struct {
+	// MinCoverage is the share of the AOI a plan should cover; defaults to 0.99.
+	MinCoverage float64
+}
+
+// CoveragePlan is a set of scenes that together cover an AOI.
+type CoveragePlan struct {
+	Scenes   []ImageResult // in priority order: earlier scenes win where they overlap
+	Coverage float64       // share of the AOI covered by the union of footprints
+	SameDay  bool          // all scenes were acquired on the same day
+}
+
+// PlanCoverage picks a small set of candidates whose footprints cover the
+// AOI. It prefers scenes from a single day, so the mosaic shows one moment
+// in time, and falls back to mixing days when no day covers MinCoverage.
+// Within a set, scenes are chosen greedily by how much uncovered AOI they
+// add, breaking ties by clear share of the AOI. Coverage is measured on a
+// grid of sample points inside the AOI.
+func PlanCoverage(aoi geo.MultiPolygon, candidates []ImageResult, opts PlanOptions) (*CoveragePlan, error) {
+	if len(aoi) == 0 {
+		return nil, errors.New("empty AOI")
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("no candidate scenes")
+	}
+	if opts.MinCoverage <= 0 {
+		opts.MinCoverage = 0.99
+	}
+	samples := aoiSamples(aoi)
+
+	// covers[i][j] reports whether candidate i's footprint contains sample j.
+	covers := make([][]bool, len(candidates))
+	for i, c := range candidates {
+		covers[i] = make([]bool, len(samples))
+		fp, err := c.Footprint()
+		if err != nil {
+			continue
+		}
+		for j, p := range samples {
+			covers[i][j] = fp.Contains(p)
+		}
+	}
+
+	byDay := make(map[string][]int)
+	var days []string
+	for i, c := range candidates {
+		d := c.Date.UTC().Format("2006-01-02")
+		if _, ok := byDay[d]; !ok {
+			days = append(days, d)
+		}
+		byDay[d] = append(byDay[d], i)
+	}
+	sort.Strings(days)
+
+	var best []int
+	var bestCov float64
+	better := func(set []int, cov float64) bool {
+		full, bestFull := cov >= opts.MinCoverage, bestCov >= opts.MinCoverage
+		switch {
+		case best == nil:
+			return true
+		case full != bestFull:
+			return full
+		case full && len(set) != len(best):
+			return len(set) < len(best)
+		case cov != bestCov:
+			return cov > bestCov
+		}
+		return meanClear(candidates, set) > meanClear(candidates, best)
+	}
+
+	for _, d := range days {
+		set, cov := greedyCover(candidates, covers, byDay[d], opts.MinCoverage)
+		if better(set, cov) {
+			best, bestCov = set, cov
+		}
+	}
+	if bestCov < opts.MinCoverage {
+		all := make([]int, len(candidates))
+		for i := range all {
+			all[i] = i
+		}
+		if set, cov := greedyCover(candidates, covers, all, opts.MinCoverage); cov > bestCov {
+			best, bestCov = set, cov
+		}
+	}
+	if len(best) == 0 || bestCov == 0 {
+		return nil, errors.New("no candidate scene covers the AOI")
+	}
+
+	plan := &CoveragePlan{Coverage: bestCov, SameDay: true}
+	for _, i := range best {
+		plan.Scenes = append(plan.Scenes, candidates[i])
+		if candidates[i].Date.UTC().Format("2006-01-02") != candidates[best[0]].Date.UTC().Format("2006-01-02") {
+			plan.SameDay = false
+		}
+	}
+	return plan, nil
+}
+
+// greedyCover repeatedly adds the pool candidate covering the most
+// still-uncovered samples until minCoverage is reached or nothing helps.
+func greedyCover(candidates []ImageResult, covers [][]bool, pool []int, minCoverage float64) ([]int, float64) {
+	n := len(covers[0])
+	covered := make([]bool, n)
+	count := 0
+	var set []int
+	used := make(map[int]bool)
+	for float64(count)/float64(n) < minCoverage {
+		pick, gain := -1, 0
+		for _, i := range pool {
+			if used[i] {
+				continue
+			}
+			g := 0
+			for j, c := range covers[i] {
+				if c && !covered[j] {
+					g++
+				}
+			}
+			if g > gain || (g == gain && g > 0 && candidates[i].aoiClear() > candidates[pick].aoiClear()) {
+				pick, gain = i, g
+			}
+		}
+		if pick < 0 {
+			break
+		}
+		used[pick] = true
+		set = append(set, pick)
+		for j, c := range covers[pick] {
+			if c && !covered[j] {
+				covered[j] = true
+				count++
+			}
+		}
+	}
+	return set, float64(count) / float64(n)
+}
+
+func meanClear(candidates []ImageResult, set []int) float64 {
+	var sum float64
+	for _, i := range set {
+		sum += candidates[i].aoiClear()
+	}
+	return sum / float64(len(set))
+}
+
+// aoiSamples lays a grid over the AOI's bounding box and keeps the points
+// inside it; tiny AOIs that no grid point hits are represented by the box
+// center.
+func aoiSamples(aoi geo.MultiPolygon) []geo.Point {
+	b := aoi.BBox()
+	w := b.WidthDeg()
+	var pts []geo.Point
+	for i := 0; i < coverageGrid; i++ {
+		for j := 0; j < coverageGrid; j++ {
+			p := geo.Point{
+				Lat: b.South + (float64(i)+0.5)/coverageGrid*(b.North-b.South),
+				Lon: b.West + (float64(j)+0.5)/coverageGrid*w,
+			}
+			if p.Lon > 180 {
+				p.Lon -= 360
+			}
+			if aoi.Contains(p) {
+				pts = append(pts, p)
+			}
+		}
+	}
+	if len(pts) == 0 {
+		pts = append(pts, b.Center())
+	}
+	return pts
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+func boxFootprint(west, south, east, north float64) geo.MultiPolygon {
+	return geo.MultiPolygon{{geo.Ring{
+		{Lat: south, Lon: west}, {Lat: south, Lon: east}, {Lat: north, Lon: east}, {Lat: north, Lon: west},
+	}}}
+}
+
+func scene(id string, day int, cloud float64, fp geo.MultiPolygon) ImageResult {
+	return ImageResult{ID: id, Date: time.Date(2024, 6, day, 10, 30, 0, 0, time.UTC), CloudCover: cloud, Geometry: fp}
+}
+
+func TestPlanCoverage(t *testing.T) {
+	aoi := boxFootprint(10, 40, 11, 41)
+	west := boxFootprint(9.8, 39.8, 10.5, 41.2)
+	east := boxFootprint(10.5, 39.8, 11.2, 41.2)
+	all := boxFootprint(9.9, 39.9, 11.1, 41.1)
+
+	for _, c := range []struct {
+		name       string
+		candidates []ImageResult
+		want       []string
+		coverage   float64
+		sameDay    bool
+	}{
+		{"one scene beats two", []ImageResult{
+			scene("W1", 1, 5, west), scene("E1", 1, 5, east), scene("A2", 2, 40, all),
+		}, []string{"A2"}, 1, true},
+		{"same day pair", []ImageResult{
+			scene("W1", 1, 5, west), scene("E1", 1, 10, east), scene("W2", 2, 0, west),
+		}, []string{"W1", "E1"}, 1, true},
+		{"clearer day", []ImageResult{
+			scene("A1", 1, 30, all), scene("A2", 2, 10, all), scene("A3", 3, 20, all),
+		}, []string{"A2"}, 1, true},
+		{"clearer scene first", []ImageResult{
+			scene("A1", 1, 30, all), scene("B1", 1, 10, all),
+		}, []string{"B1"}, 1, true},
+		{"days mixed", []ImageResult{
+			scene("W1", 1, 5, west), scene("E2", 2, 5, east),
+		}, []string{"W1", "E2"}, 1, false},
+		{"partial", []ImageResult{
+			scene("W1", 1, 5, west), scene("U1", 1, 5, nil),
+		}, []string{"W1"}, 0.5, true},
+		{"AOI cloud preferred", []ImageResult{
+			{ID: "A1", Date: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), CloudCover: 0, Geometry: all,
+				AOICloud: &AOICloud{ClearFraction: 0.4}},
+			scene("A2", 2, 50, all),
+		}, []string{"A2"}, 1, true},
+	} {
+		plan, err := PlanCoverage(aoi, c.candidates, PlanOptions{})
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		var ids []string
+		for _, s := range plan.Scenes {
+			ids = append(ids, s.ID)
+		}
+		if len(ids) != len(c.want) || plan.Coverage != c.coverage || plan.SameDay != c.sameDay {
+			t.Errorf("%s: got %v coverage %v same day %t, want %v %v %t", c.name, ids, plan.Coverage, plan.SameDay,
+				c.want, c.coverage, c.sameDay)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != c.want[i] {
+				t.Errorf("%s: got %v, want %v", c.name, ids, c.want)
+				break
+			}
+		}
+	}
+
+	elsewhere := []ImageResult{scene("X1", 1, 0, boxFootprint(20, 40, 21, 41))}
+	for _, c := range []struct {
+		name       string
+		aoi        geo.MultiPolygon
+		candidates []ImageResult
+	}{
+		{"empty AOI", nil, elsewhere},
+		{"no candidates", aoi, nil},
+		{"no overlap", aoi, elsewhere},
+	} {
+		if _, err := PlanCoverage(c.aoi, c.candidates, PlanOptions{}); err == nil {
+			t.Errorf("%s: want an error", c.name)
+		}
+	}
+}
+
+func TestPlanCoverageMinCoverage(t *testing.T) {
+	aoi := boxFootprint(10, 40, 11, 41)
+	// Day 1 covers 90% of the AOI in one scene; day 2 needs two for all of it.
+	candidates := []ImageResult{
+		scene("P1", 1, 0, boxFootprint(9.9, 39.9, 10.9, 41.1)),
+		scene("W2", 2, 0, boxFootprint(9.9, 39.9, 10.5, 41.1)),
+		scene("E2", 2, 0, boxFootprint(10.5, 39.9, 11.1, 41.1)),
+	}
+	for _, c := range []struct {
+		min  float64
+		want int
+	}{{0, 2}, {0.85, 1}} {
+		plan, err := PlanCoverage(aoi, candidates, PlanOptions{MinCoverage: c.min})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan.Scenes) != c.want {
+			t.Errorf("MinCoverage %v: %d scenes, want %d", c.min, len(plan.Scenes), c.want)
+		}
+	}
+}
+
+func TestAOISamplesTinyAOI(t *testing.T) {
+	aoi := geo.MultiPolygon{{geo.Ring{{Lat: 40, Lon: 10}, {Lat: 40, Lon: 10.0001}, {Lat: 40.00001, Lon: 10}}}}
+	pts := aoiSamples(aoi)
+	if len(pts) == 0 {
+		t.Fatal("no samples")
+	}
+	for _, p := range pts {
+		if !aoi.BBox().Contains(p) {
+			t.Errorf("sample %v outside the AOI's box", p)
+		}
+	}
+}
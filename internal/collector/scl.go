@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/raster"
+)
+
+// Sentinel-2 L2A Scene Classification Layer values.
+const (
+	sclNoData      = 0
+	sclDefective   = 1
+	sclCloudShadow = 3
+	sclCloudMedium = 8
+	sclCloudHigh   = 9
+	sclThinCirrus  = 10
+)
+
+// sclMaxSamplesAxis bounds the SCL pixels read per axis for large AOIs.
+const sclMaxSamplesAxis = 512
+
+// AOICloud is the cloud situation over an area of interest, estimated
+// from the SCL band rather than the tile-wide eo:cloud_cover.
+type AOICloud struct {
+	CloudFraction float64 // cloudy share of the valid pixels (clouds, cirrus, cloud shadow)
+	ClearFraction float64 // usable share of the whole AOI: valid and cloud-free
+	Coverage      float64 // share of the AOI with valid data in this scene
+}
+
+// SCLCloud computes AOI cloud statistics from an SCL raster window.
+// aoiPixels is the number of pixels the full AOI spans at the window's
+// sampling, which exceeds the window when the AOI runs off the tile.
+func SCLCloud(scl *raster.Raster, aoiPixels int) AOICloud {
+	var valid, cloudy int
+	for _, v := range scl.Bands[0] {
+		switch int(v) {
+		case sclNoData, sclDefective:
+			continue
+		case sclCloudShadow, sclCloudMedium, sclCloudHigh, sclThinCirrus:
+			cloudy++
+		}
+		valid++
+	}
+	if aoiPixels < len(scl.Bands[0]) {
+		aoiPixels = len(scl.Bands[0])
+	}
+	var c AOICloud
+	if aoiPixels > 0 {
+		c.Coverage = float64(valid) / float64(aoiPixels)
+		c.ClearFraction = float64(valid-cloudy) / float64(aoiPixels)
+	}
+	if valid > 0 {
+		c.CloudFraction = float64(cloudy) / float64(valid)
+	} else {
+		c.CloudFraction = 1
+	}
+	return c
+}
+
+// AOICloudCover estimates cloud cover over bbox by reading only the
+// window of the scene's SCL asset that covers it, using HTTP range
+// requests against the cloud-optimized GeoTIFF.
+func (s *Sentinel2) AOICloudCover(ctx context.Context, result ImageResult, bbox geo.BBox) (AOICloud, error) {
+	href, ok := result.Assets["SCL"]
+	if !ok {
+		return AOICloud{}, fmt.Errorf("scene %s has no SCL asset", result.ID)
+	}
+	signed, err := s.signURL(ctx, href)
+	if err != nil {
+		return AOICloud{}, fmt.Errorf("sign SCL URL: %w", err)
+	}
+
+	tif, err := raster.NewGeoTIFF(newHTTPReaderAt(ctx, s.httpClient, signed))
+	if err != nil {
+		return AOICloud{}, fmt.Errorf("open SCL: %w", err)
+	}
+	win, full, err := tif.BBoxWindow(bbox)
+	if err != nil {
+		return AOICloud{}, err
+	}
+	if win.Empty() {
+		return AOICloud{CloudFraction: 1}, nil
+	}
+
+	step := max(1, (max(full.Width, full.Height)+sclMaxSamplesAxis-1)/sclMaxSamplesAxis)
+	scl, err := tif.Read(win, step)
+	if err != nil {
+		return AOICloud{}, fmt.Errorf("read SCL: %w", err)
+	}
+	aoiPixels := ((full.Width + step - 1) / step) * ((full.Height + step - 1) / step)
+	return SCLCloud(scl, aoiPixels), nil
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/raster"
+)
+
+// sclPixel is the pixel size of the test SCL raster, in degrees; a power
+// of two keeps pixel edges exact.
+const sclPixel = 1.0 / 64
+
+// testSCL returns a 20x20 EPSG:4326 SCL raster with its north-west corner
+// at 50 N, 10 E: cloud (9) in the top-left quarter, no data (0) in rows 13
+// and below, vegetation (4) elsewhere.
+func testSCL() *raster.Raster {
+	r := raster.New(20, 20, 1)
+	r.Geo = raster.GeoTransform{OriginX: 10, OriginY: 50, PixelWidth: sclPixel, PixelHeight: sclPixel}
+	r.EPSG = raster.EPSGWGS84
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			v := float32(4)
+			switch {
+			case y >= 13:
+				v = sclNoData
+			case x < 10 && y < 10:
+				v = sclCloudHigh
+			}
+			r.Bands[0][y*20+x] = v
+		}
+	}
+	return r
+}
+
+// pixelBox returns the geographic box of test SCL pixels [x0, x1) x [y0, y1).
+func pixelBox(x0, y0, x1, y1 int) geo.BBox {
+	return geo.BBox{
+		West: 10 + float64(x0)*sclPixel, East: 10 + float64(x1)*sclPixel,
+		North: 50 - float64(y0)*sclPixel, South: 50 - float64(y1)*sclPixel,
+	}
+}
+
+func TestSCLCloud(t *testing.T) {
+	scl := raster.New(5, 2, 1)
+	copy(scl.Bands[0], []float32{
+		sclNoData, sclDefective, sclCloudShadow, sclCloudMedium, sclThinCirrus,
+		4, 5, 6, 7, 11,
+	})
+	want := AOICloud{CloudFraction: 3.0 / 8, ClearFraction: 5.0 / 20, Coverage: 8.0 / 20}
+	if got := SCLCloud(scl, 20); !closeCloud(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	// An AOI smaller than the window counts the window.
+	want = AOICloud{CloudFraction: 3.0 / 8, ClearFraction: 5.0 / 10, Coverage: 8.0 / 10}
+	if got := SCLCloud(scl, 0); !closeCloud(got, want) {
+		t.Errorf("aoiPixels 0: got %+v, want %+v", got, want)
+	}
+	empty := raster.New(2, 1, 1)
+	if got := SCLCloud(empty, 2); got != (AOICloud{CloudFraction: 1}) {
+		t.Errorf("no valid pixels: got %+v", got)
+	}
+}
+
+func closeCloud(a, b AOICloud) bool {
+	return math.Abs(a.CloudFraction-b.CloudFraction) < 1e-9 &&
+		math.Abs(a.ClearFraction-b.ClearFraction) < 1e-9 &&
+		math.Abs(a.Coverage-b.Coverage) < 1e-9
+}
+
+// redirect sends every request, including URL signing, to a test server.
+type redirect struct{ to *url.URL }
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = r.to.Scheme, r.to.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestAOICloudCover(t *testing.T) {
+	var tif bytes.Buffer
+	if err := raster.WriteGeoTIFF(&tif, testSCL()); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/sas/v1/sign":
+			w.Write([]byte(`{"href": "https://signed.example/scl.tif?sig=x"}`))
+		case "/scl.tif":
+			http.ServeContent(w, r, "scl.tif", testTime, bytes.NewReader(tif.Bytes()))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	to, _ := url.Parse(srv.URL)
+	s := &Sentinel2{httpClient: &http.Client{Transport: redirect{to}}}
+	scene := ImageResult{ID: "S2A_TEST", Assets: map[string]string{"SCL": "https://blob.example/scl.tif"}}
+
+	for _, c := range []struct {
+		name string
+		bbox geo.BBox
+		want AOICloud
+	}{
+		// 100 pixels: 25 cloudy, 20 without data.
+		{"inside", pixelBox(5, 5, 15, 15), AOICloud{CloudFraction: 25.0 / 80, ClearFraction: 55.0 / 100, Coverage: 80.0 / 100}},
+		// Half the AOI lies east of the tile.
+		{"half off", pixelBox(15, 5, 25, 15), AOICloud{CloudFraction: 0, ClearFraction: 40.0 / 100, Coverage: 40.0 / 100}},
+		{"off tile", pixelBox(30, 5, 40, 15), AOICloud{CloudFraction: 1}},
+	} {
+		got, err := s.AOICloudCover(context.Background(), scene, c.bbox)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !closeCloud(got, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+
+	if _, err := s.AOICloudCover(context.Background(), ImageResult{ID: "S2A_NOSCL"}, pixelBox(5, 5, 15, 15)); err == nil {
+		t.Error("scene without SCL asset: want an error")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/clearclown/orbital-eye/internal/geo"
@@ -48,6 +49,7 @@ type STACFeature struct {
 	Properties STACProperties            `json:"properties"`
 	Assets     map[string]STACAsset      `json:"assets"`
 	Bbox       [4]float64                `json:"bbox"`
+	Geometry   json.RawMessage           `json:"geometry"`
 }
 
 type STACProperties struct {
@@ -55,6 +57,41 @@ type STACProperties struct {
 	CloudCover float64 `json:"eo:cloud_cover"`
 	GSD        float64 `json:"gsd"`
 	Platform   string  `json:"platform"`
+	MGRSTile   string  `json:"s2:mgrs_tile"`
+
+	// Sun and view geometry. Sentinel-2 items carry scene-mean solar
+	// angles; other collections use the STAC view extension.
+	S2SunAzimuth     *float64 `json:"s2:mean_solar_azimuth"`
+	S2SunZenith      *float64 `json:"s2:mean_solar_zenith"`
+	ViewSunAzimuth   *float64 `json:"view:sun_azimuth"`
+	ViewSunElevation *float64 `json:"view:sun_elevation"`
+	ViewAzimuth      *float64 `json:"view:azimuth"`
+	ViewIncidence    *float64 `json:"view:incidence_angle"`
+	ViewOffNadir     *float64 `json:"view:off_nadir"`
+}
+
+// SunView returns the item's sun and view angles, or nil if it has no
+// sun angles.
+func (p STACProperties) SunView() *geo.SunView {
+	var sv geo.SunView
+	switch {
+	case p.S2SunAzimuth != nil && p.S2SunZenith != nil:
+		sv.SunAzimuth, sv.SunElevation = *p.S2SunAzimuth, 90-*p.S2SunZenith
+	case p.ViewSunAzimuth != nil && p.ViewSunElevation != nil:
+		sv.SunAzimuth, sv.SunElevation = *p.ViewSunAzimuth, *p.ViewSunElevation
+	default:
+		return nil
+	}
+	if p.ViewAzimuth != nil {
+		zenith := p.ViewIncidence
+		if zenith == nil {
+			zenith = p.ViewOffNadir // close to the incidence angle for low orbits
+		}
+		if zenith != nil {
+			sv.ViewAzimuth, sv.ViewZenith, sv.HasView = *p.ViewAzimuth, *zenith, true
+		}
+	}
+	return &sv
 }
 
 type STACAsset struct {
@@ -68,18 +105,49 @@ type SearchParams struct {
 	DateTo     time.Time
 	MaxCloud   float64
 	MaxResults int
+	// RankByAOI estimates cloud cover over BBox from each candidate's SCL
+	// band, applies MaxCloud to that estimate instead of the tile-wide
+	// cloud cover, and ranks scenes by their clear share of the AOI.
+	RankByAOI bool
 }
 
+// aoiCandidateFactor widens the STAC query when ranking by AOI cloud, since
+// tile-wide cloud cover is a poor predictor of the AOI's.
+const aoiCandidateFactor = 4
+
+// aoiCloudWorkers bounds concurrent SCL reads.
+const aoiCloudWorkers = 4
+
 type ImageResult struct {
 	ID         string
 	Date       time.Time
 	CloudCover float64
 	GSD        float64
 	Platform   string
+	Tile       string           // Sentinel-2 MGRS tile, e.g. "49QBB"
+	AOICloud   *AOICloud        // set when searched with RankByAOI and the SCL read succeeded
+	Geometry   geo.MultiPolygon // data footprint from the STAC item, if it parsed
+	SunView    *geo.SunView     // sun and view angles, if the item has them
 	LocalPath  string
 	Assets     map[string]string
 }
 
+// Footprint returns the scene's data footprint from its STAC geometry,
+// falling back to the nominal footprint of its MGRS tile.
+func (r ImageResult) Footprint() (geo.MultiPolygon, error) {
+	if len(r.Geometry) > 0 {
+		return r.Geometry, nil
+	}
+	if r.Tile == "" {
+		return nil, fmt.Errorf("scene %s has no footprint", r.ID)
+	}
+	fp, err := geo.S2TileFootprint(r.Tile)
+	if err != nil {
+		return nil, err
+	}
+	return geo.MultiPolygon{fp}, nil
+}
+
 func NewSentinel2(cacheDir string) *Sentinel2 {
 	return &Sentinel2{
 		httpClient: &http.Client{Timeout: 120 * time.Second},
@@ -91,10 +159,101 @@ func (s *Sentinel2) Search(ctx context.Context, params SearchParams) ([]ImageRes
 	if params.MaxResults == 0 {
 		params.MaxResults = 10
 	}
+	query := params
+	if params.RankByAOI {
+		query.MaxCloud = 100
+		query.MaxResults = params.MaxResults * aoiCandidateFactor
+	}
+
+	// STAC servers reject boxes with West > East, so AOIs crossing the
+	// antimeridian are searched as two boxes and merged.
+	seen := make(map[string]bool)
+	var results []ImageResult
+	for _, part := range params.BBox.Normalize().Split() {
+		found, err := s.searchBBox(ctx, query, part)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range found {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				results = append(results, r)
+			}
+		}
+	}
+
+	if params.RankByAOI {
+		results = s.rankByAOI(ctx, results, params)
+	} else {
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].CloudCover < results[j].CloudCover
+		})
+	}
+	if len(results) > params.MaxResults {
+		results = results[:params.MaxResults]
+	}
+
+	return results, nil
+}
+
+// rankByAOI estimates AOI cloud cover for each result, drops those over
+// MaxCloud and sorts the rest by clear share of the AOI, newest first on
+// ties. Scenes whose SCL cannot be read are judged by tile cloud cover.
+func (s *Sentinel2) rankByAOI(ctx context.Context, results []ImageResult, params SearchParams) []ImageResult {
+	bbox := params.BBox.Normalize()
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, aoiCloudWorkers)
+	for i := range results {
+		wg.Add(1)
+		go func(r *ImageResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if c, err := s.AOICloudCover(ctx, *r, bbox); err == nil {
+				r.AOICloud = &c
+			}
+		}(&results[i])
+	}
+	wg.Wait()
 
+	kept := results[:0]
+	for _, r := range results {
+		if r.aoiCloudPercent() <= params.MaxCloud && r.aoiClear() > 0 {
+			kept = append(kept, r)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		ci, cj := kept[i].aoiClear(), kept[j].aoiClear()
+		if ci != cj {
+			return ci > cj
+		}
+		return kept[i].Date.After(kept[j].Date)
+	})
+	return kept
+}
+
+// aoiCloudPercent is the AOI cloud cover in percent, falling back to the
+// tile-wide value.
+func (r ImageResult) aoiCloudPercent() float64 {
+	if r.AOICloud != nil {
+		return r.AOICloud.CloudFraction * 100
+	}
+	return r.CloudCover
+}
+
+// aoiClear is the clear share of the AOI, falling back to the tile-wide
+// clear share.
+func (r ImageResult) aoiClear() float64 {
+	if r.AOICloud != nil {
+		return r.AOICloud.ClearFraction
+	}
+	return 1 - r.CloudCover/100
+}
+
+func (s *Sentinel2) searchBBox(ctx context.Context, params SearchParams, bbox geo.BBox) ([]ImageResult, error) {
 	reqBody := STACSearchRequest{
 		Collections: []string{"sentinel-2-l2a"},
-		Bbox:        [4]float64{params.BBox.West, params.BBox.South, params.BBox.East, params.BBox.North},
+		Bbox:        [4]float64{bbox.West, bbox.South, bbox.East, bbox.North},
 		Datetime:    fmt.Sprintf("%s/%s", params.DateFrom.Format(time.RFC3339), params.DateTo.Format(time.RFC3339)),
 		Limit:       params.MaxResults,
 		Query: map[string]interface{}{
@@ -105,6 +264,33 @@ func (s *Sentinel2) Search(ctx context.Context, params SearchParams) ([]ImageRes
 		},
 	}
 
+	return s.postSearch(ctx, reqBody)
+}
+
+// STACItemsRequest looks up items by ID.
+type STACItemsRequest struct {
+	Collections []string `json:"collections"`
+	IDs         []string `json:"ids"`
+	Limit       int      `json:"limit"`
+}
+
+// Lookup returns the scene with the given ID.
+func (s *Sentinel2) Lookup(ctx context.Context, id string) (ImageResult, error) {
+	results, err := s.postSearch(ctx, STACItemsRequest{
+		Collections: []string{"sentinel-2-l2a"},
+		IDs:         []string{id},
+		Limit:       1,
+	})
+	if err != nil {
+		return ImageResult{}, err
+	}
+	if len(results) == 0 {
+		return ImageResult{}, fmt.Errorf("scene %s not found", id)
+	}
+	return results[0], nil
+}
+
+func (s *Sentinel2) postSearch(ctx context.Context, reqBody any) ([]ImageResult, error) {
 	bodyBytes, _ := json.Marshal(reqBody)
 	req, err := http.NewRequestWithContext(ctx, "POST", stacSearchURL, strings.NewReader(string(bodyBytes)))
 	if err != nil {
@@ -137,18 +323,22 @@ func (s *Sentinel2) Search(ctx context.Context, params SearchParams) ([]ImageRes
 			CloudCover: f.Properties.CloudCover,
 			GSD:        f.Properties.GSD,
 			Platform:   f.Properties.Platform,
+			Tile:       f.Properties.MGRSTile,
+			SunView:    f.Properties.SunView(),
 			Assets:     make(map[string]string),
 		}
+		if r.Tile == "" {
+			r.Tile, _ = geo.S2TileFromID(f.ID)
+		}
+		if len(f.Geometry) > 0 {
+			r.Geometry, _ = geo.ParseGeoJSON(f.Geometry)
+		}
 		for k, v := range f.Assets {
 			r.Assets[k] = v.Href
 		}
 		results = append(results, r)
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].CloudCover < results[j].CloudCover
-	})
-
 	return results, nil
 }
 
@@ -210,27 +400,32 @@ func (s *Sentinel2) Download(ctx context.Context, result ImageResult, bands []st
 			return "", fmt.Errorf("write %s: %w", band, err)
 		}
 
-		fmt.Printf("Downloaded %s → %s\n", band, outPath)
+		fmt.Fprintf(os.Stderr, "Downloaded %s → %s\n", band, outPath)
 	}
 
 	return outDir, nil
 }
 
 func (s *Sentinel2) FetchBest(ctx context.Context, lat, lon, radiusKm, maxCloud float64) (*ImageResult, string, error) {
-	bbox := geo.BBoxFromCenter(geo.Point{Lat: lat, Lon: lon}, radiusKm)
+	return s.FetchBestBBox(ctx, geo.BBoxFromCenter(geo.Point{Lat: lat, Lon: lon}, radiusKm), maxCloud)
+}
 
+// FetchBestBBox downloads the least cloudy recent scene intersecting bbox.
+func (s *Sentinel2) FetchBestBBox(ctx context.Context, bbox geo.BBox, maxCloud float64) (*ImageResult, string, error) {
 	results, err := s.Search(ctx, SearchParams{
 		BBox:       bbox,
 		DateFrom:   time.Now().AddDate(0, -3, 0),
 		DateTo:     time.Now(),
 		MaxCloud:   maxCloud,
 		MaxResults: 5,
+		RankByAOI:  true,
 	})
 	if err != nil {
 		return nil, "", err
 	}
 	if len(results) == 0 {
-		return nil, "", fmt.Errorf("no imagery found for (%.4f, %.4f) with <%g%% cloud", lat, lon, maxCloud)
+		c := bbox.Center()
+		return nil, "", fmt.Errorf("no imagery found for (%.4f, %.4f) with <%g%% cloud", c.Lat, c.Lon, maxCloud)
 	}
 
 	best := &results[0]
@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+func TestSTACSunView(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		props string
+		want  *geo.SunView
+	}{
+		{"sentinel-2", `{"s2:mean_solar_azimuth": 150.5, "s2:mean_solar_zenith": 32}`,
+			&geo.SunView{SunAzimuth: 150.5, SunElevation: 58}},
+		{"view extension", `{"view:sun_azimuth": 120, "view:sun_elevation": 40, "view:azimuth": 95, "view:incidence_angle": 12}`,
+			&geo.SunView{SunAzimuth: 120, SunElevation: 40, ViewAzimuth: 95, ViewZenith: 12, HasView: true}},
+		{"off-nadir fallback", `{"view:sun_azimuth": 120, "view:sun_elevation": 40, "view:azimuth": 95, "view:off_nadir": 11}`,
+			&geo.SunView{SunAzimuth: 120, SunElevation: 40, ViewAzimuth: 95, ViewZenith: 11, HasView: true}},
+		{"view azimuth alone", `{"view:sun_azimuth": 120, "view:sun_elevation": 40, "view:azimuth": 95}`,
+			&geo.SunView{SunAzimuth: 120, SunElevation: 40}},
+		{"no sun", `{"view:azimuth": 95, "view:off_nadir": 11, "s2:mean_solar_azimuth": 150}`, nil},
+	} {
+		var p STACProperties
+		if err := json.Unmarshal([]byte(c.props), &p); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		got := p.SunView()
+		if (got == nil) != (c.want == nil) || (got != nil && *got != *c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+
+	"github.com/clearclown/orbital-eye/internal/preprocess"
 )
 
 type Config struct {
@@ -14,6 +16,10 @@ type Config struct {
 	Landsat     LandsatConfig   `json:"landsat"`
 	Planet      PlanetConfig    `json:"planet"`
 	Monitoring  MonitorConfig   `json:"monitoring"`
+
+	// Preprocess overrides the radiometric preprocessing presets per
+	// provider, e.g. {"sentinel2": {"low": 1, "high": 99, "gamma": 1.2}}.
+	Preprocess map[string]preprocess.Params `json:"preprocess"`
 }
 
 type AIWorkerConfig struct {
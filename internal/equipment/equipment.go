@@ -0,0 +1,154 @@
+// Package equipment loads the equipment reference catalog (data/equipment)
+// and ranks candidate equipment types for detections by their measured
+// dimensions.
+//
+// A catalog file holds one entry, an array of entries, or an object with
+// an "equipment" array, in the format documented in
+// data/equipment/README.md.
+package equipment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Entry is one equipment type.
+type Entry struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Category         string            `json:"category"`
+	Country          string            `json:"country,omitempty"`
+	LengthM          float64           `json:"length_m"`
+	BeamM            float64           `json:"beam_m,omitempty"`
+	WingspanM        float64           `json:"wingspan_m,omitempty"`
+	VisualSignatures map[string]string `json:"visual_signatures,omitempty"`
+}
+
+// WidthM is the across-track size seen from above: the wingspan for
+// aircraft, otherwise the beam. It is 0 if unknown.
+func (e *Entry) WidthM() float64 {
+	if e.WingspanM > 0 {
+		return e.WingspanM
+	}
+	return e.BeamM
+}
+
+// Family returns the broad object family of the entry's category.
+func (e *Entry) Family() string {
+	return categoryFamily(e.Category)
+}
+
+func (e *Entry) validate() error {
+	var errs []string
+	if e.ID == "" {
+		errs = append(errs, "missing id")
+	}
+	if e.Name == "" {
+		errs = append(errs, "missing name")
+	}
+	if e.Category == "" {
+		errs = append(errs, "missing category")
+	}
+	if e.LengthM <= 0 {
+		errs = append(errs, "length_m must be positive")
+	}
+	if e.BeamM < 0 || e.WingspanM < 0 {
+		errs = append(errs, "negative width")
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Catalog is a validated set of equipment entries.
+type Catalog struct {
+	Entries []*Entry
+}
+
+// New validates entries. IDs must be unique.
+func New(entries []*Entry) (*Catalog, error) {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, e := range entries {
+		if err := e.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("entry %d (%s): %w", i, e.ID, err))
+		}
+		if e.ID != "" && seen[e.ID] {
+			errs = append(errs, fmt.Errorf("entry %d: duplicate id %q", i, e.ID))
+		}
+		seen[e.ID] = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &Catalog{Entries: entries}, nil
+}
+
+// Load reads catalog files. A path that is a directory loads every *.json
+// file in it.
+func Load(paths ...string) (*Catalog, error) {
+	var entries []*Entry
+	var errs []error
+	for _, path := range paths {
+		files := []string{path}
+		if st, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if st.IsDir() {
+			files, _ = filepath.Glob(filepath.Join(path, "*.json"))
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			es, err := loadFile(file)
+			if err == nil {
+				_, err = New(es)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+				continue
+			}
+			entries = append(entries, es...)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return New(entries)
+}
+
+// loadFile parses one catalog file in any of the accepted layouts.
+func loadFile(path string) ([]*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(trimmed, "["):
+		var list []*Entry
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		return list, nil
+	case strings.Contains(trimmed, `"equipment"`):
+		var wrapper struct {
+			Equipment []*Entry `json:"equipment"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err == nil && wrapper.Equipment != nil {
+			return wrapper.Equipment, nil
+		}
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return []*Entry{&e}, nil
+}
+
+// Len returns the number of entries.
+func (c *Catalog) Len() int { return len(c.Entries) }
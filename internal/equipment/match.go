@@ -0,0 +1,256 @@
+package equipment
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// Attribute keys set on detections with candidates.
+const (
+	// AttrCandidates lists "id:score" pairs, best first, comma-separated.
+	AttrCandidates = "equipment_candidates"
+	// AttrTop is the name of the best candidate.
+	AttrTop = "equipment_top"
+)
+
+// Object families. Detection classes and catalog categories map onto
+// these so that, e.g., a "ship" detection is compared with frigates and
+// submarines but not with fighters.
+const (
+	FamilyVessel   = "vessel"
+	FamilyAircraft = "aircraft"
+	FamilyVehicle  = "vehicle"
+)
+
+// familyKeywords maps words and phrases of class and category names to
+// families, checked in order. Names are compared word by word, so "car"
+// matches "Car" and "cars" but not "carrier" or "cargo". An empty family
+// marks things that are not equipment despite a keyword in their name.
+var familyKeywords = []struct {
+	keyword, family string
+}{
+	{"shipping container", ""},
+	{"straddle carrier", ""},
+	{"storage tank", ""},
+	{"tanker aircraft", FamilyAircraft},
+	// Vessels before aircraft so "aircraft carrier" and "transport ship"
+	// resolve to vessels.
+	{"ship", FamilyVessel},
+	{"warship", FamilyVessel},
+	{"boat", FamilyVessel},
+	{"gunboat", FamilyVessel},
+	{"speedboat", FamilyVessel},
+	{"vessel", FamilyVessel},
+	{"submarine", FamilyVessel},
+	{"carrier", FamilyVessel},
+	{"destroyer", FamilyVessel},
+	{"frigate", FamilyVessel},
+	{"corvette", FamilyVessel},
+	{"cruiser", FamilyVessel},
+	{"landing", FamilyVessel},
+	{"tanker", FamilyVessel},
+	{"helicopter", FamilyAircraft},
+	{"airplane", FamilyAircraft},
+	{"aircraft", FamilyAircraft},
+	{"plane", FamilyAircraft},
+	{"seaplane", FamilyAircraft},
+	{"fighter", FamilyAircraft},
+	{"bomber", FamilyAircraft},
+	{"transport", FamilyAircraft},
+	{"awacs", FamilyAircraft},
+	{"drone", FamilyAircraft},
+	{"uav", FamilyAircraft},
+	{"tank", FamilyVehicle},
+	{"vehicle", FamilyVehicle},
+	{"truck", FamilyVehicle},
+	{"car", FamilyVehicle},
+	{"apc", FamilyVehicle},
+	{"ifv", FamilyVehicle},
+	{"launcher", FamilyVehicle},
+	{"tel", FamilyVehicle},
+	{"artillery", FamilyVehicle},
+	{"radar", FamilyVehicle},
+}
+
+// categoryFamily returns the family of a class or category name, or the
+// lower-cased name itself if no keyword matches.
+func categoryFamily(name string) string {
+	n := strings.ToLower(name)
+	words := nameWords(n)
+	for _, k := range familyKeywords {
+		if containsPhrase(words, strings.Fields(k.keyword)) {
+			if k.family == "" {
+				return n
+			}
+			return k.family
+		}
+	}
+	return n
+}
+
+// nameWords splits a lower-cased name into its runs of letters and digits,
+// so "small-vehicle", "tanker_aircraft" and "Fighter (jet)" split cleanly.
+func nameWords(n string) []string {
+	return strings.FieldsFunc(n, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsPhrase reports whether phrase occurs as consecutive words, the
+// last of them possibly plural.
+func containsPhrase(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		ok := true
+		for j, p := range phrase {
+			w := words[i+j]
+			if j == len(phrase)-1 {
+				w = strings.TrimSuffix(w, "s")
+			}
+			if w != p && words[i+j] != p {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchOptions configures candidate ranking.
+type MatchOptions struct {
+	// GSD is the image pixel size in meters. Box edges are taken as
+	// uncertain by about a pixel each.
+	GSD float64
+	// RelativeError is the share of an object's size by which detector
+	// boxes typically miss; defaults to 0.15.
+	RelativeError float64
+	// MaxSigma drops candidates more than this many standard deviations
+	// off in either dimension; defaults to 3.
+	MaxSigma float64
+	// TopK limits the returned candidates; defaults to 3.
+	TopK int
+}
+
+func (o *MatchOptions) defaults() {
+	if o.GSD <= 0 {
+		o.GSD = 10
+	}
+	if o.RelativeError <= 0 {
+		o.RelativeError = 0.15
+	}
+	if o.MaxSigma <= 0 {
+		o.MaxSigma = 3
+	}
+	if o.TopK <= 0 {
+		o.TopK = 3
+	}
+}
+
+// Candidate is an equipment type consistent with a detection.
+type Candidate struct {
+	Entry *Entry
+	// Score is the candidate's share of the likelihood among all
+	// plausible candidates, in (0, 1].
+	Score float64
+	// Sigma is the worse of the two dimensions' deviations, in standard
+	// deviations, at the best-fitting orientation.
+	Sigma float64
+}
+
+// orientationSteps is the number of headings tried between 0° and 90°.
+const orientationSteps = 18
+
+// Rank returns the catalog entries of the detection's family whose
+// dimensions explain the observed box, best first. extentX and extentY
+// are the axis-aligned box extents in meters; since the object's heading
+// is unknown, each candidate is fitted at the heading whose rotated
+// footprint best matches the box.
+func (c *Catalog) Rank(class string, extentX, extentY float64, opts MatchOptions) []Candidate {
+	opts.defaults()
+	if extentX <= 0 || extentY <= 0 {
+		return nil
+	}
+	family := categoryFamily(class)
+	sigma := func(size float64) float64 {
+		return math.Hypot(opts.GSD, opts.RelativeError*size)
+	}
+
+	type scored struct {
+		e      *Entry
+		logL   float64
+		zWorst float64
+	}
+	var plausible []scored
+	for _, e := range c.Entries {
+		if e.Family() != family {
+			continue
+		}
+		l, w := e.LengthM, e.WidthM()
+		if w <= 0 {
+			w = l / 6 // typical hull or fuselage aspect when the width is unknown
+		}
+		best := scored{e: e, logL: math.Inf(-1)}
+		for i := 0; i <= orientationSteps; i++ {
+			th := float64(i) / orientationSteps * math.Pi / 2
+			sin, cos := math.Sincos(th)
+			bx, by := l*cos+w*sin, l*sin+w*cos
+			zx, zy := (extentX-bx)/sigma(bx), (extentY-by)/sigma(by)
+			logL := -0.5*(zx*zx+zy*zy) - math.Log(sigma(bx)*sigma(by))
+			if logL > best.logL {
+				best.logL, best.zWorst = logL, math.Max(math.Abs(zx), math.Abs(zy))
+			}
+		}
+		if best.zWorst <= opts.MaxSigma {
+			plausible = append(plausible, best)
+		}
+	}
+	if len(plausible) == 0 {
+		return nil
+	}
+
+	sort.Slice(plausible, func(i, j int) bool { return plausible[i].logL > plausible[j].logL })
+	var total float64
+	for _, s := range plausible {
+		total += math.Exp(s.logL - plausible[0].logL)
+	}
+	out := make([]Candidate, 0, min(opts.TopK, len(plausible)))
+	for _, s := range plausible[:min(opts.TopK, len(plausible))] {
+		out = append(out, Candidate{
+			Entry: s.e,
+			Score: math.Exp(s.logL-plausible[0].logL) / total,
+			Sigma: s.zWorst,
+		})
+	}
+	return out
+}
+
+// Annotate ranks candidates for each detection with an estimated size and
+// records the top ones in its attributes. It returns how many detections
+// received candidates.
+func (c *Catalog) Annotate(dets []*pb.Detection, opts MatchOptions) int {
+	n := 0
+	for _, d := range dets {
+		cands := c.Rank(d.ClassName, float64(d.EstimatedLengthM), float64(d.EstimatedWidthM), opts)
+		if len(cands) == 0 {
+			continue
+		}
+		if d.Attributes == nil {
+			d.Attributes = make(map[string]string)
+		}
+		parts := make([]string, len(cands))
+		for i, cand := range cands {
+			parts[i] = fmt.Sprintf("%s:%.2f", cand.Entry.ID, cand.Score)
+		}
+		d.Attributes[AttrCandidates] = strings.Join(parts, ",")
+		d.Attributes[AttrTop] = cands[0].Entry.Name
+		n++
+	}
+	return n
+}
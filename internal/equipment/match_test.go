@@ -0,0 +1,33 @@
+package equipment
+
+import "testing"
+
+func TestCategoryFamily(t *testing.T) {
+	for _, c := range []struct{ name, want string }{
+		{"ship", FamilyVessel},
+		{"Ships", FamilyVessel},
+		{"container-ship", FamilyVessel},
+		{"Aircraft Carrier", FamilyVessel},
+		{"transport ship", FamilyVessel},
+		{"warship", FamilyVessel},
+		{"tanker_aircraft", FamilyAircraft},
+		{"Fixed-wing Aircraft", FamilyAircraft},
+		{"transport", FamilyAircraft},
+		{"UAVs", FamilyAircraft},
+		{"small-vehicle", FamilyVehicle},
+		{"Cars", FamilyVehicle},
+		{"main battle tank", FamilyVehicle},
+		{"TEL", FamilyVehicle},
+		// Keywords inside other words or in non-equipment names.
+		{"Shipping Container", "shipping container"},
+		{"Straddle Carrier", "straddle carrier"},
+		{"storage-tank", "storage-tank"},
+		{"Hotel", "hotel"},
+		{"Satellite Dish", "satellite dish"},
+		{"Cargo Crane", "cargo crane"},
+	} {
+		if got := categoryFamily(c.name); got != c.want {
+			t.Errorf("categoryFamily(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package facilities
+
+import (
+	"strconv"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// Attribute keys set on detections near a facility.
+const (
+	AttrFacilityID     = "facility_id"
+	AttrFacilityName   = "facility_name"
+	AttrFacilityDistKm = "facility_distance_km"
+)
+
+// DefaultMaxKm is the association radius used when none is given.
+const DefaultMaxKm = 25.0
+
+// SetAttributes records m in attrs, allocating the map if needed.
+func (m Match) SetAttributes(attrs map[string]string) map[string]string {
+	if attrs == nil {
+		attrs = make(map[string]string)
+	}
+	attrs[AttrFacilityID] = m.Facility.ID
+	attrs[AttrFacilityName] = m.Facility.Name
+	attrs[AttrFacilityDistKm] = strconv.FormatFloat(m.DistKm, 'f', 2, 64)
+	return attrs
+}
+
+// Annotate tags each geo-referenced detection with its nearest facility
+// within maxKm and returns how many were tagged.
+func (db *DB) Annotate(dets []*pb.Detection, maxKm float64) int {
+	n := 0
+	for _, d := range dets {
+		if d.GeoCenter == nil || (d.GeoCenter.Latitude == 0 && d.GeoCenter.Longitude == 0) {
+			continue
+		}
+		if m, ok := db.Nearest(geo.Point{Lat: d.GeoCenter.Latitude, Lon: d.GeoCenter.Longitude}, maxKm); ok {
+			d.Attributes = m.SetAttributes(d.Attributes)
+			n++
+		}
+	}
+	return n
+}
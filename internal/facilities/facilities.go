@@ -0,0 +1,292 @@
+// Package facilities loads the known-facilities database
+// (data/known_facilities) and associates detections with the nearest
+// facility.
+//
+// A facility file holds one facility object, an array of them, or an
+// object with a "facilities" array, in the format documented in
+// data/known_facilities/README.md. Facilities may carry an optional
+// GeoJSON "geometry" outlining the site; otherwise their AOI is a box of
+// radius_km around the location.
+package facilities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+// DefaultRadiusKm is the AOI radius of facilities without a geometry or
+// radius_km.
+const DefaultRadiusKm = 5.0
+
+// ErrNotFound is returned by Find when no facility matches.
+var ErrNotFound = errors.New("facility not found")
+
+// Location is a facility's reference point.
+type Location struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Facility is one entry of the known-facilities database.
+type Facility struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Subtypes    []string        `json:"subtypes,omitempty"`
+	Country     string          `json:"country,omitempty"`
+	Location    Location        `json:"location"`
+	RadiusKm    float64         `json:"radius_km,omitempty"`
+	Geometry    json.RawMessage `json:"geometry,omitempty"`
+	Sources     []string        `json:"sources,omitempty"`
+	LastUpdated string          `json:"last_updated,omitempty"`
+	Provenance  *Provenance     `json:"provenance,omitempty"`
+
+	// Area is the parsed Geometry, if any.
+	Area geo.MultiPolygon `json:"-"`
+}
+
+// Provenance records where an imported facility came from, so entries can
+// be traced back to and refreshed from their source.
+type Provenance struct {
+	Source    string            `json:"source"`
+	Element   string            `json:"element,omitempty"` // e.g. "way/123456"
+	Version   int               `json:"version,omitempty"`
+	Timestamp string            `json:"timestamp,omitempty"`
+	File      string            `json:"file,omitempty"`
+	License   string            `json:"license,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// Point returns the facility's reference point.
+func (f *Facility) Point() geo.Point {
+	return geo.Point{Lat: f.Location.Lat, Lon: f.Location.Lon}
+}
+
+// AOI returns the facility outline, or a box of RadiusKm (DefaultRadiusKm
+// if unset) around its location.
+func (f *Facility) AOI() geo.MultiPolygon {
+	if len(f.Area) > 0 {
+		return f.Area
+	}
+	r := f.RadiusKm
+	if r <= 0 {
+		r = DefaultRadiusKm
+	}
+	var aoi geo.MultiPolygon
+	for _, part := range geo.BBoxFromCenter(f.Point(), r).Normalize().Split() {
+		aoi = append(aoi, geo.Polygon{part.Ring()})
+	}
+	return aoi
+}
+
+// validate checks the entry and parses its geometry.
+func (f *Facility) validate() error {
+	var errs []string
+	if f.ID == "" {
+		errs = append(errs, "missing id")
+	}
+	if f.Name == "" {
+		errs = append(errs, "missing name")
+	}
+	if f.Type == "" {
+		errs = append(errs, "missing type")
+	}
+	if f.Location.Lat < -90 || f.Location.Lat > 90 || f.Location.Lon < -180 || f.Location.Lon > 180 {
+		errs = append(errs, fmt.Sprintf("location %.6f, %.6f out of range", f.Location.Lat, f.Location.Lon))
+	}
+	if f.RadiusKm < 0 {
+		errs = append(errs, fmt.Sprintf("negative radius_km %g", f.RadiusKm))
+	}
+	if f.LastUpdated != "" {
+		if _, err := time.Parse("2006-01-02", f.LastUpdated); err != nil {
+			errs = append(errs, fmt.Sprintf("last_updated %q is not YYYY-MM-DD", f.LastUpdated))
+		}
+	}
+	if len(f.Geometry) > 0 && string(f.Geometry) != "null" {
+		area, err := geo.ParseGeoJSON(f.Geometry)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("geometry: %v", err))
+		}
+		f.Area = area
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// DB is a validated, spatially indexed set of facilities.
+type DB struct {
+	Facilities []*Facility
+	tree       *geo.RTree
+}
+
+// New validates facilities and indexes them. IDs must be unique.
+func New(list []*Facility) (*DB, error) {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, f := range list {
+		if err := f.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("facility %d (%s): %w", i, f.ID, err))
+		}
+		if f.ID != "" && seen[f.ID] {
+			errs = append(errs, fmt.Errorf("facility %d: duplicate id %q", i, f.ID))
+		}
+		seen[f.ID] = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var items []geo.RTreeItem
+	for i, f := range list {
+		if len(f.Area) == 0 {
+			p := f.Point()
+			items = append(items, geo.RTreeItem{BBox: geo.BBox{West: p.Lon, East: p.Lon, South: p.Lat, North: p.Lat}, ID: i})
+			continue
+		}
+		for _, part := range f.Area.BBox().Normalize().Split() {
+			items = append(items, geo.RTreeItem{BBox: part, ID: i})
+		}
+	}
+	return &DB{Facilities: list, tree: geo.BulkLoadRTree(items)}, nil
+}
+
+// Load reads facility files. A path that is a directory loads every
+// *.json file in it.
+func Load(paths ...string) (*DB, error) {
+	var list []*Facility
+	var errs []error
+	for _, path := range paths {
+		files := []string{path}
+		if st, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if st.IsDir() {
+			files, _ = filepath.Glob(filepath.Join(path, "*.json"))
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			fs, err := loadFile(file)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+				continue
+			}
+			db, err := New(fs)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+				continue
+			}
+			list = append(list, db.Facilities...)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return New(list)
+}
+
+// loadFile parses one facility file in any of the accepted layouts.
+func loadFile(path string) ([]*Facility, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(trimmed, "["):
+		var list []*Facility
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		return list, nil
+	case strings.Contains(trimmed, `"facilities"`):
+		var wrapper struct {
+			Facilities []*Facility `json:"facilities"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err == nil && wrapper.Facilities != nil {
+			return wrapper.Facilities, nil
+		}
+	}
+	var f Facility
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return []*Facility{&f}, nil
+}
+
+// Write saves facilities as a JSON array that Load accepts.
+func Write(path string, list []*Facility) error {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Len returns the number of facilities.
+func (db *DB) Len() int { return len(db.Facilities) }
+
+// Match is a facility near a point.
+type Match struct {
+	Facility *Facility
+	DistKm   float64 // 0 if the point lies inside the facility outline
+}
+
+// Nearest returns the facility closest to p within maxKm. Distance is 0
+// inside a facility's outline and otherwise measured to its location.
+func (db *DB) Nearest(p geo.Point, maxKm float64) (Match, bool) {
+	best := Match{DistKm: maxKm}
+	found := false
+	db.tree.NearestFunc(p, func(n geo.Neighbor) bool {
+		// Box distance is a lower bound on the facility distance.
+		if n.DistKm > best.DistKm {
+			return false
+		}
+		f := db.Facilities[n.ID]
+		d := geo.DistanceM(p, f.Point()) / 1000
+		if len(f.Area) > 0 && f.Area.Contains(p) {
+			d = 0
+		}
+		if d < best.DistKm || (!found && d == best.DistKm) {
+			best, found = Match{Facility: f, DistKm: d}, true
+		}
+		return true
+	})
+	return best, found
+}
+
+// Find returns the facility whose ID or name equals query, ignoring case,
+// or else the single facility whose name contains it.
+func (db *DB) Find(query string) (*Facility, error) {
+	q := strings.ToLower(strings.TrimSpace(query))
+	for _, f := range db.Facilities {
+		if strings.ToLower(f.ID) == q || strings.ToLower(f.Name) == q {
+			return f, nil
+		}
+	}
+	var hits []*Facility
+	for _, f := range db.Facilities {
+		if strings.Contains(strings.ToLower(f.Name), q) {
+			hits = append(hits, f)
+		}
+	}
+	switch len(hits) {
+	case 0:
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, query)
+	case 1:
+		return hits[0], nil
+	}
+	names := make([]string, len(hits))
+	for i, f := range hits {
+		names[i] = fmt.Sprintf("%s (%s)", f.Name, f.ID)
+	}
+	return nil, fmt.Errorf("%q matches %d facilities: %s", query, len(hits), strings.Join(names, ", "))
+}
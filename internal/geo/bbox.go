@@ -0,0 +1,162 @@
+package geo
+
+import "math"
+
+// normLon maps a longitude into [-180, 180], leaving values already in
+// range (including exactly ±180) untouched.
+func normLon(lon float64) float64 {
+	if lon >= -180 && lon <= 180 {
+		return lon
+	}
+	return wrapLon(lon)
+}
+
+func clampLat(lat float64) float64 {
+	return math.Max(-90, math.Min(90, lat))
+}
+
+// Normalize wraps longitudes into [-180, 180] and clamps latitudes to
+// [-90, 90]. A box spanning 360° or more of longitude becomes
+// [-180, 180]; otherwise a box reaching past ±180° crosses the
+// antimeridian and ends up with West > East.
+func (b BBox) Normalize() BBox {
+	b.South, b.North = clampLat(b.South), clampLat(b.North)
+	if b.West <= b.East && b.East-b.West >= 360 {
+		b.West, b.East = -180, 180
+		return b
+	}
+	w, e := normLon(b.West), normLon(b.East)
+	if w == e && b.West != b.East {
+		// The span wrapped onto itself, e.g. [-190, 170].
+		w, e = -180, 180
+	}
+	b.West, b.East = w, e
+	return b
+}
+
+// CrossesAntimeridian reports whether the box wraps across ±180°.
+func (b BBox) CrossesAntimeridian() bool {
+	return b.West > b.East
+}
+
+// Split returns the box as one or two boxes that do not cross the
+// antimeridian, suitable for APIs such as STAC search that reject
+// West > East.
+func (b BBox) Split() []BBox {
+	if !b.CrossesAntimeridian() {
+		return []BBox{b}
+	}
+	return []BBox{
+		{West: b.West, South: b.South, East: 180, North: b.North},
+		{West: -180, South: b.South, East: b.East, North: b.North},
+	}
+}
+
+// WidthDeg returns the longitude span in degrees.
+func (b BBox) WidthDeg() float64 {
+	if b.CrossesAntimeridian() {
+		return 360 - b.West + b.East
+	}
+	return b.East - b.West
+}
+
+// Center returns the center of the box, wrapping across the antimeridian
+// where necessary.
+func (b BBox) Center() Point {
+	return Point{
+		Lat: (b.South + b.North) / 2,
+		Lon: normLon(b.West + b.WidthDeg()/2),
+	}
+}
+
+// Contains reports whether p lies inside the box, edges included.
+func (b BBox) Contains(p Point) bool {
+	if p.Lat < b.South || p.Lat > b.North {
+		return false
+	}
+	lon := normLon(p.Lon)
+	if b.CrossesAntimeridian() {
+		return lon >= b.West || lon <= b.East
+	}
+	return lon >= b.West && lon <= b.East
+}
+
+// ContainsBBox reports whether o lies entirely inside b.
+func (b BBox) ContainsBBox(o BBox) bool {
+	if o.South < b.South || o.North > b.North {
+		return false
+	}
+	if b.WidthDeg() >= 360 {
+		return true
+	}
+	if o.WidthDeg() > b.WidthDeg() {
+		return false
+	}
+	return lonOffset(b.West, o.West)+o.WidthDeg() <= b.WidthDeg()
+}
+
+// Intersects reports whether the boxes share any point.
+func (b BBox) Intersects(o BBox) bool {
+	for _, p := range b.Split() {
+		for _, q := range o.Split() {
+			if boxIntersects(p, q) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Union returns the smallest box covering both boxes, crossing the
+// antimeridian if that gives a narrower result.
+func (b BBox) Union(o BBox) BBox {
+	u := BBox{South: math.Min(b.South, o.South), North: math.Max(b.North, o.North)}
+	wb, wo := b.WidthDeg(), o.WidthDeg()
+	if wb >= 360 || wo >= 360 {
+		u.West, u.East = -180, 180
+		return u
+	}
+
+	// Try an arc starting at each box's west edge; keep the narrower one
+	// that covers both.
+	span := func(start float64) float64 {
+		return math.Max(lonOffset(start, b.West)+wb, lonOffset(start, o.West)+wo)
+	}
+	start, width := b.West, span(b.West)
+	if w := span(o.West); w < width {
+		start, width = o.West, w
+	}
+	if width >= 360 {
+		u.West, u.East = -180, 180
+		return u
+	}
+	u.West, u.East = start, start+width
+	return u.Normalize()
+}
+
+// AreaKm2 returns the area of the box on the spherical Earth.
+func (b BBox) AreaKm2() float64 {
+	dLon := b.WidthDeg() * DegToRad
+	return EarthRadiusKm * EarthRadiusKm * dLon * math.Abs(math.Sin(b.North*DegToRad)-math.Sin(b.South*DegToRad))
+}
+
+// lonOffset returns how many degrees east of from the longitude to lies,
+// in [0, 360).
+func lonOffset(from, to float64) float64 {
+	d := math.Mod(to-from, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d
+}
+
+// Ring returns the box as a counter-clockwise ring. The box must not cross
+// the antimeridian; use Split first.
+func (b BBox) Ring() Ring {
+	return Ring{
+		{Lat: b.South, Lon: b.West},
+		{Lat: b.South, Lon: b.East},
+		{Lat: b.North, Lon: b.East},
+		{Lat: b.North, Lon: b.West},
+	}
+}
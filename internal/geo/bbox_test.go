@@ -0,0 +1,122 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// fiji is a box across the antimeridian.
+var fiji = BBox{West: 177, South: -19, East: -178, North: -16}
+
+func TestBBoxFromCenter(t *testing.T) {
+	b := BBoxFromCenter(Point{Lat: -17.7, Lon: 179.5}, 100)
+	if !b.CrossesAntimeridian() || b.West < 178 || b.East > -178 {
+		t.Errorf("box around 179.5°E = %+v, want one crossing ±180°", b)
+	}
+	if c := b.Center(); math.Abs(c.Lon-179.5) > 1e-9 || math.Abs(c.Lat+17.7) > 1e-9 {
+		t.Errorf("center %v, want -17.7, 179.5", c)
+	}
+	polar := BBoxFromCenter(Point{Lat: 89.5, Lon: 10}, 100)
+	if polar.West != -180 || polar.East != 180 || polar.North != 90 {
+		t.Errorf("box reaching the pole = %+v, want all longitudes up to 90°", polar)
+	}
+}
+
+func TestBBoxNormalize(t *testing.T) {
+	for _, c := range []struct {
+		in, want BBox
+	}{
+		{BBox{West: 170, South: 0, East: 190, North: 1}, BBox{West: 170, South: 0, East: -170, North: 1}},
+		{BBox{West: -190, South: 0, East: -170, North: 1}, BBox{West: 170, South: 0, East: -170, North: 1}},
+		{BBox{West: -190, South: 0, East: 170, North: 1}, BBox{West: -180, South: 0, East: 180, North: 1}},
+		{BBox{West: -200, South: -95, East: 200, North: 95}, BBox{West: -180, South: -90, East: 180, North: 90}},
+		{BBox{West: -180, South: 0, East: 180, North: 1}, BBox{West: -180, South: 0, East: 180, North: 1}},
+		{BBox{West: 10, South: 0, East: 20, North: 1}, BBox{West: 10, South: 0, East: 20, North: 1}},
+	} {
+		if got := c.in.Normalize(); got != c.want {
+			t.Errorf("%+v.Normalize() = %+v, want %+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestBBoxAntimeridian(t *testing.T) {
+	if w := fiji.WidthDeg(); w != 5 {
+		t.Errorf("width %v, want 5", w)
+	}
+	if c := fiji.Center(); c.Lon != 179.5 || c.Lat != -17.5 {
+		t.Errorf("center %v, want -17.5, 179.5", c)
+	}
+	parts := fiji.Split()
+	want := []BBox{{West: 177, South: -19, East: 180, North: -16}, {West: -180, South: -19, East: -178, North: -16}}
+	if len(parts) != 2 || parts[0] != want[0] || parts[1] != want[1] {
+		t.Errorf("Split = %+v, want %+v", parts, want)
+	}
+	for _, c := range []struct {
+		p  Point
+		in bool
+	}{
+		{Point{Lat: -17, Lon: 179}, true},
+		{Point{Lat: -17, Lon: -179}, true},
+		{Point{Lat: -17, Lon: 180}, true},
+		{Point{Lat: -17, Lon: 181}, true},
+		{Point{Lat: -17, Lon: 0}, false},
+		{Point{Lat: -17, Lon: 176}, false},
+		{Point{Lat: -15, Lon: 179}, false},
+	} {
+		if got := fiji.Contains(c.p); got != c.in {
+			t.Errorf("Contains(%v) = %v, want %v", c.p, got, c.in)
+		}
+	}
+	// Spherical area of the 5° × 3° box, the same either side of ±180°.
+	plain := BBox{West: 0, South: -19, East: 5, North: -16}
+	if a, b := fiji.AreaKm2(), plain.AreaKm2(); math.Abs(a-b) > 1e-6 || a < 1e5 {
+		t.Errorf("area %v km², want %v km²", a, b)
+	}
+}
+
+func TestBBoxRelations(t *testing.T) {
+	east := BBox{West: 178, South: -18, East: 179, North: -17}
+	west := BBox{West: -179, South: -18, East: -178.5, North: -17}
+	far := BBox{West: 0, South: -18, East: 10, North: -17}
+	for _, c := range []struct {
+		name               string
+		a, b               BBox
+		contains, overlaps bool
+	}{
+		{"east part", fiji, east, true, true},
+		{"west part", fiji, west, true, true},
+		{"elsewhere", fiji, far, false, false},
+		{"whole world", BBox{West: -180, South: -90, East: 180, North: 90}, fiji, true, true},
+		{"sticking out", fiji, BBox{West: -179, South: -18, East: -170, North: -17}, false, true},
+		{"touching", BBox{West: 0, South: 0, East: 1, North: 1}, BBox{West: 1, South: 1, East: 2, North: 2}, false, true},
+	} {
+		if got := c.a.ContainsBBox(c.b); got != c.contains {
+			t.Errorf("%s: ContainsBBox = %v, want %v", c.name, got, c.contains)
+		}
+		if got := c.a.Intersects(c.b); got != c.overlaps {
+			t.Errorf("%s: Intersects = %v, want %v", c.name, got, c.overlaps)
+		}
+		if got := c.b.Intersects(c.a); got != c.overlaps {
+			t.Errorf("%s: reversed Intersects = %v, want %v", c.name, got, c.overlaps)
+		}
+	}
+}
+
+func TestBBoxUnion(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		a, b BBox
+		want BBox
+	}{
+		{"across the antimeridian", BBox{West: 178, South: 0, East: 179, North: 1}, BBox{West: -179, South: -1, East: -178, North: 0},
+			BBox{West: 178, South: -1, East: -178, North: 1}},
+		{"plain", BBox{West: 10, South: 0, East: 20, North: 1}, BBox{West: 30, South: 0, East: 40, North: 2},
+			BBox{West: 10, South: 0, East: 40, North: 2}},
+		{"nested", fiji, BBox{West: 179, South: -18, East: -179, North: -17}, fiji},
+		{"world", BBox{West: -180, South: 0, East: 180, North: 1}, fiji, BBox{West: -180, South: -19, East: 180, North: 1}},
+	} {
+		if got := c.a.Union(c.b); got != c.want {
+			t.Errorf("%s: Union = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
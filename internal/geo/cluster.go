@@ -0,0 +1,286 @@
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// Noise is the cluster label DBSCAN assigns to points that belong to no cluster.
+const Noise = -1
+
+// sphereSlack bounds the ratio of great-circle distance on the 6371 km
+// sphere to WGS84 geodesic distance (about 1.0057 at most, along meridians
+// near the equator).
+const sphereSlack = 1.01
+
+// Linkage selects how HierarchicalCluster measures the distance between
+// two clusters.
+type Linkage int
+
+const (
+	SingleLinkage   Linkage = iota // closest pair of members
+	CompleteLinkage                // farthest pair of members
+	AverageLinkage                 // mean over all member pairs
+)
+
+// Cluster is a group of points produced by DBSCAN or HierarchicalCluster.
+type Cluster struct {
+	ID       int     `json:"id"`
+	Members  []int   `json:"members"` // indices into the input points
+	Centroid Point   `json:"centroid"`
+	Hull     []Point `json:"hull"` // convex hull, counter-clockwise, not closed
+}
+
+// DBSCAN groups points that have at least minPts neighbors (including
+// themselves) within epsKm of WGS84 geodesic distance. It returns one
+// label per point: a 0-based cluster ID, or Noise.
+func DBSCAN(points []Point, epsKm float64, minPts int) []int {
+	labels := make([]int, len(points))
+	const unvisited = -2
+	for i := range labels {
+		labels[i] = unvisited
+	}
+
+	items := make([]RTreeItem, len(points))
+	for i, p := range points {
+		items[i] = RTreeItem{BBox: BBox{West: p.Lon, South: p.Lat, East: p.Lon, North: p.Lat}, ID: i}
+	}
+	index := BulkLoadRTree(items)
+	// The index orders by great-circle distance, which exceeds the
+	// geodesic one by under 0.6%; candidates are taken with that margin.
+	neighbors := func(i int) []int {
+		var out []int
+		index.NearestFunc(points[i], func(n Neighbor) bool {
+			if n.DistKm > epsKm*sphereSlack {
+				return false
+			}
+			if DistanceM(points[i], points[n.ID]) <= epsKm*1000 {
+				out = append(out, n.ID)
+			}
+			return true
+		})
+		return out
+	}
+
+	cluster := 0
+	for i := range points {
+		if labels[i] != unvisited {
+			continue
+		}
+		nb := neighbors(i)
+		if len(nb) < minPts {
+			labels[i] = Noise
+			continue
+		}
+		labels[i] = cluster
+		queue := append([]int(nil), nb...)
+		for len(queue) > 0 {
+			j := queue[0]
+			queue = queue[1:]
+			if labels[j] == Noise {
+				labels[j] = cluster // border point
+			}
+			if labels[j] != unvisited {
+				continue
+			}
+			labels[j] = cluster
+			if nbj := neighbors(j); len(nbj) >= minPts {
+				queue = append(queue, nbj...)
+			}
+		}
+		cluster++
+	}
+	return labels
+}
+
+// HierarchicalCluster performs agglomerative clustering, repeatedly merging
+// the two closest clusters until no pair is closer than maxDistKm under the
+// given linkage. Distances are WGS84 geodesic. It returns a 0-based
+// cluster ID per point; every point belongs to a cluster, possibly of size
+// one.
+func HierarchicalCluster(points []Point, maxDistKm float64, linkage Linkage) []int {
+	n := len(points)
+	dist := make([][]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+		for j := 0; j < i; j++ {
+			d := DistanceM(points[i], points[j]) / 1000
+			dist[i][j], dist[j][i] = d, d
+		}
+	}
+
+	size := make([]int, n)
+	active := make([]bool, n)
+	parent := make([]int, n)
+	for i := range parent {
+		size[i], active[i], parent[i] = 1, true, i
+	}
+
+	for {
+		bi, bj, best := -1, -1, math.Inf(1)
+		for i := 0; i < n; i++ {
+			if !active[i] {
+				continue
+			}
+			for j := i + 1; j < n; j++ {
+				if active[j] && dist[i][j] < best {
+					bi, bj, best = i, j, dist[i][j]
+				}
+			}
+		}
+		if bi < 0 || best > maxDistKm {
+			break
+		}
+
+		// Merge bj into bi using the Lance–Williams update.
+		for k := 0; k < n; k++ {
+			if !active[k] || k == bi || k == bj {
+				continue
+			}
+			var d float64
+			switch linkage {
+			case CompleteLinkage:
+				d = math.Max(dist[bi][k], dist[bj][k])
+			case AverageLinkage:
+				d = (dist[bi][k]*float64(size[bi]) + dist[bj][k]*float64(size[bj])) / float64(size[bi]+size[bj])
+			default:
+				d = math.Min(dist[bi][k], dist[bj][k])
+			}
+			dist[bi][k], dist[k][bi] = d, d
+		}
+		size[bi] += size[bj]
+		active[bj] = false
+		parent[bj] = bi
+	}
+
+	root := func(i int) int {
+		for parent[i] != i {
+			i = parent[i]
+		}
+		return i
+	}
+	ids := make(map[int]int)
+	labels := make([]int, n)
+	for i := range points {
+		r := root(i)
+		id, ok := ids[r]
+		if !ok {
+			id = len(ids)
+			ids[r] = id
+		}
+		labels[i] = id
+	}
+	return labels
+}
+
+// Clusters collects labeled points into clusters with centroids and hulls,
+// ordered by ID. Noise points are skipped.
+func Clusters(points []Point, labels []int) []Cluster {
+	byID := make(map[int][]int)
+	for i, l := range labels {
+		if l != Noise {
+			byID[l] = append(byID[l], i)
+		}
+	}
+
+	out := make([]Cluster, 0, len(byID))
+	for id, members := range byID {
+		pts := make([]Point, len(members))
+		for i, m := range members {
+			pts[i] = points[m]
+		}
+		out = append(out, Cluster{
+			ID:       id,
+			Members:  members,
+			Centroid: Centroid(pts),
+			Hull:     ConvexHull(pts),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Centroid returns the geographic mean of points, computed on the unit
+// sphere so it is correct across the antimeridian.
+func Centroid(points []Point) Point {
+	var x, y, z float64
+	for _, p := range points {
+		lat, lon := p.Lat*DegToRad, p.Lon*DegToRad
+		x += math.Cos(lat) * math.Cos(lon)
+		y += math.Cos(lat) * math.Sin(lon)
+		z += math.Sin(lat)
+	}
+	if len(points) == 0 {
+		return Point{}
+	}
+	return Point{
+		Lat: math.Atan2(z, math.Hypot(x, y)) * RadToDeg,
+		Lon: math.Atan2(y, x) * RadToDeg,
+	}
+}
+
+// ConvexHull returns the convex hull of points in counter-clockwise order
+// without repeating the first vertex. Longitudes are unwrapped around the
+// first point so clusters spanning the antimeridian get a sensible hull.
+func ConvexHull(points []Point) []Point {
+	if len(points) == 0 {
+		return nil
+	}
+	ref := points[0].Lon
+	pts := make([]Point, len(points))
+	for i, p := range points {
+		pts[i] = Point{Lat: p.Lat, Lon: ref + wrapLon(p.Lon-ref)}
+	}
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i].Lon != pts[j].Lon {
+			return pts[i].Lon < pts[j].Lon
+		}
+		return pts[i].Lat < pts[j].Lat
+	})
+	if len(pts) < 3 {
+		return normalizeHull(dedupe(pts))
+	}
+
+	cross := func(o, a, b Point) float64 {
+		return (a.Lon-o.Lon)*(b.Lat-o.Lat) - (a.Lat-o.Lat)*(b.Lon-o.Lon)
+	}
+	hull := make([]Point, 0, 2*len(pts))
+	for _, p := range pts {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(pts) - 2; i >= 0; i-- {
+		p := pts[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return normalizeHull(hull[:len(hull)-1])
+}
+
+func dedupe(pts []Point) []Point {
+	if len(pts) == 2 && pts[0] == pts[1] {
+		return pts[:1]
+	}
+	return pts
+}
+
+func normalizeHull(pts []Point) []Point {
+	for i := range pts {
+		pts[i].Lon = wrapLon(pts[i].Lon)
+	}
+	return pts
+}
+
+// wrapLon maps a longitude into [-180, 180).
+func wrapLon(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
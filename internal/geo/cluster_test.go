@@ -0,0 +1,139 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// along returns points the given distances (km) east of origin.
+func along(origin Point, km ...float64) []Point {
+	pts := make([]Point, len(km))
+	for i, d := range km {
+		pts[i] = Destination(origin, 90, d*1000)
+	}
+	return pts
+}
+
+// shoelace returns the planar signed area of a lon/lat polygon, positive
+// when counter-clockwise.
+func shoelace(pts []Point) float64 {
+	var a float64
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		a += p.Lon*q.Lat - q.Lon*p.Lat
+	}
+	return a / 2
+}
+
+func TestDBSCAN(t *testing.T) {
+	port := Point{Lat: 1.25, Lon: 103.8}
+	far := Destination(port, 45, 10000)
+	var pts []Point
+	pts = append(pts, along(port, 0, 0.1, 0.2, 0.3, 0.4)...) // cluster 0
+	pts = append(pts, Destination(port, 0, 5000))            // noise
+	pts = append(pts, along(far, 0, 0.1, 0.2)...)            // cluster 1
+	pts = append(pts, Destination(far, 90, 450))             // border of cluster 1
+
+	got := DBSCAN(pts, 0.25, 3)
+	want := []int{0, 0, 0, 0, 0, Noise, 1, 1, 1, 1}
+	if !equalInts(got, want) {
+		t.Errorf("labels %v, want %v", got, want)
+	}
+
+	// Density-reachable through a chain, though the ends are far apart.
+	chain := along(port, 0, 0.2, 0.4, 0.6, 0.8, 1.0)
+	if got := DBSCAN(chain, 0.25, 2); !equalInts(got, []int{0, 0, 0, 0, 0, 0}) {
+		t.Errorf("chain: labels %v", got)
+	}
+	if got := DBSCAN(chain, 0.25, 4); !equalInts(got, []int{Noise, Noise, Noise, Noise, Noise, Noise}) {
+		t.Errorf("chain, minPts 4: labels %v", got)
+	}
+	if got := DBSCAN(nil, 1, 2); len(got) != 0 {
+		t.Errorf("no points: labels %v", got)
+	}
+}
+
+func TestDBSCANGeodesic(t *testing.T) {
+	// 0.0027° of latitude at the equator is 298.6 m on the ellipsoid but
+	// 300.2 m on the sphere the index uses.
+	pair := []Point{{Lat: 0, Lon: 30}, {Lat: 0.0027, Lon: 30}}
+	if got := DBSCAN(pair, 0.3, 2); !equalInts(got, []int{0, 0}) {
+		t.Errorf("meridian pair: labels %v, want one cluster", got)
+	}
+	// Across the antimeridian.
+	dateline := []Point{{Lat: -17, Lon: 179.999}, {Lat: -17, Lon: -179.999}, {Lat: -17, Lon: 179.9995}}
+	if got := DBSCAN(dateline, 0.5, 3); !equalInts(got, []int{0, 0, 0}) {
+		t.Errorf("antimeridian: labels %v, want one cluster", got)
+	}
+}
+
+func TestHierarchicalCluster(t *testing.T) {
+	pts := along(Point{Lat: 35.4, Lon: 139.6}, 0, 1, 2.2, 10)
+	for _, c := range []struct {
+		name    string
+		maxKm   float64
+		linkage Linkage
+		want    []int
+	}{
+		{"single", 1.5, SingleLinkage, []int{0, 0, 0, 1}},
+		{"average", 1.5, AverageLinkage, []int{0, 0, 1, 2}},
+		{"average, wider", 1.8, AverageLinkage, []int{0, 0, 0, 1}},
+		{"complete", 1.8, CompleteLinkage, []int{0, 0, 1, 2}},
+		{"complete, wider", 2.3, CompleteLinkage, []int{0, 0, 0, 1}},
+		{"tight", 0.5, SingleLinkage, []int{0, 1, 2, 3}},
+		{"everything", 20, SingleLinkage, []int{0, 0, 0, 0}},
+	} {
+		if got := HierarchicalCluster(pts, c.maxKm, c.linkage); !equalInts(got, c.want) {
+			t.Errorf("%s: labels %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestClusters(t *testing.T) {
+	pts := []Point{
+		{Lat: 10, Lon: 20}, {Lat: 10, Lon: 20.2}, {Lat: 10.2, Lon: 20.2}, {Lat: 10.2, Lon: 20},
+		{Lat: 10.1, Lon: 20.1}, // inside the square
+		{Lat: 50, Lon: 0},      // noise
+		{Lat: -20, Lon: 179.9}, {Lat: -20, Lon: -179.9},
+	}
+	got := Clusters(pts, []int{1, 1, 1, 1, 1, Noise, 0, 0})
+	if len(got) != 2 || got[0].ID != 0 || got[1].ID != 1 {
+		t.Fatalf("clusters %+v", got)
+	}
+	if !equalInts(got[0].Members, []int{6, 7}) || !equalInts(got[1].Members, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("members %v, %v", got[0].Members, got[1].Members)
+	}
+	if c := got[0].Centroid; math.Abs(c.Lat+20) > 1e-4 || math.Abs(math.Abs(c.Lon)-180) > 1e-9 {
+		t.Errorf("antimeridian centroid %v, want -20, ±180", c)
+	}
+	if c := got[1].Centroid; math.Abs(c.Lat-10.1) > 1e-3 || math.Abs(c.Lon-20.1) > 1e-9 {
+		t.Errorf("square centroid %v", c)
+	}
+	if h := got[1].Hull; len(h) != 4 || shoelace(h) <= 0 {
+		t.Errorf("square hull %v, want its 4 corners counter-clockwise", h)
+	}
+}
+
+func TestConvexHull(t *testing.T) {
+	if h := ConvexHull(nil); h != nil {
+		t.Errorf("no points: %v", h)
+	}
+	p := Point{Lat: 1, Lon: 2}
+	if h := ConvexHull([]Point{p, p}); len(h) != 1 || h[0] != p {
+		t.Errorf("duplicate point: %v", h)
+	}
+	line := []Point{{Lat: 0, Lon: 0}, {Lat: 0, Lon: 2}, {Lat: 0, Lon: 1}}
+	if h := ConvexHull(line); len(h) != 2 {
+		t.Errorf("collinear points: %v, want the two ends", h)
+	}
+	// Across the antimeridian the hull stays narrow, with longitudes wrapped.
+	h := ConvexHull([]Point{{Lat: 0, Lon: 179.5}, {Lat: 0, Lon: -179.5}, {Lat: 1, Lon: -179.5}, {Lat: 1, Lon: 179.5}})
+	if len(h) != 4 {
+		t.Fatalf("antimeridian hull %v", h)
+	}
+	for _, v := range h {
+		if math.Abs(math.Abs(v.Lon)-179.5) > 1e-9 || v.Lon >= 180 || v.Lon < -180 {
+			t.Errorf("antimeridian hull vertex %v", v)
+		}
+	}
+}
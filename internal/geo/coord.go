@@ -0,0 +1,259 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CoordFormat selects how coordinates are written in text output.
+type CoordFormat string
+
+const (
+	FormatDD   CoordFormat = "dd"   // 18.226900, 109.533100
+	FormatDMS  CoordFormat = "dms"  // 18°13'36.8"N 109°31'59.2"E
+	FormatUTM  CoordFormat = "utm"  // 49Q 345678 2015678
+	FormatMGRS CoordFormat = "mgrs" // 49QBB4567815678
+)
+
+// ParseCoordFormat validates a format name; the empty string means DD.
+func ParseCoordFormat(s string) (CoordFormat, error) {
+	switch f := CoordFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FormatDD, nil
+	case FormatDD, FormatDMS, FormatUTM, FormatMGRS:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown coordinate format %q (want dd, dms, utm or mgrs)", s)
+}
+
+// Format writes p in the given format. Points outside UTM coverage fall
+// back to decimal degrees.
+func (p Point) Format(f CoordFormat) string {
+	switch f {
+	case FormatDMS:
+		return formatDMS(p.Lat, "N", "S") + " " + formatDMS(p.Lon, "E", "W")
+	case FormatUTM:
+		if u, err := ToUTM(p); err == nil {
+			return u.String()
+		}
+	case FormatMGRS:
+		if m, err := ToMGRS(p, 5); err == nil {
+			return m.String()
+		}
+	}
+	return fmt.Sprintf("%.6f, %.6f", p.Lat, p.Lon)
+}
+
+func formatDMS(deg float64, pos, neg string) string {
+	hemi := pos
+	if deg < 0 {
+		hemi = neg
+	}
+	// Work in tenths of a second so rounding carries into minutes/degrees.
+	tenths := int64(math.Round(math.Abs(deg) * 36000))
+	d := tenths / 36000
+	m := tenths % 36000 / 600
+	s := float64(tenths%600) / 10
+	return fmt.Sprintf("%d°%02d'%04.1f\"%s", d, m, s, hemi)
+}
+
+var utmPattern = regexp.MustCompile(`(?i)^(\d{1,2})\s*([C-HJ-NP-X])\s+(\d{5,7}(?:\.\d+)?)\s*m?E?[\s,]+(\d{1,8}(?:\.\d+)?)\s*m?N?$`)
+
+// ParseCoordinate parses a coordinate written as decimal degrees, degrees
+// with minutes and seconds, UTM or MGRS:
+//
+//	18.2269, 109.5331
+//	-33.8568 151.2153
+//	18°13'36.8"N 109°31'59.2"E
+//	N 18 13 36.8, E 109 31 59.2
+//	49Q 345678 2015678
+//	49QBB4567815678
+//
+// Degree forms are latitude first unless hemisphere letters say otherwise.
+// An MGRS reference resolves to the center of the square it denotes.
+func ParseCoordinate(s string) (Point, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Point{}, fmt.Errorf("empty coordinate")
+	}
+	if mgrsPattern.MatchString(strings.ToUpper(strings.Join(strings.Fields(s), ""))) {
+		m, err := ParseMGRS(s)
+		if err != nil {
+			return Point{}, err
+		}
+		return m.Point(), nil
+	}
+	if m := utmPattern.FindStringSubmatch(s); m != nil {
+		zone, _ := strconv.Atoi(m[1])
+		if zone < 1 || zone > 60 {
+			return Point{}, fmt.Errorf("invalid UTM zone %d", zone)
+		}
+		band := byte(unicode.ToUpper(rune(m[2][0])))
+		e, _ := strconv.ParseFloat(m[3], 64)
+		n, _ := strconv.ParseFloat(m[4], 64)
+		return UTM{Zone: zone, Band: band, North: band >= 'N', Easting: e, Northing: n}.Point(), nil
+	}
+	return parseDegrees(s)
+}
+
+// degreeToken is a number or a hemisphere letter in a degree coordinate.
+type degreeToken struct {
+	num   string
+	hemi  byte
+	comma bool
+}
+
+func tokenizeDegrees(s string) ([]degreeToken, error) {
+	var toks []degreeToken
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsDigit(r) || r == '.' || ((r == '-' || r == '+') && i+1 < len(rs) && (unicode.IsDigit(rs[i+1]) || rs[i+1] == '.')):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, degreeToken{num: string(rs[i:j])})
+			i = j
+			continue
+		case strings.ContainsRune("NSEWnsew", r):
+			toks = append(toks, degreeToken{hemi: byte(unicode.ToUpper(r))})
+		case r == ',' || r == ';':
+			toks = append(toks, degreeToken{comma: true})
+		case unicode.IsSpace(r) || strings.ContainsRune("°º'\"′″’”", r):
+		default:
+			return nil, fmt.Errorf("unexpected %q in coordinate %q", r, s)
+		}
+		i++
+	}
+	return toks, nil
+}
+
+// degreeGroup is one axis of a degree coordinate: up to three numbers and
+// an optional hemisphere.
+type degreeGroup struct {
+	nums []string
+	hemi byte
+}
+
+func parseDegrees(s string) (Point, error) {
+	toks, err := tokenizeDegrees(s)
+	if err != nil {
+		return Point{}, err
+	}
+
+	var groups []degreeGroup
+	var cur degreeGroup
+	flush := func() {
+		if len(cur.nums) > 0 || cur.hemi != 0 {
+			groups = append(groups, cur)
+		}
+		cur = degreeGroup{}
+	}
+
+	hasHemi, hasComma := false, false
+	for _, t := range toks {
+		hasHemi = hasHemi || t.hemi != 0
+		hasComma = hasComma || t.comma
+	}
+	prefix := len(toks) > 0 && toks[0].hemi != 0
+
+	switch {
+	case hasHemi:
+		for _, t := range toks {
+			switch {
+			case t.num != "":
+				cur.nums = append(cur.nums, t.num)
+			case t.hemi != 0 && prefix:
+				flush()
+				cur.hemi = t.hemi
+			case t.hemi != 0:
+				cur.hemi = t.hemi
+				flush()
+			case t.comma:
+				flush()
+			}
+		}
+		flush()
+	case hasComma:
+		for _, t := range toks {
+			if t.comma {
+				flush()
+			} else {
+				cur.nums = append(cur.nums, t.num)
+			}
+		}
+		flush()
+	default:
+		var nums []string
+		for _, t := range toks {
+			nums = append(nums, t.num)
+		}
+		if len(nums)%2 != 0 || len(nums) > 6 {
+			return Point{}, fmt.Errorf("cannot split %q into latitude and longitude", s)
+		}
+		half := len(nums) / 2
+		groups = []degreeGroup{{nums: nums[:half]}, {nums: nums[half:]}}
+	}
+
+	if len(groups) != 2 {
+		return Point{}, fmt.Errorf("cannot split %q into latitude and longitude", s)
+	}
+	latG, lonG := groups[0], groups[1]
+	if latG.hemi == 'E' || latG.hemi == 'W' || lonG.hemi == 'N' || lonG.hemi == 'S' {
+		latG, lonG = lonG, latG
+	}
+	if latG.hemi == 'E' || latG.hemi == 'W' || lonG.hemi == 'N' || lonG.hemi == 'S' {
+		return Point{}, fmt.Errorf("conflicting hemispheres in %q", s)
+	}
+
+	lat, err := latG.value("S")
+	if err != nil {
+		return Point{}, fmt.Errorf("latitude in %q: %w", s, err)
+	}
+	lon, err := lonG.value("W")
+	if err != nil {
+		return Point{}, fmt.Errorf("longitude in %q: %w", s, err)
+	}
+	if math.Abs(lat) > 90 {
+		return Point{}, fmt.Errorf("latitude %.6f out of range", lat)
+	}
+	if math.Abs(lon) > 180 {
+		return Point{}, fmt.Errorf("longitude %.6f out of range", lon)
+	}
+	return Point{Lat: lat, Lon: lon}, nil
+}
+
+func (g degreeGroup) value(negHemi string) (float64, error) {
+	if len(g.nums) == 0 || len(g.nums) > 3 {
+		return 0, fmt.Errorf("want degrees with optional minutes and seconds")
+	}
+	var parts [3]float64
+	for i, n := range g.nums {
+		v, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", n)
+		}
+		if i > 0 && (v < 0 || v >= 60) {
+			return 0, fmt.Errorf("minutes and seconds must be in [0, 60), got %s", n)
+		}
+		parts[i] = v
+	}
+	neg := strings.HasPrefix(g.nums[0], "-")
+	v := math.Abs(parts[0]) + parts[1]/60 + parts[2]/3600
+	if g.hemi != 0 && string(g.hemi) == negHemi {
+		if neg {
+			return 0, fmt.Errorf("negative value with hemisphere %c", g.hemi)
+		}
+		neg = true
+	}
+	if neg {
+		v = -v
+	}
+	return v, nil
+}
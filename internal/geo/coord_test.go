@@ -0,0 +1,248 @@
+package geo
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestUTMReference(t *testing.T) {
+	// GeoConvert(1) manual: echo 33.3 44.4 | GeoConvert -u
+	u, err := ToUTM(Point{Lat: 33.3, Lon: 44.4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Zone != 38 || u.Band != 'S' || !u.North || math.Abs(u.Easting-444140.54) > 0.01 || math.Abs(u.Northing-3684706.36) > 0.01 {
+		t.Errorf("ToUTM = %+v, want 38S 444140.54 3684706.36", u)
+	}
+	if s := u.String(); s != "38S 444140 3684706" || u.EPSG() != 32638 {
+		t.Errorf("String %q, EPSG %d", s, u.EPSG())
+	}
+	m, err := ToMGRS(Point{Lat: 33.3, Lon: 44.4}, 5)
+	if err != nil || m.String() != "38SMB4414084706" {
+		t.Errorf("ToMGRS = %v, %v, want 38SMB4414084706", m, err)
+	}
+}
+
+func TestUTMZone(t *testing.T) {
+	for _, c := range []struct {
+		lat, lon float64
+		zone     int
+	}{
+		{0, -180, 1},
+		{0, 180, 1}, // wraps to -180
+		{0, 179.999, 60},
+		{0, 5.999999, 31},
+		{0, 6, 32},
+		{-45, -0.000001, 30},
+		// Norway: zone 32 is widened west over 3°E–12°E in 56°N–64°N.
+		{60, 5, 32},
+		{60, 2.999, 31},
+		{55.999, 5, 31},
+		{64, 5, 31},
+		// Svalbard: 72°N–84°N uses only odd zones 31–37 up to 42°E.
+		{78, 8.999, 31},
+		{78, 9, 33},
+		{78, 20.999, 33},
+		{78, 21, 35},
+		{78, 33, 37},
+		{78, 41.999, 37},
+		{78, 42, 38},
+		{71.999, 10, 32},
+		{83.999, 10, 33},
+	} {
+		if got := UTMZone(Point{Lat: c.lat, Lon: c.lon}); got != c.zone {
+			t.Errorf("UTMZone(%v, %v) = %d, want %d", c.lat, c.lon, got, c.zone)
+		}
+	}
+}
+
+func TestUTMBand(t *testing.T) {
+	for _, c := range []struct {
+		lat  float64
+		band byte
+	}{
+		{-80.001, 0}, {-80, 'C'}, {-72.001, 'C'}, {-72, 'D'}, {-0.0001, 'M'}, {0, 'N'},
+		{71.999, 'W'}, {72, 'X'}, {84, 'X'}, {84.001, 0},
+	} {
+		if got := UTMBand(c.lat); got != c.band {
+			t.Errorf("UTMBand(%v) = %q, want %q", c.lat, got, c.band)
+		}
+	}
+}
+
+func TestUTMPolarLimits(t *testing.T) {
+	for _, lat := range []float64{84.001, 90, -80.001, -90} {
+		p := Point{Lat: lat, Lon: 10}
+		if _, err := ToUTM(p); err == nil {
+			t.Errorf("ToUTM(%v) succeeded outside UTM coverage", p)
+		}
+		if _, err := ToMGRS(p, 5); err == nil {
+			t.Errorf("ToMGRS(%v) succeeded outside UTM coverage", p)
+		}
+		if s := p.Format(FormatMGRS); !strings.Contains(s, ", ") {
+			t.Errorf("Format(%v, mgrs) = %q, want the decimal-degree fallback", p, s)
+		}
+	}
+	for _, lat := range []float64{84, -80} {
+		if _, err := ToMGRS(Point{Lat: lat, Lon: 10}, 5); err != nil {
+			t.Errorf("ToMGRS at %v°: %v", lat, err)
+		}
+	}
+}
+
+// roundTripPoints sit on zone and band edges, inside the Norway and
+// Svalbard exceptions and at the limits of UTM coverage.
+var roundTripPoints = []Point{
+	{Lat: 33.3, Lon: 44.4},
+	{Lat: -33.8568, Lon: 151.2153},
+	{Lat: 0.0001, Lon: 3},
+	{Lat: -0.0001, Lon: 3},
+	{Lat: 0, Lon: -179.9999},
+	{Lat: 8, Lon: 150},
+	{Lat: 23.9999, Lon: 5.9999},
+	{Lat: 60, Lon: 4},
+	{Lat: 63.9999, Lon: 11.9999},
+	{Lat: 78.2, Lon: 15.6},
+	{Lat: 79, Lon: 40.5},
+	{Lat: 83.9, Lon: 30},
+	{Lat: 84, Lon: -100},
+	{Lat: -79.9999, Lon: 10},
+	{Lat: -80, Lon: -170},
+}
+
+func TestUTMRoundTrip(t *testing.T) {
+	for _, p := range roundTripPoints {
+		u, err := ToUTM(p)
+		if err != nil {
+			t.Errorf("%v: %v", p, err)
+			continue
+		}
+		if q := u.Point(); math.Abs(q.Lat-p.Lat) > 1e-9 || math.Abs(wrapLon(q.Lon-p.Lon)) > 1e-9 {
+			t.Errorf("%v → %v → %v", p, u, q)
+		}
+		parsed, err := ParseCoordinate(u.String())
+		if err != nil {
+			t.Errorf("%v: parse %q: %v", p, u.String(), err)
+		} else if d := DistanceM(p, parsed); d > 1.5 {
+			t.Errorf("%v: %q parses %.2f m away", p, u.String(), d)
+		}
+	}
+}
+
+func TestMGRSRoundTrip(t *testing.T) {
+	for _, p := range roundTripPoints {
+		for _, precision := range []int{5, 3, 0} {
+			m, err := ToMGRS(p, precision)
+			if err != nil {
+				t.Errorf("%v: %v", p, err)
+				continue
+			}
+			// The point lies in the square the reference denotes, whose
+			// center is at most half its diagonal away.
+			q, err := ParseCoordinate(m.String())
+			if err != nil {
+				t.Errorf("%v: parse %q: %v", p, m.String(), err)
+				continue
+			}
+			if d, max := DistanceM(p, q), m.CellSizeM()*math.Sqrt2/2*1.001+0.01; d > max {
+				t.Errorf("%v: %s center is %.1f m away, more than %.1f m", p, m, d, max)
+			}
+			// The row letter's 2000 km cycle resolves to the square that
+			// holds the point.
+			sw := m.UTM()
+			u := ToUTMZone(p, m.Zone)
+			if de, dn := u.Easting-sw.Easting, u.Northing-sw.Northing; de < 0 || de >= m.CellSizeM() || dn < 0 || dn >= m.CellSizeM() {
+				t.Errorf("%v: %s has its south-west corner at %.0f, %.0f, not within %.0f m of %.0f, %.0f",
+					p, m, sw.Easting, sw.Northing, m.CellSizeM(), u.Easting, u.Northing)
+			}
+		}
+	}
+}
+
+func TestParseMGRS(t *testing.T) {
+	m, err := ParseMGRS("49q bb 45678 15678")
+	want := MGRS{Zone: 49, Band: 'Q', Column: 'B', Row: 'B', Easting: 45678, Northing: 15678, Precision: 5}
+	if err != nil || m != want {
+		t.Errorf("ParseMGRS = %+v, %v, want %+v", m, err, want)
+	}
+	if m, err := ParseMGRS("49QBB4515"); err != nil || m.Easting != 45000 || m.Northing != 15000 || m.CellSizeM() != 1000 {
+		t.Errorf("ParseMGRS(49QBB4515) = %+v, %v", m, err)
+	}
+	for _, s := range []string{"49QBB456781567", "61QBB", "0QBB", "49IBB", "49QIB", "49QBW", "49QSB12", "49QBB12345678901"} {
+		if _, err := ParseMGRS(s); err == nil {
+			t.Errorf("ParseMGRS(%q) succeeded", s)
+		}
+	}
+}
+
+func TestParseCoordinate(t *testing.T) {
+	dms := func(d, m, s float64) float64 { return d + m/60 + s/3600 }
+	for _, c := range []struct {
+		in       string
+		lat, lon float64
+	}{
+		{"18.2269, 109.5331", 18.2269, 109.5331},
+		{"-33.8568 151.2153", -33.8568, 151.2153},
+		{`18°13'36.8"N 109°31'59.2"E`, dms(18, 13, 36.8), dms(109, 31, 59.2)},
+		{"N 18 13 36.8, E 109 31 59.2", dms(18, 13, 36.8), dms(109, 31, 59.2)},
+		{"109°31′59.2″E 18°13′36.8″N", dms(18, 13, 36.8), dms(109, 31, 59.2)},
+		{"33 51 24.5 S, 151 12 55 E", -dms(33, 51, 24.5), dms(151, 12, 55)},
+		{"33 51.4 151 12.9", dms(33, 51.4, 0), dms(151, 12.9, 0)},
+		{"-33 51 24.5, -70 30 0", -dms(33, 51, 24.5), -70.5},
+		{"0, 180", 0, 180},
+		{"90S 180W", -90, -180},
+	} {
+		p, err := ParseCoordinate(c.in)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if math.Abs(p.Lat-c.lat) > 1e-12 || math.Abs(p.Lon-c.lon) > 1e-12 {
+			t.Errorf("%q = %v, want %v, %v", c.in, p, c.lat, c.lon)
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"18.2",
+		"91, 0",
+		"0, 180.5",
+		"18 60 0, 109 0 0",
+		"18N 109N",
+		"-18S 109E",
+		"18.2 x 109.5",
+		"1 2 3 4 5 6 7 8",
+		"61Q 345678 2015678",
+	} {
+		if p, err := ParseCoordinate(in); err == nil {
+			t.Errorf("ParseCoordinate(%q) = %v, want an error", in, p)
+		}
+	}
+}
+
+func TestFormatDMS(t *testing.T) {
+	p := Point{Lat: 18.2269, Lon: 109.5331}
+	if s := p.Format(FormatDMS); s != `18°13'36.8"N 109°31'59.2"E` {
+		t.Errorf("Format(dms) = %s", s)
+	}
+	// Seconds that round up to 60 carry into the minutes and degrees.
+	if s := (Point{Lat: -10.99999, Lon: 0}).Format(FormatDMS); s != `11°00'00.0"S 0°00'00.0"E` {
+		t.Errorf("Format(dms) = %s", s)
+	}
+	q, err := ParseCoordinate(p.Format(FormatDMS))
+	if err != nil || DistanceM(p, q) > 3 {
+		t.Errorf("DMS round trip: %v, %v", q, err)
+	}
+}
+
+func TestParseCoordFormat(t *testing.T) {
+	for in, want := range map[string]CoordFormat{"": FormatDD, " MGRS ": FormatMGRS, "dms": FormatDMS, "UTM": FormatUTM} {
+		if f, err := ParseCoordFormat(in); err != nil || f != want {
+			t.Errorf("ParseCoordFormat(%q) = %q, %v", in, f, err)
+		}
+	}
+	if _, err := ParseCoordFormat("ddm"); err == nil {
+		t.Error("ParseCoordFormat(ddm) succeeded")
+	}
+}
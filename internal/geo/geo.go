@@ -14,7 +14,8 @@ type Point struct {
 	Lon float64 `json:"lon"`
 }
 
-// BBox represents a bounding box.
+// BBox represents a bounding box. Longitudes are in [-180, 180]; a box
+// crossing the antimeridian has West > East, following RFC 7946.
 type BBox struct {
 	West  float64 `json:"west"`
 	South float64 `json:"south"`
@@ -23,15 +24,26 @@ type BBox struct {
 }
 
 // BBoxFromCenter creates a bounding box from a center point and radius.
+// Latitudes are clamped at the poles; if the radius reaches a pole the box
+// spans all longitudes, and boxes reaching past ±180° cross the antimeridian.
 func BBoxFromCenter(center Point, radiusKm float64) BBox {
+	lat := clampLat(center.Lat)
 	dLat := (radiusKm / EarthRadiusKm) * RadToDeg
-	dLon := (radiusKm / (EarthRadiusKm * math.Cos(center.Lat*DegToRad))) * RadToDeg
-	return BBox{
-		West:  center.Lon - dLon,
-		South: center.Lat - dLat,
-		East:  center.Lon + dLon,
-		North: center.Lat + dLat,
+	b := BBox{South: clampLat(lat - dLat), North: clampLat(lat + dLat)}
+
+	cosLat := math.Cos(lat * DegToRad)
+	if b.North >= 90 || b.South <= -90 || cosLat <= 0 {
+		b.West, b.East = -180, 180
+		return b
+	}
+	dLon := (radiusKm / (EarthRadiusKm * cosLat)) * RadToDeg
+	if dLon >= 180 {
+		b.West, b.East = -180, 180
+		return b
 	}
+	b.West = center.Lon - dLon
+	b.East = center.Lon + dLon
+	return b.Normalize()
 }
 
 // Haversine returns the distance in km between two points.
@@ -48,11 +60,20 @@ func Haversine(a, b Point) float64 {
 }
 
 // PixelToGeo converts pixel coordinates to geographic coordinates given reference points and GSD.
+// Columns step east along the top-left pixel's parallel on the WGS84
+// ellipsoid, so a pixel row stays at one latitude; rows then step south
+// along the meridian.
 func PixelToGeo(px, py int, topLeft Point, gsdMeters float64) Point {
-	dLatPerPx := (gsdMeters / 1000.0 / EarthRadiusKm) * RadToDeg
-	dLonPerPx := (gsdMeters / 1000.0 / (EarthRadiusKm * math.Cos(topLeft.Lat*DegToRad))) * RadToDeg
-	return Point{
-		Lat: topLeft.Lat - float64(py)*dLatPerPx,
-		Lon: topLeft.Lon + float64(px)*dLonPerPx,
+	p := topLeft
+	if px != 0 {
+		// The parallel's radius is N·cos φ, N the prime vertical radius.
+		sinLat, cosLat := math.Sincos(p.Lat * DegToRad)
+		if r := WGS84A / math.Sqrt(1-wgs84E2*sinLat*sinLat) * cosLat; r > 0 {
+			p.Lon = wrapLon(p.Lon + float64(px)*gsdMeters/r*RadToDeg)
+		}
+	}
+	if py != 0 {
+		p = Destination(p, 180, float64(py)*gsdMeters)
 	}
+	return p
 }
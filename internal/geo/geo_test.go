@@ -0,0 +1,30 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPixelToGeoKeepsRows(t *testing.T) {
+	// At 60°N a geodesic leaving due east for 100 km ends about 1.35 km
+	// south of the parallel; a pixel row must not.
+	topLeft := Point{Lat: 60, Lon: 10}
+	p := PixelToGeo(10000, 0, topLeft, 10)
+	if p.Lat != topLeft.Lat {
+		t.Errorf("row 0 ends at latitude %.9f, want %.9f", p.Lat, topLeft.Lat)
+	}
+	// 100 km along the parallel; the geodesic chord is slightly shorter.
+	if d := DistanceM(topLeft, p); d > 100000 || d < 99990 {
+		t.Errorf("10000 px at 10 m span %.3f m", d)
+	}
+	// Halfway along, the parallel is the midpoint by arc length.
+	mid := PixelToGeo(5000, 0, topLeft, 10)
+	if got, want := mid.Lon-topLeft.Lon, (p.Lon-topLeft.Lon)/2; math.Abs(got-want) > 1e-12 {
+		t.Errorf("column 5000 at %.12f° east, want %.12f°", got, want)
+	}
+
+	q := PixelToGeo(0, 1000, topLeft, 10)
+	if g := Inverse(topLeft, q); math.Abs(g.DistanceM-10000) > 1e-6 || q.Lon != topLeft.Lon {
+		t.Errorf("1000 rows down: %.6f m at %v, want 10000 m due south", g.DistanceM, q)
+	}
+}
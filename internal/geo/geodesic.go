@@ -0,0 +1,520 @@
+package geo
+
+import "math"
+
+// WGS84 ellipsoid parameters.
+const (
+	WGS84A  = 6378137.0             // semi-major axis in meters
+	WGS84F  = 1 / 298.257223563     // flattening
+	WGS84B  = WGS84A * (1 - WGS84F) // semi-minor axis in meters
+	wgs84E2 = WGS84F * (2 - WGS84F) // first eccentricity squared
+)
+
+const (
+	vincentyTolerance = 1e-12
+	vincentyMaxIter   = 200
+)
+
+// Geodesic is the solution of the inverse problem between two points on
+// the WGS84 ellipsoid.
+type Geodesic struct {
+	DistanceM      float64 // length of the geodesic in meters
+	InitialBearing float64 // azimuth at the start, degrees clockwise from north in [0, 360)
+	FinalBearing   float64 // azimuth at the end, degrees clockwise from north in [0, 360)
+	Approximate    bool    // true if no solution was found and a great-circle one is given instead
+}
+
+// Inverse solves the inverse geodesic problem on the WGS84 ellipsoid with
+// Vincenty's formulae, accurate to well under a millimeter. For nearly
+// antipodal points, where Vincenty's iteration on the longitude does not
+// converge, the starting azimuth is solved for instead (see
+// antipodalInverse), to the same accuracy.
+func Inverse(a, b Point) Geodesic {
+	L := wrapLon(b.Lon-a.Lon) * DegToRad
+	U1 := math.Atan((1 - WGS84F) * math.Tan(a.Lat*DegToRad))
+	U2 := math.Atan((1 - WGS84F) * math.Tan(b.Lat*DegToRad))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	var sinSigma, cosSigma, sigma, cos2Alpha, cos2SigmaM, sinLambda, cosLambda float64
+	converged := false
+	for i := 0; i < vincentyMaxIter; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		t1 := cosU2 * sinLambda
+		t2 := cosU1*sinU2 - sinU1*cosU2*cosLambda
+		sinSigma = math.Sqrt(t1*t1 + t2*t2)
+		if sinSigma == 0 {
+			return Geodesic{} // coincident points
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cos2Alpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		C := WGS84F / 16 * cos2Alpha * (4 + WGS84F*(4-3*cos2Alpha))
+		prev := lambda
+		lambda = L + (1-C)*WGS84F*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < vincentyTolerance {
+			converged = true
+			break
+		}
+	}
+	if !converged || math.Abs(lambda) > math.Pi {
+		return antipodalInverse(a, b)
+	}
+
+	u2 := cos2Alpha * (WGS84A*WGS84A - WGS84B*WGS84B) / (WGS84B * WGS84B)
+	A := 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+	B := u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+
+	// Azimuths from the converged lambda rather than the last iterate's.
+	sinLambda, cosLambda = math.Sincos(lambda)
+	alpha1 := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	alpha2 := math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)
+	return Geodesic{
+		DistanceM:      WGS84B * A * (sigma - deltaSigma),
+		InitialBearing: normBearing(alpha1 * RadToDeg),
+		FinalBearing:   normBearing(alpha2 * RadToDeg),
+	}
+}
+
+// antipodalInverse solves the inverse problem for nearly antipodal points.
+// Each starting azimuth α1 defines a geodesic; following it on Vincenty's
+// auxiliary sphere to one of its two crossings of b's latitude gives the
+// longitude reached there. The azimuths at which that longitude equals b's
+// are bracketed on a grid over [0°, 180°] for either crossing and refined
+// by bisection, and the shortest of the resulting geodesics is returned.
+// Near the antipode there are usually two, mirror images for points
+// symmetric about the equator.
+func antipodalInverse(a, b Point) Geodesic {
+	if math.Abs(b.Lat) > math.Abs(a.Lat) {
+		// Start from the point nearer a pole, so that every geodesic
+		// through it reaches the other's latitude.
+		g := antipodalInverse(b, a)
+		return Geodesic{
+			DistanceM:      g.DistanceM,
+			InitialBearing: normBearing(g.FinalBearing + 180),
+			FinalBearing:   normBearing(g.InitialBearing + 180),
+			Approximate:    g.Approximate,
+		}
+	}
+	// Reduce to lon12 in [0°, 180°] and a in the southern hemisphere.
+	lon12 := wrapLon(b.Lon - a.Lon)
+	lonSign, latSign := 1.0, 1.0
+	if lon12 < 0 {
+		lon12, lonSign = -lon12, -1
+	}
+	lat1, lat2 := a.Lat, b.Lat
+	if lat1 > 0 {
+		lat1, lat2, latSign = -lat1, -lat2, -1
+	}
+	L := lon12 * DegToRad
+	U1 := math.Atan((1 - WGS84F) * math.Tan(lat1*DegToRad))
+	U2 := math.Atan((1 - WGS84F) * math.Tan(lat2*DegToRad))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2 := math.Sin(U2)
+
+	type solution struct {
+		dL, s, alpha1, alpha2 float64
+	}
+	// solve follows the geodesic leaving at alpha1 to the crossing of b's
+	// latitude before (crossing 0) or after (1) its northern vertex.
+	solve := func(alpha1 float64, crossing int) solution {
+		sinAlpha1, cosAlpha1 := math.Sincos(alpha1)
+		sinAlpha := cosU1 * sinAlpha1
+		cos2Alpha := 1 - sinAlpha*sinAlpha
+		// Arc lengths are measured from the northward equator crossing.
+		sigma1 := math.Atan2(math.Tan(U1), cosAlpha1)
+		if sigma1 > 0 {
+			sigma1 -= 2 * math.Pi // leaving the equator southward
+		}
+		t := 0.0
+		if cosAlpha := math.Sqrt(cos2Alpha); cosAlpha > 0 {
+			t = math.Max(-1, math.Min(1, sinU2/cosAlpha))
+		}
+		sigma2 := math.Asin(t)
+		if crossing == 1 {
+			sigma2 = math.Pi - sigma2
+		}
+		for sigma2 <= sigma1 {
+			sigma2 += 2 * math.Pi
+		}
+		for sigma2-sigma1 > 2*math.Pi {
+			sigma2 -= 2 * math.Pi
+		}
+		sigma := sigma2 - sigma1
+		sinSigma, cosSigma := math.Sincos(sigma)
+		cos2SigmaM := math.Cos(sigma1 + sigma2)
+
+		// Longitude on the auxiliary sphere, then on the ellipsoid.
+		lambda := math.Atan2(sinAlpha*math.Sin(sigma2), math.Cos(sigma2)) -
+			math.Atan2(sinAlpha*math.Sin(sigma1), math.Cos(sigma1))
+		if lambda < 0 {
+			lambda += 2 * math.Pi
+		}
+		C := WGS84F / 16 * cos2Alpha * (4 + WGS84F*(4-3*cos2Alpha))
+		l := lambda - (1-C)*WGS84F*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+
+		u2 := cos2Alpha * (WGS84A*WGS84A - WGS84B*WGS84B) / (WGS84B * WGS84B)
+		A := 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+		B := u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+		return solution{
+			dL:     l - L,
+			s:      WGS84B * A * (sigma - deltaSigma),
+			alpha1: alpha1,
+			alpha2: math.Atan2(sinAlpha, -x),
+		}
+	}
+
+	// Roots are accepted only where the longitude really matches: the
+	// crossings swap at some azimuths, and the jump there changes sign too.
+	const (
+		steps = 180
+		tol   = 1e-12
+	)
+	best := solution{s: math.Inf(1)}
+	consider := func(sol solution) {
+		if math.Abs(sol.dL) < tol && sol.s < best.s {
+			best = sol
+		}
+	}
+	for crossing := 0; crossing < 2; crossing++ {
+		prev := solve(0, crossing)
+		consider(prev)
+		for i := 1; i <= steps; i++ {
+			next := solve(math.Pi*float64(i)/steps, crossing)
+			consider(next)
+			if (prev.dL < 0) != (next.dL < 0) {
+				lo, hi := prev, next
+				for j := 0; j < 100 && hi.alpha1-lo.alpha1 > 1e-15; j++ {
+					mid := solve((lo.alpha1+hi.alpha1)/2, crossing)
+					if (mid.dL < 0) == (lo.dL < 0) {
+						lo = mid
+					} else {
+						hi = mid
+					}
+				}
+				consider(lo)
+				consider(hi)
+			}
+			prev = next
+		}
+	}
+	if math.IsInf(best.s, 1) {
+		return sphericalInverse(a, b)
+	}
+
+	// Undo the reductions: mirroring about the equator maps an azimuth α
+	// to 180° - α, and about the meridian to -α.
+	fix := func(alpha float64) float64 {
+		deg := alpha * RadToDeg
+		if latSign < 0 {
+			deg = 180 - deg
+		}
+		return normBearing(lonSign * deg)
+	}
+	return Geodesic{
+		DistanceM:      best.s,
+		InitialBearing: fix(best.alpha1),
+		FinalBearing:   fix(best.alpha2),
+	}
+}
+
+// sphericalInverse is the great-circle solution on a sphere of the mean
+// radius, the last resort of antipodalInverse.
+func sphericalInverse(a, b Point) Geodesic {
+	const meanRadiusM = (2*WGS84A + WGS84B) / 3
+	return Geodesic{
+		DistanceM:      Haversine(a, b) / EarthRadiusKm * meanRadiusM,
+		InitialBearing: sphericalBearing(a, b),
+		FinalBearing:   normBearing(sphericalBearing(b, a) + 180),
+		Approximate:    true,
+	}
+}
+
+func sphericalBearing(a, b Point) float64 {
+	lat1, lat2 := a.Lat*DegToRad, b.Lat*DegToRad
+	dLon := (b.Lon - a.Lon) * DegToRad
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	return normBearing(math.Atan2(y, x) * RadToDeg)
+}
+
+// Direct solves the direct geodesic problem: starting at p with the given
+// azimuth (degrees clockwise from north) and traveling distM meters along
+// the WGS84 geodesic, it returns the destination and the final azimuth.
+func Direct(p Point, azimuthDeg, distM float64) (Point, float64) {
+	alpha1 := azimuthDeg * DegToRad
+	sinAlpha1, cosAlpha1 := math.Sincos(alpha1)
+
+	tanU1 := (1 - WGS84F) * math.Tan(p.Lat*DegToRad)
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cos2Alpha := 1 - sinAlpha*sinAlpha
+	u2 := cos2Alpha * (WGS84A*WGS84A - WGS84B*WGS84B) / (WGS84B * WGS84B)
+	A := 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+	B := u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+
+	sigma := distM / (WGS84B * A)
+	var sinSigma, cosSigma, cos2SigmaM float64
+	for i := 0; i < vincentyMaxIter; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		prev := sigma
+		sigma = distM/(WGS84B*A) + deltaSigma
+		if math.Abs(sigma-prev) < vincentyTolerance {
+			break
+		}
+	}
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+	sinSigma, cosSigma = math.Sincos(sigma)
+
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	lat2 := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1, (1-WGS84F)*math.Sqrt(sinAlpha*sinAlpha+x*x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	C := WGS84F / 16 * cos2Alpha * (4 + WGS84F*(4-3*cos2Alpha))
+	L := lambda - (1-C)*WGS84F*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+	alpha2 := math.Atan2(sinAlpha, -x)
+
+	return Point{
+		Lat: lat2 * RadToDeg,
+		Lon: wrapLon(p.Lon + L*RadToDeg),
+	}, normBearing(alpha2 * RadToDeg)
+}
+
+// DistanceM returns the WGS84 geodesic distance in meters.
+func DistanceM(a, b Point) float64 {
+	return Inverse(a, b).DistanceM
+}
+
+// InitialBearing returns the azimuth of the geodesic at a towards b.
+func InitialBearing(a, b Point) float64 {
+	return Inverse(a, b).InitialBearing
+}
+
+// FinalBearing returns the azimuth of the geodesic from a on arrival at b.
+func FinalBearing(a, b Point) float64 {
+	return Inverse(a, b).FinalBearing
+}
+
+// Destination returns the point distM meters from p along the geodesic
+// leaving at the given azimuth.
+func Destination(p Point, azimuthDeg, distM float64) Point {
+	dst, _ := Direct(p, azimuthDeg, distM)
+	return dst
+}
+
+func normBearing(deg float64) float64 {
+	deg = math.Mod(deg, 360) + 0 // no -0
+	if deg < 0 {
+		deg += 360
+	}
+	if deg >= 360 { // tiny negative inputs round up to 360
+		deg = 0
+	}
+	return deg
+}
+
+func authalicQ(sinLat float64) float64 {
+	e := math.Sqrt(wgs84E2)
+	es := e * sinLat
+	return (1 - wgs84E2) * (sinLat/(1-es*es) - 1/(2*e)*math.Log((1-es)/(1+es)))
+}
+
+// authalicRadiusM is the radius of the sphere with the ellipsoid's surface area.
+var authalicRadiusM = WGS84A * math.Sqrt(authalicQ(1)/2)
+
+// GeodesicAreaM2 returns the area enclosed by the ring's geodesic edges
+// on the WGS84 ellipsoid in square meters, after Karney, "Algorithms for
+// geodesics", J. Geodesy 87 (2013), section 6: each edge contributes the
+// area between it and the equator, and a ring around a pole is closed
+// over it. Of the two regions the ring bounds, the smaller is measured,
+// whatever the ring's orientation. The edges' azimuths come from Inverse,
+// whose truncation limits the result to a relative error of about 1e-11.
+func (r Ring) GeodesicAreaM2() float64 {
+	var sum, winding float64
+	for i := range r {
+		p1, p2 := r[i], r[(i+1)%len(r)]
+		sum += edgeAreaM2(p1, p2)
+		winding += wrapLon(p2.Lon - p1.Lon)
+	}
+	total := 4 * math.Pi * authalicRadiusM * authalicRadiusM
+	if math.Abs(winding) > 180 {
+		// The ring goes around a pole: the edges' areas down to the
+		// equator leave out half the ellipsoid.
+		if sum < 0 {
+			sum += total / 2
+		} else {
+			sum -= total / 2
+		}
+	}
+	sum = math.Mod(sum, total)
+	if sum > total/2 {
+		sum -= total
+	} else if sum < -total/2 {
+		sum += total
+	}
+	return math.Abs(sum)
+}
+
+// areaOrder is the order in the third flattening and eps of the series
+// for the area between a geodesic and the equator.
+const areaOrder = 6
+
+// c4Table holds the coefficients of Karney's C4 series (eq. 64) as
+// polynomials in n, each followed by its divisor, from GeographicLib.
+var c4Table = [...]float64{
+	97, 15015,
+	1088, 156, 45045,
+	-224, -4784, 1573, 45045,
+	-10656, 14144, -4576, -858, 45045,
+	64, 624, -4576, 6864, -3003, 15015,
+	100, 208, 572, 3432, -12012, 30030, 45045,
+	1, 9009,
+	-2944, 468, 135135,
+	5792, 1040, -1287, 135135,
+	5952, -11648, 9152, -2574, 135135,
+	-64, -624, 4576, -6864, 3003, 135135,
+	8, 10725,
+	1856, -936, 225225,
+	-8448, 4992, -1144, 225225,
+	-1440, 4160, -4576, 1716, 225225,
+	-136, 63063,
+	1024, -208, 105105,
+	3584, -3328, 1144, 315315,
+	-128, 135135,
+	-2560, 832, 405405,
+	128, 99099,
+}
+
+// c4x are the C4 coefficients evaluated for WGS84's third flattening, as
+// polynomials in eps.
+var c4x = func() []float64 {
+	n := WGS84F / (2 - WGS84F)
+	var out []float64
+	o := 0
+	for l := 0; l < areaOrder; l++ {
+		for j := areaOrder - 1; j >= l; j-- {
+			m := areaOrder - j - 1
+			out = append(out, polyval(c4Table[o:o+m+1], n)/c4Table[o+m+1])
+			o += m + 2
+		}
+	}
+	return out
+}()
+
+// polyval evaluates a polynomial with coefficients from the highest power.
+func polyval(p []float64, x float64) float64 {
+	var y float64
+	for _, c := range p {
+		y = y*x + c
+	}
+	return y
+}
+
+// edgeAreaM2 returns S12, the area between the geodesic from a to b and
+// the equator, positive for an edge running east north of the equator
+// (eqs. 58-59 and 64-65).
+func edgeAreaM2(a, b Point) float64 {
+	if a == b {
+		return 0
+	}
+	// Poles as the limit from the edge's meridian.
+	const poleLat = 90 - 1e-9
+	a.Lat = math.Max(-poleLat, math.Min(poleLat, a.Lat))
+	b.Lat = math.Max(-poleLat, math.Min(poleLat, b.Lat))
+	g := Inverse(a, b)
+	sbet1, cbet1 := math.Sincos(math.Atan((1 - WGS84F) * math.Tan(a.Lat*DegToRad)))
+	sbet2, cbet2 := math.Sincos(math.Atan((1 - WGS84F) * math.Tan(b.Lat*DegToRad)))
+	salp1, calp1 := math.Sincos(g.InitialBearing * DegToRad)
+	salp2, calp2 := math.Sincos(g.FinalBearing * DegToRad)
+
+	var s12 float64
+	salp0, calp0 := salp1*cbet1, math.Hypot(calp1, salp1*sbet1)
+	if salp0 != 0 && calp0 != 0 {
+		k2 := calp0 * calp0 * wgs84E2 / (1 - wgs84E2)
+		eps := k2 / (2*(1+math.Sqrt(1+k2)) + k2)
+		var c4 [areaOrder]float64
+		mult, o := 1.0, 0
+		for l := range c4 {
+			m := areaOrder - l - 1
+			c4[l] = mult * polyval(c4x[o:o+m+1], eps)
+			o += m + 1
+			mult *= eps
+		}
+		series := func(ssig, csig float64) float64 {
+			sig := math.Atan2(ssig, csig)
+			var sum float64
+			for l, c := range c4 {
+				sum += c * math.Cos(float64(2*l+1)*sig)
+			}
+			return sum
+		}
+		a4 := WGS84A * WGS84A * calp0 * salp0 * wgs84E2
+		s12 = a4 * (series(sbet2, calp2*cbet2) - series(sbet1, calp1*cbet1))
+	}
+	alp12 := math.Atan2(salp2*calp1-calp2*salp1, calp2*calp1+salp2*salp1)
+	return s12 + authalicRadiusM*authalicRadiusM*alp12
+}
+
+// GeodesicPerimeterM returns the length of the ring's edges along WGS84
+// geodesics in meters.
+func (r Ring) GeodesicPerimeterM() float64 {
+	var sum float64
+	for i := range r {
+		sum += DistanceM(r[i], r[(i+1)%len(r)])
+	}
+	return sum
+}
+
+// GeodesicAreaM2 returns the outer ring's area minus its holes.
+func (p Polygon) GeodesicAreaM2() float64 {
+	if len(p) == 0 {
+		return 0
+	}
+	area := p[0].GeodesicAreaM2()
+	for _, hole := range p[1:] {
+		area -= hole.GeodesicAreaM2()
+	}
+	return area
+}
+
+// GeodesicPerimeterM returns the total length of all rings, holes included.
+func (p Polygon) GeodesicPerimeterM() float64 {
+	var sum float64
+	for _, r := range p {
+		sum += r.GeodesicPerimeterM()
+	}
+	return sum
+}
+
+// GeodesicAreaM2 returns the summed area of all member polygons.
+func (m MultiPolygon) GeodesicAreaM2() float64 {
+	var sum float64
+	for _, p := range m {
+		sum += p.GeodesicAreaM2()
+	}
+	return sum
+}
+
+// GeodesicPerimeterM returns the summed perimeter of all member polygons.
+func (m MultiPolygon) GeodesicPerimeterM() float64 {
+	var sum float64
+	for _, p := range m {
+		sum += p.GeodesicPerimeterM()
+	}
+	return sum
+}
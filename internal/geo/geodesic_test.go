@@ -0,0 +1,169 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// halfMeridianM is the length of the WGS84 meridian from pole to pole,
+// the distance between any two antipodal points.
+const halfMeridianM = 20003931.4586
+
+// Reference solutions published with GeographicLib: the examples of the
+// GeodSolve(1) manual and of Karney, "Algorithms for geodesics", J. Geodesy
+// 87 (2013), including its nearly antipodal case, where Vincenty's method
+// fails to converge.
+var geodesicVectors = []struct {
+	name            string
+	lat1, lon1      float64
+	lat2, lon2      float64
+	azi1, azi2, s12 float64
+	aziTol, s12TolM float64
+}{
+	{"GeodSolve JFK-LHR", 40.6, -73.8, 51.6, -0.5, 51.198882845, 107.821776735, 5551759.400319, 1e-8, 1e-3},
+	{"GeodSolve JFK-SIN", dms(40, 38, 23), -dms(73, 46, 44), dms(1, 21, 33), dms(103, 59, 22), dms(3, 18, 29.9), dms(177, 29, 9.2), 15347628, 0.1 / 3600, 1},
+	{"Karney short", -30.12345, 0, -30.12344, 0.00005, 77.04353354, 77.04350844, 4.944208, 1e-6, 1e-6},
+	{"Karney antipodal", -30, 0, 29.9, 179.8, 161.890524, 18.090737, 19989832.82761, 1e-6, 1e-3},
+}
+
+func dms(d, m, s float64) float64 { return d + m/60 + s/3600 }
+
+func TestInverseReference(t *testing.T) {
+	for _, v := range geodesicVectors {
+		g := Inverse(Point{Lat: v.lat1, Lon: v.lon1}, Point{Lat: v.lat2, Lon: v.lon2})
+		if g.Approximate {
+			t.Errorf("%s: approximate solution", v.name)
+		}
+		if math.Abs(g.DistanceM-v.s12) > v.s12TolM {
+			t.Errorf("%s: distance %.6f m, want %.6f m", v.name, g.DistanceM, v.s12)
+		}
+		if d := angleDiff(g.InitialBearing, v.azi1); d > v.aziTol {
+			t.Errorf("%s: initial bearing %.9f°, want %.9f°", v.name, g.InitialBearing, v.azi1)
+		}
+		if d := angleDiff(g.FinalBearing, v.azi2); d > v.aziTol {
+			t.Errorf("%s: final bearing %.9f°, want %.9f°", v.name, g.FinalBearing, v.azi2)
+		}
+	}
+}
+
+func TestDirectReference(t *testing.T) {
+	for _, v := range geodesicVectors {
+		p, azi2 := Direct(Point{Lat: v.lat1, Lon: v.lon1}, v.azi1, v.s12)
+		// Tolerances follow from the vectors' precision: an azimuth error
+		// of aziTol moves the end point sideways by s12·aziTol in radians.
+		tolM := v.s12TolM + v.s12*v.aziTol*DegToRad
+		if e := offsetM(p, Point{Lat: v.lat2, Lon: v.lon2}); e > tolM {
+			t.Errorf("%s: destination %v is %.4f m from the reference (tolerance %.4f m)", v.name, p, e, tolM)
+		}
+		if d := angleDiff(azi2, v.azi2); d > 10*v.aziTol {
+			t.Errorf("%s: final azimuth %.9f°, want %.9f°", v.name, azi2, v.azi2)
+		}
+	}
+}
+
+// TestInverseNearAntipodal checks the region where Vincenty's iteration
+// fails: solutions must be exact (Direct returns to the target), never the
+// spherical fallback, and continuous with Vincenty's across the boundary.
+func TestInverseNearAntipodal(t *testing.T) {
+	for lat1 := -80.0; lat1 <= 80; lat1 += 10 {
+		for dlat := -1.0; dlat <= 1; dlat += 0.25 {
+			var prev float64
+			for lon := 178.0; lon <= 180; lon += 0.05 {
+				a, b := Point{Lat: lat1}, Point{Lat: -lat1 + dlat, Lon: lon}
+				g := Inverse(a, b)
+				if g.Approximate {
+					t.Fatalf("%v → %v: approximate solution", a, b)
+				}
+				p, _ := Direct(a, g.InitialBearing, g.DistanceM)
+				if e := offsetM(p, b); e > 1e-3 {
+					t.Fatalf("%v → %v: Direct along the solution ends %.4f m from the target", a, b, e)
+				}
+				if g.DistanceM > halfMeridianM+1e-3 {
+					t.Fatalf("%v → %v: distance %.4f m exceeds the half meridian", a, b, g.DistanceM)
+				}
+				// Moving b 0.05° of longitude changes the distance by at most
+				// that arc of its parallel, of radius N·cos φ.
+				sinLat, cosLat := math.Sincos(b.Lat * DegToRad)
+				step := 0.05 * DegToRad * WGS84A / math.Sqrt(1-wgs84E2*sinLat*sinLat) * cosLat
+				if prev > 0 && math.Abs(g.DistanceM-prev) > step+1e-3 {
+					t.Fatalf("%v → %v: distance jumps from %.4f to %.4f m", a, b, prev, g.DistanceM)
+				}
+				prev = g.DistanceM
+			}
+		}
+	}
+}
+
+func TestInverseAntipodes(t *testing.T) {
+	for _, c := range [][2]Point{
+		{{Lat: 0, Lon: 0}, {Lat: 0, Lon: 180}},
+		{{Lat: -30, Lon: 0}, {Lat: 30, Lon: 180}},
+		{{Lat: 45, Lon: 10}, {Lat: -45, Lon: -170}},
+	} {
+		g := Inverse(c[0], c[1])
+		if math.Abs(g.DistanceM-halfMeridianM) > 1e-3 || g.Approximate {
+			t.Errorf("%v → %v: %.4f m (approximate %v), want %.4f m", c[0], c[1], g.DistanceM, g.Approximate, halfMeridianM)
+		}
+	}
+}
+
+func TestBearingRange(t *testing.T) {
+	g := Inverse(Point{Lat: 0, Lon: 0}, Point{Lat: 1, Lon: 0})
+	if g.InitialBearing != 0 || math.Signbit(g.InitialBearing) {
+		t.Errorf("due north: initial bearing %v, want 0", g.InitialBearing)
+	}
+	if b := normBearing(-1e-18); b < 0 || b >= 360 {
+		t.Errorf("normBearing(-1e-18) = %v, want [0, 360)", b)
+	}
+}
+
+func angleDiff(a, b float64) float64 {
+	d := math.Abs(math.Mod(a-b, 360))
+	return math.Min(d, 360-d)
+}
+
+// offsetM is the local planar distance between two nearby points.
+func offsetM(a, b Point) float64 {
+	return math.Hypot(a.Lat-b.Lat, wrapLon(a.Lon-b.Lon)*math.Cos(b.Lat*DegToRad)) * DegToRad * WGS84A
+}
+
+// Polygon vectors from GeographicLib's Planimeter tests. Vincenty's
+// azimuths carry errors of order 1e-11 rad, c²·1e-11 ≈ 400 m² over a
+// hemisphere, so areas are compared relative to their size.
+var areaVectors = []struct {
+	name       string
+	ring       Ring
+	perimeterM float64
+	areaM2     float64
+	perimTolM  float64
+	areaRelTol float64
+}{
+	{"polar square", Ring{{Lat: 89, Lon: 0}, {Lat: 89, Lon: 90}, {Lat: 89, Lon: 180}, {Lat: 89, Lon: 270}}, 631819.8745, 24952305678.0, 1e-4, 1e-10},
+	{"south polar square", Ring{{Lat: -89, Lon: 0}, {Lat: -89, Lon: -90}, {Lat: -89, Lon: -180}, {Lat: -89, Lon: -270}}, 631819.8745, 24952305678.0, 1e-4, 1e-10},
+	{"diamond", Ring{{Lat: 0, Lon: -1}, {Lat: -1, Lon: 0}, {Lat: 0, Lon: 1}, {Lat: 1, Lon: 0}}, 627598.2731, 24619419146.0, 1e-3, 1e-10},
+	{"octant", Ring{{Lat: 90, Lon: 0}, {Lat: 0, Lon: 0}, {Lat: 0, Lon: 90}}, 30022685, 63758202715511.0, 1, 1e-10},
+	{"Antarctica", Ring{
+		{Lat: -63.1, Lon: -58}, {Lat: -72.9, Lon: -74}, {Lat: -71.9, Lon: -102}, {Lat: -74.9, Lon: -102},
+		{Lat: -74.3, Lon: -131}, {Lat: -77.5, Lon: -163}, {Lat: -77.4, Lon: 163}, {Lat: -71.7, Lon: 172},
+		{Lat: -65.9, Lon: 140}, {Lat: -65.7, Lon: 113}, {Lat: -66.6, Lon: 88}, {Lat: -66.9, Lon: 59},
+		{Lat: -69.8, Lon: 25}, {Lat: -70.0, Lon: -4}, {Lat: -71.0, Lon: -14}, {Lat: -77.3, Lon: -33},
+		{Lat: -77.9, Lon: -46}, {Lat: -74.7, Lon: -61},
+	}, 16831067.893, 13662703680020.1, 1e-3, 1e-10},
+}
+
+func TestGeodesicAreaReference(t *testing.T) {
+	for _, v := range areaVectors {
+		if p := v.ring.GeodesicPerimeterM(); math.Abs(p-v.perimeterM) > v.perimTolM {
+			t.Errorf("%s: perimeter %.4f m, want %.4f m", v.name, p, v.perimeterM)
+		}
+		reversed := make(Ring, len(v.ring))
+		for i, p := range v.ring {
+			reversed[len(v.ring)-1-i] = p
+		}
+		for _, r := range []Ring{v.ring, reversed} {
+			if a := r.GeodesicAreaM2(); math.Abs(a-v.areaM2) > v.areaRelTol*v.areaM2 {
+				t.Errorf("%s: area %.1f m², want %.1f m²", v.name, a, v.areaM2)
+			}
+		}
+	}
+}
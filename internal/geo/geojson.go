@@ -0,0 +1,116 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type geojsonObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geojsonObject  `json:"geometry"`
+	Geometries  []geojsonObject `json:"geometries"`
+	Features    []geojsonObject `json:"features"`
+}
+
+// ParseGeoJSON extracts all polygons from a GeoJSON Polygon, MultiPolygon,
+// GeometryCollection, Feature or FeatureCollection. Non-areal geometries
+// are ignored.
+func ParseGeoJSON(data []byte) (MultiPolygon, error) {
+	var obj geojsonObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	return obj.polygons()
+}
+
+func (o *geojsonObject) polygons() (MultiPolygon, error) {
+	switch o.Type {
+	case "FeatureCollection":
+		var out MultiPolygon
+		for i := range o.Features {
+			m, err := o.Features[i].polygons()
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+			out = append(out, m...)
+		}
+		return out, nil
+	case "Feature":
+		if o.Geometry == nil {
+			return nil, nil
+		}
+		return o.Geometry.polygons()
+	case "GeometryCollection":
+		var out MultiPolygon
+		for i := range o.Geometries {
+			m, err := o.Geometries[i].polygons()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, m...)
+		}
+		return out, nil
+	case "Polygon":
+		var coords [][][]float64
+		if err := json.Unmarshal(o.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("polygon coordinates: %w", err)
+		}
+		p, err := polygonFromCoords(coords)
+		if err != nil {
+			return nil, err
+		}
+		return MultiPolygon{p}, nil
+	case "MultiPolygon":
+		var coords [][][][]float64
+		if err := json.Unmarshal(o.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("multipolygon coordinates: %w", err)
+		}
+		var out MultiPolygon
+		for _, pc := range coords {
+			p, err := polygonFromCoords(pc)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, p)
+		}
+		return out, nil
+	case "Point", "MultiPoint", "LineString", "MultiLineString":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported GeoJSON type %q", o.Type)
+}
+
+func polygonFromCoords(coords [][][]float64) (Polygon, error) {
+	var p Polygon
+	for _, rc := range coords {
+		pts := make([]Point, 0, len(rc))
+		for _, c := range rc {
+			if len(c) < 2 {
+				return nil, fmt.Errorf("position needs at least 2 values")
+			}
+			pts = append(pts, Point{Lon: c[0], Lat: c[1]})
+		}
+		r, err := newRing(pts)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, r)
+	}
+	if len(p) == 0 {
+		return nil, fmt.Errorf("polygon has no rings")
+	}
+	return p, nil
+}
+
+// GeoJSONCoordinates returns the polygon in GeoJSON coordinate order
+// ([ring][vertex][lon, lat]) with closed rings.
+func (p Polygon) GeoJSONCoordinates() [][][2]float64 {
+	out := make([][][2]float64, len(p))
+	for i, r := range p {
+		for _, pt := range r.Closed() {
+			out[i] = append(out[i], [2]float64{pt.Lon, pt.Lat})
+		}
+	}
+	return out
+}
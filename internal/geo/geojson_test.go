@@ -0,0 +1,68 @@
+package geo
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGeoJSON(t *testing.T) {
+	doc := `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {"name": "harbor"}, "geometry":
+			{"type": "Polygon", "coordinates": [
+				[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]],
+				[[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]]}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [5, 5]}},
+		{"type": "Feature", "geometry": null},
+		{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": [
+			{"type": "LineString", "coordinates": [[0, 0], [1, 1]]},
+			{"type": "MultiPolygon", "coordinates": [
+				[[[20, 20, 5], [21, 20, 5], [21, 21, 5], [20, 20, 5]]],
+				[[[30, 30], [31, 30], [31, 31]]]]}]}}]}`
+	m, err := ParseGeoJSON([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 3 || len(m[0]) != 2 || len(m[0][0]) != 4 || len(m[1][0]) != 3 || len(m[2][0]) != 3 {
+		t.Fatalf("parsed %v", m)
+	}
+	// Coordinates are lon, lat; the closing vertex is dropped and the
+	// altitude ignored.
+	if m[1][0][1] != (Point{Lat: 20, Lon: 21}) {
+		t.Errorf("vertex %v, want lat 20, lon 21", m[1][0][1])
+	}
+	if m.Contains(Point{Lat: 5, Lon: 5}) || !m.Contains(Point{Lat: 1, Lon: 5}) {
+		t.Error("hole not applied")
+	}
+
+	// Encoding through GeoJSONCoordinates closes the rings again.
+	out, err := json.Marshal(map[string]any{"type": "Polygon", "coordinates": m[0].GeoJSONCoordinates()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := ParseGeoJSON(out)
+	if err != nil || !reflect.DeepEqual(back[0], m[0]) {
+		t.Errorf("round trip %s = %v, %v", out, back, err)
+	}
+}
+
+func TestParseGeoJSONMalformed(t *testing.T) {
+	for _, c := range []struct{ doc, msg string }{
+		{`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0]`, "invalid GeoJSON"},
+		{`[1, 2]`, "invalid GeoJSON"},
+		{`{"type": "Circle", "coordinates": [0, 0]}`, "unsupported"},
+		{`{"coordinates": [[[0, 0], [1, 0], [1, 1]]]}`, "unsupported"},
+		{`{"type": "Polygon", "coordinates": [[0, 0], [1, 0], [1, 1]]}`, "polygon coordinates"},
+		{`{"type": "Polygon", "coordinates": []}`, "no rings"},
+		{`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 0]]]}`, "distinct vertices"},
+		{`{"type": "Polygon", "coordinates": [[[0], [1, 0], [1, 1]]]}`, "at least 2 values"},
+		{`{"type": "MultiPolygon", "coordinates": [[[0, 0], [1, 0], [1, 1]]]}`, "multipolygon coordinates"},
+		{`{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": []}}]}`, "feature 0"},
+	} {
+		_, err := ParseGeoJSON([]byte(c.doc))
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("%s: error %v, want one mentioning %q", c.doc, err, c.msg)
+		}
+	}
+}
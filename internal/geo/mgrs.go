@@ -0,0 +1,189 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mgrsColumnSets are the 100 km column letters, repeating every three zones.
+var mgrsColumnSets = [3]string{"STUVWXYZ", "ABCDEFGH", "JKLMNPQR"}
+
+// mgrsRows are the 100 km row letters; even zones start five letters in.
+const mgrsRows = "ABCDEFGHJKLMNPQRSTUV"
+
+var mgrsPattern = regexp.MustCompile(`^(\d{1,2})([C-HJ-NP-X])([A-HJ-NP-Z])([A-HJ-NP-V])(\d*)$`)
+
+// MGRS is a Military Grid Reference System coordinate: a UTM zone and
+// latitude band, a 100 km square, and an offset within it truncated to
+// Precision digits per axis (0 = the square itself, 5 = 1 m).
+type MGRS struct {
+	Zone      int
+	Band      byte
+	Column    byte
+	Row       byte
+	Easting   float64 // meters within the 100 km square
+	Northing  float64 // meters within the 100 km square
+	Precision int
+}
+
+// String formats the reference as "49QBB4567815678".
+func (m MGRS) String() string {
+	s := fmt.Sprintf("%d%c%c%c", m.Zone, m.Band, m.Column, m.Row)
+	if m.Precision == 0 {
+		return s
+	}
+	div := math.Pow(10, float64(5-m.Precision))
+	return s + fmt.Sprintf("%0*d%0*d", m.Precision, int(m.Easting/div), m.Precision, int(m.Northing/div))
+}
+
+// CellSizeM returns the side of the square the reference denotes.
+func (m MGRS) CellSizeM() float64 {
+	return math.Pow(10, float64(5-m.Precision))
+}
+
+// ToMGRS converts p to an MGRS reference with the given digits per axis
+// (0–5). Polar regions (UPS) are not supported.
+func ToMGRS(p Point, precision int) (MGRS, error) {
+	if precision < 0 || precision > 5 {
+		return MGRS{}, fmt.Errorf("MGRS precision must be 0–5, got %d", precision)
+	}
+	u, err := ToUTM(p)
+	if err != nil {
+		return MGRS{}, err
+	}
+	e, n := floorM(u.Easting), floorM(u.Northing)
+	col := int(e/100000) - 1
+	set := mgrsColumnSets[u.Zone%3]
+	if col < 0 || col >= len(set) {
+		return MGRS{}, fmt.Errorf("easting %.0f outside the MGRS grid", u.Easting)
+	}
+	row := int(n/100000) % 20
+	if u.Zone%2 == 0 {
+		row = (row + 5) % 20
+	}
+	div := math.Pow(10, float64(5-precision))
+	return MGRS{
+		Zone:      u.Zone,
+		Band:      u.Band,
+		Column:    set[col],
+		Row:       mgrsRows[row],
+		Easting:   math.Floor(math.Mod(e, 100000)/div) * div,
+		Northing:  math.Floor(math.Mod(n, 100000)/div) * div,
+		Precision: precision,
+	}, nil
+}
+
+// ParseMGRS parses an MGRS reference such as "49QBB4567815678" or
+// "49Q BB 45678 15678". Spaces are ignored and letters are case-insensitive.
+func ParseMGRS(s string) (MGRS, error) {
+	compact := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	m := mgrsPattern.FindStringSubmatch(compact)
+	if m == nil {
+		return MGRS{}, fmt.Errorf("invalid MGRS reference %q", s)
+	}
+	zone, _ := strconv.Atoi(m[1])
+	if zone < 1 || zone > 60 {
+		return MGRS{}, fmt.Errorf("invalid MGRS zone %d", zone)
+	}
+	digits := m[5]
+	if len(digits)%2 != 0 || len(digits) > 10 {
+		return MGRS{}, fmt.Errorf("MGRS reference %q needs an even number of digits, at most 10", s)
+	}
+	if !strings.ContainsRune(mgrsColumnSets[zone%3], rune(m[3][0])) {
+		return MGRS{}, fmt.Errorf("column letter %s is not used in zone %d", m[3], zone)
+	}
+
+	r := MGRS{Zone: zone, Band: m[2][0], Column: m[3][0], Row: m[4][0], Precision: len(digits) / 2}
+	if r.Precision > 0 {
+		div := math.Pow(10, float64(5-r.Precision))
+		e, _ := strconv.Atoi(digits[:r.Precision])
+		n, _ := strconv.Atoi(digits[r.Precision:])
+		r.Easting, r.Northing = float64(e)*div, float64(n)*div
+	}
+	return r, nil
+}
+
+// UTM returns the UTM coordinate of the reference's south-west corner.
+func (m MGRS) UTM() UTM {
+	col := strings.IndexByte(mgrsColumnSets[m.Zone%3], m.Column)
+	row := strings.IndexByte(mgrsRows, m.Row)
+	if m.Zone%2 == 0 {
+		row = (row + 15) % 20
+	}
+	u := UTM{
+		Zone:     m.Zone,
+		Band:     m.Band,
+		North:    m.Band >= 'N',
+		Easting:  float64(col+1)*100000 + m.Easting,
+		Northing: float64(row)*100000 + m.Northing,
+	}
+
+	// Row letters repeat every 2000 km; pick the cycle that falls inside
+	// the latitude band. The slack covers squares straddling the band's
+	// southern edge and the curvature of parallels away from the central
+	// meridian.
+	const slack = 200000
+	bandSouth := -80 + 8*float64(strings.IndexByte(utmBands, m.Band))
+	minNorthing := ToUTMZone(Point{Lat: bandSouth, Lon: utmCentralMeridian(m.Zone)}, m.Zone).Northing
+	for u.Northing < minNorthing-slack {
+		u.Northing += 2000000
+	}
+	return u
+}
+
+// Point returns the center of the square the reference denotes.
+func (m MGRS) Point() Point {
+	u := m.UTM()
+	half := m.CellSizeM() / 2
+	u.Easting += half
+	u.Northing += half
+	return u.Point()
+}
+
+var s2TilePattern = regexp.MustCompile(`(?:^|_)T?(\d{2}[C-HJ-NP-X][A-HJ-NP-Z][A-HJ-NP-V])(?:_|$)`)
+
+// S2TileFromID extracts the MGRS tile from a Sentinel-2 product or STAC
+// item ID, e.g. "S2A_MSIL2A_20240101T030000_R032_T49QBB_20240101T060000"
+// or "S2B_49QBB_20240101_0_L2A".
+func S2TileFromID(id string) (string, bool) {
+	m := s2TilePattern.FindStringSubmatch(strings.ToUpper(id))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// Sentinel-2 tiles are 109.8 km squares anchored at the north-west corner
+// of their 100 km MGRS square, overlapping their east and south neighbors.
+const s2TileSizeM = 109800
+
+// S2TileFootprint returns the nominal footprint of a Sentinel-2 tile such
+// as "49QBB" or "T49QBB" as a lat/lon polygon. Edges are densified so the
+// polygon follows the UTM grid closely.
+func S2TileFootprint(tile string) (Polygon, error) {
+	m, err := ParseMGRS(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(tile)), "T"))
+	if err != nil || m.Precision != 0 {
+		return nil, fmt.Errorf("invalid Sentinel-2 tile %q", tile)
+	}
+	sw := m.UTM()
+	west, north := sw.Easting, sw.Northing+100000
+	east, south := west+s2TileSizeM, north-s2TileSizeM
+
+	const steps = 8
+	corners := [][2]float64{{west, south}, {east, south}, {east, north}, {west, north}}
+	var ring Ring
+	for i, c := range corners {
+		next := corners[(i+1)%len(corners)]
+		for s := 0; s < steps; s++ {
+			f := float64(s) / steps
+			u := sw
+			u.Easting = c[0] + f*(next[0]-c[0])
+			u.Northing = c[1] + f*(next[1]-c[1])
+			ring = append(ring, u.Point())
+		}
+	}
+	return Polygon{ring}, nil
+}
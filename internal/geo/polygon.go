@@ -0,0 +1,268 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Ring is a closed sequence of vertices. The closing vertex is implied and
+// not repeated.
+type Ring []Point
+
+// Polygon is an outer ring followed by zero or more holes.
+type Polygon []Ring
+
+// MultiPolygon is a set of polygons, e.g. a harbor split by a breakwater.
+type MultiPolygon []Polygon
+
+// newRing drops a repeated closing vertex and rejects degenerate rings.
+func newRing(pts []Point) (Ring, error) {
+	if len(pts) > 1 && pts[0] == pts[len(pts)-1] {
+		pts = pts[:len(pts)-1]
+	}
+	if len(pts) < 3 {
+		return nil, fmt.Errorf("ring has %d distinct vertices, need at least 3", len(pts))
+	}
+	return Ring(pts), nil
+}
+
+// Contains reports whether p lies inside the ring (even-odd rule). Rings
+// that cross the antimeridian are handled by unwrapping longitudes.
+func (r Ring) Contains(p Point) bool {
+	u := r.unwrapped()
+	if len(u) == 0 {
+		return false
+	}
+	p.Lon = u[0].Lon + wrapLon(p.Lon-u[0].Lon)
+	in := false
+	for i, j := 0, len(u)-1; i < len(u); j, i = i, i+1 {
+		a, b := u[i], u[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) {
+			x := a.Lon + (p.Lat-a.Lat)*(b.Lon-a.Lon)/(b.Lat-a.Lat)
+			if p.Lon < x {
+				in = !in
+			}
+		}
+	}
+	return in
+}
+
+// unwrapped returns the ring with longitudes made continuous, so an edge
+// from 179° to -179° becomes 179° to 181°.
+func (r Ring) unwrapped() Ring {
+	if len(r) == 0 {
+		return nil
+	}
+	out := make(Ring, len(r))
+	out[0] = r[0]
+	for i := 1; i < len(r); i++ {
+		out[i] = Point{Lat: r[i].Lat, Lon: out[i-1].Lon + wrapLon(r[i].Lon-r[i-1].Lon)}
+	}
+	return out
+}
+
+// BBox returns the ring's bounding box. For rings crossing the
+// antimeridian West > East.
+func (r Ring) BBox() BBox {
+	b := BBox{West: math.Inf(1), South: math.Inf(1), East: math.Inf(-1), North: math.Inf(-1)}
+	for _, p := range r.unwrapped() {
+		b.West = math.Min(b.West, p.Lon)
+		b.East = math.Max(b.East, p.Lon)
+		b.South = math.Min(b.South, p.Lat)
+		b.North = math.Max(b.North, p.Lat)
+	}
+	return b.Normalize()
+}
+
+// Closed returns the ring with its first vertex repeated at the end, as
+// GeoJSON and WKT require.
+func (r Ring) Closed() []Point {
+	if len(r) == 0 {
+		return nil
+	}
+	return append(append([]Point(nil), r...), r[0])
+}
+
+// Contains reports whether p lies inside the outer ring and outside every hole.
+func (p Polygon) Contains(pt Point) bool {
+	if len(p) == 0 || !p[0].Contains(pt) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.Contains(pt) {
+			return false
+		}
+	}
+	return true
+}
+
+// BBox returns the bounding box of the outer ring.
+func (p Polygon) BBox() BBox {
+	if len(p) == 0 {
+		return BBox{}
+	}
+	return p[0].BBox()
+}
+
+// Contains reports whether pt lies inside any member polygon.
+func (m MultiPolygon) Contains(pt Point) bool {
+	for _, p := range m {
+		if p.Contains(pt) {
+			return true
+		}
+	}
+	return false
+}
+
+// BBox returns the bounding box of all member polygons.
+func (m MultiPolygon) BBox() BBox {
+	if len(m) == 0 {
+		return BBox{}
+	}
+	b := m[0].BBox()
+	for _, p := range m[1:] {
+		b = b.Union(p.BBox())
+	}
+	return b
+}
+
+// ClipToBBox clips every ring of the polygon to b, which must not cross
+// the antimeridian (see MultiPolygon.ClipToBBox). The result is nil if the
+// outer ring lies entirely outside b; holes that vanish are dropped.
+func (p Polygon) ClipToBBox(b BBox) Polygon {
+	if len(p) == 0 {
+		return nil
+	}
+	// Clip in unwrapped longitudes, trying the copies of the polygon one
+	// revolution either side so rings given across ±180° still meet b.
+	u := make(Polygon, len(p))
+	ref := p[0][0].Lon
+	for i, r := range p {
+		u[i] = r.unwrapped()
+		shift := ref + wrapLon(u[i][0].Lon-ref) - u[i][0].Lon
+		for j := range u[i] {
+			u[i][j].Lon += shift
+		}
+	}
+	for _, shift := range []float64{0, -360, 360} {
+		moved := make(Polygon, len(u))
+		for i, r := range u {
+			moved[i] = make(Ring, len(r))
+			for j, pt := range r {
+				moved[i][j] = Point{Lat: pt.Lat, Lon: pt.Lon + shift}
+			}
+		}
+		if c := moved.ClipConvex(b.Ring()); c != nil {
+			for _, r := range c {
+				for j := range r {
+					r[j].Lon = normLon(r[j].Lon)
+				}
+			}
+			return c
+		}
+	}
+	return nil
+}
+
+// ClipConvex clips the polygon against a convex, counter-clockwise clip
+// ring using the Sutherland–Hodgman algorithm.
+func (p Polygon) ClipConvex(clip Ring) Polygon {
+	var out Polygon
+	for i, r := range p {
+		c := clipRing(r, clip)
+		if len(c) < 3 {
+			if i == 0 {
+				return nil
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// ClipToBBox clips each member polygon to b, dropping those that vanish.
+// Boxes crossing the antimeridian are clipped part by part, so a polygon
+// may come back as two pieces.
+func (m MultiPolygon) ClipToBBox(b BBox) MultiPolygon {
+	var out MultiPolygon
+	for _, part := range b.Split() {
+		for _, p := range m {
+			if c := p.ClipToBBox(part); c != nil {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+func clipRing(subject, clip Ring) Ring {
+	// side > 0 means q is left of the directed edge a→b, i.e. inside a CCW clip.
+	side := func(a, b, q Point) float64 {
+		return (b.Lon-a.Lon)*(q.Lat-a.Lat) - (b.Lat-a.Lat)*(q.Lon-a.Lon)
+	}
+	intersect := func(a, b, p, q Point) Point {
+		a1, b1 := b.Lat-a.Lat, a.Lon-b.Lon
+		c1 := a1*a.Lon + b1*a.Lat
+		a2, b2 := q.Lat-p.Lat, p.Lon-q.Lon
+		c2 := a2*p.Lon + b2*p.Lat
+		det := a1*b2 - a2*b1
+		return Point{Lon: (b2*c1 - b1*c2) / det, Lat: (a1*c2 - a2*c1) / det}
+	}
+
+	out := append(Ring(nil), subject...)
+	for i := range clip {
+		a, b := clip[i], clip[(i+1)%len(clip)]
+		in := out
+		out = nil
+		for j := range in {
+			cur, prev := in[j], in[(j+len(in)-1)%len(in)]
+			curIn, prevIn := side(a, b, cur) >= 0, side(a, b, prev) >= 0
+			if curIn {
+				if !prevIn {
+					out = append(out, intersect(a, b, prev, cur))
+				}
+				out = append(out, cur)
+			} else if prevIn {
+				out = append(out, intersect(a, b, prev, cur))
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+	}
+	return out
+}
+
+// LoadAOI reads an area of interest from a GeoJSON or WKT file. The format
+// is chosen by extension (.geojson/.json, .wkt), falling back to sniffing
+// the content.
+func LoadAOI(path string) (MultiPolygon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read AOI: %w", err)
+	}
+
+	var aoi MultiPolygon
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".geojson" || ext == ".json":
+		aoi, err = ParseGeoJSON(data)
+	case ext == ".wkt":
+		aoi, err = ParseWKT(string(data))
+	case strings.HasPrefix(strings.TrimSpace(string(data)), "{"):
+		aoi, err = ParseGeoJSON(data)
+	default:
+		aoi, err = ParseWKT(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse AOI %s: %w", path, err)
+	}
+	if len(aoi) == 0 {
+		return nil, errors.New("AOI contains no polygons")
+	}
+	return aoi, nil
+}
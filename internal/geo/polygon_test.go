@@ -0,0 +1,140 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func square(west, south, size float64) Ring {
+	return Ring{
+		{Lat: south, Lon: west},
+		{Lat: south, Lon: west + size},
+		{Lat: south + size, Lon: west + size},
+		{Lat: south + size, Lon: west},
+	}
+}
+
+func TestPolygonHoles(t *testing.T) {
+	// A harbor with a hole for an island.
+	p := Polygon{square(0, 0, 10), square(4, 4, 2)}
+	for _, c := range []struct {
+		pt Point
+		in bool
+	}{
+		{Point{Lat: 1, Lon: 1}, true},
+		{Point{Lat: 5, Lon: 5}, false},
+		{Point{Lat: 5, Lon: 3.9}, true},
+		{Point{Lat: 11, Lon: 5}, false},
+		{Point{Lat: 5, Lon: -0.1}, false},
+	} {
+		if got := p.Contains(c.pt); got != c.in {
+			t.Errorf("Contains(%v) = %v, want %v", c.pt, got, c.in)
+		}
+	}
+	if (Polygon{}).Contains(Point{}) {
+		t.Error("empty polygon contains a point")
+	}
+}
+
+func TestMultiPolygonContains(t *testing.T) {
+	// Two basins, the second with a hole, and a third polygon inside
+	// that hole.
+	m := MultiPolygon{
+		{square(0, 0, 1)},
+		{square(10, 10, 4), square(11, 11, 2)},
+		{square(11.5, 11.5, 1)},
+	}
+	for _, c := range []struct {
+		pt Point
+		in bool
+	}{
+		{Point{Lat: 0.5, Lon: 0.5}, true},
+		{Point{Lat: 10.5, Lon: 10.5}, true},
+		{Point{Lat: 11.2, Lon: 11.2}, false},
+		{Point{Lat: 12, Lon: 12}, true},
+		{Point{Lat: 5, Lon: 5}, false},
+	} {
+		if got := m.Contains(c.pt); got != c.in {
+			t.Errorf("Contains(%v) = %v, want %v", c.pt, got, c.in)
+		}
+	}
+	if b := m.BBox(); b != (BBox{West: 0, South: 0, East: 14, North: 14}) {
+		t.Errorf("BBox = %+v", b)
+	}
+}
+
+func TestRingAntimeridian(t *testing.T) {
+	r := Ring{{Lat: -19, Lon: 177}, {Lat: -19, Lon: -178}, {Lat: -16, Lon: -178}, {Lat: -16, Lon: 177}}
+	for _, c := range []struct {
+		pt Point
+		in bool
+	}{
+		{Point{Lat: -17, Lon: 179.9}, true},
+		{Point{Lat: -17, Lon: -179.9}, true},
+		{Point{Lat: -17, Lon: 180}, true},
+		{Point{Lat: -17, Lon: 0}, false},
+		{Point{Lat: -17, Lon: -177}, false},
+	} {
+		if got := r.Contains(c.pt); got != c.in {
+			t.Errorf("Contains(%v) = %v, want %v", c.pt, got, c.in)
+		}
+	}
+	if b := r.BBox(); b != fiji {
+		t.Errorf("BBox = %+v, want %+v", b, fiji)
+	}
+
+	// Clipping to a box across the antimeridian returns a piece either side.
+	clipped := MultiPolygon{{r}}.ClipToBBox(BBox{West: 179, South: -20, East: -179, North: -17})
+	if len(clipped) != 2 {
+		t.Fatalf("clipped to %d pieces, want 2", len(clipped))
+	}
+	for _, p := range clipped {
+		b := p.BBox()
+		if b.South != -19 || b.North != -17 || b.WidthDeg() != 1 {
+			t.Errorf("piece %+v", b)
+		}
+	}
+}
+
+func TestClipToBBox(t *testing.T) {
+	p := Polygon{square(0, 0, 10), square(1, 1, 1), square(8, 8, 1)}
+	c := p.ClipToBBox(BBox{West: 5, South: 5, East: 20, North: 20})
+	if len(c) != 2 {
+		t.Fatalf("clipped to %d rings, want the outer ring and the one hole inside", len(c))
+	}
+	if b := c[0].BBox(); b != (BBox{West: 5, South: 5, East: 10, North: 10}) {
+		t.Errorf("outer ring %+v", b)
+	}
+	if c := p.ClipToBBox(BBox{West: 20, South: 20, East: 30, North: 30}); c != nil {
+		t.Errorf("disjoint clip = %v, want nil", c)
+	}
+}
+
+func TestLoadAOI(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"aoi.geojson": `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`,
+		"aoi.wkt":     `POLYGON ((0 0, 1 0, 1 1, 0 1, 0 0))`,
+		"aoi.txt":     ` {"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}}`,
+		"aoi":         `SRID=4326;POLYGON ((0 0, 1 0, 1 1, 0 0))`,
+		"empty.wkt":   `POLYGON EMPTY`,
+		"bad.geojson": `{"type":"Polygon","coordinates":[[[0,0],[1,0]]]}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"aoi.geojson", "aoi.wkt", "aoi.txt", "aoi"} {
+		aoi, err := LoadAOI(filepath.Join(dir, name))
+		if err != nil || len(aoi) != 1 || !aoi.Contains(Point{Lat: 0.2, Lon: 0.8}) {
+			t.Errorf("%s: %v, %v", name, aoi, err)
+		}
+	}
+	for _, name := range []string{"empty.wkt", "bad.geojson", "missing.wkt"} {
+		if _, err := LoadAOI(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,624 @@
+package geo
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	rtreeMaxEntries = 16
+	rtreeMinEntries = 6
+)
+
+// RTree is an in-memory R-tree of bounding boxes keyed by integer IDs.
+// IDs are opaque to the tree; callers typically use indices into their own
+// slices of detections, facilities or AOIs. Stored boxes must not cross
+// the antimeridian (insert the parts returned by BBox.Split instead);
+// query boxes may.
+type RTree struct {
+	root *rtreeNode
+	size int
+}
+
+// RTreeItem is a box stored in an RTree.
+type RTreeItem struct {
+	BBox BBox
+	ID   int
+}
+
+// Neighbor is a result of RTree.Nearest.
+type Neighbor struct {
+	ID     int
+	BBox   BBox
+	DistKm float64
+}
+
+type rtreeNode struct {
+	leaf     bool
+	bbox     BBox
+	items    []RTreeItem  // leaf entries
+	children []*rtreeNode // internal entries
+}
+
+// NewRTree returns an empty tree.
+func NewRTree() *RTree {
+	return &RTree{root: &rtreeNode{leaf: true}}
+}
+
+// BulkLoadRTree builds a packed tree from items using Sort-Tile-Recursive
+// loading, which is much faster than repeated inserts and yields better
+// query performance.
+func BulkLoadRTree(items []RTreeItem) *RTree {
+	if len(items) == 0 {
+		return NewRTree()
+	}
+	leaves := strPack(items, func(it RTreeItem) BBox { return it.BBox })
+	nodes := make([]*rtreeNode, len(leaves))
+	for i, group := range leaves {
+		n := &rtreeNode{leaf: true, items: append([]RTreeItem(nil), group...)}
+		n.recompute()
+		nodes[i] = n
+	}
+	for len(nodes) > 1 {
+		groups := strPack(nodes, func(n *rtreeNode) BBox { return n.bbox })
+		next := make([]*rtreeNode, len(groups))
+		for i, g := range groups {
+			n := &rtreeNode{children: g}
+			n.recompute()
+			next[i] = n
+		}
+		nodes = next
+	}
+	return &RTree{root: nodes[0], size: len(items)}
+}
+
+// strPack partitions entries into groups of at most rtreeMaxEntries,
+// tiling them by center longitude and then latitude.
+func strPack[T any](entries []T, box func(T) BBox) [][]T {
+	entries = append([]T(nil), entries...)
+	cx := func(b BBox) float64 { return b.West + b.East }
+	cy := func(b BBox) float64 { return b.South + b.North }
+
+	leaves := (len(entries) + rtreeMaxEntries - 1) / rtreeMaxEntries
+	slices := int(math.Ceil(math.Sqrt(float64(leaves))))
+	perSlice := slices * rtreeMaxEntries
+
+	sort.Slice(entries, func(i, j int) bool { return cx(box(entries[i])) < cx(box(entries[j])) })
+	var out [][]T
+	for s := 0; s < len(entries); s += perSlice {
+		slice := entries[s:min(s+perSlice, len(entries))]
+		sort.Slice(slice, func(i, j int) bool { return cy(box(slice[i])) < cy(box(slice[j])) })
+		for g := 0; g < len(slice); g += rtreeMaxEntries {
+			out = append(out, slice[g:min(g+rtreeMaxEntries, len(slice))])
+		}
+	}
+	return out
+}
+
+// Len returns the number of items in the tree.
+func (t *RTree) Len() int { return t.size }
+
+// Insert adds a box under id.
+func (t *RTree) Insert(b BBox, id int) {
+	t.size++
+	split := t.root.insert(RTreeItem{BBox: b, ID: id})
+	if split != nil {
+		root := &rtreeNode{children: []*rtreeNode{t.root, split}}
+		root.recompute()
+		t.root = root
+	}
+}
+
+// InsertPoint adds a degenerate box at p under id.
+func (t *RTree) InsertPoint(p Point, id int) {
+	t.Insert(BBox{West: p.Lon, South: p.Lat, East: p.Lon, North: p.Lat}, id)
+}
+
+// Delete removes the item with the given box and id, reporting whether it
+// was found. Underfull nodes are dissolved and their entries reinserted.
+func (t *RTree) Delete(b BBox, id int) bool {
+	var orphans []RTreeItem
+	if !t.root.remove(b, id, &orphans) {
+		return false
+	}
+	t.size--
+	if !t.root.leaf && len(t.root.children) == 1 {
+		t.root = t.root.children[0]
+	}
+	if !t.root.leaf && len(t.root.children) == 0 {
+		t.root = &rtreeNode{leaf: true}
+	}
+	t.size -= len(orphans)
+	for _, it := range orphans {
+		t.Insert(it.BBox, it.ID)
+	}
+	return true
+}
+
+// Search returns the IDs of all items intersecting b.
+func (t *RTree) Search(b BBox) []int {
+	var out []int
+	t.SearchFunc(b, func(it RTreeItem) bool {
+		out = append(out, it.ID)
+		return true
+	})
+	return out
+}
+
+// SearchFunc calls fn for each item intersecting b until fn returns false.
+func (t *RTree) SearchFunc(b BBox, fn func(RTreeItem) bool) {
+	if t.size == 0 {
+		return
+	}
+	for _, part := range b.Split() {
+		if !t.root.search(part, fn) {
+			return
+		}
+	}
+}
+
+// Nearest returns up to k items ordered by great-circle distance from p to
+// their boxes (0 for boxes containing p).
+func (t *RTree) Nearest(p Point, k int) []Neighbor {
+	var out []Neighbor
+	t.NearestFunc(p, func(n Neighbor) bool {
+		out = append(out, n)
+		return len(out) < k
+	})
+	return out
+}
+
+// NearestFunc visits items in increasing distance from p until fn returns
+// false. It is useful for open-ended queries such as "all facilities
+// within 5 km, closest first".
+func (t *RTree) NearestFunc(p Point, fn func(Neighbor) bool) {
+	if t.size == 0 {
+		return
+	}
+	pq := &rtreeQueue{{node: t.root, dist: bboxDistanceKm(p, t.root.bbox)}}
+	for pq.Len() > 0 {
+		e := heap.Pop(pq).(rtreeQueueEntry)
+		switch {
+		case e.node == nil:
+			if !fn(Neighbor{ID: e.item.ID, BBox: e.item.BBox, DistKm: e.dist}) {
+				return
+			}
+		case e.node.leaf:
+			for _, it := range e.node.items {
+				heap.Push(pq, rtreeQueueEntry{item: it, dist: bboxDistanceKm(p, it.BBox)})
+			}
+		default:
+			for _, c := range e.node.children {
+				heap.Push(pq, rtreeQueueEntry{node: c, dist: bboxDistanceKm(p, c.bbox)})
+			}
+		}
+	}
+}
+
+// Items returns all stored items in tree order.
+func (t *RTree) Items() []RTreeItem {
+	var out []RTreeItem
+	var walk func(n *rtreeNode)
+	walk = func(n *rtreeNode) {
+		if n.leaf {
+			out = append(out, n.items...)
+			return
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(t.root)
+	return out
+}
+
+// ── node operations ──────────────────────────────────────────────────────
+
+func (n *rtreeNode) entries() int {
+	if n.leaf {
+		return len(n.items)
+	}
+	return len(n.children)
+}
+
+func (n *rtreeNode) recompute() {
+	first := true
+	extend := func(b BBox) {
+		if first {
+			n.bbox, first = b, false
+			return
+		}
+		n.bbox = boxUnion(n.bbox, b)
+	}
+	for _, it := range n.items {
+		extend(it.BBox)
+	}
+	for _, c := range n.children {
+		extend(c.bbox)
+	}
+}
+
+// insert adds it below n and returns a new sibling if n had to split.
+func (n *rtreeNode) insert(it RTreeItem) *rtreeNode {
+	if n.entries() == 0 {
+		n.bbox = it.BBox
+	} else {
+		n.bbox = boxUnion(n.bbox, it.BBox)
+	}
+
+	if n.leaf {
+		n.items = append(n.items, it)
+	} else {
+		best := n.chooseSubtree(it.BBox)
+		if split := n.children[best].insert(it); split != nil {
+			n.children = append(n.children, split)
+		}
+	}
+	if n.entries() > rtreeMaxEntries {
+		return n.split()
+	}
+	return nil
+}
+
+// chooseSubtree picks the child needing the least enlargement, breaking
+// ties by smaller area.
+func (n *rtreeNode) chooseSubtree(b BBox) int {
+	best, bestEnl, bestArea := 0, math.Inf(1), math.Inf(1)
+	for i, c := range n.children {
+		area := boxArea(c.bbox)
+		enl := boxArea(boxUnion(c.bbox, b)) - area
+		if enl < bestEnl || (enl == bestEnl && area < bestArea) {
+			best, bestEnl, bestArea = i, enl, area
+		}
+	}
+	return best
+}
+
+// split divides an overfull node with Guttman's quadratic split, keeping
+// one half in n and returning the other.
+func (n *rtreeNode) split() *rtreeNode {
+	count := n.entries()
+	boxes := make([]BBox, count)
+	for i := range boxes {
+		if n.leaf {
+			boxes[i] = n.items[i].BBox
+		} else {
+			boxes[i] = n.children[i].bbox
+		}
+	}
+
+	// Pick the pair of seeds that would waste the most area together.
+	s1, s2, worst := 0, 1, math.Inf(-1)
+	for i := 0; i < count; i++ {
+		for j := i + 1; j < count; j++ {
+			d := boxArea(boxUnion(boxes[i], boxes[j])) - boxArea(boxes[i]) - boxArea(boxes[j])
+			if d > worst {
+				s1, s2, worst = i, j, d
+			}
+		}
+	}
+
+	groupA, groupB := []int{s1}, []int{s2}
+	boxA, boxB := boxes[s1], boxes[s2]
+	assigned := make([]bool, count)
+	assigned[s1], assigned[s2] = true, true
+	remaining := count - 2
+
+	for remaining > 0 {
+		// Force assignment if one group needs all remaining entries.
+		if len(groupA)+remaining == rtreeMinEntries || len(groupB)+remaining == rtreeMinEntries {
+			toA := len(groupA)+remaining == rtreeMinEntries
+			for i := 0; i < count; i++ {
+				if !assigned[i] {
+					assigned[i] = true
+					if toA {
+						groupA = append(groupA, i)
+					} else {
+						groupB = append(groupB, i)
+					}
+				}
+			}
+			break
+		}
+
+		// Pick the entry with the strongest preference for one group.
+		next, nextDiff := -1, math.Inf(-1)
+		var dA, dB float64
+		for i := 0; i < count; i++ {
+			if assigned[i] {
+				continue
+			}
+			a := boxArea(boxUnion(boxA, boxes[i])) - boxArea(boxA)
+			b := boxArea(boxUnion(boxB, boxes[i])) - boxArea(boxB)
+			if diff := math.Abs(a - b); diff > nextDiff {
+				next, nextDiff, dA, dB = i, diff, a, b
+			}
+		}
+		assigned[next] = true
+		remaining--
+		if dA < dB || (dA == dB && len(groupA) <= len(groupB)) {
+			groupA = append(groupA, next)
+			boxA = boxUnion(boxA, boxes[next])
+		} else {
+			groupB = append(groupB, next)
+			boxB = boxUnion(boxB, boxes[next])
+		}
+	}
+
+	sibling := &rtreeNode{leaf: n.leaf}
+	if n.leaf {
+		items := n.items
+		n.items = nil
+		for _, i := range groupA {
+			n.items = append(n.items, items[i])
+		}
+		for _, i := range groupB {
+			sibling.items = append(sibling.items, items[i])
+		}
+	} else {
+		children := n.children
+		n.children = nil
+		for _, i := range groupA {
+			n.children = append(n.children, children[i])
+		}
+		for _, i := range groupB {
+			sibling.children = append(sibling.children, children[i])
+		}
+	}
+	n.recompute()
+	sibling.recompute()
+	return sibling
+}
+
+// remove deletes the matching item below n. Entries of nodes that become
+// underfull are appended to orphans for reinsertion.
+func (n *rtreeNode) remove(b BBox, id int, orphans *[]RTreeItem) bool {
+	if !boxIntersects(n.bbox, b) {
+		return false
+	}
+	if n.leaf {
+		for i, it := range n.items {
+			if it.ID == id && it.BBox == b {
+				n.items = append(n.items[:i], n.items[i+1:]...)
+				n.recompute()
+				return true
+			}
+		}
+		return false
+	}
+	for i, c := range n.children {
+		if !c.remove(b, id, orphans) {
+			continue
+		}
+		if c.entries() < rtreeMinEntries {
+			n.children = append(n.children[:i], n.children[i+1:]...)
+			collectItems(c, orphans)
+		}
+		n.recompute()
+		return true
+	}
+	return false
+}
+
+func collectItems(n *rtreeNode, out *[]RTreeItem) {
+	if n.leaf {
+		*out = append(*out, n.items...)
+		return
+	}
+	for _, c := range n.children {
+		collectItems(c, out)
+	}
+}
+
+func (n *rtreeNode) search(b BBox, fn func(RTreeItem) bool) bool {
+	if n.leaf {
+		for _, it := range n.items {
+			if boxIntersects(it.BBox, b) && !fn(it) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, c := range n.children {
+		if boxIntersects(c.bbox, b) && !c.search(b, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// ── nearest-neighbor queue ───────────────────────────────────────────────
+
+type rtreeQueueEntry struct {
+	node *rtreeNode // nil for leaf items
+	item RTreeItem
+	dist float64
+}
+
+type rtreeQueue []rtreeQueueEntry
+
+func (q rtreeQueue) Len() int            { return len(q) }
+func (q rtreeQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q rtreeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *rtreeQueue) Push(x interface{}) { *q = append(*q, x.(rtreeQueueEntry)) }
+func (q *rtreeQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// ── box helpers ──────────────────────────────────────────────────────────
+
+func boxUnion(a, b BBox) BBox {
+	return BBox{
+		West:  math.Min(a.West, b.West),
+		South: math.Min(a.South, b.South),
+		East:  math.Max(a.East, b.East),
+		North: math.Max(a.North, b.North),
+	}
+}
+
+func boxIntersects(a, b BBox) bool {
+	return a.West <= b.East && b.West <= a.East && a.South <= b.North && b.South <= a.North
+}
+
+// boxArea is the planar area in square degrees, used only to steer splits.
+func boxArea(b BBox) float64 {
+	return (b.East - b.West) * (b.North - b.South)
+}
+
+// bboxDistanceKm returns the great-circle distance from p to the nearest
+// point of b, or 0 if p lies inside b.
+func bboxDistanceKm(p Point, b BBox) float64 {
+	inLon := p.Lon >= b.West && p.Lon <= b.East
+	if inLon && p.Lat >= b.South && p.Lat <= b.North {
+		return 0
+	}
+	if inLon {
+		// The closest point of a parallel lies on p's meridian.
+		lat := math.Max(b.South, math.Min(b.North, p.Lat))
+		return Haversine(p, Point{Lat: lat, Lon: p.Lon})
+	}
+	// Outside the longitude range the nearest point lies on the closer
+	// meridian edge, at the foot of the perpendicular from p if that falls
+	// within the edge, otherwise at one of its ends.
+	best := math.Inf(1)
+	for _, lon := range []float64{b.West, b.East} {
+		best = math.Min(best, meridianSegmentDistanceKm(p, lon, b.South, b.North))
+	}
+	return best
+}
+
+func meridianSegmentDistanceKm(p Point, lon, south, north float64) float64 {
+	d := math.Min(Haversine(p, Point{Lat: south, Lon: lon}), Haversine(p, Point{Lat: north, Lon: lon}))
+	dLon := (p.Lon - lon) * DegToRad
+	if c := math.Cos(dLon); c > 0 {
+		foot := math.Atan(math.Tan(p.Lat*DegToRad)/c) * RadToDeg
+		if foot >= south && foot <= north {
+			d = math.Min(d, Haversine(p, Point{Lat: foot, Lon: lon}))
+		}
+	}
+	return d
+}
+
+// ── serialization ────────────────────────────────────────────────────────
+
+var rtreeMagic = [4]byte{'O', 'E', 'R', 'T'}
+
+const rtreeVersion = 1
+
+// WriteTo serializes the tree structure so it can be reloaded without
+// rebuilding. It implements io.WriterTo.
+func (t *RTree) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	binary.Write(cw, binary.LittleEndian, rtreeMagic)
+	binary.Write(cw, binary.LittleEndian, uint16(rtreeVersion))
+	binary.Write(cw, binary.LittleEndian, uint64(t.size))
+	writeNode(cw, t.root)
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.(*bufio.Writer).Flush()
+}
+
+func writeNode(w io.Writer, n *rtreeNode) {
+	var leaf uint8
+	if n.leaf {
+		leaf = 1
+	}
+	binary.Write(w, binary.LittleEndian, leaf)
+	binary.Write(w, binary.LittleEndian, uint32(n.entries()))
+	if n.leaf {
+		for _, it := range n.items {
+			binary.Write(w, binary.LittleEndian, it.BBox)
+			binary.Write(w, binary.LittleEndian, int64(it.ID))
+		}
+		return
+	}
+	for _, c := range n.children {
+		writeNode(w, c)
+	}
+}
+
+// ReadRTree loads a tree written by RTree.WriteTo.
+func ReadRTree(r io.Reader) (*RTree, error) {
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	var version uint16
+	var size uint64
+	if err := binary.Read(br, binary.LittleEndian, &magic); err != nil {
+		return nil, fmt.Errorf("read rtree header: %w", err)
+	}
+	if magic != rtreeMagic {
+		return nil, errors.New("not an rtree file")
+	}
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("read rtree header: %w", err)
+	}
+	if version != rtreeVersion {
+		return nil, fmt.Errorf("unsupported rtree version %d", version)
+	}
+	if err := binary.Read(br, binary.LittleEndian, &size); err != nil {
+		return nil, fmt.Errorf("read rtree header: %w", err)
+	}
+	root, err := readNode(br)
+	if err != nil {
+		return nil, fmt.Errorf("read rtree: %w", err)
+	}
+	return &RTree{root: root, size: int(size)}, nil
+}
+
+func readNode(r io.Reader) (*rtreeNode, error) {
+	var leaf uint8
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &leaf); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	n := &rtreeNode{leaf: leaf == 1}
+	for i := uint32(0); i < count; i++ {
+		if n.leaf {
+			var it struct {
+				BBox BBox
+				ID   int64
+			}
+			if err := binary.Read(r, binary.LittleEndian, &it); err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, RTreeItem{BBox: it.BBox, ID: int(it.ID)})
+		} else {
+			c, err := readNode(r)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, c)
+		}
+	}
+	n.recompute()
+	return n, nil
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
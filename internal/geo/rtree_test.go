@@ -0,0 +1,194 @@
+package geo
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// randomItems returns n small boxes, a third of them points, spread over
+// the globe away from the antimeridian.
+func randomItems(rng *rand.Rand, n int) []RTreeItem {
+	items := make([]RTreeItem, n)
+	for i := range items {
+		lat := rng.Float64()*170 - 85
+		lon := rng.Float64()*358 - 179
+		b := BBox{West: lon, South: lat, East: lon, North: lat}
+		if i%3 != 0 {
+			b.East += rng.Float64() * 0.5
+			b.North = math.Min(b.North+rng.Float64()*0.5, 90)
+		}
+		items[i] = RTreeItem{BBox: b, ID: i}
+	}
+	return items
+}
+
+func randomQuery(rng *rand.Rand) BBox {
+	lat := rng.Float64()*160 - 80
+	lon := rng.Float64()*360 - 180
+	// Some queries cross the antimeridian.
+	b := BBox{West: lon, South: lat, East: lon + rng.Float64()*20, North: lat + rng.Float64()*10}
+	return b.Normalize()
+}
+
+func treesUnderTest(items []RTreeItem) map[string]*RTree {
+	inserted := NewRTree()
+	for _, it := range items {
+		inserted.Insert(it.BBox, it.ID)
+	}
+	return map[string]*RTree{"insert": inserted, "bulk": BulkLoadRTree(items)}
+}
+
+func TestRTreeSearchMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	items := randomItems(rng, 5000)
+	for name, tree := range treesUnderTest(items) {
+		if tree.Len() != len(items) {
+			t.Fatalf("%s: Len = %d, want %d", name, tree.Len(), len(items))
+		}
+		for q := 0; q < 200; q++ {
+			b := randomQuery(rng)
+			var want []int
+			for _, it := range items {
+				if b.Intersects(it.BBox) {
+					want = append(want, it.ID)
+				}
+			}
+			got := tree.Search(b)
+			sort.Ints(got)
+			if !equalInts(got, want) {
+				t.Fatalf("%s: Search(%+v) returned %d items, want %d", name, b, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestRTreeNearestMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	items := randomItems(rng, 5000)
+	const k = 10
+	for name, tree := range treesUnderTest(items) {
+		for q := 0; q < 200; q++ {
+			p := Point{Lat: rng.Float64()*170 - 85, Lon: rng.Float64()*360 - 180}
+			dists := make([]float64, len(items))
+			for i, it := range items {
+				dists[i] = bboxDistanceKm(p, it.BBox)
+			}
+			sort.Float64s(dists)
+
+			got := tree.Nearest(p, k)
+			if len(got) != k {
+				t.Fatalf("%s: Nearest returned %d items, want %d", name, len(got), k)
+			}
+			for i, n := range got {
+				// Compare distances, not IDs, so that ties may come in any order.
+				if math.Abs(n.DistKm-dists[i]) > 1e-9 {
+					t.Fatalf("%s: Nearest(%v)[%d] at %.6f km, want %.6f km", name, p, i, n.DistKm, dists[i])
+				}
+				if d := bboxDistanceKm(p, items[n.ID].BBox); d != n.DistKm {
+					t.Fatalf("%s: neighbor %d reported at %.6f km, is at %.6f km", name, n.ID, n.DistKm, d)
+				}
+			}
+		}
+	}
+}
+
+func TestRTreeDelete(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	items := randomItems(rng, 2000)
+	for name, tree := range treesUnderTest(items) {
+		for _, it := range items[:1000] {
+			if !tree.Delete(it.BBox, it.ID) {
+				t.Fatalf("%s: Delete(%d) = false", name, it.ID)
+			}
+		}
+		if tree.Delete(items[0].BBox, items[0].ID) {
+			t.Fatalf("%s: deleted item %d twice", name, items[0].ID)
+		}
+		got := tree.Search(BBox{West: -180, South: -90, East: 180, North: 90})
+		sort.Ints(got)
+		var want []int
+		for _, it := range items[1000:] {
+			want = append(want, it.ID)
+		}
+		if !equalInts(got, want) {
+			t.Fatalf("%s: %d items left after deletes, want %d", name, len(got), len(want))
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ── benchmarks at 1M points ──────────────────────────────────────────────
+
+const benchPoints = 1_000_000
+
+var (
+	benchOnce  sync.Once
+	benchItems []RTreeItem
+	benchTree  *RTree
+)
+
+func benchData() ([]RTreeItem, *RTree) {
+	benchOnce.Do(func() {
+		rng := rand.New(rand.NewSource(4))
+		benchItems = make([]RTreeItem, benchPoints)
+		for i := range benchItems {
+			p := Point{Lat: rng.Float64()*170 - 85, Lon: rng.Float64()*358 - 179}
+			benchItems[i] = RTreeItem{BBox: BBox{West: p.Lon, South: p.Lat, East: p.Lon, North: p.Lat}, ID: i}
+		}
+		benchTree = BulkLoadRTree(benchItems)
+	})
+	return benchItems, benchTree
+}
+
+func BenchmarkRTreeInsert(b *testing.B) {
+	items, _ := benchData()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tree := NewRTree()
+		for _, it := range items {
+			tree.Insert(it.BBox, it.ID)
+		}
+	}
+}
+
+func BenchmarkRTreeBulkLoad(b *testing.B) {
+	items, _ := benchData()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		BulkLoadRTree(items)
+	}
+}
+
+func BenchmarkRTreeSearch(b *testing.B) {
+	_, tree := benchData()
+	rng := rand.New(rand.NewSource(5))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		// About 1° square, some tens of points.
+		lat, lon := rng.Float64()*160-80, rng.Float64()*358-179
+		tree.Search(BBox{West: lon, South: lat, East: lon + 1, North: lat + 1})
+	}
+}
+
+func BenchmarkRTreeNearest(b *testing.B) {
+	_, tree := benchData()
+	rng := rand.New(rand.NewSource(6))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tree.Nearest(Point{Lat: rng.Float64()*170 - 85, Lon: rng.Float64()*360 - 180}, 10)
+	}
+}
@@ -0,0 +1,151 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SunView is the illumination and viewing geometry of a scene, in degrees.
+type SunView struct {
+	SunAzimuth   float64 // clockwise from north, from the target toward the sun
+	SunElevation float64 // above the horizon
+	// ViewAzimuth is the azimuth from the sensor's nadir point toward the
+	// target: the direction tall objects lean in the image (STAC
+	// view:azimuth).
+	ViewAzimuth float64
+	ViewZenith  float64 // incidence angle of the line of sight at the target
+	HasView     bool    // view angles are known
+}
+
+// ShadowOptions configures the height estimators.
+type ShadowOptions struct {
+	// GSD is the pixel size in meters; each segment endpoint is taken as
+	// uncertain by half a pixel. Defaults to 10.
+	GSD float64
+	// FromTop means the segment starts at the imaged top of the object
+	// rather than at its base, so relief displacement off nadir shifts the
+	// start point.
+	FromTop bool
+	// SunSigmaDeg is the uncertainty of the sun angles; STAC carries
+	// scene means that vary across a tile. Defaults to 0.5.
+	SunSigmaDeg float64
+	// ViewSigmaDeg is the assumed view zenith uncertainty when the view
+	// angles are unknown and FromTop is set. Defaults to 6, about
+	// Sentinel-2's swath-edge incidence.
+	ViewSigmaDeg float64
+}
+
+// HeightEstimate is a structure height derived from its shadow.
+type HeightEstimate struct {
+	HeightM         float64 // estimated height above the shadow-receiving ground
+	SigmaM          float64 // one-sigma uncertainty
+	SegmentM        float64 // length of the measured segment
+	SegmentAzimuth  float64 // direction of the measured segment
+	ExpectedAzimuth float64 // direction a shadow segment should have for this geometry
+	MisfitDeg       float64 // angle between the two; large values suggest a bad measurement
+}
+
+// ShadowHeight estimates the height of a structure from a shadow segment
+// measured on the image, from the object's base (or imaged top, see
+// ShadowOptions.FromTop) to the tip of its shadow on flat ground.
+//
+// On flat ground the shadow runs away from the sun with length
+// h / tan(sun elevation). Measured from the imaged top, the start point is
+// additionally displaced by h * tan(view zenith) along the view azimuth.
+// The height is the least-squares fit of the segment to that direction;
+// the uncertainty combines endpoint precision with the sun (and, if
+// unknown, view) angle uncertainty.
+func ShadowHeight(start, end Point, sv SunView, opts ShadowOptions) (HeightEstimate, error) {
+	g := Inverse(start, end)
+	if g.DistanceM == 0 {
+		return HeightEstimate{}, errors.New("shadow segment has zero length")
+	}
+	az := g.InitialBearing * DegToRad
+	m := [2]float64{g.DistanceM * math.Sin(az), g.DistanceM * math.Cos(az)}
+	return shadowHeight(m, sv, opts)
+}
+
+// ShadowLengthHeight estimates a height from a shadow length alone, e.g.
+// from a detected shadow, assuming it runs straight away from the sun and
+// is measured from the object's base.
+func ShadowLengthHeight(lengthM float64, sv SunView, opts ShadowOptions) (HeightEstimate, error) {
+	if lengthM <= 0 {
+		return HeightEstimate{}, fmt.Errorf("shadow length must be positive, got %g", lengthM)
+	}
+	az := (sv.SunAzimuth + 180) * DegToRad
+	opts.FromTop = false
+	return shadowHeight([2]float64{lengthM * math.Sin(az), lengthM * math.Cos(az)}, sv, opts)
+}
+
+func shadowHeight(m [2]float64, sv SunView, opts ShadowOptions) (HeightEstimate, error) {
+	if sv.SunElevation <= 0 || sv.SunElevation >= 90 {
+		return HeightEstimate{}, fmt.Errorf("sun elevation %.2f° gives no usable shadow", sv.SunElevation)
+	}
+	if opts.GSD <= 0 {
+		opts.GSD = 10
+	}
+	if opts.SunSigmaDeg <= 0 {
+		opts.SunSigmaDeg = 0.5
+	}
+	if opts.ViewSigmaDeg <= 0 {
+		opts.ViewSigmaDeg = 6
+	}
+
+	fit := func(sv SunView) (h float64, k [2]float64) {
+		k = shadowDirection(sv, opts.FromTop)
+		kk := k[0]*k[0] + k[1]*k[1]
+		return (m[0]*k[0] + m[1]*k[1]) / kk, k
+	}
+	h, k := fit(sv)
+	if h <= 0 {
+		return HeightEstimate{}, errors.New("segment points toward the sun; measure from the object to the shadow tip")
+	}
+	kLen := math.Hypot(k[0], k[1])
+	segLen := math.Hypot(m[0], m[1])
+
+	// Endpoint precision: two endpoints, half a pixel each.
+	sigmaM := opts.GSD * 0.5 * math.Sqrt2
+	variance := math.Pow(sigmaM/kLen, 2)
+	// Angle uncertainty by central differences.
+	perturb := func(f func(*SunView, float64)) float64 {
+		lo, hi := sv, sv
+		f(&lo, -opts.SunSigmaDeg)
+		f(&hi, opts.SunSigmaDeg)
+		hLo, _ := fit(lo)
+		hHi, _ := fit(hi)
+		return (hHi - hLo) / 2
+	}
+	variance += math.Pow(perturb(func(s *SunView, d float64) { s.SunElevation += d }), 2)
+	variance += math.Pow(perturb(func(s *SunView, d float64) { s.SunAzimuth += d }), 2)
+	if opts.FromTop && !sv.HasView {
+		// Relief displacement of unknown direction and up to
+		// h*tan(ViewSigmaDeg) in size.
+		variance += math.Pow(h*math.Tan(opts.ViewSigmaDeg*DegToRad)/kLen, 2)
+	}
+
+	cos := (m[0]*k[0] + m[1]*k[1]) / (segLen * kLen)
+	return HeightEstimate{
+		HeightM:         h,
+		SigmaM:          math.Sqrt(variance),
+		SegmentM:        segLen,
+		SegmentAzimuth:  normBearing(math.Atan2(m[0], m[1]) / DegToRad),
+		ExpectedAzimuth: normBearing(math.Atan2(k[0], k[1]) / DegToRad),
+		MisfitDeg:       math.Acos(math.Max(-1, math.Min(1, cos))) / DegToRad,
+	}, nil
+}
+
+// shadowDirection returns the ground offset (east, north) from the
+// segment's start to the shadow tip per meter of height.
+func shadowDirection(sv SunView, fromTop bool) [2]float64 {
+	az := (sv.SunAzimuth + 180) * DegToRad
+	l := 1 / math.Tan(sv.SunElevation*DegToRad)
+	k := [2]float64{l * math.Sin(az), l * math.Cos(az)}
+	if fromTop && sv.HasView {
+		lean := sv.ViewAzimuth * DegToRad
+		d := math.Tan(sv.ViewZenith * DegToRad)
+		k[0] -= d * math.Sin(lean)
+		k[1] -= d * math.Cos(lean)
+	}
+	return k
+}
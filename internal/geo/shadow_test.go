@@ -0,0 +1,125 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// offset returns the point east and north meters from p.
+func offset(p Point, east, north float64) Point {
+	return Destination(p, normBearing(math.Atan2(east, north)/DegToRad), math.Hypot(east, north))
+}
+
+func TestShadowHeight(t *testing.T) {
+	base := Point{Lat: 35.68, Lon: 139.76}
+	const h = 50.0
+	sun := SunView{SunAzimuth: 135, SunElevation: 40}
+	shadow := h / math.Tan(40*DegToRad) // toward 315°
+	tip := Destination(base, 315, shadow)
+	leaning := SunView{SunAzimuth: 135, SunElevation: 40, ViewAzimuth: 90, ViewZenith: 10, HasView: true}
+	top := offset(base, h*math.Tan(10*DegToRad), 0) // the top leans east
+	l := 1 / math.Tan(40*DegToRad)
+	topAz := normBearing(math.Atan2(l*math.Sin(315*DegToRad)-math.Tan(10*DegToRad), l*math.Cos(315*DegToRad)) / DegToRad)
+
+	for _, c := range []struct {
+		name       string
+		start, end Point
+		sv         SunView
+		opts       ShadowOptions
+		wantAz     float64
+	}{
+		{"from base", base, tip, sun, ShadowOptions{}, 315},
+		{"from base, view ignored", base, tip, leaning, ShadowOptions{}, 315},
+		{"from top", top, tip, leaning, ShadowOptions{FromTop: true}, topAz},
+	} {
+		est, err := ShadowHeight(c.start, c.end, c.sv, c.opts)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if math.Abs(est.HeightM-h) > 0.05 || est.MisfitDeg > 0.1 {
+			t.Errorf("%s: height %.3f m, misfit %.3f°, want %.0f m, 0°", c.name, est.HeightM, est.MisfitDeg, h)
+		}
+		if math.Abs(est.ExpectedAzimuth-c.wantAz) > 1e-9 {
+			t.Errorf("%s: expected azimuth %.3f°, want %.3f°", c.name, est.ExpectedAzimuth, c.wantAz)
+		}
+		if math.Abs(est.SegmentAzimuth-est.ExpectedAzimuth) > 0.1 {
+			t.Errorf("%s: segment azimuth %.3f°, expected %.3f°", c.name, est.SegmentAzimuth, est.ExpectedAzimuth)
+		}
+	}
+
+	// A segment off the shadow direction is fitted by its projection and
+	// reported as a misfit.
+	est, err := ShadowHeight(base, Destination(base, 345, shadow), sun, ShadowOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(est.MisfitDeg-30) > 0.01 || math.Abs(est.HeightM-h*math.Cos(30*DegToRad)) > 0.05 {
+		t.Errorf("off direction: height %.3f m, misfit %.3f°", est.HeightM, est.MisfitDeg)
+	}
+}
+
+func TestShadowHeightUncertainty(t *testing.T) {
+	sv := SunView{SunAzimuth: 180, SunElevation: 45}
+	est, err := ShadowLengthHeight(50, sv, ShadowOptions{GSD: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Endpoints: 10 m pixels, half a pixel each. Sun elevation: ±0.5°.
+	// The azimuth uncertainty does not change a length-only fit.
+	sunTerm := 50 * (math.Tan(45.5*DegToRad) - math.Tan(44.5*DegToRad)) / 2
+	want := math.Hypot(10*0.5*math.Sqrt2, sunTerm)
+	if math.Abs(est.HeightM-50) > 1e-9 || math.Abs(est.SigmaM-want) > 1e-9 {
+		t.Errorf("got %.6f ± %.6f m, want 50 ± %.6f m", est.HeightM, est.SigmaM, want)
+	}
+	if est.ExpectedAzimuth != 0 || est.SegmentM != 50 {
+		t.Errorf("azimuth %v, segment %v, want 0, 50", est.ExpectedAzimuth, est.SegmentM)
+	}
+
+	// Measured from the imaged top with no view angles, the unknown relief
+	// displacement widens the uncertainty.
+	base := Point{Lat: 35.68, Lon: 139.76}
+	tip := Destination(base, 0, 50)
+	fromBase, err := ShadowHeight(base, tip, sv, ShadowOptions{GSD: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromTop, err := ShadowHeight(base, tip, sv, ShadowOptions{GSD: 10, FromTop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromTop.HeightM != fromBase.HeightM || fromTop.SigmaM <= fromBase.SigmaM {
+		t.Errorf("from top %.3f ± %.3f m, from base %.3f ± %.3f m", fromTop.HeightM, fromTop.SigmaM, fromBase.HeightM, fromBase.SigmaM)
+	}
+
+	// Lower sun, longer shadow, smaller height per meter measured.
+	low, err := ShadowLengthHeight(50, SunView{SunAzimuth: 180, SunElevation: 20}, ShadowOptions{GSD: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 50 * math.Tan(20*DegToRad); math.Abs(low.HeightM-want) > 1e-9 || low.SigmaM >= est.SigmaM {
+		t.Errorf("low sun: %.3f ± %.3f m, want %.3f m with less uncertainty than %.3f m", low.HeightM, low.SigmaM, want, est.SigmaM)
+	}
+}
+
+func TestShadowHeightErrors(t *testing.T) {
+	p := Point{Lat: 35.68, Lon: 139.76}
+	sv := SunView{SunAzimuth: 180, SunElevation: 45}
+	for _, c := range []struct {
+		name       string
+		start, end Point
+		sv         SunView
+	}{
+		{"zero length", p, p, sv},
+		{"toward the sun", p, Destination(p, 180, 50), sv},
+		{"sun below horizon", p, Destination(p, 0, 50), SunView{SunAzimuth: 180, SunElevation: -2}},
+		{"sun at zenith", p, Destination(p, 0, 50), SunView{SunAzimuth: 180, SunElevation: 90}},
+	} {
+		if est, err := ShadowHeight(c.start, c.end, c.sv, ShadowOptions{}); err == nil {
+			t.Errorf("%s: got %+v, want an error", c.name, est)
+		}
+	}
+	if _, err := ShadowLengthHeight(0, sv, ShadowOptions{}); err == nil {
+		t.Error("zero length: want an error")
+	}
+}
@@ -0,0 +1,216 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	utmK0            = 0.9996
+	utmFalseEasting  = 500000.0
+	utmFalseNorthing = 10000000.0 // southern hemisphere
+)
+
+// utmBands are the MGRS/UTM latitude band letters from 80°S northwards,
+// 8° each except X, which covers 72°N–84°N.
+const utmBands = "CDEFGHJKLMNPQRSTUVWX"
+
+// UTM is a Universal Transverse Mercator coordinate on WGS84.
+type UTM struct {
+	Zone     int     // 1–60
+	Band     byte    // latitude band letter C–X; 0 if unknown
+	North    bool    // hemisphere
+	Easting  float64 // meters
+	Northing float64 // meters
+}
+
+// String formats the coordinate as "49Q 345678 2015678".
+func (u UTM) String() string {
+	band := u.Band
+	if band == 0 {
+		band = 'N'
+		if !u.North {
+			band = 'S'
+		}
+	}
+	return fmt.Sprintf("%d%c %.0f %.0f", u.Zone, band, floorM(u.Easting), floorM(u.Northing))
+}
+
+// EPSG returns the WGS84 / UTM EPSG code (326zz north, 327zz south).
+func (u UTM) EPSG() int {
+	if u.North {
+		return 32600 + u.Zone
+	}
+	return 32700 + u.Zone
+}
+
+// Krüger series coefficients for WGS84 (Karney 2011, to n^6).
+var (
+	utmN     = WGS84F / (2 - WGS84F)
+	utmA     = WGS84A / (1 + utmN) * (1 + utmN*utmN/4 + math.Pow(utmN, 4)/64 + math.Pow(utmN, 6)/256)
+	utmE     = 2 * math.Sqrt(utmN) / (1 + utmN)
+	utmAlpha = krugerAlpha(utmN)
+	utmBeta  = krugerBeta(utmN)
+)
+
+func krugerAlpha(n float64) [6]float64 {
+	n2, n3, n4, n5, n6 := n*n, n*n*n, math.Pow(n, 4), math.Pow(n, 5), math.Pow(n, 6)
+	return [6]float64{
+		n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180 - 127*n5/288 + 7891*n6/37800,
+		13*n2/48 - 3*n3/5 + 557*n4/1440 + 281*n5/630 - 1983433*n6/1935360,
+		61*n3/240 - 103*n4/140 + 15061*n5/26880 + 167603*n6/181440,
+		49561*n4/161280 - 179*n5/168 + 6601661*n6/7257600,
+		34729*n5/80640 - 3418889*n6/1995840,
+		212378941 * n6 / 319334400,
+	}
+}
+
+func krugerBeta(n float64) [6]float64 {
+	n2, n3, n4, n5, n6 := n*n, n*n*n, math.Pow(n, 4), math.Pow(n, 5), math.Pow(n, 6)
+	return [6]float64{
+		n/2 - 2*n2/3 + 37*n3/96 - n4/360 - 81*n5/512 + 96199*n6/604800,
+		n2/48 + n3/15 - 437*n4/1440 + 46*n5/105 - 1118711*n6/3870720,
+		17*n3/480 - 37*n4/840 - 209*n5/4480 + 5569*n6/90720,
+		4397*n4/161280 - 11*n5/504 - 830251*n6/7257600,
+		4583*n5/161280 - 108847*n6/3991680,
+		20648693 * n6 / 638668800,
+	}
+}
+
+// UTMZone returns the UTM zone for a point, honoring the Norway and
+// Svalbard exceptions.
+func UTMZone(p Point) int {
+	lon := wrapLon(p.Lon)
+	zone := int(math.Floor((lon+180)/6)) + 1
+	if zone > 60 {
+		zone = 60
+	}
+	switch {
+	case p.Lat >= 56 && p.Lat < 64 && lon >= 3 && lon < 12:
+		zone = 32
+	case p.Lat >= 72 && p.Lat < 84 && lon >= 0 && lon < 42:
+		switch {
+		case lon < 9:
+			zone = 31
+		case lon < 21:
+			zone = 33
+		case lon < 33:
+			zone = 35
+		default:
+			zone = 37
+		}
+	}
+	return zone
+}
+
+// UTMBand returns the latitude band letter, or 0 outside 80°S–84°N.
+func UTMBand(lat float64) byte {
+	if lat < -80 || lat > 84 {
+		return 0
+	}
+	i := int(math.Floor((lat + 80) / 8))
+	if i > len(utmBands)-1 {
+		i = len(utmBands) - 1
+	}
+	return utmBands[i]
+}
+
+// ToUTM projects p into its standard UTM zone.
+func ToUTM(p Point) (UTM, error) {
+	if p.Lat < -80 || p.Lat > 84 {
+		return UTM{}, fmt.Errorf("latitude %.4f outside UTM coverage (80°S–84°N)", p.Lat)
+	}
+	return ToUTMZone(p, UTMZone(p)), nil
+}
+
+// ToUTMZone projects p into the given zone, which may be a neighbor of its
+// standard zone (e.g. to keep a scene in a single grid).
+func ToUTMZone(p Point, zone int) UTM {
+	lat := p.Lat * DegToRad
+	lon := wrapLon(p.Lon-utmCentralMeridian(zone)) * DegToRad
+
+	sinLat := math.Sin(lat)
+	t := math.Sinh(math.Atanh(sinLat) - utmE*math.Atanh(utmE*sinLat))
+	xi0 := math.Atan2(t, math.Cos(lon))
+	eta0 := math.Atanh(math.Sin(lon) / math.Sqrt(1+t*t))
+
+	xi, eta := xi0, eta0
+	for j, a := range utmAlpha {
+		k := 2 * float64(j+1)
+		xi += a * math.Sin(k*xi0) * math.Cosh(k*eta0)
+		eta += a * math.Cos(k*xi0) * math.Sinh(k*eta0)
+	}
+
+	u := UTM{
+		Zone:     zone,
+		Band:     UTMBand(p.Lat),
+		North:    p.Lat >= 0,
+		Easting:  utmFalseEasting + utmK0*utmA*eta,
+		Northing: utmK0 * utmA * xi,
+	}
+	if !u.North {
+		u.Northing += utmFalseNorthing
+	}
+	return u
+}
+
+// Point converts the UTM coordinate back to latitude/longitude.
+func (u UTM) Point() Point {
+	n := u.Northing
+	if !u.North {
+		n -= utmFalseNorthing
+	}
+	xi := n / (utmK0 * utmA)
+	eta := (u.Easting - utmFalseEasting) / (utmK0 * utmA)
+
+	xi0, eta0 := xi, eta
+	for j, b := range utmBeta {
+		k := 2 * float64(j+1)
+		xi0 -= b * math.Sin(k*xi) * math.Cosh(k*eta)
+		eta0 -= b * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+
+	sinhEta0 := math.Sinh(eta0)
+	cosXi0 := math.Cos(xi0)
+	tauP := math.Sin(xi0) / math.Sqrt(sinhEta0*sinhEta0+cosXi0*cosXi0)
+	lon := math.Atan2(sinhEta0, cosXi0)
+
+	// Solve for tau = tan(lat) from the conformal tau' by Newton iteration.
+	tau := tauP
+	for i := 0; i < 10; i++ {
+		sigma := math.Sinh(utmE * math.Atanh(utmE*tau/math.Sqrt(1+tau*tau)))
+		tauI := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+		delta := (tauP - tauI) / math.Sqrt(1+tauI*tauI) *
+			(1 + (1-wgs84E2)*tau*tau) / ((1 - wgs84E2) * math.Sqrt(1+tau*tau))
+		tau += delta
+		if math.Abs(delta) < 1e-12 {
+			break
+		}
+	}
+
+	return Point{
+		Lat: math.Atan(tau) * RadToDeg,
+		Lon: wrapLon(utmCentralMeridian(u.Zone) + lon*RadToDeg),
+	}
+}
+
+// floorM truncates to whole meters, tolerating round-trip error just below
+// an integer.
+func floorM(v float64) float64 {
+	return math.Floor(v + 1e-6)
+}
+
+func utmCentralMeridian(zone int) float64 {
+	return float64(zone)*6 - 183
+}
+
+// UTMFromEPSG returns the zone and hemisphere of a WGS84 / UTM EPSG code.
+func UTMFromEPSG(epsg int) (zone int, north bool, ok bool) {
+	switch {
+	case epsg > 32600 && epsg <= 32660:
+		return epsg - 32600, true, true
+	case epsg > 32700 && epsg <= 32760:
+		return epsg - 32700, false, true
+	}
+	return 0, false, false
+}
@@ -0,0 +1,201 @@
+package geo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseWKT parses a WKT POLYGON or MULTIPOLYGON, optionally prefixed with
+// an EWKT "SRID=4326;" tag. Coordinates are in lon lat order; Z and M
+// values are ignored.
+func ParseWKT(s string) (MultiPolygon, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToUpper(s), "SRID=") {
+		if i := strings.IndexByte(s, ';'); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	p := &wktParser{s: s}
+
+	tag := strings.ToUpper(p.word())
+	switch dim := strings.ToUpper(p.peekWord()); dim {
+	case "Z", "M", "ZM":
+		p.word()
+	}
+	if p.peekWord() != "" && strings.EqualFold(p.peekWord(), "EMPTY") {
+		return nil, nil
+	}
+
+	var out MultiPolygon
+	switch tag {
+	case "POLYGON":
+		poly, err := p.polygon()
+		if err != nil {
+			return nil, err
+		}
+		out = MultiPolygon{poly}
+	case "MULTIPOLYGON":
+		if err := p.expect('('); err != nil {
+			return nil, err
+		}
+		for {
+			poly, err := p.polygon()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, poly)
+			if !p.accept(',') {
+				break
+			}
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported WKT geometry %q", tag)
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected trailing WKT at offset %d", p.pos)
+	}
+	return out, nil
+}
+
+type wktParser struct {
+	s   string
+	pos int
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *wktParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && unicode.IsLetter(rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *wktParser) peekWord() string {
+	save := p.pos
+	w := p.word()
+	p.pos = save
+	return w
+}
+
+func (p *wktParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *wktParser) expect(c byte) error {
+	if !p.accept(c) {
+		return fmt.Errorf("expected %q at offset %d", c, p.pos)
+	}
+	return nil
+}
+
+func (p *wktParser) number() (float64, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number at offset %d", start)
+	}
+	return v, nil
+}
+
+func (p *wktParser) polygon() (Polygon, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var poly Polygon
+	for {
+		r, err := p.ring()
+		if err != nil {
+			return nil, err
+		}
+		poly = append(poly, r)
+		if !p.accept(',') {
+			break
+		}
+	}
+	return poly, p.expect(')')
+}
+
+func (p *wktParser) ring() (Ring, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var pts []Point
+	for {
+		lon, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		lat, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		// Skip optional Z/M ordinates.
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.s) || p.s[p.pos] == ',' || p.s[p.pos] == ')' {
+				break
+			}
+			if _, err := p.number(); err != nil {
+				return nil, err
+			}
+		}
+		pts = append(pts, Point{Lat: lat, Lon: lon})
+		if !p.accept(',') {
+			break
+		}
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return newRing(pts)
+}
+
+// WKT formats the polygon as a WKT POLYGON.
+func (p Polygon) WKT() string {
+	return "POLYGON " + p.wktBody()
+}
+
+// WKT formats the multipolygon as a WKT MULTIPOLYGON.
+func (m MultiPolygon) WKT() string {
+	parts := make([]string, len(m))
+	for i, p := range m {
+		parts[i] = p.wktBody()
+	}
+	return "MULTIPOLYGON (" + strings.Join(parts, ", ") + ")"
+}
+
+func (p Polygon) wktBody() string {
+	rings := make([]string, len(p))
+	for i, r := range p {
+		pts := r.Closed()
+		coords := make([]string, len(pts))
+		for j, pt := range pts {
+			coords[j] = strconv.FormatFloat(pt.Lon, 'f', -1, 64) + " " + strconv.FormatFloat(pt.Lat, 'f', -1, 64)
+		}
+		rings[i] = "(" + strings.Join(coords, ", ") + ")"
+	}
+	return "(" + strings.Join(rings, ", ") + ")"
+}
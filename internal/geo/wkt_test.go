@@ -0,0 +1,63 @@
+package geo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWKT(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want MultiPolygon
+	}{
+		{"POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (4 4, 6 4, 6 6, 4 4))",
+			MultiPolygon{{square(0, 0, 10), {{Lat: 4, Lon: 4}, {Lat: 4, Lon: 6}, {Lat: 6, Lon: 6}}}}},
+		{"srid=4326; polygon z ((0 0 1, 1 0 1, 1 1 1, 0 0 1))",
+			MultiPolygon{{{{Lat: 0, Lon: 0}, {Lat: 0, Lon: 1}, {Lat: 1, Lon: 1}}}}},
+		{"MULTIPOLYGON (((0 0, 1 0, 1 1)), ((-1.5e1 2, 3 4, 5 -6, -15 2)))",
+			MultiPolygon{
+				{{{Lat: 0, Lon: 0}, {Lat: 0, Lon: 1}, {Lat: 1, Lon: 1}}},
+				{{{Lat: 2, Lon: -15}, {Lat: 4, Lon: 3}, {Lat: -6, Lon: 5}}},
+			}},
+		{"MULTIPOLYGON EMPTY", nil},
+	} {
+		got, err := ParseWKT(c.in)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseWKT(%q) = %v, %v, want %v", c.in, got, err, c.want)
+		}
+	}
+}
+
+func TestWKTRoundTrip(t *testing.T) {
+	m := MultiPolygon{
+		{square(0.5, -10.25, 2), square(1, -10, 0.5)},
+		{{{Lat: -16.5, Lon: 179.5}, {Lat: -16.5, Lon: -179.5}, {Lat: -17, Lon: 180}}},
+	}
+	got, err := ParseWKT(m.WKT())
+	if err != nil || !reflect.DeepEqual(got, m) {
+		t.Errorf("%s parses as %v, %v", m.WKT(), got, err)
+	}
+	if s := m[1].WKT(); s != "POLYGON ((179.5 -16.5, -179.5 -16.5, 180 -17, 179.5 -16.5))" {
+		t.Errorf("Polygon.WKT = %s", s)
+	}
+}
+
+func TestParseWKTMalformed(t *testing.T) {
+	for _, c := range []struct{ in, msg string }{
+		{"POINT (1 2)", "unsupported"},
+		{"LINESTRING (0 0, 1 1)", "unsupported"},
+		{"POLYGON (0 0, 1 0, 1 1, 0 0)", "expected '('"},
+		{"POLYGON ((0 0, 1 0, 1 1, 0 0)", "expected ')'"},
+		{"POLYGON ((0 0, 1 x, 1 1, 0 0))", "invalid number"},
+		{"POLYGON ((0 0, 1 0, 0 0))", "distinct vertices"},
+		{"POLYGON ((0 0, 1 0, 1 1, 0 0)) junk", "trailing"},
+		{"MULTIPOLYGON ((0 0, 1 0, 1 1, 0 0))", "expected '('"},
+		{"", "unsupported"},
+	} {
+		_, err := ParseWKT(c.in)
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("ParseWKT(%q): error %v, want one mentioning %q", c.in, err, c.msg)
+		}
+	}
+}
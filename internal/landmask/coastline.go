@@ -0,0 +1,149 @@
+package landmask
+
+import (
+	"errors"
+	"math"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/raster"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// footprintSamples is the number of sample points per axis across a
+// detection's footprint.
+const footprintSamples = 5
+
+const metersPerDegreeLat = 111320.0
+
+// CoastlineMask classifies water as anything outside a set of land
+// polygons, such as OSM land polygons or Natural Earth ne_10m_land
+// exported to GeoJSON or WKT. It needs georeferenced detections.
+type CoastlineMask struct {
+	GSD float64 // meters per detection pixel, used when a detection has no size estimate
+
+	land  geo.MultiPolygon
+	index *geo.RTree
+}
+
+// LoadCoastline reads land polygons from a GeoJSON or WKT file.
+func LoadCoastline(path string, gsd float64) (*CoastlineMask, error) {
+	land, err := geo.LoadAOI(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCoastlineMask(land, gsd), nil
+}
+
+// NewCoastlineMask indexes the land polygons for point lookups.
+func NewCoastlineMask(land geo.MultiPolygon, gsd float64) *CoastlineMask {
+	var items []geo.RTreeItem
+	for i, p := range land {
+		for _, part := range p.BBox().Split() {
+			items = append(items, geo.RTreeItem{BBox: part, ID: i})
+		}
+	}
+	return &CoastlineMask{GSD: gsd, land: land, index: geo.BulkLoadRTree(items)}
+}
+
+// WaterFraction samples a grid of points across the detection's footprint,
+// centered on its geo center, and returns the share outside all land
+// polygons.
+func (m *CoastlineMask) WaterFraction(d *pb.Detection) (float64, bool) {
+	c := d.GeoCenter
+	if c == nil || (c.Latitude == 0 && c.Longitude == 0) {
+		return 0, false
+	}
+	center := geo.Point{Lat: c.Latitude, Lon: c.Longitude}
+
+	// The worker estimates length along x and width along y.
+	eastM, northM := float64(d.EstimatedLengthM), float64(d.EstimatedWidthM)
+	if (eastM <= 0 || northM <= 0) && d.Bbox != nil && m.GSD > 0 {
+		eastM = float64(d.Bbox.XMax-d.Bbox.XMin) * m.GSD
+		northM = float64(d.Bbox.YMax-d.Bbox.YMin) * m.GSD
+	}
+	dLat := northM / metersPerDegreeLat
+	dLon := eastM / (metersPerDegreeLat * math.Max(math.Cos(center.Lat*geo.DegToRad), 0.01))
+
+	n := footprintSamples
+	if dLat <= 0 || dLon <= 0 {
+		n = 1 // no size known: judge the center alone
+	}
+	var water int
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			p := center
+			if n > 1 {
+				p.Lat += (float64(i)/float64(n-1) - 0.5) * dLat
+				p.Lon += (float64(j)/float64(n-1) - 0.5) * dLon
+			}
+			if !m.onLand(p) {
+				water++
+			}
+		}
+	}
+	return float64(water) / float64(n*n), true
+}
+
+func (m *CoastlineMask) onLand(p geo.Point) bool {
+	land := false
+	m.index.SearchFunc(geo.BBox{West: p.Lon, South: p.Lat, East: p.Lon, North: p.Lat}.Normalize(), func(it geo.RTreeItem) bool {
+		land = m.land[it.ID].Contains(p)
+		return !land
+	})
+	return land
+}
+
+// rasterBlock is the side in pixels of the blocks Rasterize skips at once
+// when no land polygon is near them.
+const rasterBlock = 32
+
+// Rasterize returns a land mask on a raster grid (row-major, true = land),
+// judged at pixel centers and grown by bufferPx pixels so that bright
+// shoreline structures next to land are masked too.
+func (m *CoastlineMask) Rasterize(g raster.GeoTransform, epsg, width, height, bufferPx int) ([]bool, error) {
+	if !g.Valid() {
+		return nil, errors.New("raster is not georeferenced")
+	}
+	land := make([]bool, width*height)
+	for by := 0; by < height; by += rasterBlock {
+		for bx := 0; bx < width; bx += rasterBlock {
+			x1, y1 := min(bx+rasterBlock, width), min(by+rasterBlock, height)
+			var b geo.BBox
+			for i, c := range [][2]int{{bx, by}, {x1, by}, {bx, y1}, {x1, y1}} {
+				p, err := raster.PixelToPoint(g, epsg, float64(c[0]), float64(c[1]))
+				if err != nil {
+					return nil, err
+				}
+				pb := geo.BBox{West: p.Lon, South: p.Lat, East: p.Lon, North: p.Lat}
+				if i == 0 {
+					b = pb
+				} else {
+					b = b.Union(pb)
+				}
+			}
+			near := false
+			for _, part := range b.Split() {
+				m.index.SearchFunc(part, func(geo.RTreeItem) bool {
+					near = true
+					return false
+				})
+			}
+			if !near {
+				continue
+			}
+			for y := by; y < y1; y++ {
+				for x := bx; x < x1; x++ {
+					p, err := raster.PixelToPoint(g, epsg, float64(x)+0.5, float64(y)+0.5)
+					if err != nil {
+						return nil, err
+					}
+					land[y*width+x] = m.onLand(p)
+				}
+			}
+		}
+	}
+	if bufferPx > 0 {
+		land = raster.Dilate(land, width, height, bufferPx)
+	}
+	return land, nil
+}
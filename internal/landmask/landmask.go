@@ -0,0 +1,119 @@
+// Package landmask suppresses vessel detections that fall on land.
+//
+// Generic detectors happily report boats in parking lots and ships on
+// piers. A Mask estimates how much of a detection's footprint is water,
+// either from the scene itself (NDWI) or from land polygons on disk, and
+// Apply drops or flags vessel-class detections that are mostly on land.
+package landmask
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// Attribute keys set on evaluated detections.
+const (
+	AttrWaterFraction = "water_fraction"
+	AttrOnLand        = "on_land"
+)
+
+// Mask estimates water coverage under detections.
+type Mask interface {
+	// WaterFraction returns the fraction of the detection's footprint that
+	// is water, in [0, 1]. ok is false if the mask cannot judge the
+	// detection, e.g. it lies outside the mask or has no geo center.
+	WaterFraction(d *pb.Detection) (frac float64, ok bool)
+}
+
+// Action is what Apply does with a vessel detection found on land.
+type Action string
+
+const (
+	ActionDrop Action = "drop" // remove the detection
+	ActionFlag Action = "flag" // keep it and set the on_land attribute
+)
+
+// ParseAction validates an action name; the empty string means drop.
+func ParseAction(s string) (Action, error) {
+	switch a := Action(strings.ToLower(s)); a {
+	case "":
+		return ActionDrop, nil
+	case ActionDrop, ActionFlag:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown land action %q (want drop or flag)", s)
+}
+
+// DefaultVesselClasses are the class-name substrings treated as vessels.
+var DefaultVesselClasses = []string{"boat", "ship", "vessel"}
+
+// Options configures Apply.
+type Options struct {
+	Action   Action
+	Classes  []string // class-name substrings to check; defaults to DefaultVesselClasses
+	MinWater float64  // minimum water fraction to count as afloat; defaults to 0.5
+}
+
+// Stats summarizes an Apply run.
+type Stats struct {
+	Checked     int // vessel detections the mask could judge
+	OnLand      int // of those, found mostly on land
+	Unevaluated int // vessel detections the mask could not judge
+}
+
+// Apply evaluates every vessel-class detection against the mask and
+// returns the detections to keep. Other classes, and vessels the mask
+// cannot judge, pass through unchanged.
+func Apply(dets []*pb.Detection, mask Mask, opts Options) ([]*pb.Detection, Stats) {
+	if opts.Action == "" {
+		opts.Action = ActionDrop
+	}
+	if len(opts.Classes) == 0 {
+		opts.Classes = DefaultVesselClasses
+	}
+	if opts.MinWater <= 0 {
+		opts.MinWater = 0.5
+	}
+
+	var st Stats
+	kept := dets[:0]
+	for _, d := range dets {
+		if !isVessel(d.ClassName, opts.Classes) {
+			kept = append(kept, d)
+			continue
+		}
+		frac, ok := mask.WaterFraction(d)
+		if !ok {
+			st.Unevaluated++
+			kept = append(kept, d)
+			continue
+		}
+		st.Checked++
+		onLand := frac < opts.MinWater
+		if onLand {
+			st.OnLand++
+			if opts.Action == ActionDrop {
+				continue
+			}
+		}
+		if d.Attributes == nil {
+			d.Attributes = make(map[string]string)
+		}
+		d.Attributes[AttrWaterFraction] = fmt.Sprintf("%.2f", frac)
+		d.Attributes[AttrOnLand] = fmt.Sprintf("%t", onLand)
+		kept = append(kept, d)
+	}
+	return kept, st
+}
+
+func isVessel(class string, classes []string) bool {
+	class = strings.ToLower(class)
+	for _, c := range classes {
+		if strings.Contains(class, strings.ToLower(c)) {
+			return true
+		}
+	}
+	return false
+}
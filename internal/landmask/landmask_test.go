@@ -0,0 +1,223 @@
+package landmask
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/raster"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// island is a one-degree square of land, away from (0, 0) which the mask
+// treats as "no position".
+var island = geo.MultiPolygon{{geo.Ring{
+	{Lat: 10, Lon: 10}, {Lat: 10, Lon: 11}, {Lat: 11, Lon: 11}, {Lat: 11, Lon: 10},
+}}}
+
+func vesselAt(class string, lat, lon float64, lengthM, widthM float32) *pb.Detection {
+	return &pb.Detection{
+		ClassName:        class,
+		GeoCenter:        &pb.GeoPoint{Latitude: lat, Longitude: lon},
+		EstimatedLengthM: lengthM,
+		EstimatedWidthM:  widthM,
+	}
+}
+
+// shoreLon places a 1 km long footprint across the island's east coast so
+// that two of its five sample columns fall on land.
+func shoreLon(lat float64) float64 {
+	return 11 + 0.1*1000/(metersPerDegreeLat*math.Cos(lat*geo.DegToRad))
+}
+
+func TestCoastlineWaterFraction(t *testing.T) {
+	m := NewCoastlineMask(island, 10)
+	for _, c := range []struct {
+		name string
+		d    *pb.Detection
+		want float64
+		ok   bool
+	}{
+		{"inland", vesselAt("ship", 10.5, 10.5, 100, 20), 0, true},
+		{"offshore", vesselAt("ship", 10.5, 11.5, 100, 20), 1, true},
+		{"straddling", vesselAt("ship", 10.5, shoreLon(10.5), 1000, 20), 0.6, true},
+		{"center only", vesselAt("ship", 10.5, 10.999, 0, 0), 0, true},
+		{"size from bbox", &pb.Detection{
+			GeoCenter: &pb.GeoPoint{Latitude: 10.5, Longitude: shoreLon(10.5)},
+			Bbox:      &pb.BoundingBox{XMin: 100, XMax: 200, YMin: 0, YMax: 2},
+		}, 0.6, true},
+		{"no position", &pb.Detection{ClassName: "ship"}, 0, false},
+		{"null island", vesselAt("ship", 0, 0, 100, 20), 0, false},
+	} {
+		got, ok := m.WaterFraction(c.d)
+		if ok != c.ok || math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s: got %v, %t, want %v, %t", c.name, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	m := NewCoastlineMask(island, 10)
+	newDets := func() []*pb.Detection {
+		return []*pb.Detection{
+			vesselAt("ship", 10.5, 10.5, 100, 20),              // on land
+			vesselAt("Small Boat", 10.5, 11.5, 20, 5),          // afloat
+			vesselAt("vessel", 10.5, shoreLon(10.5), 1000, 20), // 60% water
+			vesselAt("storage-tank", 10.5, 10.5, 30, 30),       // not a vessel
+			{ClassName: "ship"},                                // no position
+		}
+	}
+
+	kept, st := Apply(newDets(), m, Options{})
+	if want := (Stats{Checked: 3, OnLand: 1, Unevaluated: 1}); st != want {
+		t.Errorf("drop: stats %+v, want %+v", st, want)
+	}
+	var classes []string
+	for _, d := range kept {
+		classes = append(classes, d.ClassName)
+	}
+	if len(kept) != 4 || kept[0].ClassName != "Small Boat" || kept[1].ClassName != "vessel" {
+		t.Errorf("drop: kept %v", classes)
+	}
+	if a := kept[1].Attributes; a[AttrWaterFraction] != "0.60" || a[AttrOnLand] != "false" {
+		t.Errorf("drop: shoreline attributes %v", a)
+	}
+	if kept[2].Attributes != nil || kept[3].Attributes != nil {
+		t.Errorf("drop: non-vessel or unplaced detection labeled: %v, %v", kept[2].Attributes, kept[3].Attributes)
+	}
+
+	kept, st = Apply(newDets(), m, Options{Action: ActionFlag, MinWater: 0.7})
+	if want := (Stats{Checked: 3, OnLand: 2, Unevaluated: 1}); st != want {
+		t.Errorf("flag: stats %+v, want %+v", st, want)
+	}
+	if len(kept) != 5 {
+		t.Fatalf("flag: kept %d detections, want all 5", len(kept))
+	}
+	for i, want := range []string{"true", "false", "true"} {
+		if got := kept[i].Attributes[AttrOnLand]; got != want {
+			t.Errorf("flag: %s on_land = %q, want %q", kept[i].ClassName, got, want)
+		}
+	}
+	if got := kept[0].Attributes[AttrWaterFraction]; got != "0.00" {
+		t.Errorf("flag: inland water_fraction = %q", got)
+	}
+
+	kept, st = Apply(newDets(), m, Options{Classes: []string{"tank"}})
+	if st != (Stats{Checked: 1, OnLand: 1}) || len(kept) != 4 {
+		t.Errorf("custom classes: stats %+v, kept %d", st, len(kept))
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want Action
+		ok   bool
+	}{
+		{"drop", ActionDrop, true},
+		{"FLAG", ActionFlag, true},
+		{"", ActionDrop, true},
+		{"keep", "", false},
+	} {
+		got, err := ParseAction(c.in)
+		if (err == nil) != c.ok || (c.ok && got != c.want) {
+			t.Errorf("%q: got %q, %v", c.in, got, err)
+		}
+	}
+}
+
+func TestRasterize(t *testing.T) {
+	m := NewCoastlineMask(island, 10)
+	// Quarter-degree pixels over 9.5..12.5 E, 9.5..12.5 N: pixel centers
+	// 2..5 in each axis fall on the island.
+	g := raster.GeoTransform{OriginX: 9.5, OriginY: 12.5, PixelWidth: 0.25, PixelHeight: 0.25}
+	for _, c := range []struct {
+		buffer, lo, hi int
+	}{{0, 2, 5}, {1, 1, 6}} {
+		land, err := m.Rasterize(g, raster.EPSGWGS84, 12, 12, c.buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := 0; y < 12; y++ {
+			for x := 0; x < 12; x++ {
+				want := x >= c.lo && x <= c.hi && y >= c.lo+4 && y <= c.hi+4
+				if land[y*12+x] != want {
+					t.Errorf("buffer %d: pixel (%d, %d) land = %t, want %t", c.buffer, x, y, land[y*12+x], want)
+				}
+			}
+		}
+	}
+	if _, err := m.Rasterize(raster.GeoTransform{}, raster.EPSGWGS84, 12, 12, 0); err == nil {
+		t.Error("ungeoreferenced grid: want an error")
+	}
+}
+
+// writeBand writes a single-band 20x10 GeoTIFF whose left half holds left
+// and right half holds right; the bottom row is zero, i.e. no data.
+func writeBand(t *testing.T, path string, left, right float32) {
+	t.Helper()
+	r := raster.New(20, 10, 1)
+	r.HasNoData = true
+	for y := 0; y < 9; y++ {
+		for x := 0; x < 20; x++ {
+			v := left
+			if x >= 10 {
+				v = right
+			}
+			r.Bands[0][y*20+x] = v
+		}
+	}
+	if err := raster.WriteGeoTIFFFile(path, r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNDWIWaterFraction(t *testing.T) {
+	dir := t.TempDir()
+	green, nir := filepath.Join(dir, "B03.tif"), filepath.Join(dir, "B08.tif")
+	writeBand(t, green, 0.3, 0.1) // water on the left, land on the right
+	writeBand(t, nir, 0.05, 0.4)
+
+	// The detector ran on a 40x20 image of the same extent.
+	m, err := OpenNDWI(green, nir, 40, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	box := func(x0, y0, x1, y1 float32) *pb.Detection {
+		return &pb.Detection{ClassName: "ship", Bbox: &pb.BoundingBox{XMin: x0, YMin: y0, XMax: x1, YMax: y1}}
+	}
+	for _, c := range []struct {
+		name string
+		d    *pb.Detection
+		want float64
+		ok   bool
+	}{
+		{"water", box(0, 0, 10, 10), 1, true},
+		{"land", box(30, 0, 40, 10), 0, true},
+		{"straddling", box(10, 0, 30, 10), 0.5, true},
+		{"no data", box(0, 18, 40, 20), 0, false},
+		{"outside", box(50, 0, 60, 10), 0, false},
+		{"no bbox", &pb.Detection{ClassName: "ship"}, 0, false},
+	} {
+		got, ok := m.WaterFraction(c.d)
+		if ok != c.ok || math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s: got %v, %t, want %v, %t", c.name, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestOpenNDWISizeMismatch(t *testing.T) {
+	dir := t.TempDir()
+	green, nir := filepath.Join(dir, "B03.tif"), filepath.Join(dir, "B08.tif")
+	writeBand(t, green, 0.3, 0.1)
+	if err := raster.WriteGeoTIFFFile(nir, raster.New(10, 10, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := OpenNDWI(green, nir, 0, 0); err == nil {
+		m.Close()
+		t.Error("bands of different sizes: want an error")
+	}
+}
@@ -0,0 +1,104 @@
+package landmask
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/clearclown/orbital-eye/internal/raster"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// maxSamplesPerAxis bounds how many band pixels are read per detection;
+// larger footprints are decimated.
+const maxSamplesPerAxis = 64
+
+// NDWIMask classifies water from the scene's own green and near-infrared
+// bands (Sentinel-2 B03 and B08) with the Normalized Difference Water
+// Index, NDWI = (green - nir) / (green + nir). Band pixels are read lazily
+// for each detection's bounding box.
+type NDWIMask struct {
+	Threshold float32 // NDWI above which a pixel is water; 0 by default
+
+	green, nir     *raster.GeoTIFF
+	scaleX, scaleY float64 // detection pixels to band pixels
+}
+
+// OpenNDWI opens the green and NIR band files. imageWidth and imageHeight
+// are the dimensions of the image the detector ran on, which must cover
+// the same extent as the bands; pass 0 if they share the bands' grid.
+func OpenNDWI(greenPath, nirPath string, imageWidth, imageHeight int) (*NDWIMask, error) {
+	green, err := raster.Open(greenPath)
+	if err != nil {
+		return nil, fmt.Errorf("open green band: %w", err)
+	}
+	nir, err := raster.Open(nirPath)
+	if err != nil {
+		green.Close()
+		return nil, fmt.Errorf("open NIR band: %w", err)
+	}
+	if green.Width != nir.Width || green.Height != nir.Height {
+		green.Close()
+		nir.Close()
+		return nil, fmt.Errorf("green band is %dx%d but NIR band is %dx%d",
+			green.Width, green.Height, nir.Width, nir.Height)
+	}
+
+	m := &NDWIMask{green: green, nir: nir, scaleX: 1, scaleY: 1}
+	if imageWidth > 0 && imageHeight > 0 {
+		m.scaleX = float64(green.Width) / float64(imageWidth)
+		m.scaleY = float64(green.Height) / float64(imageHeight)
+	}
+	return m, nil
+}
+
+// Close releases the band files.
+func (m *NDWIMask) Close() error {
+	err := m.green.Close()
+	if nerr := m.nir.Close(); err == nil {
+		err = nerr
+	}
+	return err
+}
+
+// WaterFraction returns the share of valid pixels under the detection's
+// bounding box whose NDWI exceeds the threshold.
+func (m *NDWIMask) WaterFraction(d *pb.Detection) (float64, bool) {
+	if d.Bbox == nil {
+		return 0, false
+	}
+	x0 := int(math.Floor(float64(d.Bbox.XMin) * m.scaleX))
+	y0 := int(math.Floor(float64(d.Bbox.YMin) * m.scaleY))
+	x1 := int(math.Ceil(float64(d.Bbox.XMax) * m.scaleX))
+	y1 := int(math.Ceil(float64(d.Bbox.YMax) * m.scaleY))
+	win := raster.Window{X: x0, Y: y0, Width: max(x1-x0, 1), Height: max(y1-y0, 1)}.
+		Intersect(m.green.Width, m.green.Height)
+	if win.Empty() {
+		return 0, false
+	}
+
+	step := max(1, (max(win.Width, win.Height)+maxSamplesPerAxis-1)/maxSamplesPerAxis)
+	g, err := m.green.Read(win, step)
+	if err != nil {
+		return 0, false
+	}
+	n, err := m.nir.Read(win, step)
+	if err != nil {
+		return 0, false
+	}
+
+	var valid, water int
+	for i := range g.Bands[0] {
+		gv, nv := g.Bands[0][i], n.Bands[0][i]
+		if g.IsNoData(gv) || n.IsNoData(nv) || gv+nv <= 0 {
+			continue
+		}
+		valid++
+		if (gv-nv)/(gv+nv) > m.Threshold {
+			water++
+		}
+	}
+	if valid == 0 {
+		return 0, false
+	}
+	return float64(water) / float64(valid), true
+}
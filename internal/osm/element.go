@@ -0,0 +1,76 @@
+// Package osm reads OpenStreetMap extracts (.osm XML and .osm.pbf) and
+// imports military, aeroway and port features as known facilities.
+package osm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Kind is an OSM element type. Kinds combine as a bit set to select what
+// Scan decodes.
+type Kind uint8
+
+const (
+	KindNode Kind = 1 << iota
+	KindWay
+	KindRelation
+
+	KindAll = KindNode | KindWay | KindRelation
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNode:
+		return "node"
+	case KindWay:
+		return "way"
+	case KindRelation:
+		return "relation"
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(k))
+}
+
+// parseKind maps an XML member type to a Kind.
+func parseKind(s string) Kind {
+	switch s {
+	case "node":
+		return KindNode
+	case "way":
+		return KindWay
+	case "relation":
+		return KindRelation
+	}
+	return 0
+}
+
+// Member is a relation member.
+type Member struct {
+	Kind Kind
+	Ref  int64
+	Role string
+}
+
+// Element is a node, way or relation. Only the fields of its kind are set.
+type Element struct {
+	Kind      Kind
+	ID        int64
+	Lat, Lon  float64  // nodes
+	Refs      []int64  // ways: node IDs in order
+	Members   []Member // relations
+	Tags      map[string]string
+	Version   int
+	Timestamp time.Time
+}
+
+// Scan decodes the elements of the given kinds from an extract and calls
+// fn for each; an error from fn stops the scan. Files ending in .pbf are
+// read as OSM PBF, anything else as OSM XML.
+func Scan(path string, kinds Kind, fn func(*Element) error) error {
+	if strings.EqualFold(filepath.Ext(path), ".pbf") {
+		return scanPBF(path, kinds, fn)
+	}
+	return scanXML(path, kinds, fn)
+}
@@ -0,0 +1,411 @@
+package osm
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/facilities"
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+// Provenance values recorded on imported facilities.
+const (
+	Source  = "openstreetmap"
+	License = "ODbL-1.0"
+)
+
+// DefaultAeroways are the aeroway values imported by default. Values such
+// as apron, hangar or taxiway describe parts of an airfield and would
+// swamp the database with thousands of small entries.
+var DefaultAeroways = []string{"aerodrome", "airstrip", "heliport"}
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// BBox, if set, keeps only facilities whose extent intersects it.
+	BBox *geo.BBox
+	// Aeroways lists the aeroway values to import; defaults to
+	// DefaultAeroways. "*" imports every value.
+	Aeroways []string
+	// Nodes also imports matching nodes as point facilities. Most sites
+	// mapped only as a node lack an outline, so this is off by default.
+	Nodes bool
+}
+
+// ImportStats summarizes an import.
+type ImportStats struct {
+	Ways, Relations, Nodes int // matching elements imported
+	Incomplete             int // areas dropped for missing nodes or open rings
+	OutsideBBox            int
+}
+
+// Import scans an extract for military=*, aeroway=* and landuse=port
+// features and converts them to facilities. Areas come from closed ways
+// and multipolygon relations; the extract is read three times (relations,
+// ways, nodes) so only the nodes that outline matching features are held
+// in memory.
+func Import(path string, opts ImportOptions) ([]*facilities.Facility, ImportStats, error) {
+	var stats ImportStats
+	aeroways := make(map[string]bool)
+	if opts.Aeroways == nil {
+		opts.Aeroways = DefaultAeroways
+	}
+	for _, v := range opts.Aeroways {
+		aeroways[v] = true
+	}
+	match := func(tags map[string]string) bool { return matches(tags, aeroways) }
+
+	var relations []*Element
+	memberWays := make(map[int64]bool)
+	err := Scan(path, KindRelation, func(e *Element) error {
+		if e.Tags["type"] != "multipolygon" || !match(e.Tags) {
+			return nil
+		}
+		relations = append(relations, e)
+		for _, m := range e.Members {
+			if m.Kind == KindWay {
+				memberWays[m.Ref] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+
+	var areas []*Element
+	ways := make(map[int64][]int64)
+	needed := make(map[int64]geo.Point)
+	err = Scan(path, KindWay, func(e *Element) error {
+		keep := false
+		if match(e.Tags) && isClosed(e.Refs) {
+			areas = append(areas, e)
+			keep = true
+		}
+		if memberWays[e.ID] {
+			ways[e.ID] = e.Refs
+			keep = true
+		}
+		if keep {
+			for _, ref := range e.Refs {
+				needed[ref] = geo.Point{}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+
+	var points []*Element
+	found := make(map[int64]bool, len(needed))
+	err = Scan(path, KindNode, func(e *Element) error {
+		if _, ok := needed[e.ID]; ok {
+			needed[e.ID] = geo.Point{Lat: e.Lat, Lon: e.Lon}
+			found[e.ID] = true
+		}
+		if opts.Nodes && match(e.Tags) {
+			points = append(points, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+	ring := func(refs []int64) (geo.Ring, bool) {
+		if !isClosed(refs) {
+			return nil, false
+		}
+		r := make(geo.Ring, 0, len(refs)-1)
+		for _, ref := range refs[:len(refs)-1] {
+			if !found[ref] {
+				return nil, false
+			}
+			r = append(r, needed[ref])
+		}
+		return r, true
+	}
+
+	file := filepath.Base(path)
+	var out []*facilities.Facility
+	add := func(e *Element, area geo.MultiPolygon, loc geo.Point) {
+		if opts.BBox != nil {
+			b := geo.BBox{West: loc.Lon, East: loc.Lon, South: loc.Lat, North: loc.Lat}
+			if len(area) > 0 {
+				b = area.BBox()
+			}
+			if !opts.BBox.Intersects(b) {
+				stats.OutsideBBox++
+				return
+			}
+		}
+		out = append(out, facility(e, area, loc, file))
+		switch e.Kind {
+		case KindNode:
+			stats.Nodes++
+		case KindWay:
+			stats.Ways++
+		case KindRelation:
+			stats.Relations++
+		}
+	}
+
+	for _, e := range relations {
+		area, ok := assemble(e, ways, ring)
+		if !ok {
+			stats.Incomplete++
+			continue
+		}
+		add(e, area, outerCentroid(area))
+	}
+	for _, e := range areas {
+		r, ok := ring(e.Refs)
+		if !ok {
+			stats.Incomplete++
+			continue
+		}
+		add(e, geo.MultiPolygon{{r}}, geo.Centroid(r))
+	}
+	for _, e := range points {
+		add(e, nil, geo.Point{Lat: e.Lat, Lon: e.Lon})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, stats, nil
+}
+
+// matches reports whether tags describe a feature to import.
+func matches(tags map[string]string, aeroways map[string]bool) bool {
+	if v, ok := tags["military"]; ok && v != "no" {
+		return true
+	}
+	if v, ok := tags["aeroway"]; ok && (aeroways[v] || aeroways["*"]) {
+		return true
+	}
+	return tags["landuse"] == "port"
+}
+
+// isClosed reports whether a way's node list forms a ring.
+func isClosed(refs []int64) bool {
+	return len(refs) >= 4 && refs[0] == refs[len(refs)-1]
+}
+
+// assemble builds a multipolygon relation's area by joining its outer and
+// inner member ways into rings and assigning each inner ring to the outer
+// ring that contains it.
+func assemble(e *Element, ways map[int64][]int64, ring func([]int64) (geo.Ring, bool)) (geo.MultiPolygon, bool) {
+	var outerWays, innerWays [][]int64
+	for _, m := range e.Members {
+		if m.Kind != KindWay {
+			continue
+		}
+		refs, ok := ways[m.Ref]
+		if !ok {
+			return nil, false // member outside the extract
+		}
+		if m.Role == "inner" {
+			innerWays = append(innerWays, refs)
+		} else {
+			outerWays = append(outerWays, refs)
+		}
+	}
+	build := func(segments [][]int64) ([]geo.Ring, bool) {
+		joined, ok := joinRings(segments)
+		if !ok {
+			return nil, false
+		}
+		rings := make([]geo.Ring, len(joined))
+		for i, refs := range joined {
+			if rings[i], ok = ring(refs); !ok {
+				return nil, false
+			}
+		}
+		return rings, true
+	}
+	outers, ok := build(outerWays)
+	if !ok || len(outers) == 0 {
+		return nil, false
+	}
+	inners, ok := build(innerWays)
+	if !ok {
+		return nil, false
+	}
+
+	area := make(geo.MultiPolygon, len(outers))
+	for i, r := range outers {
+		area[i] = geo.Polygon{r}
+	}
+	for _, in := range inners {
+		for i := range area {
+			if area[i][0].Contains(in[0]) {
+				area[i] = append(area[i], in)
+				break
+			}
+		}
+	}
+	return area, true
+}
+
+// joinRings chains way segments that share end nodes into closed rings,
+// reversing segments as needed. It fails if any chain stays open.
+func joinRings(segments [][]int64) ([][]int64, bool) {
+	pool := make([][]int64, 0, len(segments))
+	for _, s := range segments {
+		if len(s) >= 2 {
+			pool = append(pool, s)
+		}
+	}
+	var rings [][]int64
+	for len(pool) > 0 {
+		cur := append([]int64(nil), pool[0]...)
+		pool = pool[1:]
+		for cur[0] != cur[len(cur)-1] {
+			end := cur[len(cur)-1]
+			next := -1
+			for i, s := range pool {
+				if s[0] == end || s[len(s)-1] == end {
+					next = i
+					break
+				}
+			}
+			if next < 0 {
+				return nil, false
+			}
+			s := pool[next]
+			pool = append(pool[:next], pool[next+1:]...)
+			if s[0] != end {
+				s = reversed(s)
+			}
+			cur = append(cur, s[1:]...)
+		}
+		if len(cur) < 4 {
+			return nil, false
+		}
+		rings = append(rings, cur)
+	}
+	return rings, true
+}
+
+func reversed(s []int64) []int64 {
+	out := make([]int64, len(s))
+	for i, v := range s {
+		out[len(s)-1-i] = v
+	}
+	return out
+}
+
+// outerCentroid returns the centroid of the vertices of all outer rings.
+func outerCentroid(area geo.MultiPolygon) geo.Point {
+	var pts []geo.Point
+	for _, p := range area {
+		pts = append(pts, p[0]...)
+	}
+	return geo.Centroid(pts)
+}
+
+// facility converts a matching element to a facility.
+func facility(e *Element, area geo.MultiPolygon, loc geo.Point, file string) *facilities.Facility {
+	types := facilityTypes(e.Tags)
+	f := &facilities.Facility{
+		ID:       fmt.Sprintf("osm-%s-%d", e.Kind, e.ID),
+		Name:     facilityName(e, types[0]),
+		Type:     types[0],
+		Subtypes: types[1:],
+		Country:  e.Tags["addr:country"],
+		Location: facilities.Location{Lat: loc.Lat, Lon: loc.Lon},
+		Sources:  []string{Source},
+		Provenance: &facilities.Provenance{
+			Source:  Source,
+			Element: fmt.Sprintf("%s/%d", e.Kind, e.ID),
+			Version: e.Version,
+			File:    file,
+			License: License,
+			Tags:    e.Tags,
+		},
+	}
+	if !e.Timestamp.IsZero() {
+		f.LastUpdated = e.Timestamp.Format("2006-01-02")
+		f.Provenance.Timestamp = e.Timestamp.Format(time.RFC3339)
+	}
+	if len(area) > 0 {
+		f.Geometry = geometryJSON(area)
+	}
+	return f
+}
+
+// facilityTypes maps the element's tags to facility types, most specific
+// first: military tags, then landuse=port, then aeroway.
+func facilityTypes(tags map[string]string) []string {
+	var types []string
+	add := func(t string) {
+		for _, have := range types {
+			if have == t {
+				return
+			}
+		}
+		types = append(types, t)
+	}
+	if v, ok := tags["military"]; ok && v != "no" {
+		switch v {
+		case "naval_base", "barracks":
+			add(v)
+		case "airfield":
+			add("airbase")
+		case "base", "yes":
+			add("military_base")
+		case "range":
+			add("military_range")
+		default:
+			add("military_" + v)
+		}
+	}
+	if tags["landuse"] == "port" {
+		add("port")
+	}
+	if v, ok := tags["aeroway"]; ok {
+		switch v {
+		case "aerodrome", "airstrip":
+			add("airfield")
+		case "heliport", "apron", "hangar", "helipad":
+			add(v)
+		default:
+			add("aeroway_" + v)
+		}
+	}
+	if len(types) == 0 {
+		add("unknown")
+	}
+	return types
+}
+
+// facilityName prefers the English name, then the local name, and falls
+// back to the type and element reference.
+func facilityName(e *Element, typ string) string {
+	for _, k := range []string{"name:en", "name", "official_name", "ref"} {
+		if v := strings.TrimSpace(e.Tags[k]); v != "" {
+			return v
+		}
+	}
+	return fmt.Sprintf("Unnamed %s (%s/%d)", strings.ReplaceAll(typ, "_", " "), e.Kind, e.ID)
+}
+
+// geometryJSON encodes an area as a GeoJSON Polygon or MultiPolygon.
+func geometryJSON(area geo.MultiPolygon) json.RawMessage {
+	var g struct {
+		Type        string `json:"type"`
+		Coordinates any    `json:"coordinates"`
+	}
+	if len(area) == 1 {
+		g.Type, g.Coordinates = "Polygon", area[0].GeoJSONCoordinates()
+	} else {
+		coords := make([][][][2]float64, len(area))
+		for i, p := range area {
+			coords[i] = p.GeoJSONCoordinates()
+		}
+		g.Type, g.Coordinates = "MultiPolygon", coords
+	}
+	data, _ := json.Marshal(g)
+	return data
+}
@@ -0,0 +1,485 @@
+package osm
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Limits from the OSM PBF specification.
+const (
+	pbfMaxHeaderSize = 64 << 10
+	pbfMaxBlobSize   = 32 << 20
+)
+
+// scanPBF reads an OSM PBF file: a sequence of length-prefixed BlobHeader
+// and Blob messages whose OSMData blobs hold PrimitiveBlocks. Messages are
+// decoded directly from the wire format (osmformat.proto, fileformat.proto)
+// so no generated code is needed.
+func scanPBF(path string, kinds Kind, fn func(*Element) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 1<<20)
+
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if size > pbfMaxHeaderSize {
+			return fmt.Errorf("%s: blob header of %d bytes exceeds limit", path, size)
+		}
+		header := make([]byte, size)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		blobType, dataSize, err := parseBlobHeader(header)
+		if err != nil {
+			return fmt.Errorf("%s: blob header: %w", path, err)
+		}
+		if dataSize > pbfMaxBlobSize {
+			return fmt.Errorf("%s: blob of %d bytes exceeds limit", path, dataSize)
+		}
+		blob := make([]byte, dataSize)
+		if _, err := io.ReadFull(r, blob); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if blobType != "OSMData" {
+			continue // OSMHeader carries nothing we need
+		}
+		data, err := blobData(blob)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := decodePrimitiveBlock(data, kinds, fn); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+}
+
+// fields iterates over the fields of a protobuf message, calling fn with
+// the field number, wire type and raw value (varint value or bytes).
+func fields(b []byte, fn func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var v uint64
+		var bs []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			bs, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, typ, v, bs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varints appends the values of a repeated varint field, packed or not.
+func varints(dst []uint64, typ protowire.Type, v uint64, bs []byte) ([]uint64, error) {
+	if typ == protowire.VarintType {
+		return append(dst, v), nil
+	}
+	for len(bs) > 0 {
+		x, n := protowire.ConsumeVarint(bs)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		dst = append(dst, x)
+		bs = bs[n:]
+	}
+	return dst, nil
+}
+
+func parseBlobHeader(b []byte) (typ string, size int, err error) {
+	err = fields(b, func(num protowire.Number, _ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			typ = string(bs)
+		case 3:
+			size = int(int32(v))
+		}
+		return nil
+	})
+	if err == nil && size < 0 {
+		err = fmt.Errorf("negative blob size %d", size)
+	}
+	return typ, size, err
+}
+
+// blobData returns the uncompressed contents of a Blob.
+func blobData(b []byte) ([]byte, error) {
+	var raw, zdata []byte
+	rawSize := 0
+	compressed := ""
+	err := fields(b, func(num protowire.Number, _ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			raw = bs
+		case 2:
+			rawSize = int(int32(v))
+		case 3:
+			zdata = bs
+		case 4:
+			compressed = "lzma"
+		case 6:
+			compressed = "lz4"
+		case 7:
+			compressed = "zstd"
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case raw != nil:
+		return raw, nil
+	case zdata != nil:
+		if rawSize < 0 || rawSize > pbfMaxBlobSize {
+			return nil, fmt.Errorf("invalid raw blob size %d", rawSize)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(zdata))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		out := bytes.NewBuffer(make([]byte, 0, rawSize))
+		if _, err := io.Copy(out, io.LimitReader(zr, pbfMaxBlobSize+1)); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	case compressed != "":
+		return nil, fmt.Errorf("unsupported %s blob compression (re-encode with zlib, e.g. osmium cat -f pbf,pbf_compression=zlib)", compressed)
+	}
+	return nil, errors.New("empty blob")
+}
+
+// block holds the per-PrimitiveBlock decoding parameters.
+type block struct {
+	strings     []string
+	granularity int64
+	latOffset   int64
+	lonOffset   int64
+	dateGran    int64
+}
+
+// lat and lon convert stored coordinates to degrees, rounded to the 1e-7°
+// precision of the OSM database so values print as they were mapped.
+func (b *block) lat(v int64) float64 { return degrees(b.latOffset + b.granularity*v) }
+func (b *block) lon(v int64) float64 { return degrees(b.lonOffset + b.granularity*v) }
+
+func degrees(nano int64) float64 { return math.Round(float64(nano)/100) / 1e7 }
+
+func (b *block) time(v int64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(v * b.dateGran).UTC()
+}
+
+func (b *block) str(i uint64) (string, error) {
+	if i >= uint64(len(b.strings)) {
+		return "", fmt.Errorf("string index %d out of range", i)
+	}
+	return b.strings[i], nil
+}
+
+func (b *block) tags(keys, vals []uint64) (map[string]string, error) {
+	if len(keys) != len(vals) {
+		return nil, errors.New("mismatched tag keys and values")
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(keys))
+	for i := range keys {
+		k, err := b.str(keys[i])
+		if err != nil {
+			return nil, err
+		}
+		v, err := b.str(vals[i])
+		if err != nil {
+			return nil, err
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+func decodePrimitiveBlock(data []byte, kinds Kind, fn func(*Element) error) error {
+	b := &block{granularity: 100, dateGran: 1000}
+	var groups [][]byte
+	err := fields(data, func(num protowire.Number, _ protowire.Type, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			return fields(bs, func(num protowire.Number, _ protowire.Type, _ uint64, s []byte) error {
+				if num == 1 {
+					b.strings = append(b.strings, string(s))
+				}
+				return nil
+			})
+		case 2:
+			groups = append(groups, bs)
+		case 17:
+			b.granularity = int64(int32(v))
+		case 18:
+			b.dateGran = int64(int32(v))
+		case 19:
+			b.latOffset = int64(v)
+		case 20:
+			b.lonOffset = int64(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Groups may precede the block parameters on the wire, so decode them
+	// once the whole block has been read.
+	for _, g := range groups {
+		err := fields(g, func(num protowire.Number, _ protowire.Type, _ uint64, bs []byte) error {
+			switch {
+			case num == 1 && kinds&KindNode != 0:
+				return b.node(bs, fn)
+			case num == 2 && kinds&KindNode != 0:
+				return b.denseNodes(bs, fn)
+			case num == 3 && kinds&KindWay != 0:
+				return b.way(bs, fn)
+			case num == 4 && kinds&KindRelation != 0:
+				return b.relation(bs, fn)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// info decodes the version and timestamp of an Info message.
+func (b *block) info(bs []byte, e *Element) error {
+	return fields(bs, func(num protowire.Number, _ protowire.Type, v uint64, _ []byte) error {
+		switch num {
+		case 1:
+			e.Version = int(int32(v))
+		case 2:
+			e.Timestamp = b.time(int64(v))
+		}
+		return nil
+	})
+}
+
+func (b *block) node(bs []byte, fn func(*Element) error) error {
+	e := &Element{Kind: KindNode}
+	var keys, vals []uint64
+	var lat, lon int64
+	err := fields(bs, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		var err error
+		switch num {
+		case 1:
+			e.ID = protowire.DecodeZigZag(v)
+		case 2:
+			keys, err = varints(keys, typ, v, bs)
+		case 3:
+			vals, err = varints(vals, typ, v, bs)
+		case 4:
+			err = b.info(bs, e)
+		case 8:
+			lat = protowire.DecodeZigZag(v)
+		case 9:
+			lon = protowire.DecodeZigZag(v)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	e.Lat, e.Lon = b.lat(lat), b.lon(lon)
+	if e.Tags, err = b.tags(keys, vals); err != nil {
+		return err
+	}
+	return fn(e)
+}
+
+func (b *block) denseNodes(bs []byte, fn func(*Element) error) error {
+	var ids, lats, lons, keyVals, versions, stamps []uint64
+	err := fields(bs, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		var err error
+		switch num {
+		case 1:
+			ids, err = varints(ids, typ, v, bs)
+		case 5:
+			err = fields(bs, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+				var err error
+				switch num {
+				case 1:
+					versions, err = varints(versions, typ, v, bs)
+				case 2:
+					stamps, err = varints(stamps, typ, v, bs)
+				}
+				return err
+			})
+		case 8:
+			lats, err = varints(lats, typ, v, bs)
+		case 9:
+			lons, err = varints(lons, typ, v, bs)
+		case 10:
+			keyVals, err = varints(keyVals, typ, v, bs)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return errors.New("dense nodes: mismatched id and coordinate arrays")
+	}
+
+	var id, lat, lon, stamp int64
+	kv := 0
+	for i := range ids {
+		id += protowire.DecodeZigZag(ids[i])
+		lat += protowire.DecodeZigZag(lats[i])
+		lon += protowire.DecodeZigZag(lons[i])
+		e := &Element{Kind: KindNode, ID: id, Lat: b.lat(lat), Lon: b.lon(lon)}
+		if i < len(versions) {
+			e.Version = int(int32(versions[i]))
+		}
+		if i < len(stamps) {
+			stamp += protowire.DecodeZigZag(stamps[i])
+			e.Timestamp = b.time(stamp)
+		}
+		// keys_vals holds key, value pairs per node, each node's list
+		// terminated by 0.
+		for kv < len(keyVals) && keyVals[kv] != 0 {
+			if kv+1 >= len(keyVals) {
+				return errors.New("dense nodes: truncated keys_vals")
+			}
+			k, err := b.str(keyVals[kv])
+			if err != nil {
+				return err
+			}
+			v, err := b.str(keyVals[kv+1])
+			if err != nil {
+				return err
+			}
+			if e.Tags == nil {
+				e.Tags = make(map[string]string)
+			}
+			e.Tags[k] = v
+			kv += 2
+		}
+		kv++ // terminator
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *block) way(bs []byte, fn func(*Element) error) error {
+	e := &Element{Kind: KindWay}
+	var keys, vals, refs []uint64
+	err := fields(bs, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		var err error
+		switch num {
+		case 1:
+			e.ID = int64(v)
+		case 2:
+			keys, err = varints(keys, typ, v, bs)
+		case 3:
+			vals, err = varints(vals, typ, v, bs)
+		case 4:
+			err = b.info(bs, e)
+		case 8:
+			refs, err = varints(refs, typ, v, bs)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if e.Tags, err = b.tags(keys, vals); err != nil {
+		return err
+	}
+	e.Refs = make([]int64, len(refs))
+	var ref int64
+	for i, d := range refs {
+		ref += protowire.DecodeZigZag(d)
+		e.Refs[i] = ref
+	}
+	return fn(e)
+}
+
+func (b *block) relation(bs []byte, fn func(*Element) error) error {
+	e := &Element{Kind: KindRelation}
+	var keys, vals, roles, memIDs, types []uint64
+	err := fields(bs, func(num protowire.Number, typ protowire.Type, v uint64, bs []byte) error {
+		var err error
+		switch num {
+		case 1:
+			e.ID = int64(v)
+		case 2:
+			keys, err = varints(keys, typ, v, bs)
+		case 3:
+			vals, err = varints(vals, typ, v, bs)
+		case 4:
+			err = b.info(bs, e)
+		case 8:
+			roles, err = varints(roles, typ, v, bs)
+		case 9:
+			memIDs, err = varints(memIDs, typ, v, bs)
+		case 10:
+			types, err = varints(types, typ, v, bs)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if e.Tags, err = b.tags(keys, vals); err != nil {
+		return err
+	}
+	if len(roles) != len(memIDs) || len(types) != len(memIDs) {
+		return errors.New("relation: mismatched member arrays")
+	}
+	var ref int64
+	for i := range memIDs {
+		ref += protowire.DecodeZigZag(memIDs[i])
+		role, err := b.str(uint64(int32(roles[i])))
+		if err != nil {
+			return err
+		}
+		e.Members = append(e.Members, Member{Kind: Kind(1) << types[i], Ref: ref, Role: role})
+	}
+	return fn(e)
+}
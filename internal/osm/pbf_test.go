@@ -0,0 +1,265 @@
+package osm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// pbfWriter encodes elements as an OSM PBF file with one zlib-compressed
+// PrimitiveBlock, the way osmium writes them: dense nodes, delta-coded
+// references and a shared string table.
+type pbfWriter struct {
+	strings []string
+	index   map[string]uint64
+}
+
+func (w *pbfWriter) str(s string) uint64 {
+	if i, ok := w.index[s]; ok {
+		return i
+	}
+	w.index[s] = uint64(len(w.strings))
+	w.strings = append(w.strings, s)
+	return w.index[s]
+}
+
+// tagIDs returns the string indexes of tags sorted by key.
+func (w *pbfWriter) tagIDs(tags map[string]string) (keys, vals []uint64) {
+	var ks []string
+	for k := range tags {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	for _, k := range ks {
+		keys = append(keys, w.str(k))
+		vals = append(vals, w.str(tags[k]))
+	}
+	return keys, vals
+}
+
+func packed(b []byte, num protowire.Number, vs []uint64) []byte {
+	var p []byte
+	for _, v := range vs {
+		p = protowire.AppendVarint(p, v)
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, p)
+}
+
+func varintField(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func bytesField(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// deltas zigzag-encodes the successive differences of vs.
+func deltas(vs []int64) []uint64 {
+	out := make([]uint64, len(vs))
+	var prev int64
+	for i, v := range vs {
+		out[i] = protowire.EncodeZigZag(v - prev)
+		prev = v
+	}
+	return out
+}
+
+const (
+	testGranularity = 100
+	testLatOffset   = 36_000_000_000 // nanodegrees
+)
+
+func (w *pbfWriter) block(elems []*Element) []byte {
+	w.index = map[string]uint64{}
+	w.strings = nil
+	w.str("")
+
+	var ids, lats, lons, stamps, kv []int64
+	var versions []uint64
+	var group []byte
+	for _, e := range elems {
+		if e.Kind != KindNode {
+			continue
+		}
+		ids = append(ids, e.ID)
+		lats = append(lats, (int64(e.Lat*1e9+0.5*sign(e.Lat))-testLatOffset)/testGranularity)
+		lons = append(lons, int64(e.Lon*1e9+0.5*sign(e.Lon))/testGranularity)
+		versions = append(versions, uint64(e.Version))
+		stamps = append(stamps, e.Timestamp.Unix())
+		keys, vals := w.tagIDs(e.Tags)
+		for i := range keys {
+			kv = append(kv, int64(keys[i]), int64(vals[i]))
+		}
+		kv = append(kv, 0)
+	}
+	var dense, info []byte
+	dense = packed(dense, 1, deltas(ids))
+	info = packed(info, 1, versions)
+	info = packed(info, 2, deltas(stamps))
+	dense = bytesField(dense, 5, info)
+	dense = packed(dense, 8, deltas(lats))
+	dense = packed(dense, 9, deltas(lons))
+	var kvs []uint64
+	for _, v := range kv {
+		kvs = append(kvs, uint64(v))
+	}
+	dense = packed(dense, 10, kvs)
+	group = bytesField(group, 2, dense)
+
+	for _, e := range elems {
+		var m []byte
+		m = varintField(m, 1, uint64(e.ID))
+		keys, vals := w.tagIDs(e.Tags)
+		m = packed(m, 2, keys)
+		m = packed(m, 3, vals)
+		var info []byte
+		info = varintField(info, 1, uint64(e.Version))
+		info = varintField(info, 2, uint64(e.Timestamp.Unix()))
+		m = bytesField(m, 4, info)
+		switch e.Kind {
+		case KindWay:
+			m = packed(m, 8, deltas(e.Refs))
+			group = bytesField(group, 3, m)
+		case KindRelation:
+			var roles, types []uint64
+			var refs []int64
+			for _, mem := range e.Members {
+				roles = append(roles, w.str(mem.Role))
+				refs = append(refs, mem.Ref)
+				types = append(types, map[Kind]uint64{KindNode: 0, KindWay: 1, KindRelation: 2}[mem.Kind])
+			}
+			m = packed(m, 8, roles)
+			m = packed(m, 9, deltas(refs))
+			m = packed(m, 10, types)
+			group = bytesField(group, 4, m)
+		}
+	}
+
+	// The group precedes the string table and block parameters, which
+	// decoders must accept.
+	var b, table []byte
+	b = bytesField(b, 2, group)
+	for _, s := range w.strings {
+		table = bytesField(table, 1, []byte(s))
+	}
+	b = bytesField(b, 1, table)
+	b = varintField(b, 17, testGranularity)
+	b = varintField(b, 19, uint64(testLatOffset))
+	return b
+}
+
+func sign(v float64) float64 {
+	if v < 0 {
+		return -1
+	}
+	return 1
+}
+
+// appendBlob appends a length-prefixed BlobHeader and its Blob.
+func appendBlob(out []byte, typ string, blob []byte) []byte {
+	var h []byte
+	h = bytesField(h, 1, []byte(typ))
+	h = varintField(h, 3, uint64(len(blob)))
+	out = binary.BigEndian.AppendUint32(out, uint32(len(h)))
+	return append(append(out, h...), blob...)
+}
+
+func writePBF(t *testing.T, elems []*Element) string {
+	t.Helper()
+	var w pbfWriter
+	data := w.block(elems)
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+
+	var header, blob []byte
+	header = bytesField(header, 4, []byte("OsmSchema-V0.6"))
+	header = bytesField(header, 4, []byte("DenseNodes"))
+	var out []byte
+	out = appendBlob(out, "OSMHeader", bytesField(nil, 1, header))
+	blob = varintField(blob, 2, uint64(len(data)))
+	blob = bytesField(blob, 3, z.Bytes())
+	out = appendBlob(out, "OSMData", blob)
+
+	path := filepath.Join(t.TempDir(), "sites.osm.pbf")
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func scanAll(t *testing.T, path string, kinds Kind) []*Element {
+	t.Helper()
+	var out []*Element
+	if err := Scan(path, kinds, func(e *Element) error {
+		out = append(out, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestScanPBFMatchesXML(t *testing.T) {
+	want := scanAll(t, "testdata/sites.osm", KindAll)
+	if len(want) != 17 {
+		t.Fatalf("XML fixture: %d elements, want 17", len(want))
+	}
+	got := scanAll(t, writePBF(t, want), KindAll)
+	if !reflect.DeepEqual(got, want) {
+		for i := range want {
+			if i < len(got) && !reflect.DeepEqual(got[i], want[i]) {
+				t.Errorf("element %d: %+v, want %+v", i, got[i], want[i])
+			}
+		}
+		t.Fatalf("PBF decoded %d elements, want %d", len(got), len(want))
+	}
+
+	ways := scanAll(t, writePBF(t, want), KindWay)
+	if len(ways) != 4 || ways[0].ID != 10 || !reflect.DeepEqual(ways[0].Refs, []int64{1, 2, 3, 4, 1}) {
+		t.Errorf("ways only: %+v", ways)
+	}
+}
+
+func TestScanPBFRejectsBadSizes(t *testing.T) {
+	var h []byte
+	h = bytesField(h, 1, []byte("OSMData"))
+	h = varintField(h, 3, uint64(0xffffffff)) // int32 -1
+	negative := binary.BigEndian.AppendUint32(nil, uint32(len(h)))
+	negative = append(negative, h...)
+
+	var blob []byte
+	blob = varintField(blob, 2, uint64(0xffffffff))
+	blob = bytesField(blob, 3, []byte{0x78, 0x9c})
+	negativeRaw := appendBlob(nil, "OSMData", blob)
+	truncated := appendBlob(nil, "OSMData", bytesField(nil, 1, []byte("raw")))
+	truncated = truncated[:len(truncated)-2]
+
+	for name, data := range map[string][]byte{
+		"negative datasize": negative,
+		"negative raw_size": negativeRaw,
+		"oversized header":  binary.BigEndian.AppendUint32(nil, pbfMaxHeaderSize+1),
+		"truncated blob":    truncated,
+	} {
+		path := filepath.Join(t.TempDir(), "bad.osm.pbf")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := Scan(path, KindAll, func(*Element) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "bad.osm.pbf") {
+			t.Errorf("%s: error %v", name, err)
+		}
+	}
+}
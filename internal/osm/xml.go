@@ -0,0 +1,112 @@
+package osm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// scanXML streams an OSM XML file.
+func scanXML(path string, kinds Kind, fn func(*Element) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d := xml.NewDecoder(f)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		kind := parseKind(start.Name.Local)
+		if kind == 0 {
+			continue // <osm>, <bounds>, ...
+		}
+		if kinds&kind == 0 {
+			if err := d.Skip(); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+		e, err := xmlElement(d, start, kind)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", path, kind, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+// xmlElement reads one element whose start tag has been consumed.
+func xmlElement(d *xml.Decoder, start xml.StartElement, kind Kind) (*Element, error) {
+	e := &Element{Kind: kind}
+	for _, a := range start.Attr {
+		var err error
+		switch a.Name.Local {
+		case "id":
+			e.ID, err = strconv.ParseInt(a.Value, 10, 64)
+		case "lat":
+			e.Lat, err = strconv.ParseFloat(a.Value, 64)
+		case "lon":
+			e.Lon, err = strconv.ParseFloat(a.Value, 64)
+		case "version":
+			e.Version, err = strconv.Atoi(a.Value)
+		case "timestamp":
+			e.Timestamp, err = time.Parse(time.RFC3339, a.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", a.Name.Local, err)
+		}
+	}
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return e, nil
+		case xml.StartElement:
+			attrs := make(map[string]string, len(t.Attr))
+			for _, a := range t.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+			switch t.Name.Local {
+			case "tag":
+				if e.Tags == nil {
+					e.Tags = make(map[string]string)
+				}
+				e.Tags[attrs["k"]] = attrs["v"]
+			case "nd":
+				ref, err := strconv.ParseInt(attrs["ref"], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("nd ref: %w", err)
+				}
+				e.Refs = append(e.Refs, ref)
+			case "member":
+				ref, err := strconv.ParseInt(attrs["ref"], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("member ref: %w", err)
+				}
+				e.Members = append(e.Members, Member{Kind: parseKind(attrs["type"]), Ref: ref, Role: attrs["role"]})
+			}
+			if err := d.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
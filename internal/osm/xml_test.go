@@ -0,0 +1,108 @@
+package osm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+func TestScanXML(t *testing.T) {
+	elems := scanAll(t, "testdata/sites.osm", KindAll)
+	var kinds []Kind
+	for _, e := range elems {
+		kinds = append(kinds, e.Kind)
+	}
+	if len(elems) != 17 || kinds[11] != KindNode || kinds[12] != KindWay || kinds[16] != KindRelation {
+		t.Fatalf("kinds %v", kinds)
+	}
+
+	heliport := elems[11]
+	want := &Element{
+		Kind: KindNode, ID: 12, Lat: 36.8012345, Lon: -76.1000001, Version: 2,
+		Timestamp: time.Date(2023, 6, 2, 8, 30, 0, 0, time.UTC),
+		Tags:      map[string]string{"aeroway": "heliport", "name": "Test Helipad"},
+	}
+	if !reflect.DeepEqual(heliport, want) {
+		t.Errorf("node 12 = %+v, want %+v", heliport, want)
+	}
+	rel := elems[16]
+	members := []Member{{KindWay, 11, "outer"}, {KindWay, 12, "outer"}, {KindWay, 13, "inner"}}
+	if rel.ID != 20 || !reflect.DeepEqual(rel.Members, members) || rel.Tags["type"] != "multipolygon" {
+		t.Errorf("relation 20 = %+v", rel)
+	}
+
+	relations := scanAll(t, "testdata/sites.osm", KindRelation)
+	if len(relations) != 1 || relations[0].ID != 20 {
+		t.Errorf("relations only: %+v", relations)
+	}
+}
+
+func TestScanXMLMalformed(t *testing.T) {
+	for name, doc := range map[string]string{
+		"bad coordinate": `<osm><node id="1" lat="north" lon="0"/></osm>`,
+		"bad nd ref":     `<osm><way id="1"><nd ref="x"/></way></osm>`,
+		"unclosed":       `<osm><way id="1"><nd ref="1"/>`,
+	} {
+		path := filepath.Join(t.TempDir(), "bad.osm")
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := Scan(path, KindAll, func(*Element) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "bad.osm") {
+			t.Errorf("%s: error %v", name, err)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	fs, stats, err := Import("testdata/sites.osm", ImportOptions{Nodes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportStats{Ways: 1, Relations: 1, Nodes: 1}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	if len(fs) != 3 {
+		t.Fatalf("%d facilities, want 3", len(fs))
+	}
+	// Sorted by ID.
+	heli, field, base := fs[0], fs[1], fs[2]
+	if heli.ID != "osm-node-12" || heli.Type != "heliport" || heli.Name != "Test Helipad" || heli.Geometry != nil {
+		t.Errorf("heliport = %+v", heli)
+	}
+	if base.ID != "osm-way-10" || base.Type != "naval_base" || base.Country != "US" || base.LastUpdated != "2023-09-10" {
+		t.Errorf("naval base = %+v", base)
+	}
+	if p := base.Provenance; p == nil || p.Element != "way/10" || p.Version != 4 || p.File != "sites.osm" || p.License != License {
+		t.Errorf("naval base provenance = %+v", base.Provenance)
+	}
+
+	if field.ID != "osm-relation-20" || field.Type != "airfield" || field.Name != "Test Field" {
+		t.Fatalf("airfield = %+v", field)
+	}
+	// The outer ring is joined from two ways; the inner way is its hole.
+	area, err := geo.ParseGeoJSON(field.Geometry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(area) != 1 || len(area[0]) != 2 || len(area[0][0]) != 4 || len(area[0][1]) != 3 {
+		t.Fatalf("airfield area %v", area)
+	}
+	if area.Contains(geo.Point{Lat: 36.906, Lon: -76.191}) || !area.Contains(geo.Point{Lat: 36.915, Lon: -76.185}) {
+		t.Error("airfield hole not applied")
+	}
+
+	bbox := geo.BBox{South: 36.93, North: 37, West: -76.4, East: -76.25}
+	fs, stats, err = Import("testdata/sites.osm", ImportOptions{BBox: &bbox})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 1 || fs[0].ID != "osm-way-10" || stats.OutsideBBox != 1 {
+		t.Errorf("in bbox: %d facilities, stats %+v", len(fs), stats)
+	}
+}
@@ -0,0 +1,204 @@
+package pol
+
+import (
+	"math"
+	"time"
+)
+
+// madScale converts a MAD to a standard deviation for normal data.
+const madScale = 1.4826
+
+// Options configures baselines and scoring.
+type Options struct {
+	// Threshold is the |z| at or above which a count is anomalous;
+	// defaults to 3.
+	Threshold float64
+	// MinHistory is the number of earlier samples needed before a count
+	// is scored; defaults to 5.
+	MinHistory int
+	// MinBucket is the number of samples a weekday or month needs before
+	// its own level is used; defaults to 3.
+	MinBucket int
+	// Window limits the history to samples this recent; 0 keeps all.
+	Window time.Duration
+}
+
+func (o *Options) defaults() {
+	if o.Threshold <= 0 {
+		o.Threshold = 3
+	}
+	if o.MinHistory <= 0 {
+		o.MinHistory = 5
+	}
+	if o.MinBucket <= 0 {
+		o.MinBucket = 3
+	}
+}
+
+// Baseline is the normal level of one series. The expected count for a
+// time is the overall mean adjusted by the mean offsets of its weekday
+// and its month, where those have enough samples (an additive seasonal
+// decomposition); residuals around that expectation set the spread.
+type Baseline struct {
+	Key
+	Overall  Stats
+	Weekday  [7]Stats  // indexed by time.Weekday
+	Month    [12]Stats // January first
+	Residual Stats     // counts minus their expected values
+	// Sigma is the spread used for z-scores: 1.4826 × the residual MAD,
+	// or the residual standard deviation if the MAD is 0, but at least
+	// one object.
+	Sigma     float64
+	minBucket int
+}
+
+// NewBaseline builds the baseline of one series from its samples.
+func NewBaseline(k Key, samples []Sample, opts Options) *Baseline {
+	opts.defaults()
+	b := &Baseline{Key: k, minBucket: opts.MinBucket}
+	var all []float64
+	var wd [7][]float64
+	var mo [12][]float64
+	for _, s := range samples {
+		c := float64(s.Count)
+		all = append(all, c)
+		wd[s.Time.Weekday()] = append(wd[s.Time.Weekday()], c)
+		mo[s.Time.Month()-1] = append(mo[s.Time.Month()-1], c)
+	}
+	b.Overall = stats(all)
+	for i := range wd {
+		b.Weekday[i] = stats(wd[i])
+	}
+	for i := range mo {
+		b.Month[i] = stats(mo[i])
+	}
+
+	res := make([]float64, len(samples))
+	for i, s := range samples {
+		e, _ := b.Expected(s.Time)
+		res[i] = float64(s.Count) - e
+	}
+	b.Residual = stats(res)
+	b.Sigma = madScale * b.Residual.MAD
+	if b.Sigma == 0 {
+		b.Sigma = b.Residual.StdDev
+	}
+	b.Sigma = math.Max(b.Sigma, 1)
+	return b
+}
+
+// Expected returns the expected count at t and which components were
+// used: "overall", "weekday", "month" or "weekday+month".
+func (b *Baseline) Expected(t time.Time) (float64, string) {
+	e, basis := b.Overall.Mean, ""
+	if w := b.Weekday[t.Weekday()]; w.N >= b.minBucket && w.N < b.Overall.N {
+		e += w.Mean - b.Overall.Mean
+		basis = "weekday"
+	}
+	if m := b.Month[t.Month()-1]; m.N >= b.minBucket && m.N < b.Overall.N {
+		e += m.Mean - b.Overall.Mean
+		if basis != "" {
+			basis += "+"
+		}
+		basis += "month"
+	}
+	if basis == "" {
+		basis = "overall"
+	}
+	return math.Max(e, 0), basis
+}
+
+// Score is a count compared with its baseline.
+type Score struct {
+	Key
+	Time     time.Time `json:"time"`
+	Count    int       `json:"count"`
+	Expected float64   `json:"expected"`
+	Basis    string    `json:"basis"`
+	// Z is the deviation from the expectation in units of the baseline's
+	// robust spread.
+	Z       float64 `json:"z"`
+	History int     `json:"history"` // samples in the baseline
+	Anomaly bool    `json:"anomaly"`
+}
+
+// Direction returns "high" or "low" for anomalies and "" otherwise.
+func (s Score) Direction() string {
+	switch {
+	case !s.Anomaly:
+		return ""
+	case s.Z > 0:
+		return "high"
+	}
+	return "low"
+}
+
+// Score compares a count at t with the baseline.
+func (b *Baseline) Score(t time.Time, count int, threshold float64) Score {
+	if threshold <= 0 {
+		threshold = 3
+	}
+	e, basis := b.Expected(t)
+	z := (float64(count) - e) / b.Sigma
+	return Score{
+		Key:      b.Key,
+		Time:     t,
+		Count:    count,
+		Expected: e,
+		Basis:    basis,
+		Z:        z,
+		History:  b.Overall.N,
+		Anomaly:  math.Abs(z) >= threshold,
+	}
+}
+
+// Baselines builds one baseline per series from all samples.
+func Baselines(samples []Sample, opts Options) []*Baseline {
+	var out []*Baseline
+	for _, series := range split(samples) {
+		out = append(out, NewBaseline(Key{series[0].AOI, series[0].Class}, series, opts))
+	}
+	return out
+}
+
+// Analyze scores every sample against a baseline built only from the
+// samples before it (within Window), so a series can be replayed as it
+// would have been monitored. Samples with less than MinHistory earlier
+// samples are not scored.
+func Analyze(samples []Sample, opts Options) []Score {
+	opts.defaults()
+	var out []Score
+	for _, series := range split(samples) {
+		k := Key{series[0].AOI, series[0].Class}
+		for i, s := range series {
+			start := 0
+			if opts.Window > 0 {
+				for start < i && s.Time.Sub(series[start].Time) > opts.Window {
+					start++
+				}
+			}
+			if i-start < opts.MinHistory {
+				continue
+			}
+			b := NewBaseline(k, series[start:i], opts)
+			out = append(out, b.Score(s.Time, s.Count, opts.Threshold))
+		}
+	}
+	return out
+}
+
+// split groups samples into time-ordered series.
+func split(samples []Sample) [][]Sample {
+	sorted := append([]Sample(nil), samples...)
+	sortSamples(sorted)
+	var out [][]Sample
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j].AOI == sorted[i].AOI && sorted[j].Class == sorted[i].Class {
+			j++
+		}
+		out = append(out, sorted[i:j])
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+// Package pol builds pattern-of-life baselines from per-scene object
+// counts and scores new counts against them, so that unusual activity at
+// an AOI stands out from its normal weekly and seasonal rhythm.
+package pol
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Sample is the number of objects of one class counted at an AOI in one
+// scene.
+type Sample struct {
+	AOI   string
+	Class string
+	Time  time.Time
+	Count int
+}
+
+// Key identifies a time series.
+type Key struct {
+	AOI   string `json:"aoi"`
+	Class string `json:"class"`
+}
+
+// Fill adds zero-count samples so that every class ever seen at an AOI has
+// a sample for every scene time of that AOI: a scene with no ships is
+// information about ships.
+func Fill(samples []Sample) []Sample {
+	times := make(map[string]map[time.Time]bool)
+	classes := make(map[string]map[string]bool)
+	have := make(map[Key]map[time.Time]bool)
+	for _, s := range samples {
+		if times[s.AOI] == nil {
+			times[s.AOI] = make(map[time.Time]bool)
+			classes[s.AOI] = make(map[string]bool)
+		}
+		times[s.AOI][s.Time] = true
+		if s.Class == "" {
+			continue
+		}
+		classes[s.AOI][s.Class] = true
+		k := Key{s.AOI, s.Class}
+		if have[k] == nil {
+			have[k] = make(map[time.Time]bool)
+		}
+		have[k][s.Time] = true
+	}
+	var out []Sample
+	for _, s := range samples {
+		if s.Class != "" {
+			out = append(out, s)
+		}
+	}
+	for aoi, ts := range times {
+		for class := range classes[aoi] {
+			for t := range ts {
+				if !have[Key{aoi, class}][t] {
+					out = append(out, Sample{AOI: aoi, Class: class, Time: t})
+				}
+			}
+		}
+	}
+	sortSamples(out)
+	return out
+}
+
+func sortSamples(s []Sample) {
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].AOI != s[j].AOI {
+			return s[i].AOI < s[j].AOI
+		}
+		if s[i].Class != s[j].Class {
+			return s[i].Class < s[j].Class
+		}
+		return s[i].Time.Before(s[j].Time)
+	})
+}
+
+// Stats summarizes a set of counts.
+type Stats struct {
+	N      int     `json:"n"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Median float64 `json:"median"`
+	MAD    float64 `json:"mad"` // median absolute deviation
+}
+
+func stats(xs []float64) Stats {
+	s := Stats{N: len(xs)}
+	if s.N == 0 {
+		return s
+	}
+	for _, x := range xs {
+		s.Mean += x
+	}
+	s.Mean /= float64(s.N)
+	if s.N > 1 {
+		var ss float64
+		for _, x := range xs {
+			ss += (x - s.Mean) * (x - s.Mean)
+		}
+		s.StdDev = math.Sqrt(ss / float64(s.N-1))
+	}
+	s.Median = median(xs)
+	dev := make([]float64, len(xs))
+	for i, x := range xs {
+		dev[i] = math.Abs(x - s.Median)
+	}
+	s.MAD = median(dev)
+	return s
+}
+
+func median(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	s := append([]float64(nil), xs...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
@@ -0,0 +1,82 @@
+package preprocess
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clearclown/orbital-eye/internal/raster"
+)
+
+// ProcessFile applies p to a GeoTIFF, PNG or JPEG file. GeoTIFFs are read
+// strip by strip, so full Sentinel-2 tiles are never held as float32.
+func ProcessFile(path string, p Params) (image.Image, Applied, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tif", ".tiff":
+		g, err := raster.Open(path)
+		if err != nil {
+			return nil, Applied{}, err
+		}
+		defer g.Close()
+		return Process(g, g.Width, g.Height, p)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, Applied{}, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, Applied{}, fmt.Errorf("decode %s: %w", path, err)
+	}
+	r := raster.FromImage(img)
+	return Process(memReader{r}, r.Width, r.Height, p)
+}
+
+// WriteTemp writes img as a PNG in the system temp directory and returns
+// its path; the caller removes it.
+func WriteTemp(img image.Image) (string, error) {
+	f, err := os.CreateTemp("", "orbital-eye-*.png")
+	if err != nil {
+		return "", err
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// memReader reads windows of an in-memory raster.
+type memReader struct{ r *raster.Raster }
+
+func (m memReader) Read(win raster.Window, step int) (*raster.Raster, error) {
+	if step < 1 {
+		step = 1
+	}
+	win = win.Intersect(m.r.Width, m.r.Height)
+	if win.Empty() {
+		return nil, errors.New("window lies outside the image")
+	}
+	outW, outH := (win.Width+step-1)/step, (win.Height+step-1)/step
+	out := raster.New(outW, outH, len(m.r.Bands))
+	out.NoData, out.HasNoData = m.r.NoData, m.r.HasNoData
+	for b, band := range m.r.Bands {
+		for y := 0; y < outH; y++ {
+			for x := 0; x < outW; x++ {
+				out.Bands[b][y*outW+x] = band[(win.Y+y*step)*m.r.Width+win.X+x*step]
+			}
+		}
+	}
+	return out, nil
+}
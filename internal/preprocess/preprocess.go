@@ -0,0 +1,365 @@
+// Package preprocess turns raw sensor values into the 8-bit image sent to
+// the AI worker.
+//
+// Sentinel-2 L2A reflectance (0–10000+), 16-bit products and linear SAR
+// backscatter all read poorly once OpenCV squeezes them into 8 bits. A
+// Params value describes a radiometric pipeline — optional SAR dB
+// conversion, a percentile or fixed-range stretch, gamma and histogram
+// equalization — and Process applies it, returning the parameters it
+// actually used so detections can record them.
+package preprocess
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/clearclown/orbital-eye/internal/raster"
+	pb "github.com/clearclown/orbital-eye/proto/gen"
+)
+
+// AttrPreprocess is the detection attribute recording the preprocessing
+// applied to the image the detection was found on.
+const AttrPreprocess = "preprocess"
+
+// Stretch selects how input values are mapped onto 0–255.
+type Stretch string
+
+const (
+	// StretchPercentile maps the Low and High percentiles of each band's
+	// valid samples to 0 and 255.
+	StretchPercentile Stretch = "percentile"
+	// StretchLinear maps the fixed input values Low and High to 0 and 255,
+	// e.g. 0–65535 for plain 16→8-bit scaling.
+	StretchLinear Stretch = "linear"
+	// StretchNone clamps values to 0–255 unchanged.
+	StretchNone Stretch = "none"
+)
+
+// Params describes a preprocessing pipeline. Steps run in field order.
+type Params struct {
+	// SARdB converts linear backscatter to decibels before stretching.
+	SARdB bool `json:"sar_db,omitempty"`
+	// Stretch is the value mapping; empty means StretchPercentile.
+	Stretch Stretch `json:"stretch,omitempty"`
+	// Low and High are percentiles (0–100) for StretchPercentile and input
+	// values (after dB conversion) for StretchLinear.
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+	// Gamma brightens (> 1) or darkens (< 1) the stretched values as
+	// v^(1/Gamma); 0 and 1 leave them unchanged.
+	Gamma float64 `json:"gamma,omitempty"`
+	// Equalize applies histogram equalization per band after the stretch.
+	Equalize bool `json:"equalize,omitempty"`
+}
+
+// Presets are the default pipelines per imagery provider.
+var Presets = map[string]Params{
+	"sentinel2": {Stretch: StretchPercentile, Low: 2, High: 98},
+	"sentinel1": {SARdB: true, Stretch: StretchPercentile, Low: 1, High: 99},
+	"landsat":   {Stretch: StretchPercentile, Low: 2, High: 98},
+	"planet":    {Stretch: StretchLinear, Low: 0, High: 65535},
+	"none":      {Stretch: StretchNone},
+}
+
+// ForProvider returns the pipeline for a provider: an entry of overrides
+// (typically from the config file) if present, otherwise the preset.
+func ForProvider(provider string, overrides map[string]Params) (Params, error) {
+	name := strings.ToLower(provider)
+	if p, ok := overrides[name]; ok {
+		return p, p.Validate()
+	}
+	if p, ok := Presets[name]; ok {
+		return p, nil
+	}
+	names := make([]string, 0, len(Presets))
+	for n := range Presets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return Params{}, fmt.Errorf("unknown preprocessing provider %q (want one of %s)", provider, strings.Join(names, ", "))
+}
+
+// Validate checks that the parameters are consistent.
+func (p Params) Validate() error {
+	switch p.Stretch {
+	case "", StretchPercentile:
+		if p.Low < 0 || p.High > 100 || p.Low >= p.High {
+			return fmt.Errorf("percentiles must satisfy 0 <= low < high <= 100, got %g and %g", p.Low, p.High)
+		}
+	case StretchLinear:
+		if p.Low >= p.High {
+			return fmt.Errorf("linear stretch needs low < high, got %g and %g", p.Low, p.High)
+		}
+	case StretchNone:
+	default:
+		return fmt.Errorf("unknown stretch %q (want percentile, linear or none)", p.Stretch)
+	}
+	if p.Gamma < 0 {
+		return fmt.Errorf("gamma must be positive, got %g", p.Gamma)
+	}
+	return nil
+}
+
+// Applied records a preprocessing run: the pipeline plus the per-band
+// input values that were mapped to 0 and 255.
+type Applied struct {
+	Provider string       `json:"provider"`
+	Params   Params       `json:"params"`
+	Ranges   [][2]float64 `json:"ranges"`
+}
+
+// String renders the record compactly, e.g.
+// "sentinel2 percentile(2,98) in=[412,2950 380,2801 240,2410]".
+func (a Applied) String() string {
+	p := a.Params
+	var parts []string
+	if a.Provider != "" {
+		parts = append(parts, a.Provider)
+	}
+	if p.SARdB {
+		parts = append(parts, "db")
+	}
+	switch p.Stretch {
+	case "", StretchPercentile:
+		parts = append(parts, fmt.Sprintf("percentile(%g,%g)", p.Low, p.High))
+	case StretchLinear:
+		parts = append(parts, fmt.Sprintf("linear(%g,%g)", p.Low, p.High))
+	default:
+		parts = append(parts, string(p.Stretch))
+	}
+	if len(a.Ranges) > 0 && p.Stretch != StretchNone {
+		rs := make([]string, len(a.Ranges))
+		for i, r := range a.Ranges {
+			rs[i] = fmt.Sprintf("%.4g,%.4g", r[0], r[1])
+		}
+		parts = append(parts, "in=["+strings.Join(rs, " ")+"]")
+	}
+	if p.Gamma > 0 && p.Gamma != 1 {
+		parts = append(parts, fmt.Sprintf("gamma=%g", p.Gamma))
+	}
+	if p.Equalize {
+		parts = append(parts, "equalize")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Annotate records a on every detection.
+func Annotate(dets []*pb.Detection, a Applied) {
+	s := a.String()
+	for _, d := range dets {
+		if d.Attributes == nil {
+			d.Attributes = make(map[string]string)
+		}
+		d.Attributes[AttrPreprocess] = s
+	}
+}
+
+// Reader is a raster that can be read window by window, such as a
+// *raster.GeoTIFF.
+type Reader interface {
+	Read(win raster.Window, step int) (*raster.Raster, error)
+}
+
+// statsMaxSamplesAxis bounds the pixels per axis sampled for percentiles
+// and equalization histograms.
+const statsMaxSamplesAxis = 1024
+
+// stripRows is the number of rows converted per read.
+const stripRows = 256
+
+// Process applies p to a width x height raster and returns the 8-bit
+// result: grayscale for one or two bands, RGB from the first three bands
+// otherwise. Nodata pixels, and pixels where all bands are 0 in files
+// without a nodata value, become black and are left out of statistics.
+func Process(src Reader, width, height int, p Params) (image.Image, Applied, error) {
+	if err := p.Validate(); err != nil {
+		return nil, Applied{}, err
+	}
+	if p.Stretch == "" {
+		p.Stretch = StretchPercentile
+	}
+
+	step := max(1, (max(width, height)+statsMaxSamplesAxis-1)/statsMaxSamplesAxis)
+	sample, err := src.Read(raster.Window{Width: width, Height: height}, step)
+	if err != nil {
+		return nil, Applied{}, err
+	}
+	bands := min(len(sample.Bands), 3)
+	if bands == 2 {
+		bands = 1
+	}
+	pl := pipeline{params: p, ranges: make([][2]float64, bands), luts: make([]*[256]uint8, bands)}
+	for b := 0; b < bands; b++ {
+		pl.ranges[b] = pl.inputRange(sample, b)
+	}
+	if p.Equalize {
+		for b := 0; b < bands; b++ {
+			pl.luts[b] = pl.equalizeLUT(sample, b)
+		}
+	}
+
+	rect := image.Rect(0, 0, width, height)
+	var gray *image.Gray
+	var rgba *image.RGBA
+	if bands == 1 {
+		gray = image.NewGray(rect)
+	} else {
+		rgba = image.NewRGBA(rect)
+	}
+	for y0 := 0; y0 < height; y0 += stripRows {
+		strip, err := src.Read(raster.Window{Y: y0, Width: width, Height: min(stripRows, height-y0)}, 1)
+		if err != nil {
+			return nil, Applied{}, err
+		}
+		for i := 0; i < strip.Width*strip.Height; i++ {
+			o := y0*width + i
+			valid := validPixel(strip, i)
+			if gray != nil {
+				if valid {
+					gray.Pix[o] = pl.convert(strip.Bands[0][i], 0)
+				}
+				continue
+			}
+			if valid {
+				for b := 0; b < 3; b++ {
+					rgba.Pix[o*4+b] = pl.convert(strip.Bands[b][i], b)
+				}
+			}
+			rgba.Pix[o*4+3] = 255
+		}
+	}
+
+	applied := Applied{Params: p, Ranges: pl.ranges}
+	if gray != nil {
+		return gray, applied, nil
+	}
+	return rgba, applied, nil
+}
+
+// validPixel reports whether pixel i of r holds data.
+func validPixel(r *raster.Raster, i int) bool {
+	zero := true
+	for _, band := range r.Bands {
+		v := band[i]
+		if r.IsNoData(v) {
+			return false
+		}
+		zero = zero && v == 0
+	}
+	return !zero || r.HasNoData
+}
+
+type pipeline struct {
+	params Params
+	ranges [][2]float64
+	luts   []*[256]uint8
+}
+
+// value returns the input value of a sample after optional dB conversion;
+// ok is false for non-positive backscatter.
+func (pl *pipeline) value(v float32) (float64, bool) {
+	x := float64(v)
+	if pl.params.SARdB {
+		if x <= 0 {
+			return 0, false
+		}
+		x = 10 * math.Log10(x)
+	}
+	return x, true
+}
+
+// inputRange returns the input values of band b mapped to 0 and 255.
+func (pl *pipeline) inputRange(sample *raster.Raster, b int) [2]float64 {
+	p := pl.params
+	switch p.Stretch {
+	case StretchLinear:
+		return [2]float64{p.Low, p.High}
+	case StretchNone:
+		return [2]float64{0, 255}
+	}
+	var vals []float64
+	for i, v := range sample.Bands[b] {
+		if !validPixel(sample, i) {
+			continue
+		}
+		if x, ok := pl.value(v); ok {
+			vals = append(vals, x)
+		}
+	}
+	if len(vals) == 0 {
+		return [2]float64{0, 255}
+	}
+	sort.Float64s(vals)
+	lo, hi := percentile(vals, p.Low), percentile(vals, p.High)
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return [2]float64{lo, hi}
+}
+
+// percentile returns the q-th percentile of sorted values, interpolating
+// between neighbours.
+func percentile(sorted []float64, q float64) float64 {
+	pos := q / 100 * float64(len(sorted)-1)
+	i := int(pos)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	f := pos - float64(i)
+	return sorted[i]*(1-f) + sorted[i+1]*f
+}
+
+// stretch maps a sample of band b to 0–255 before equalization.
+func (pl *pipeline) stretch(v float32, b int) uint8 {
+	x, ok := pl.value(v)
+	if !ok {
+		return 0
+	}
+	if pl.params.Stretch == StretchNone {
+		return uint8(math.Round(math.Max(0, math.Min(255, x))))
+	}
+	r := pl.ranges[b]
+	t := math.Max(0, math.Min(1, (x-r[0])/(r[1]-r[0])))
+	if g := pl.params.Gamma; g > 0 && g != 1 {
+		t = math.Pow(t, 1/g)
+	}
+	return uint8(math.Round(t * 255))
+}
+
+func (pl *pipeline) convert(v float32, b int) uint8 {
+	s := pl.stretch(v, b)
+	if lut := pl.luts[b]; lut != nil {
+		return lut[s]
+	}
+	return s
+}
+
+// equalizeLUT builds a histogram-equalization table for band b from the
+// stretched values of the sample.
+func (pl *pipeline) equalizeLUT(sample *raster.Raster, b int) *[256]uint8 {
+	var hist [256]int
+	n := 0
+	for i, v := range sample.Bands[b] {
+		if validPixel(sample, i) {
+			hist[pl.stretch(v, b)]++
+			n++
+		}
+	}
+	lut := new([256]uint8)
+	cdf, cdfMin := 0, 0
+	for i, c := range hist {
+		cdf += c
+		if cdfMin == 0 {
+			cdfMin = cdf
+		}
+		if n > cdfMin {
+			lut[i] = uint8(math.Round(float64(max(cdf-cdfMin, 0)) * 255 / float64(n-cdfMin)))
+		} else {
+			lut[i] = uint8(i)
+		}
+	}
+	return lut
+}
@@ -0,0 +1,202 @@
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SpectralIndex is a normalized difference (A - B) / (A + B) of two
+// Sentinel-2 bands.
+type SpectralIndex struct {
+	Name        string
+	A, B        string
+	Description string
+}
+
+// Indices are the supported spectral indices, keyed by lower-case name.
+var Indices = map[string]SpectralIndex{
+	"ndvi": {Name: "NDVI", A: "B08", B: "B04", Description: "vegetation"},
+	"ndwi": {Name: "NDWI", A: "B03", B: "B08", Description: "open water (McFeeters)"},
+	"nbr":  {Name: "NBR", A: "B08", B: "B12", Description: "burn scars"},
+	"ndbi": {Name: "NDBI", A: "B11", B: "B08", Description: "built-up areas"},
+}
+
+// LookupIndex returns the index with the given case-insensitive name.
+func LookupIndex(name string) (SpectralIndex, error) {
+	idx, ok := Indices[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(Indices))
+		for n := range Indices {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return SpectralIndex{}, fmt.Errorf("unknown index %q (want one of %s)", name, strings.Join(names, ", "))
+	}
+	return idx, nil
+}
+
+// BandOptions configures LoadBands.
+type BandOptions struct {
+	// Resolution of the common grid in CRS units; 0 uses the finest band.
+	Resolution float64
+	// Window restricts the read to part of the common grid; the zero
+	// value reads the full extent.
+	Window Window
+	// Offset is added to every valid sample, e.g. -1000 for Sentinel-2
+	// L2A products from processing baseline 04.00 on.
+	Offset float64
+}
+
+// LoadBands reads <dir>/<name>.tif for each band name and resamples them
+// onto a common grid covering the bands' shared extent. Coarser bands are
+// interpolated bilinearly and finer ones averaged. The result has one
+// band per name, in order. Samples equal to a band's nodata value, or 0
+// when the band declares none (Sentinel-2's fill value), become NaN.
+func LoadBands(dir string, names []string, opts BandOptions) (*Raster, error) {
+	if len(names) == 0 {
+		return nil, errors.New("no bands requested")
+	}
+	files := make([]*GeoTIFF, len(names))
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+
+	var minX, maxY, maxX, minY float64
+	res := opts.Resolution
+	for i, name := range names {
+		f, err := Open(filepath.Join(dir, name+".tif"))
+		if err != nil {
+			return nil, fmt.Errorf("band %s: %w", name, err)
+		}
+		files[i] = f
+		if !f.Geo.Valid() {
+			return nil, fmt.Errorf("band %s is not georeferenced", name)
+		}
+		if f.EPSG != files[0].EPSG {
+			return nil, fmt.Errorf("band %s is in EPSG:%d, %s in EPSG:%d", name, f.EPSG, names[0], files[0].EPSG)
+		}
+		x0, y0 := f.Geo.ToCRS(0, 0)
+		x1, y1 := f.Geo.ToCRS(float64(f.Width), float64(f.Height))
+		if i == 0 {
+			minX, maxY, maxX, minY = x0, y0, x1, y1
+		} else {
+			minX, maxY = math.Max(minX, x0), math.Min(maxY, y0)
+			maxX, minY = math.Min(maxX, x1), math.Max(minY, y1)
+		}
+		if opts.Resolution == 0 && (res == 0 || f.Geo.PixelWidth < res) {
+			res = f.Geo.PixelWidth
+		}
+	}
+	if maxX <= minX || maxY <= minY {
+		return nil, errors.New("bands do not overlap")
+	}
+
+	grid := GeoTransform{OriginX: minX, OriginY: maxY, PixelWidth: res, PixelHeight: res}
+	gridW, gridH := int((maxX-minX)/res), int((maxY-minY)/res)
+	win := opts.Window
+	if win.Empty() {
+		win = Window{Width: gridW, Height: gridH}
+	}
+	win = win.Intersect(gridW, gridH)
+	if win.Empty() {
+		return nil, errors.New("window lies outside the bands' common extent")
+	}
+
+	out := New(win.Width, win.Height, len(names))
+	out.EPSG = files[0].EPSG
+	out.NoData, out.HasNoData = math.NaN(), true
+	x0, y0 := grid.ToCRS(float64(win.X), float64(win.Y))
+	out.Geo = GeoTransform{OriginX: x0, OriginY: y0, PixelWidth: res, PixelHeight: res}
+	x1, y1 := grid.ToCRS(float64(win.X+win.Width), float64(win.Y+win.Height))
+
+	for i, f := range files {
+		// Source pixels covering the window, with a one-pixel margin for
+		// interpolation.
+		c0, r0 := f.Geo.ToPixel(x0, y0)
+		c1, r1 := f.Geo.ToPixel(x1, y1)
+		src := Window{X: int(math.Floor(c0)) - 1, Y: int(math.Floor(r0)) - 1}
+		src.Width = int(math.Ceil(c1)) + 1 - src.X
+		src.Height = int(math.Ceil(r1)) + 1 - src.Y
+		src = src.Intersect(f.Width, f.Height)
+		band, err := f.Read(src, 1)
+		if err != nil {
+			return nil, fmt.Errorf("read band %s: %w", names[i], err)
+		}
+		prepareBand(band, opts.Offset)
+
+		m := Nearest
+		switch {
+		case f.Geo.PixelWidth > res:
+			m = Bilinear
+		case f.Geo.PixelWidth < res:
+			m = Average
+		}
+		sx, sy := (c1-c0)/float64(win.Width), (r1-r0)/float64(win.Height)
+		resampleBand(out.Bands[i], win.Width, win.Height, band, 0, c0-float64(src.X), r0-float64(src.Y), sx, sy, m)
+	}
+	return out, nil
+}
+
+// prepareBand turns fill values into NaN and applies the radiometric
+// offset to the first band of r.
+func prepareBand(r *Raster, offset float64) {
+	b := r.Bands[0]
+	for i, v := range b {
+		if r.IsNoData(v) || (!r.HasNoData && v == 0) {
+			b[i] = float32(math.NaN())
+			continue
+		}
+		b[i] = v + float32(offset)
+	}
+	r.NoData, r.HasNoData = math.NaN(), true
+}
+
+// NormalizedDifference computes (a - b) / (a + b) per pixel for two
+// single-band rasters on the same grid. Pixels where either input is
+// nodata or the sum is not positive are NaN.
+func NormalizedDifference(a, b *Raster) (*Raster, error) {
+	if a.Width != b.Width || a.Height != b.Height {
+		return nil, fmt.Errorf("band sizes differ: %dx%d vs %dx%d", a.Width, a.Height, b.Width, b.Height)
+	}
+	out := New(a.Width, a.Height, 1)
+	out.Geo, out.EPSG = a.Geo, a.EPSG
+	out.NoData, out.HasNoData = math.NaN(), true
+	nan := float32(math.NaN())
+	for i := range out.Bands[0] {
+		av, bv := a.Bands[0][i], b.Bands[0][i]
+		if a.IsNoData(av) || b.IsNoData(bv) || av+bv <= 0 {
+			out.Bands[0][i] = nan
+			continue
+		}
+		out.Bands[0][i] = (av - bv) / (av + bv)
+	}
+	return out, nil
+}
+
+// Band returns band i of r as a single-band raster sharing its samples.
+func (r *Raster) Band(i int) *Raster {
+	return &Raster{
+		Width: r.Width, Height: r.Height,
+		Bands: [][]float32{r.Bands[i]},
+		Geo:   r.Geo, EPSG: r.EPSG,
+		NoData: r.NoData, HasNoData: r.HasNoData,
+	}
+}
+
+// ComputeIndex loads the two bands of idx from dir and returns the index
+// as a single-band raster.
+func ComputeIndex(dir string, idx SpectralIndex, opts BandOptions) (*Raster, error) {
+	bands, err := LoadBands(dir, []string{idx.A, idx.B}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", idx.Name, err)
+	}
+	return NormalizedDifference(bands.Band(0), bands.Band(1))
+}
@@ -0,0 +1,133 @@
+package raster
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// writeBandFile writes a uniform single-band UTM GeoTIFF named <name>.tif
+// with its north-west corner at (500000, 4200000).
+func writeBandFile(t *testing.T, dir, name string, epsg, size int, res float64, v float32) *Raster {
+	t.Helper()
+	r := New(size, size, 1)
+	for i := range r.Bands[0] {
+		r.Bands[0][i] = v
+	}
+	r.Geo = GeoTransform{OriginX: 500000, OriginY: 4200000, PixelWidth: res, PixelHeight: res}
+	r.EPSG = epsg
+	if err := WriteGeoTIFFFile(filepath.Join(dir, name+".tif"), r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestLookupIndex(t *testing.T) {
+	idx, err := LookupIndex("NDWI")
+	if err != nil || idx.A != "B03" || idx.B != "B08" {
+		t.Errorf("NDWI: got %+v, %v", idx, err)
+	}
+	if _, err := LookupIndex("evi"); err == nil {
+		t.Error("evi: want an error")
+	}
+}
+
+func TestNormalizedDifference(t *testing.T) {
+	nan := float32(math.NaN())
+	a := grid(5, 1, 0.5, 0.2, 0, nan, -9)
+	b := grid(5, 1, 0.1, 0.2, 0, 0.3, 0.1)
+	a.NoData, a.HasNoData = -9, true
+	got, err := NormalizedDifference(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float32{0.4 / 0.6, 0, nan, nan, nan}
+	for i, w := range want {
+		v := got.Bands[0][i]
+		if math.IsNaN(float64(w)) != math.IsNaN(float64(v)) || math.Abs(float64(v-w)) > 1e-6 {
+			t.Errorf("pixel %d = %v, want %v", i, v, w)
+		}
+	}
+	if _, err := NormalizedDifference(a, grid(4, 1)); err == nil {
+		t.Error("different sizes: want an error")
+	}
+}
+
+func TestLoadBands(t *testing.T) {
+	dir := t.TempDir()
+	nir := writeBandFile(t, dir, "B08", 32633, 4, 10, 1500)
+	nir.Bands[0][0] = 0 // Sentinel-2 fill value
+	if err := WriteGeoTIFFFile(filepath.Join(dir, "B08.tif"), nir); err != nil {
+		t.Fatal(err)
+	}
+	writeBandFile(t, dir, "B04", 32633, 4, 10, 1100)
+	writeBandFile(t, dir, "B12", 32633, 2, 20, 1300)
+	writeBandFile(t, dir, "B11", 32634, 2, 20, 1300)
+
+	// The 20 m band is interpolated onto the 10 m grid.
+	r, err := LoadBands(dir, []string{"B08", "B12"}, BandOptions{Offset: -1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantGeo := GeoTransform{OriginX: 500000, OriginY: 4200000, PixelWidth: 10, PixelHeight: 10}
+	if r.Width != 4 || r.Height != 4 || r.Geo != wantGeo || r.EPSG != 32633 {
+		t.Fatalf("grid %dx%d %+v EPSG:%d, want 4x4 %+v EPSG:32633", r.Width, r.Height, r.Geo, r.EPSG, wantGeo)
+	}
+	if v := r.Bands[0][0]; !math.IsNaN(float64(v)) {
+		t.Errorf("fill pixel = %v, want NaN", v)
+	}
+	if r.Bands[0][5] != 500 || r.Bands[1][5] != 300 {
+		t.Errorf("offset samples = %v, %v, want 500, 300", r.Bands[0][5], r.Bands[1][5])
+	}
+
+	// At 20 m the 10 m band is averaged, skipping the fill pixel.
+	r, err = LoadBands(dir, []string{"B08", "B12"}, BandOptions{Resolution: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width != 2 || r.Height != 2 || r.Bands[0][0] != 1500 || r.Bands[1][3] != 1300 {
+		t.Errorf("20 m: %dx%d, samples %v, %v", r.Width, r.Height, r.Bands[0], r.Bands[1])
+	}
+
+	r, err = LoadBands(dir, []string{"B08", "B04"}, BandOptions{Window: Window{X: 2, Y: 1, Width: 5, Height: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantGeo = GeoTransform{OriginX: 500020, OriginY: 4199990, PixelWidth: 10, PixelHeight: 10}
+	if r.Width != 2 || r.Height != 2 || r.Geo != wantGeo {
+		t.Errorf("window: %dx%d %+v, want 2x2 %+v", r.Width, r.Height, r.Geo, wantGeo)
+	}
+
+	for _, c := range []struct {
+		name  string
+		bands []string
+		opts  BandOptions
+	}{
+		{"no bands", nil, BandOptions{}},
+		{"missing", []string{"B08", "B02"}, BandOptions{}},
+		{"mixed CRS", []string{"B08", "B11"}, BandOptions{}},
+		{"window outside", []string{"B08"}, BandOptions{Window: Window{X: 10, Y: 10, Width: 2, Height: 2}}},
+	} {
+		if _, err := LoadBands(dir, c.bands, c.opts); err == nil {
+			t.Errorf("%s: want an error", c.name)
+		}
+	}
+}
+
+func TestComputeIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeBandFile(t, dir, "B08", 32633, 4, 10, 0.5)
+	writeBandFile(t, dir, "B04", 32633, 4, 10, 0.1)
+	r, err := ComputeIndex(dir, Indices["ndvi"], BandOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range r.Bands[0] {
+		if math.Abs(float64(v)-0.4/0.6) > 1e-6 {
+			t.Fatalf("pixel %d = %v, want %v", i, v, 0.4/0.6)
+		}
+	}
+	if _, err := ComputeIndex(dir, Indices["nbr"], BandOptions{}); err == nil {
+		t.Error("NBR without B12: want an error")
+	}
+}
@@ -0,0 +1,94 @@
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+// bboxEdgeSamples is the number of points per bounding-box edge projected
+// when finding its pixel window; UTM bends lines of latitude.
+const bboxEdgeSamples = 8
+
+// Project converts a WGS84 point to coordinates in the given CRS. EPSG
+// 4326 and the WGS84 / UTM zones (326zz, 327zz) are supported.
+func Project(epsg int, p geo.Point) (x, y float64, err error) {
+	if epsg == EPSGWGS84 {
+		return p.Lon, p.Lat, nil
+	}
+	zone, north, ok := geo.UTMFromEPSG(epsg)
+	if !ok {
+		return 0, 0, fmt.Errorf("unsupported CRS EPSG:%d", epsg)
+	}
+	u := geo.ToUTMZone(p, zone)
+	// Keep the raster's false northing even for points across the equator.
+	switch {
+	case north && !u.North:
+		u.Northing -= 10000000
+	case !north && u.North:
+		u.Northing += 10000000
+	}
+	return u.Easting, u.Northing, nil
+}
+
+// Unproject converts CRS coordinates back to a WGS84 point.
+func Unproject(epsg int, x, y float64) (geo.Point, error) {
+	if epsg == EPSGWGS84 {
+		return geo.Point{Lat: y, Lon: x}, nil
+	}
+	zone, north, ok := geo.UTMFromEPSG(epsg)
+	if !ok {
+		return geo.Point{}, fmt.Errorf("unsupported CRS EPSG:%d", epsg)
+	}
+	return geo.UTM{Zone: zone, North: north, Easting: x, Northing: y}.Point(), nil
+}
+
+// PixelToPoint returns the WGS84 location of a (fractional) pixel position.
+func PixelToPoint(g GeoTransform, epsg int, col, row float64) (geo.Point, error) {
+	x, y := g.ToCRS(col, row)
+	return Unproject(epsg, x, y)
+}
+
+// BBoxWindow returns the pixel window of a width x height raster covering
+// a geographic box, clipped to the raster. The unclipped window is also
+// returned so callers can tell how much of the box the raster covers.
+func BBoxWindow(g GeoTransform, epsg, width, height int, b geo.BBox) (clipped, full Window, err error) {
+	if !g.Valid() {
+		return Window{}, Window{}, errors.New("raster is not georeferenced")
+	}
+	minC, minR := math.Inf(1), math.Inf(1)
+	maxC, maxR := math.Inf(-1), math.Inf(-1)
+	add := func(lat, lon float64) error {
+		x, y, err := Project(epsg, geo.Point{Lat: lat, Lon: lon})
+		if err != nil {
+			return err
+		}
+		c, r := g.ToPixel(x, y)
+		minC, maxC = math.Min(minC, c), math.Max(maxC, c)
+		minR, maxR = math.Min(minR, r), math.Max(maxR, r)
+		return nil
+	}
+	w := b.WidthDeg()
+	for i := 0; i <= bboxEdgeSamples; i++ {
+		f := float64(i) / bboxEdgeSamples
+		lon := b.West + f*w
+		lat := b.South + f*(b.North-b.South)
+		for _, pt := range [][2]float64{{b.South, lon}, {b.North, lon}, {lat, b.West}, {lat, b.West + w}} {
+			if err := add(pt[0], pt[1]); err != nil {
+				return Window{}, Window{}, err
+			}
+		}
+	}
+
+	full = Window{X: int(math.Floor(minC)), Y: int(math.Floor(minR))}
+	full.Width = int(math.Ceil(maxC)) - full.X
+	full.Height = int(math.Ceil(maxR)) - full.Y
+	return full.Intersect(width, height), full, nil
+}
+
+// BBoxWindow returns the window of the file covering b; see BBoxWindow.
+func (g *GeoTIFF) BBoxWindow(b geo.BBox) (clipped, full Window, err error) {
+	return BBoxWindow(g.Geo, g.EPSG, g.Width, g.Height, b)
+}
@@ -0,0 +1,541 @@
+package raster
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/tiff/lzw"
+)
+
+// TIFF tags used by the reader.
+const (
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagPlanarConfig    = 284
+	tagPredictor       = 317
+	tagTileWidth       = 322
+	tagTileLength      = 323
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+	tagSampleFormat    = 339
+	tagModelPixelScale = 33550
+	tagModelTiepoint   = 33922
+	tagGeoKeyDirectory = 34735
+	tagGDALNoData      = 42113
+)
+
+const (
+	compressionNone     = 1
+	compressionLZW      = 5
+	compressionDeflate  = 8
+	compressionPackBits = 32773
+	compressionDeflateA = 32946
+
+	sampleFormatUint  = 1
+	sampleFormatInt   = 2
+	sampleFormatFloat = 3
+
+	geoKeyGeographicType = 2048
+	geoKeyProjectedCS    = 3072
+)
+
+// GeoTIFF is an open TIFF or GeoTIFF file. Pixel data is decoded lazily,
+// one strip or tile at a time, so windows of large scenes can be read
+// without loading the whole image.
+type GeoTIFF struct {
+	r     io.ReaderAt
+	c     io.Closer
+	order binary.ByteOrder
+	big   bool
+
+	Width         int
+	Height        int
+	SamplesPerPx  int
+	BitsPerSample int
+	SampleFormat  int
+	Geo           GeoTransform
+	EPSG          int
+	NoData        float64
+	HasNoData     bool
+
+	planar      bool
+	compression int
+	predictor   int
+	chunkW      int
+	chunkH      int
+	offsets     []uint64
+	counts      []uint64
+}
+
+// Open opens a TIFF/GeoTIFF file from disk.
+func Open(path string) (*GeoTIFF, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	g, err := NewGeoTIFF(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	g.c = f
+	return g, nil
+}
+
+// NewGeoTIFF parses the first image directory of a TIFF read through r.
+func NewGeoTIFF(r io.ReaderAt) (*GeoTIFF, error) {
+	hdr := make([]byte, 16)
+	if _, err := r.ReadAt(hdr[:8], 0); err != nil {
+		return nil, fmt.Errorf("read TIFF header: %w", err)
+	}
+	g := &GeoTIFF{r: r, SamplesPerPx: 1, BitsPerSample: 8, SampleFormat: sampleFormatUint, compression: compressionNone, predictor: 1}
+	switch string(hdr[:2]) {
+	case "II":
+		g.order = binary.LittleEndian
+	case "MM":
+		g.order = binary.BigEndian
+	default:
+		return nil, errors.New("not a TIFF file")
+	}
+
+	var ifdOff uint64
+	switch g.order.Uint16(hdr[2:4]) {
+	case 42:
+		ifdOff = uint64(g.order.Uint32(hdr[4:8]))
+	case 43:
+		g.big = true
+		if _, err := r.ReadAt(hdr, 0); err != nil {
+			return nil, fmt.Errorf("read BigTIFF header: %w", err)
+		}
+		ifdOff = g.order.Uint64(hdr[8:16])
+	default:
+		return nil, errors.New("not a TIFF file")
+	}
+
+	entries, err := g.readIFD(ifdOff)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.parse(entries); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Close releases the underlying file, if any.
+func (g *GeoTIFF) Close() error {
+	if g.c != nil {
+		return g.c.Close()
+	}
+	return nil
+}
+
+// GSD returns the ground sample distance in meters, or 0 if the file is
+// not georeferenced.
+func (g *GeoTIFF) GSD() float64 {
+	return pixelSizeMeters(g.Geo, g.EPSG)
+}
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint64
+	data  []byte
+}
+
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 16: 8, 17: 8, 18: 8}
+
+func (g *GeoTIFF) readIFD(off uint64) (map[uint16]ifdEntry, error) {
+	countSize, entrySize, inline := 2, 12, 4
+	if g.big {
+		countSize, entrySize, inline = 8, 20, 8
+	}
+	buf := make([]byte, countSize)
+	if _, err := g.r.ReadAt(buf, int64(off)); err != nil {
+		return nil, fmt.Errorf("read IFD: %w", err)
+	}
+	var n uint64
+	if g.big {
+		n = g.order.Uint64(buf)
+	} else {
+		n = uint64(g.order.Uint16(buf))
+	}
+
+	raw := make([]byte, int(n)*entrySize)
+	if _, err := g.r.ReadAt(raw, int64(off)+int64(countSize)); err != nil {
+		return nil, fmt.Errorf("read IFD entries: %w", err)
+	}
+
+	entries := make(map[uint16]ifdEntry, n)
+	for i := 0; i < int(n); i++ {
+		e := raw[i*entrySize : (i+1)*entrySize]
+		ent := ifdEntry{tag: g.order.Uint16(e[0:2]), typ: g.order.Uint16(e[2:4])}
+		var valField []byte
+		if g.big {
+			ent.count = g.order.Uint64(e[4:12])
+			valField = e[12:20]
+		} else {
+			ent.count = uint64(g.order.Uint32(e[4:8]))
+			valField = e[8:12]
+		}
+		size, ok := typeSizes[ent.typ]
+		if !ok {
+			continue
+		}
+		n := int(ent.count) * size
+		if n <= inline {
+			ent.data = valField[:n]
+		} else {
+			var ptr uint64
+			if g.big {
+				ptr = g.order.Uint64(valField)
+			} else {
+				ptr = uint64(g.order.Uint32(valField))
+			}
+			ent.data = make([]byte, n)
+			if _, err := g.r.ReadAt(ent.data, int64(ptr)); err != nil {
+				return nil, fmt.Errorf("read tag %d: %w", ent.tag, err)
+			}
+		}
+		entries[ent.tag] = ent
+	}
+	return entries, nil
+}
+
+// uints decodes an integer-typed entry.
+func (g *GeoTIFF) uints(e ifdEntry) []uint64 {
+	out := make([]uint64, e.count)
+	for i := range out {
+		switch e.typ {
+		case 1, 6, 7:
+			out[i] = uint64(e.data[i])
+		case 3, 8:
+			out[i] = uint64(g.order.Uint16(e.data[i*2:]))
+		case 4, 9:
+			out[i] = uint64(g.order.Uint32(e.data[i*4:]))
+		case 16, 17, 18:
+			out[i] = g.order.Uint64(e.data[i*8:])
+		}
+	}
+	return out
+}
+
+func (g *GeoTIFF) floats(e ifdEntry) []float64 {
+	out := make([]float64, e.count)
+	for i := range out {
+		switch e.typ {
+		case 11:
+			out[i] = float64(math.Float32frombits(g.order.Uint32(e.data[i*4:])))
+		case 12:
+			out[i] = math.Float64frombits(g.order.Uint64(e.data[i*8:]))
+		}
+	}
+	return out
+}
+
+func (g *GeoTIFF) first(entries map[uint16]ifdEntry, tag uint16, def int) int {
+	e, ok := entries[tag]
+	if !ok || e.count == 0 {
+		return def
+	}
+	return int(g.uints(e)[0])
+}
+
+func (g *GeoTIFF) parse(entries map[uint16]ifdEntry) error {
+	g.Width = g.first(entries, tagImageWidth, 0)
+	g.Height = g.first(entries, tagImageLength, 0)
+	if g.Width <= 0 || g.Height <= 0 {
+		return errors.New("missing image dimensions")
+	}
+	g.SamplesPerPx = g.first(entries, tagSamplesPerPixel, 1)
+	g.BitsPerSample = g.first(entries, tagBitsPerSample, 1)
+	g.SampleFormat = g.first(entries, tagSampleFormat, sampleFormatUint)
+	g.compression = g.first(entries, tagCompression, compressionNone)
+	g.predictor = g.first(entries, tagPredictor, 1)
+	g.planar = g.first(entries, tagPlanarConfig, 1) == 2
+
+	switch g.compression {
+	case compressionNone, compressionLZW, compressionDeflate, compressionDeflateA, compressionPackBits:
+	default:
+		return fmt.Errorf("unsupported TIFF compression %d", g.compression)
+	}
+	switch g.BitsPerSample {
+	case 8, 16, 32, 64:
+	default:
+		return fmt.Errorf("unsupported bits per sample %d", g.BitsPerSample)
+	}
+	if g.predictor == 3 {
+		return errors.New("floating-point predictor is not supported")
+	}
+
+	if _, tiled := entries[tagTileWidth]; tiled {
+		g.chunkW = g.first(entries, tagTileWidth, 0)
+		g.chunkH = g.first(entries, tagTileLength, 0)
+		g.offsets = g.uints(entries[tagTileOffsets])
+		g.counts = g.uints(entries[tagTileByteCounts])
+	} else {
+		g.chunkW = g.Width
+		g.chunkH = g.first(entries, tagRowsPerStrip, g.Height)
+		if g.chunkH > g.Height {
+			g.chunkH = g.Height
+		}
+		g.offsets = g.uints(entries[tagStripOffsets])
+		g.counts = g.uints(entries[tagStripByteCounts])
+	}
+	if g.chunkW <= 0 || g.chunkH <= 0 || len(g.offsets) == 0 || len(g.offsets) != len(g.counts) {
+		return errors.New("invalid strip/tile layout")
+	}
+	want := g.chunksAcross() * g.chunksDown()
+	if g.planar {
+		want *= g.SamplesPerPx
+	}
+	if len(g.offsets) < want {
+		return fmt.Errorf("expected %d strips/tiles, found %d", want, len(g.offsets))
+	}
+
+	if e, ok := entries[tagModelPixelScale]; ok && e.count >= 2 {
+		s := g.floats(e)
+		g.Geo.PixelWidth, g.Geo.PixelHeight = s[0], s[1]
+	}
+	if e, ok := entries[tagModelTiepoint]; ok && e.count >= 6 {
+		t := g.floats(e)
+		// Tiepoint maps raster (I, J) to model (X, Y).
+		g.Geo.OriginX = t[3] - t[0]*g.Geo.PixelWidth
+		g.Geo.OriginY = t[4] + t[1]*g.Geo.PixelHeight
+	}
+	if e, ok := entries[tagGeoKeyDirectory]; ok && e.count >= 4 {
+		keys := g.uints(e)
+		for i := 4; i+3 < len(keys); i += 4 {
+			id, loc, val := keys[i], keys[i+1], keys[i+3]
+			if loc != 0 {
+				continue
+			}
+			switch id {
+			case geoKeyProjectedCS:
+				g.EPSG = int(val)
+			case geoKeyGeographicType:
+				if g.EPSG == 0 {
+					g.EPSG = int(val)
+				}
+			}
+		}
+	}
+	if e, ok := entries[tagGDALNoData]; ok {
+		s := strings.TrimRight(string(e.data), "\x00 ")
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			g.NoData, g.HasNoData = v, true
+		}
+	}
+	return nil
+}
+
+func (g *GeoTIFF) chunksAcross() int { return (g.Width + g.chunkW - 1) / g.chunkW }
+func (g *GeoTIFF) chunksDown() int   { return (g.Height + g.chunkH - 1) / g.chunkH }
+
+// ReadAll reads every band at full resolution.
+func (g *GeoTIFF) ReadAll() (*Raster, error) {
+	return g.Read(Window{Width: g.Width, Height: g.Height}, 1)
+}
+
+// Read reads a window of all bands, keeping every step-th pixel in each
+// direction. A step of 1 reads at full resolution.
+func (g *GeoTIFF) Read(win Window, step int) (*Raster, error) {
+	if step < 1 {
+		step = 1
+	}
+	win = win.Intersect(g.Width, g.Height)
+	if win.Empty() {
+		return nil, errors.New("window lies outside the image")
+	}
+	outW := (win.Width + step - 1) / step
+	outH := (win.Height + step - 1) / step
+	out := New(outW, outH, g.SamplesPerPx)
+	out.EPSG = g.EPSG
+	out.NoData, out.HasNoData = g.NoData, g.HasNoData
+	if g.Geo.Valid() {
+		x, y := g.Geo.ToCRS(float64(win.X), float64(win.Y))
+		out.Geo = GeoTransform{OriginX: x, OriginY: y, PixelWidth: g.Geo.PixelWidth * float64(step), PixelHeight: g.Geo.PixelHeight * float64(step)}
+	}
+
+	planes := 1
+	spp := g.SamplesPerPx
+	if g.planar {
+		planes, spp = g.SamplesPerPx, 1
+	}
+	per := g.chunksAcross() * g.chunksDown()
+
+	cx0, cx1 := win.X/g.chunkW, (win.X+win.Width-1)/g.chunkW
+	cy0, cy1 := win.Y/g.chunkH, (win.Y+win.Height-1)/g.chunkH
+	for plane := 0; plane < planes; plane++ {
+		for cy := cy0; cy <= cy1; cy++ {
+			for cx := cx0; cx <= cx1; cx++ {
+				idx := plane*per + cy*g.chunksAcross() + cx
+				samples, err := g.decodeChunk(idx, spp)
+				if err != nil {
+					return nil, err
+				}
+				g.copyChunk(out, samples, win, step, cx, cy, plane, spp)
+			}
+		}
+	}
+	return out, nil
+}
+
+// copyChunk copies the samples of one decoded chunk that fall on the
+// window's sampling grid into out.
+func (g *GeoTIFF) copyChunk(out *Raster, samples []float32, win Window, step, cx, cy, plane, spp int) {
+	x0, y0 := cx*g.chunkW, cy*g.chunkH
+	rows := len(samples) / (g.chunkW * spp)
+	for oy := 0; oy < out.Height; oy++ {
+		sy := win.Y + oy*step
+		if sy < y0 || sy >= y0+rows {
+			continue
+		}
+		for ox := 0; ox < out.Width; ox++ {
+			sx := win.X + ox*step
+			if sx < x0 || sx >= x0+g.chunkW {
+				continue
+			}
+			base := ((sy-y0)*g.chunkW + (sx - x0)) * spp
+			for s := 0; s < spp; s++ {
+				out.Bands[plane+s][oy*out.Width+ox] = samples[base+s]
+			}
+		}
+	}
+}
+
+// decodeChunk reads, decompresses and converts one strip or tile.
+func (g *GeoTIFF) decodeChunk(idx, spp int) ([]float32, error) {
+	raw := make([]byte, g.counts[idx])
+	if _, err := g.r.ReadAt(raw, int64(g.offsets[idx])); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read chunk %d: %w", idx, err)
+	}
+
+	var data []byte
+	var err error
+	switch g.compression {
+	case compressionNone:
+		data = raw
+	case compressionLZW:
+		data, err = io.ReadAll(lzw.NewReader(bytes.NewReader(raw), lzw.MSB, 8))
+	case compressionDeflate, compressionDeflateA:
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bytes.NewReader(raw)); err == nil {
+			data, err = io.ReadAll(zr)
+			zr.Close()
+		}
+	case compressionPackBits:
+		data, err = unpackBits(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decompress chunk %d: %w", idx, err)
+	}
+
+	bps := g.BitsPerSample / 8
+	rowLen := g.chunkW * spp
+	rows := len(data) / (rowLen * bps)
+	if rows > g.chunkH {
+		rows = g.chunkH
+	}
+	data = data[:rows*rowLen*bps]
+	if g.predictor == 2 {
+		g.undoHorizontalPredictor(data, rowLen, bps, spp)
+	}
+
+	out := make([]float32, rows*rowLen)
+	for i := range out {
+		out[i] = g.sample(data[i*bps:], bps)
+	}
+	return out, nil
+}
+
+func (g *GeoTIFF) sample(b []byte, bps int) float32 {
+	switch g.SampleFormat {
+	case sampleFormatFloat:
+		if bps == 4 {
+			return math.Float32frombits(g.order.Uint32(b))
+		}
+		return float32(math.Float64frombits(g.order.Uint64(b)))
+	case sampleFormatInt:
+		switch bps {
+		case 1:
+			return float32(int8(b[0]))
+		case 2:
+			return float32(int16(g.order.Uint16(b)))
+		case 4:
+			return float32(int32(g.order.Uint32(b)))
+		}
+		return float32(int64(g.order.Uint64(b)))
+	default:
+		switch bps {
+		case 1:
+			return float32(b[0])
+		case 2:
+			return float32(g.order.Uint16(b))
+		case 4:
+			return float32(g.order.Uint32(b))
+		}
+		return float32(g.order.Uint64(b))
+	}
+}
+
+func (g *GeoTIFF) undoHorizontalPredictor(data []byte, rowLen, bps, spp int) {
+	rows := len(data) / (rowLen * bps)
+	for r := 0; r < rows; r++ {
+		row := data[r*rowLen*bps : (r+1)*rowLen*bps]
+		for i := spp; i < rowLen; i++ {
+			switch bps {
+			case 1:
+				row[i] += row[i-spp]
+			case 2:
+				v := g.order.Uint16(row[i*2:]) + g.order.Uint16(row[(i-spp)*2:])
+				g.order.PutUint16(row[i*2:], v)
+			case 4:
+				v := g.order.Uint32(row[i*4:]) + g.order.Uint32(row[(i-spp)*4:])
+				g.order.PutUint32(row[i*4:], v)
+			case 8:
+				v := g.order.Uint64(row[i*8:]) + g.order.Uint64(row[(i-spp)*8:])
+				g.order.PutUint64(row[i*8:], v)
+			}
+		}
+	}
+}
+
+func unpackBits(src []byte) ([]byte, error) {
+	var dst []byte
+	for i := 0; i < len(src); {
+		n := int(int8(src[i]))
+		i++
+		switch {
+		case n >= 0:
+			if i+n+1 > len(src) {
+				return nil, errors.New("packbits: truncated literal run")
+			}
+			dst = append(dst, src[i:i+n+1]...)
+			i += n + 1
+		case n != -128:
+			if i >= len(src) {
+				return nil, errors.New("packbits: truncated repeat run")
+			}
+			for j := 0; j < 1-n; j++ {
+				dst = append(dst, src[i])
+			}
+			i++
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,92 @@
+package raster
+
+// Binary masks are row-major []bool grids of a raster's size.
+
+// Dilate grows a mask by r pixels in each direction (a square structuring
+// element), one separable pass per axis.
+func Dilate(mask []bool, w, h, r int) []bool {
+	if r <= 0 {
+		return append([]bool(nil), mask...)
+	}
+	pass := func(src []bool, n, lines int, at func(line, i int) int) []bool {
+		dst := make([]bool, len(src))
+		for l := 0; l < lines; l++ {
+			last := -2*r - 1 // position of the last set pixel seen
+			for i := 0; i < n+r; i++ {
+				if i < n && src[at(l, i)] {
+					last = i
+				}
+				if j := i - r; j >= 0 && i-last <= 2*r {
+					dst[at(l, j)] = true
+				}
+			}
+		}
+		return dst
+	}
+	rows := pass(mask, w, h, func(y, x int) int { return y*w + x })
+	return pass(rows, h, w, func(x, y int) int { return y*w + x })
+}
+
+// Erode shrinks a mask by r pixels in each direction. Pixels beyond the
+// edges count as set, so regions touching the border are not eaten away.
+func Erode(mask []bool, w, h, r int) []bool {
+	out := Dilate(invert(mask), w, h, r)
+	for i, v := range out {
+		out[i] = !v
+	}
+	return out
+}
+
+// Opening removes features narrower than 2r+1 pixels.
+func Opening(mask []bool, w, h, r int) []bool {
+	return Dilate(Erode(mask, w, h, r), w, h, r)
+}
+
+// Closing fills gaps narrower than 2r+1 pixels.
+func Closing(mask []bool, w, h, r int) []bool {
+	return Erode(Dilate(mask, w, h, r), w, h, r)
+}
+
+func invert(mask []bool) []bool {
+	out := make([]bool, len(mask))
+	for i, v := range mask {
+		out[i] = !v
+	}
+	return out
+}
+
+// Components returns the 8-connected groups of set pixels as lists of
+// pixel indices, in raster order of their first pixel.
+func Components(mask []bool, w, h int) [][]int {
+	seen := make([]bool, len(mask))
+	var out [][]int
+	var stack []int
+	for start, set := range mask {
+		if !set || seen[start] {
+			continue
+		}
+		seen[start] = true
+		comp := []int{}
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			comp = append(comp, i)
+			x, y := i%w, i/w
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					if j := ny*w + nx; mask[j] && !seen[j] {
+						seen[j] = true
+						stack = append(stack, j)
+					}
+				}
+			}
+		}
+		out = append(out, comp)
+	}
+	return out
+}
@@ -0,0 +1,235 @@
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+// mosaicControlStep is the spacing, in output pixels, of the control grid
+// through which output pixels are mapped into each source. Positions in
+// between are interpolated, which is exact for sources in the output CRS
+// and well under a pixel for neighbouring UTM zones.
+const mosaicControlStep = 16
+
+// MosaicOptions configures Mosaic.
+type MosaicOptions struct {
+	// BBox is the area to mosaic.
+	BBox geo.BBox
+	// EPSG is the output CRS; 0 uses the first source's.
+	EPSG int
+	// Resolution is the output pixel size in CRS units; 0 uses the first
+	// source's pixel size.
+	Resolution float64
+}
+
+// Mosaic merges georeferenced sources onto one north-up grid covering
+// opts.BBox, e.g. adjacent Sentinel-2 tiles that may lie in different UTM
+// zones. Sources are given in priority order: each output pixel takes the
+// first source with valid data there (nearest-neighbour sampling). A
+// source pixel is nodata when any band equals the file's nodata value, or
+// when all bands are 0 and the file declares none. All sources must have
+// the same number of bands. Pixels no source covers are NaN.
+func Mosaic(sources []*GeoTIFF, opts MosaicOptions) (*Raster, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("no sources to mosaic")
+	}
+	first := sources[0]
+	for i, s := range sources {
+		if !s.Geo.Valid() {
+			return nil, fmt.Errorf("source %d is not georeferenced", i)
+		}
+		if s.SamplesPerPx != first.SamplesPerPx {
+			return nil, fmt.Errorf("source %d has %d bands, source 0 has %d", i, s.SamplesPerPx, first.SamplesPerPx)
+		}
+	}
+	epsg := opts.EPSG
+	if epsg == 0 {
+		epsg = first.EPSG
+	}
+	res := opts.Resolution
+	if res == 0 {
+		res = first.Geo.PixelWidth
+		switch {
+		case epsg == EPSGWGS84 && first.EPSG != EPSGWGS84:
+			res /= metersPerDegree
+		case epsg != EPSGWGS84 && first.EPSG == EPSGWGS84:
+			res = pixelSizeMeters(first.Geo, first.EPSG)
+		}
+	}
+
+	grid, w, h, err := mosaicGrid(opts.BBox.Normalize(), epsg, res)
+	if err != nil {
+		return nil, err
+	}
+	return mosaicOnto(sources, opts.BBox.Normalize(), grid, epsg, w, h)
+}
+
+// MosaicGrid is Mosaic onto a given grid, e.g. that of another raster, so
+// that the result lines up with it pixel for pixel.
+func MosaicGrid(sources []*GeoTIFF, g GeoTransform, epsg, width, height int) (*Raster, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("no sources to mosaic")
+	}
+	for i, s := range sources {
+		if !s.Geo.Valid() {
+			return nil, fmt.Errorf("source %d is not georeferenced", i)
+		}
+		if s.SamplesPerPx != sources[0].SamplesPerPx {
+			return nil, fmt.Errorf("source %d has %d bands, source 0 has %d", i, s.SamplesPerPx, sources[0].SamplesPerPx)
+		}
+	}
+	if !g.Valid() || width <= 0 || height <= 0 {
+		return nil, errors.New("mosaic grid is empty")
+	}
+
+	// The grid's geographic extent, from points along its edges.
+	var b geo.BBox
+	first := true
+	for i := 0; i <= bboxEdgeSamples; i++ {
+		f := float64(i) / bboxEdgeSamples
+		for _, px := range [][2]float64{{f * float64(width), 0}, {f * float64(width), float64(height)}, {0, f * float64(height)}, {float64(width), f * float64(height)}} {
+			p, err := PixelToPoint(g, epsg, px[0], px[1])
+			if err != nil {
+				return nil, err
+			}
+			pb := geo.BBox{West: p.Lon, South: p.Lat, East: p.Lon, North: p.Lat}
+			if first {
+				b, first = pb, false
+			} else {
+				b = b.Union(pb)
+			}
+		}
+	}
+	return mosaicOnto(sources, b, g, epsg, width, height)
+}
+
+// mosaicOnto fills a new raster on the given grid from the sources' parts
+// within area.
+func mosaicOnto(sources []*GeoTIFF, area geo.BBox, grid GeoTransform, epsg, w, h int) (*Raster, error) {
+	out := New(w, h, sources[0].SamplesPerPx)
+	out.Geo, out.EPSG = grid, epsg
+	out.NoData, out.HasNoData = math.NaN(), true
+	nan := float32(math.NaN())
+	for _, b := range out.Bands {
+		for i := range b {
+			b[i] = nan
+		}
+	}
+	filled := make([]bool, w*h)
+
+	for i, s := range sources {
+		win, _, err := s.BBoxWindow(area)
+		if err != nil {
+			return nil, fmt.Errorf("source %d: %w", i, err)
+		}
+		if win.Empty() {
+			continue
+		}
+		src, err := s.Read(win, 1)
+		if err != nil {
+			return nil, fmt.Errorf("read source %d: %w", i, err)
+		}
+		if err := mosaicSource(out, filled, src, win, s.Geo, s.EPSG); err != nil {
+			return nil, fmt.Errorf("source %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// mosaicGrid returns the output grid covering b in the given CRS, snapped
+// outward to whole multiples of res.
+func mosaicGrid(b geo.BBox, epsg int, res float64) (GeoTransform, int, int, error) {
+	if res <= 0 {
+		return GeoTransform{}, 0, 0, errors.New("resolution must be positive")
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	w := b.WidthDeg()
+	for i := 0; i <= bboxEdgeSamples; i++ {
+		f := float64(i) / bboxEdgeSamples
+		lon := b.West + f*w
+		lat := b.South + f*(b.North-b.South)
+		for _, pt := range [][2]float64{{b.South, lon}, {b.North, lon}, {lat, b.West}, {lat, b.West + w}} {
+			x, y, err := Project(epsg, geo.Point{Lat: pt[0], Lon: pt[1]})
+			if err != nil {
+				return GeoTransform{}, 0, 0, err
+			}
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+	minX, maxX = math.Floor(minX/res)*res, math.Ceil(maxX/res)*res
+	minY, maxY = math.Floor(minY/res)*res, math.Ceil(maxY/res)*res
+	g := GeoTransform{OriginX: minX, OriginY: maxY, PixelWidth: res, PixelHeight: res}
+	width, height := int(math.Round((maxX-minX)/res)), int(math.Round((maxY-minY)/res))
+	if width <= 0 || height <= 0 {
+		return GeoTransform{}, 0, 0, errors.New("mosaic area is empty")
+	}
+	return g, width, height, nil
+}
+
+// mosaicSource fills the still-empty pixels of out from src, a window of
+// a file with transform g in CRS epsg.
+func mosaicSource(out *Raster, filled []bool, src *Raster, win Window, g GeoTransform, epsg int) error {
+	// Source pixel positions at the control grid nodes.
+	nx := (out.Width+mosaicControlStep-1)/mosaicControlStep + 1
+	ny := (out.Height+mosaicControlStep-1)/mosaicControlStep + 1
+	nodes := make([][2]float64, nx*ny)
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			x, y := out.Geo.ToCRS(float64(i*mosaicControlStep)+0.5, float64(j*mosaicControlStep)+0.5)
+			if epsg != out.EPSG {
+				p, err := Unproject(out.EPSG, x, y)
+				if err != nil {
+					return err
+				}
+				if x, y, err = Project(epsg, p); err != nil {
+					return err
+				}
+			}
+			c, r := g.ToPixel(x, y)
+			nodes[j*nx+i] = [2]float64{c - float64(win.X), r - float64(win.Y)}
+		}
+	}
+
+	bands := len(src.Bands)
+	for row := 0; row < out.Height; row++ {
+		j, fy := row/mosaicControlStep, float64(row%mosaicControlStep)/mosaicControlStep
+		for col := 0; col < out.Width; col++ {
+			o := row*out.Width + col
+			if filled[o] {
+				continue
+			}
+			i, fx := col/mosaicControlStep, float64(col%mosaicControlStep)/mosaicControlStep
+			n00, n10 := nodes[j*nx+i], nodes[j*nx+i+1]
+			n01, n11 := nodes[(j+1)*nx+i], nodes[(j+1)*nx+i+1]
+			sc := (n00[0]*(1-fx)+n10[0]*fx)*(1-fy) + (n01[0]*(1-fx)+n11[0]*fx)*fy
+			sr := (n00[1]*(1-fx)+n10[1]*fx)*(1-fy) + (n01[1]*(1-fx)+n11[1]*fx)*fy
+			x, y := int(math.Floor(sc)), int(math.Floor(sr))
+			if x < 0 || y < 0 || x >= src.Width || y >= src.Height {
+				continue
+			}
+			s := y*src.Width + x
+			valid, zero := true, true
+			for b := 0; b < bands; b++ {
+				v := src.Bands[b][s]
+				if src.IsNoData(v) {
+					valid = false
+					break
+				}
+				zero = zero && v == 0
+			}
+			if !valid || (zero && !src.HasNoData) {
+				continue
+			}
+			for b := 0; b < bands; b++ {
+				out.Bands[b][o] = src.Bands[b][s]
+			}
+			filled[o] = true
+		}
+	}
+	return nil
+}
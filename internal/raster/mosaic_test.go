@@ -0,0 +1,146 @@
+package raster
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+// openRaster writes r to a temporary GeoTIFF and opens it.
+func openRaster(t *testing.T, r *Raster) *GeoTIFF {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "src.tif")
+	if err := WriteGeoTIFFFile(path, r); err != nil {
+		t.Fatal(err)
+	}
+	g, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+	return g
+}
+
+// wgsTile returns a uniform EPSG:4326 raster of 1/64 degree pixels with
+// its north-west corner at (west, north).
+func wgsTile(west, north float64, width, height, bands int, v float32) *Raster {
+	r := New(width, height, bands)
+	for _, b := range r.Bands {
+		for i := range b {
+			b[i] = v
+		}
+	}
+	r.Geo = GeoTransform{OriginX: west, OriginY: north, PixelWidth: 1.0 / 64, PixelHeight: 1.0 / 64}
+	r.EPSG = EPSGWGS84
+	return r
+}
+
+func TestMosaicPriority(t *testing.T) {
+	// a covers 10-10.5 E, b 10.25-11 E; both 40-40.5 N. a has a hole of
+	// fill values (0, no nodata declared) at the top left and takes
+	// priority elsewhere.
+	a := wgsTile(10, 40.5, 32, 32, 2, 1)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			a.Bands[0][y*32+x], a.Bands[1][y*32+x] = 0, 0
+		}
+	}
+	a.Bands[0][10*32+20], a.Bands[1][10*32+20] = 0, 0 // hole over b
+	b := wgsTile(10.25, 40.5, 48, 32, 2, 2)
+
+	bbox := geo.BBox{West: 10, South: 40, East: 11, North: 40.5}
+	out, err := Mosaic([]*GeoTIFF{openRaster(t, a), openRaster(t, b)}, MosaicOptions{BBox: bbox})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantGeo := GeoTransform{OriginX: 10, OriginY: 40.5, PixelWidth: 1.0 / 64, PixelHeight: 1.0 / 64}
+	if out.Width != 64 || out.Height != 32 || out.Geo != wantGeo || out.EPSG != EPSGWGS84 || len(out.Bands) != 2 {
+		t.Fatalf("grid %dx%dx%d %+v EPSG:%d", out.Width, out.Height, len(out.Bands), out.Geo, out.EPSG)
+	}
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			var want float32
+			switch {
+			case x < 4 && y < 4:
+				want = float32(math.NaN()) // hole in a, outside b
+			case x < 32 && !(x == 20 && y == 10):
+				want = 1
+			default:
+				want = 2
+			}
+			for band := range out.Bands {
+				v := out.Bands[band][y*64+x]
+				if v != want && !(math.IsNaN(float64(v)) && math.IsNaN(float64(want))) {
+					t.Fatalf("band %d pixel (%d, %d) = %v, want %v", band, x, y, v, want)
+				}
+			}
+		}
+	}
+
+	// The same sources onto the mosaic's own grid give the same result.
+	again, err := MosaicGrid([]*GeoTIFF{openRaster(t, a), openRaster(t, b)}, out.Geo, out.EPSG, out.Width, out.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range out.Bands[0] {
+		if w := again.Bands[0][i]; v != w && !(math.IsNaN(float64(v)) && math.IsNaN(float64(w))) {
+			t.Fatalf("MosaicGrid pixel %d = %v, Mosaic %v", i, w, v)
+		}
+	}
+}
+
+func TestMosaicAcrossCRS(t *testing.T) {
+	// A UTM zone 32N source whose samples are their column index, mosaicked
+	// onto a WGS84 grid; each output pixel should hold the column its
+	// center projects into.
+	src := New(200, 200, 1)
+	src.Geo = GeoTransform{OriginX: 600000, OriginY: 4500000, PixelWidth: 60, PixelHeight: 60}
+	src.EPSG = 32632
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			src.Bands[0][y*200+x] = float32(x + 1)
+		}
+	}
+	nw, _ := Unproject(32632, 602000, 4498000)
+	se, _ := Unproject(32632, 610000, 4490000)
+	bbox := geo.BBox{West: nw.Lon, North: nw.Lat, East: se.Lon, South: se.Lat}
+	out, err := Mosaic([]*GeoTIFF{openRaster(t, src)}, MosaicOptions{BBox: bbox, EPSG: EPSGWGS84})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 60 / metersPerDegree; math.Abs(out.Geo.PixelWidth-want) > 1e-12 {
+		t.Errorf("pixel size %v, want %v", out.Geo.PixelWidth, want)
+	}
+	var off int
+	for y := 0; y < out.Height; y++ {
+		for x := 0; x < out.Width; x++ {
+			v := out.Bands[0][y*out.Width+x]
+			lon, lat := out.Geo.ToCRS(float64(x)+0.5, float64(y)+0.5)
+			e, n, _ := Project(32632, geo.Point{Lat: lat, Lon: lon})
+			col, _ := src.Geo.ToPixel(e, n)
+			if want := math.Floor(col) + 1; math.Abs(float64(v)-want) > 1 {
+				off++
+			}
+		}
+	}
+	if off > 0 {
+		t.Errorf("%d of %d pixels sampled more than a pixel off", off, out.Width*out.Height)
+	}
+}
+
+func TestMosaicErrors(t *testing.T) {
+	one := openRaster(t, wgsTile(10, 40.5, 4, 4, 1, 1))
+	two := openRaster(t, wgsTile(10, 40.5, 4, 4, 2, 1))
+	bbox := geo.BBox{West: 10, South: 40, East: 11, North: 40.5}
+	if _, err := Mosaic(nil, MosaicOptions{BBox: bbox}); err == nil {
+		t.Error("no sources: want an error")
+	}
+	if _, err := Mosaic([]*GeoTIFF{one, two}, MosaicOptions{BBox: bbox}); err == nil {
+		t.Error("different band counts: want an error")
+	}
+	if _, err := MosaicGrid([]*GeoTIFF{one}, GeoTransform{}, EPSGWGS84, 4, 4); err == nil {
+		t.Error("empty grid: want an error")
+	}
+}
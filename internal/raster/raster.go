@@ -0,0 +1,190 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	EPSGWGS84 = 4326
+
+	metersPerDegree = 111320.0
+)
+
+// GeoTransform maps pixel coordinates of a north-up raster to CRS coordinates.
+// X = OriginX + col*PixelWidth, Y = OriginY - row*PixelHeight.
+type GeoTransform struct {
+	OriginX     float64 `json:"origin_x"`
+	OriginY     float64 `json:"origin_y"`
+	PixelWidth  float64 `json:"pixel_width"`
+	PixelHeight float64 `json:"pixel_height"`
+}
+
+// Valid reports whether the transform carries a usable pixel size.
+func (g GeoTransform) Valid() bool {
+	return g.PixelWidth > 0 && g.PixelHeight > 0
+}
+
+// ToCRS returns the CRS coordinates of a (fractional) pixel position.
+func (g GeoTransform) ToCRS(col, row float64) (x, y float64) {
+	return g.OriginX + col*g.PixelWidth, g.OriginY - row*g.PixelHeight
+}
+
+// ToPixel returns the (fractional) pixel position of CRS coordinates.
+func (g GeoTransform) ToPixel(x, y float64) (col, row float64) {
+	return (x - g.OriginX) / g.PixelWidth, (g.OriginY - y) / g.PixelHeight
+}
+
+// Window is a pixel rectangle within a raster.
+type Window struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Intersect clips the window to a width x height raster.
+func (w Window) Intersect(width, height int) Window {
+	r := image.Rect(w.X, w.Y, w.X+w.Width, w.Y+w.Height).Intersect(image.Rect(0, 0, width, height))
+	return Window{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+}
+
+// Empty reports whether the window covers no pixels.
+func (w Window) Empty() bool {
+	return w.Width <= 0 || w.Height <= 0
+}
+
+// Raster is an in-memory multi-band grid of samples.
+type Raster struct {
+	Width     int
+	Height    int
+	Bands     [][]float32
+	Geo       GeoTransform
+	EPSG      int
+	NoData    float64
+	HasNoData bool
+}
+
+// New allocates a zeroed raster.
+func New(width, height, bands int) *Raster {
+	r := &Raster{Width: width, Height: height, Bands: make([][]float32, bands)}
+	for i := range r.Bands {
+		r.Bands[i] = make([]float32, width*height)
+	}
+	return r
+}
+
+// At returns the sample of band b at (x, y).
+func (r *Raster) At(b, x, y int) float32 {
+	return r.Bands[b][y*r.Width+x]
+}
+
+// IsNoData reports whether v equals the raster's nodata value.
+func (r *Raster) IsNoData(v float32) bool {
+	if math.IsNaN(float64(v)) {
+		return true
+	}
+	return r.HasNoData && float64(v) == r.NoData
+}
+
+// GSD returns the ground sample distance in meters, or 0 if unknown.
+func (r *Raster) GSD() float64 {
+	return pixelSizeMeters(r.Geo, r.EPSG)
+}
+
+func pixelSizeMeters(g GeoTransform, epsg int) float64 {
+	if !g.Valid() {
+		return 0
+	}
+	if epsg == EPSGWGS84 {
+		lat := g.OriginY * math.Pi / 180
+		return g.PixelHeight * metersPerDegree * math.Max(math.Cos(lat), 0.01)
+	}
+	return (g.PixelWidth + g.PixelHeight) / 2
+}
+
+// Image converts the raster to an 8-bit image. One band is rendered as
+// grayscale, three or more as RGB from the first three bands. Samples are
+// used as-is when they already fit in 0–255, otherwise linearly rescaled
+// from the data range.
+func (r *Raster) Image() image.Image {
+	lo, hi := r.valueRange()
+	scale := float32(1)
+	if lo < 0 || hi > 255 {
+		scale = 255 / (hi - lo)
+	} else {
+		lo = 0
+	}
+	conv := func(v float32) uint8 {
+		v = (v - lo) * scale
+		if v < 0 {
+			return 0
+		}
+		if v > 255 {
+			return 255
+		}
+		return uint8(v + 0.5)
+	}
+
+	rect := image.Rect(0, 0, r.Width, r.Height)
+	if len(r.Bands) < 3 {
+		img := image.NewGray(rect)
+		for i, v := range r.Bands[0] {
+			img.Pix[i] = conv(v)
+		}
+		return img
+	}
+	img := image.NewRGBA(rect)
+	for i := 0; i < r.Width*r.Height; i++ {
+		img.Pix[i*4+0] = conv(r.Bands[0][i])
+		img.Pix[i*4+1] = conv(r.Bands[1][i])
+		img.Pix[i*4+2] = conv(r.Bands[2][i])
+		img.Pix[i*4+3] = 255
+	}
+	return img
+}
+
+func (r *Raster) valueRange() (lo, hi float32) {
+	lo, hi = float32(math.Inf(1)), float32(math.Inf(-1))
+	n := len(r.Bands)
+	if n > 3 {
+		n = 3
+	}
+	for _, band := range r.Bands[:n] {
+		for _, v := range band {
+			if r.IsNoData(v) {
+				continue
+			}
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+	}
+	if lo > hi {
+		return 0, 255
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
+
+// FromImage converts a decoded image to a 3-band RGB raster.
+func FromImage(img image.Image) *Raster {
+	b := img.Bounds()
+	r := New(b.Dx(), b.Dy(), 3)
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width; x++ {
+			c := color.RGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+			i := y*r.Width + x
+			r.Bands[0][i] = float32(c.R)
+			r.Bands[1][i] = float32(c.G)
+			r.Bands[2][i] = float32(c.B)
+		}
+	}
+	return r
+}
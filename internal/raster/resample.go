@@ -0,0 +1,125 @@
+package raster
+
+import "math"
+
+// Resampling selects how samples are interpolated onto a new grid.
+type Resampling int
+
+const (
+	Nearest  Resampling = iota // nearest neighbor
+	Bilinear                   // bilinear interpolation, for upsampling
+	Average                    // mean of covered pixels, for downsampling
+)
+
+// Resample returns r resampled to width x height pixels over the same
+// extent. Nodata samples are skipped when interpolating; output pixels
+// with no valid input are set to the nodata value, or NaN if r has none.
+func Resample(r *Raster, width, height int, m Resampling) *Raster {
+	out := New(width, height, len(r.Bands))
+	out.EPSG = r.EPSG
+	out.NoData, out.HasNoData = r.NoData, r.HasNoData
+	sx := float64(r.Width) / float64(width)
+	sy := float64(r.Height) / float64(height)
+	if r.Geo.Valid() {
+		out.Geo = r.Geo
+		out.Geo.PixelWidth *= sx
+		out.Geo.PixelHeight *= sy
+	}
+	for b := range r.Bands {
+		resampleBand(out.Bands[b], width, height, r, b, 0, 0, sx, sy, m)
+	}
+	return out
+}
+
+// resampleBand fills dst (dstW x dstH) from band b of src. Destination
+// pixel (dx, dy) covers the source area starting at (x0+dx*sx, y0+dy*sy),
+// in source pixel units where pixel i spans [i, i+1).
+func resampleBand(dst []float32, dstW, dstH int, src *Raster, b int, x0, y0, sx, sy float64, m Resampling) {
+	fill := float32(math.NaN())
+	if src.HasNoData {
+		fill = float32(src.NoData)
+	}
+	band := src.Bands[b]
+	valid := func(x, y int) (float32, bool) {
+		if x < 0 || y < 0 || x >= src.Width || y >= src.Height {
+			return 0, false
+		}
+		v := band[y*src.Width+x]
+		return v, !src.IsNoData(v)
+	}
+
+	for dy := 0; dy < dstH; dy++ {
+		for dx := 0; dx < dstW; dx++ {
+			v, ok := float32(0), false
+			switch m {
+			case Nearest:
+				v, ok = valid(int(math.Floor(x0+(float64(dx)+0.5)*sx)), int(math.Floor(y0+(float64(dy)+0.5)*sy)))
+			case Bilinear:
+				v, ok = bilinear(valid, x0+(float64(dx)+0.5)*sx-0.5, y0+(float64(dy)+0.5)*sy-0.5)
+			case Average:
+				v, ok = boxMean(valid, x0+float64(dx)*sx, y0+float64(dy)*sy, sx, sy)
+			}
+			if !ok {
+				v = fill
+			}
+			dst[dy*dstW+dx] = v
+		}
+	}
+}
+
+// bilinear interpolates at continuous pixel-center coordinates (fx, fy),
+// clamping at the edges and falling back to the nearest valid neighbor
+// when any of the four is nodata.
+func bilinear(valid func(x, y int) (float32, bool), fx, fy float64) (float32, bool) {
+	x, y := int(math.Floor(fx)), int(math.Floor(fy))
+	tx, ty := float32(fx-float64(x)), float32(fy-float64(y))
+	v00, ok00 := valid(x, y)
+	v10, ok10 := valid(x+1, y)
+	v01, ok01 := valid(x, y+1)
+	v11, ok11 := valid(x+1, y+1)
+	if ok00 && ok10 && ok01 && ok11 {
+		top := v00 + (v10-v00)*tx
+		bottom := v01 + (v11-v01)*tx
+		return top + (bottom-top)*ty, true
+	}
+
+	// Edge or nodata neighborhood: take the closest valid corner.
+	best, found := float32(0), false
+	bestD := float32(math.MaxFloat32)
+	for _, c := range []struct {
+		v      float32
+		ok     bool
+		dx, dy float32
+	}{{v00, ok00, tx, ty}, {v10, ok10, 1 - tx, ty}, {v01, ok01, tx, 1 - ty}, {v11, ok11, 1 - tx, 1 - ty}} {
+		if d := c.dx*c.dx + c.dy*c.dy; c.ok && d < bestD {
+			best, bestD, found = c.v, d, true
+		}
+	}
+	return best, found
+}
+
+// boxMean averages the valid pixels overlapping [x, x+w) x [y, y+h).
+func boxMean(valid func(x, y int) (float32, bool), x, y, w, h float64) (float32, bool) {
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	x1, y1 := int(math.Ceil(x+w)), int(math.Ceil(y+h))
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	var sum float64
+	var n int
+	for yy := y0; yy < y1; yy++ {
+		for xx := x0; xx < x1; xx++ {
+			if v, ok := valid(xx, yy); ok {
+				sum += float64(v)
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return float32(sum / float64(n)), true
+}
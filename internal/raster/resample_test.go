@@ -0,0 +1,78 @@
+package raster
+
+import (
+	"math"
+	"testing"
+)
+
+func grid(width, height int, vals ...float32) *Raster {
+	r := New(width, height, 1)
+	copy(r.Bands[0], vals)
+	return r
+}
+
+func TestResample(t *testing.T) {
+	src := grid(2, 2,
+		0, 10,
+		20, 30)
+	holed := grid(4, 2,
+		1, 3, -1, -1,
+		5, 7, -1, 9)
+	holed.NoData, holed.HasNoData = -1, true
+
+	for _, c := range []struct {
+		name string
+		r    *Raster
+		w, h int
+		m    Resampling
+		want []float32
+	}{
+		{"nearest up", src, 4, 4, Nearest, []float32{
+			0, 0, 10, 10,
+			0, 0, 10, 10,
+			20, 20, 30, 30,
+			20, 20, 30, 30}},
+		// Edge pixels clamp to the nearest source pixel center.
+		{"bilinear up", src, 4, 4, Bilinear, []float32{
+			0, 0, 10, 10,
+			0, 7.5, 12.5, 10,
+			20, 17.5, 22.5, 30,
+			20, 20, 30, 30}},
+		{"bilinear identity", src, 2, 2, Bilinear, []float32{0, 10, 20, 30}},
+		// Nodata pixels are left out of the mean; none valid gives nodata.
+		{"average down", holed, 2, 1, Average, []float32{4, 9}},
+		{"average all", src, 1, 1, Average, []float32{15}},
+	} {
+		got := Resample(c.r, c.w, c.h, c.m)
+		if got.Width != c.w || got.Height != c.h {
+			t.Errorf("%s: got %dx%d", c.name, got.Width, got.Height)
+			continue
+		}
+		for i, want := range c.want {
+			if v := got.Bands[0][i]; math.Abs(float64(v-want)) > 1e-5 {
+				t.Errorf("%s: pixel %d = %v, want %v (all %v)", c.name, i, v, want, got.Bands[0])
+				break
+			}
+		}
+	}
+
+	empty := grid(2, 1, -1, -1)
+	empty.NoData, empty.HasNoData = -1, true
+	if v := Resample(empty, 1, 1, Average).Bands[0][0]; v != -1 {
+		t.Errorf("all nodata: got %v, want the nodata value", v)
+	}
+	if v := Resample(grid(2, 1, float32(math.NaN()), float32(math.NaN())), 1, 1, Average).Bands[0][0]; !math.IsNaN(float64(v)) {
+		t.Errorf("all NaN without nodata: got %v, want NaN", v)
+	}
+}
+
+func TestResampleGeoTransform(t *testing.T) {
+	r := New(100, 50, 1)
+	r.Geo = GeoTransform{OriginX: 300000, OriginY: 5000000, PixelWidth: 10, PixelHeight: 10}
+	r.EPSG = 32631
+	got := Resample(r, 50, 10, Average)
+	want := GeoTransform{OriginX: 300000, OriginY: 5000000, PixelWidth: 20, PixelHeight: 50}
+	if got.Geo != want || got.EPSG != r.EPSG {
+		t.Errorf("got %+v EPSG:%d, want %+v EPSG:%d", got.Geo, got.EPSG, want, r.EPSG)
+	}
+}
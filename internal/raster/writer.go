@@ -0,0 +1,230 @@
+package raster
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Tags and GeoKeys only needed by the writer.
+const (
+	tagPhotometric = 262
+
+	geoKeyModelType  = 1024
+	geoKeyRasterType = 1025
+
+	modelTypeProjected  = 1
+	modelTypeGeographic = 2
+	rasterPixelIsArea   = 1
+)
+
+// TIFF field types.
+const (
+	tiffASCII  = 2
+	tiffShort  = 3
+	tiffLong   = 4
+	tiffDouble = 12
+)
+
+// writeStripBytes is the target uncompressed strip size.
+const writeStripBytes = 64 << 10
+
+type tiffField struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+// WriteGeoTIFF writes r as a little-endian, deflate-compressed 32-bit
+// float GeoTIFF with one plane per band. The geotransform, EPSG code and
+// nodata value are recorded so the file round-trips through Open and loads
+// in GIS tools.
+func WriteGeoTIFF(w io.Writer, r *Raster) error {
+	if r.Width <= 0 || r.Height <= 0 || len(r.Bands) == 0 {
+		return errors.New("empty raster")
+	}
+	le := binary.LittleEndian
+
+	rowsPerStrip := max(1, writeStripBytes/(r.Width*4))
+	if rowsPerStrip > r.Height {
+		rowsPerStrip = r.Height
+	}
+	stripsPerBand := (r.Height + rowsPerStrip - 1) / rowsPerStrip
+
+	var strips [][]byte
+	row := make([]byte, r.Width*4)
+	for _, band := range r.Bands {
+		for s := 0; s < stripsPerBand; s++ {
+			var buf bytes.Buffer
+			zw := zlib.NewWriter(&buf)
+			for y := s * rowsPerStrip; y < min((s+1)*rowsPerStrip, r.Height); y++ {
+				for x, v := range band[y*r.Width : (y+1)*r.Width] {
+					le.PutUint32(row[x*4:], math.Float32bits(v))
+				}
+				zw.Write(row)
+			}
+			if err := zw.Close(); err != nil {
+				return fmt.Errorf("compress strip: %w", err)
+			}
+			strips = append(strips, buf.Bytes())
+		}
+	}
+
+	// Layout: header, strips, IFD, out-of-line field data.
+	offset := uint64(8)
+	offsets := make([]uint32, len(strips))
+	counts := make([]uint32, len(strips))
+	for i, s := range strips {
+		offsets[i], counts[i] = uint32(offset), uint32(len(s))
+		offset += uint64(len(s))
+	}
+	offset += offset & 1
+	if offset > math.MaxUint32 {
+		return errors.New("raster too large for classic TIFF")
+	}
+
+	bands := len(r.Bands)
+	fields := []tiffField{
+		longField(tagImageWidth, uint32(r.Width)),
+		longField(tagImageLength, uint32(r.Height)),
+		shortField(tagBitsPerSample, repeat16(32, bands)...),
+		shortField(tagCompression, compressionDeflate),
+		shortField(tagPhotometric, 1), // BlackIsZero
+		longField(tagStripOffsets, offsets...),
+		shortField(tagSamplesPerPixel, uint16(bands)),
+		longField(tagRowsPerStrip, uint32(rowsPerStrip)),
+		longField(tagStripByteCounts, counts...),
+		shortField(tagPlanarConfig, 2),
+		shortField(tagSampleFormat, repeat16(sampleFormatFloat, bands)...),
+	}
+	if r.Geo.Valid() {
+		fields = append(fields,
+			doubleField(tagModelPixelScale, r.Geo.PixelWidth, r.Geo.PixelHeight, 0),
+			doubleField(tagModelTiepoint, 0, 0, 0, r.Geo.OriginX, r.Geo.OriginY, 0),
+		)
+	}
+	if r.EPSG != 0 {
+		modelType, csKey := uint16(modelTypeProjected), uint16(geoKeyProjectedCS)
+		if r.EPSG == EPSGWGS84 {
+			modelType, csKey = modelTypeGeographic, geoKeyGeographicType
+		}
+		fields = append(fields, shortField(tagGeoKeyDirectory,
+			1, 1, 0, 3,
+			geoKeyModelType, 0, 1, modelType,
+			geoKeyRasterType, 0, 1, rasterPixelIsArea,
+			csKey, 0, 1, uint16(r.EPSG),
+		))
+	}
+	if r.HasNoData {
+		s := strconv.FormatFloat(r.NoData, 'g', -1, 64)
+		if math.IsNaN(r.NoData) {
+			s = "nan"
+		}
+		fields = append(fields, tiffField{tag: tagGDALNoData, typ: tiffASCII, count: uint32(len(s) + 1), data: append([]byte(s), 0)})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].tag < fields[j].tag })
+
+	ifdOff := offset
+	extra := ifdOff + 2 + 12*uint64(len(fields)) + 4
+
+	bw := bufio.NewWriter(w)
+	hdr := make([]byte, 8)
+	copy(hdr, "II")
+	le.PutUint16(hdr[2:], 42)
+	le.PutUint32(hdr[4:], uint32(ifdOff))
+	bw.Write(hdr)
+	for _, s := range strips {
+		bw.Write(s)
+	}
+	if sumLen(strips)%2 == 1 {
+		bw.WriteByte(0) // word-align the IFD
+	}
+
+	entry := make([]byte, 12)
+	var tail []byte
+	le.PutUint16(entry, uint16(len(fields)))
+	bw.Write(entry[:2])
+	for _, f := range fields {
+		le.PutUint16(entry[0:], f.tag)
+		le.PutUint16(entry[2:], f.typ)
+		le.PutUint32(entry[4:], f.count)
+		for i := 8; i < 12; i++ {
+			entry[i] = 0
+		}
+		if len(f.data) <= 4 {
+			copy(entry[8:], f.data)
+		} else {
+			le.PutUint32(entry[8:], uint32(extra+uint64(len(tail))))
+			tail = append(tail, f.data...)
+			if len(tail)%2 == 1 {
+				tail = append(tail, 0)
+			}
+		}
+		bw.Write(entry)
+	}
+	bw.Write([]byte{0, 0, 0, 0}) // no next IFD
+	bw.Write(tail)
+	return bw.Flush()
+}
+
+// WriteGeoTIFFFile writes r to path; see WriteGeoTIFF.
+func WriteGeoTIFFFile(path string, r *Raster) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteGeoTIFF(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func sumLen(chunks [][]byte) int {
+	n := 0
+	for _, c := range chunks {
+		n += len(c)
+	}
+	return n
+}
+
+func repeat16(v uint16, n int) []uint16 {
+	out := make([]uint16, n)
+	for i := range out {
+		out[i] = v
+	}
+	return out
+}
+
+func shortField(tag uint16, vals ...uint16) tiffField {
+	data := make([]byte, 2*len(vals))
+	for i, v := range vals {
+		binary.LittleEndian.PutUint16(data[2*i:], v)
+	}
+	return tiffField{tag: tag, typ: tiffShort, count: uint32(len(vals)), data: data}
+}
+
+func longField(tag uint16, vals ...uint32) tiffField {
+	data := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		binary.LittleEndian.PutUint32(data[4*i:], v)
+	}
+	return tiffField{tag: tag, typ: tiffLong, count: uint32(len(vals)), data: data}
+}
+
+func doubleField(tag uint16, vals ...float64) tiffField {
+	data := make([]byte, 8*len(vals))
+	for i, v := range vals {
+		binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(v))
+	}
+	return tiffField{tag: tag, typ: tiffDouble, count: uint32(len(vals)), data: data}
+}
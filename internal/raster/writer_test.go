@@ -0,0 +1,84 @@
+package raster
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// ramp returns a width x height raster whose band b holds b*1e4 + y*width + x.
+func ramp(width, height, bands int) *Raster {
+	r := New(width, height, bands)
+	for b := range r.Bands {
+		for i := range r.Bands[b] {
+			r.Bands[b][i] = float32(b*10000 + i)
+		}
+	}
+	return r
+}
+
+func TestWriteGeoTIFFRoundTrip(t *testing.T) {
+	utm := ramp(300, 100, 2) // more than one strip per band
+	utm.Geo = GeoTransform{OriginX: 500000, OriginY: 4200000, PixelWidth: 10, PixelHeight: 10}
+	utm.EPSG = 32633
+	utm.NoData, utm.HasNoData = -9999, true
+	utm.Bands[1][7] = -9999
+
+	wgs := ramp(5, 3, 1)
+	wgs.Geo = GeoTransform{OriginX: 103.5, OriginY: 1.5, PixelWidth: 0.001, PixelHeight: 0.002}
+	wgs.EPSG = EPSGWGS84
+	wgs.NoData, wgs.HasNoData = math.NaN(), true
+	wgs.Bands[0][4] = float32(math.NaN())
+
+	plain := ramp(4, 4, 1)
+
+	dir := t.TempDir()
+	for _, c := range []struct {
+		name string
+		r    *Raster
+	}{{"utm", utm}, {"wgs84", wgs}, {"plain", plain}} {
+		path := filepath.Join(dir, c.name+".tif")
+		if err := WriteGeoTIFFFile(path, c.r); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		g, err := Open(path)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		got, err := g.ReadAll()
+		g.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got.Width != c.r.Width || got.Height != c.r.Height || len(got.Bands) != len(c.r.Bands) {
+			t.Errorf("%s: got %dx%dx%d, want %dx%dx%d", c.name, got.Width, got.Height, len(got.Bands),
+				c.r.Width, c.r.Height, len(c.r.Bands))
+			continue
+		}
+		if got.Geo != c.r.Geo || got.EPSG != c.r.EPSG || got.HasNoData != c.r.HasNoData {
+			t.Errorf("%s: georeference %+v EPSG:%d nodata %t, want %+v EPSG:%d nodata %t", c.name,
+				got.Geo, got.EPSG, got.HasNoData, c.r.Geo, c.r.EPSG, c.r.HasNoData)
+		}
+		if c.r.HasNoData && got.NoData != c.r.NoData && !(math.IsNaN(got.NoData) && math.IsNaN(c.r.NoData)) {
+			t.Errorf("%s: nodata %v, want %v", c.name, got.NoData, c.r.NoData)
+		}
+		for b := range c.r.Bands {
+			for i, want := range c.r.Bands[b] {
+				v := got.Bands[b][i]
+				if v != want && !(math.IsNaN(float64(v)) && math.IsNaN(float64(want))) {
+					t.Errorf("%s: band %d sample %d = %v, want %v", c.name, b, i, v, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestWriteGeoTIFFEmpty(t *testing.T) {
+	for _, r := range []*Raster{New(0, 5, 1), New(5, 5, 0)} {
+		if err := WriteGeoTIFF(&bytes.Buffer{}, r); err == nil {
+			t.Errorf("%dx%dx%d raster: want an error", r.Width, r.Height, len(r.Bands))
+		}
+	}
+}
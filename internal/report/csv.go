@@ -0,0 +1,70 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CSVOptions controls CSV output.
+type CSVOptions struct {
+	// BOM prefixes the output with a UTF-8 byte order mark so Excel
+	// detects the encoding of non-ASCII class names and attributes.
+	BOM bool
+	// Comma is the field delimiter; defaults to ','.
+	Comma rune
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// WriteCSV writes one row per detection with a fixed column order followed
+// by one column per attribute key, sorted by name.
+func WriteCSV(summary *Summary, w io.Writer, opts CSVOptions) error {
+	if opts.BOM {
+		if _, err := w.Write(utf8BOM); err != nil {
+			return err
+		}
+	}
+
+	ids := make([]int, len(summary.Detections))
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	t := buildTable(summary.Detections, ids)
+
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	if err := cw.Write(t.header); err != nil {
+		return err
+	}
+	record := make([]string, len(t.header))
+	for _, row := range t.rows {
+		for i, c := range row {
+			record[i] = c.text
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteCSVFile writes detections as CSV to outPath.
+func WriteCSVFile(summary *Summary, outPath string, opts CSVOptions) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if err := WriteCSV(summary, f, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
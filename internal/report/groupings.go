@@ -0,0 +1,79 @@
+package report
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+)
+
+// GroupingOptions configures spatial grouping of detections.
+type GroupingOptions struct {
+	Method    string  // "dbscan" (default) or "hierarchical"
+	EpsKm     float64 // neighborhood radius (dbscan) or merge distance (hierarchical)
+	MinPoints int     // minimum members of a grouping; defaults to 2
+}
+
+// Grouping is a set of detections located close together, e.g. aircraft
+// parked on the same apron.
+type Grouping struct {
+	ID          int            `json:"id"`
+	Members     []int          `json:"members"` // 1-based detection indices
+	Centroid    GeoPoint       `json:"centroid"`
+	Hull        []GeoPoint     `json:"hull"`
+	RadiusKm    float64        `json:"radius_km"` // farthest member from the centroid
+	ClassCounts map[string]int `json:"class_counts"`
+}
+
+// FindGroupings clusters georeferenced detections by geodesic distance.
+// Detections without a geo center are ignored.
+func FindGroupings(dets []Detection, opts GroupingOptions) ([]Grouping, error) {
+	if opts.EpsKm <= 0 {
+		return nil, fmt.Errorf("grouping distance must be positive")
+	}
+	if opts.MinPoints <= 0 {
+		opts.MinPoints = 2
+	}
+
+	var pts []geo.Point
+	var idx []int
+	for i, d := range dets {
+		if hasGeo(d) {
+			pts = append(pts, geo.Point{Lat: d.GeoCenter.Latitude, Lon: d.GeoCenter.Longitude})
+			idx = append(idx, i)
+		}
+	}
+
+	var labels []int
+	switch opts.Method {
+	case "", "dbscan":
+		labels = geo.DBSCAN(pts, opts.EpsKm, opts.MinPoints)
+	case "hierarchical":
+		labels = geo.HierarchicalCluster(pts, opts.EpsKm, geo.AverageLinkage)
+	default:
+		return nil, fmt.Errorf("unknown grouping method %q", opts.Method)
+	}
+
+	var groups []Grouping
+	for _, c := range geo.Clusters(pts, labels) {
+		if len(c.Members) < opts.MinPoints {
+			continue
+		}
+		g := Grouping{
+			ID:          len(groups) + 1,
+			Centroid:    GeoPoint{Latitude: c.Centroid.Lat, Longitude: c.Centroid.Lon},
+			ClassCounts: make(map[string]int),
+		}
+		for _, m := range c.Members {
+			d := dets[idx[m]]
+			g.Members = append(g.Members, idx[m]+1)
+			g.ClassCounts[d.ClassName]++
+			g.RadiusKm = math.Max(g.RadiusKm, geo.DistanceM(c.Centroid, pts[m])/1000)
+		}
+		for _, p := range c.Hull {
+			g.Hull = append(g.Hull, GeoPoint{Latitude: p.Lat, Longitude: p.Lon})
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
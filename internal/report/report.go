@@ -6,7 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/pol"
 )
 
 // Detection mirrors the protobuf Detection message for JSON input.
@@ -37,15 +41,26 @@ type DetectResult struct {
 	Detections      []Detection `json:"detections"`
 	InferenceTimeMs float32     `json:"inference_time_ms"`
 	ModelVersion    string      `json:"model_version"`
+	SceneTime       string      `json:"scene_time,omitempty"` // RFC 3339, if known
+	// Footprint is the outline of the analysed image, if georeferenced:
+	// the area where a missing detection means nothing was there.
+	Footprint geo.Ring `json:"footprint,omitempty"`
 }
 
 // ReportMeta holds metadata for the report.
 type ReportMeta struct {
-	Location string
-	Lat      float64
-	Lon      float64
-	Period   string
-	Source   string
+	Location    string
+	Lat         float64
+	Lon         float64
+	HasCoords   bool // Lat/Lon are set, even if both are zero
+	Period      string
+	Source      string
+	CoordFormat geo.CoordFormat // how coordinates are written; empty means decimal degrees
+}
+
+// formatCoord writes a coordinate in the report's format.
+func (m ReportMeta) formatCoord(lat, lon float64) string {
+	return geo.Point{Lat: lat, Lon: lon}.Format(m.CoordFormat)
 }
 
 // Summary holds aggregated statistics from detections.
@@ -54,6 +69,8 @@ type Summary struct {
 	ClassCounts     map[string]int
 	AvgConfidence   float32
 	Detections      []Detection
+	Groupings       []Grouping
+	PatternOfLife   []pol.Score // class counts scored against their baselines
 }
 
 // Summarize aggregates detection results.
@@ -86,8 +103,8 @@ func PrintText(summary *Summary, meta ReportMeta, w *os.File) {
 	if meta.Location != "" {
 		fmt.Fprintf(w, "  Location:  %s\n", meta.Location)
 	}
-	if meta.Lat != 0 || meta.Lon != 0 {
-		fmt.Fprintf(w, "  Coords:    %.4f, %.4f\n", meta.Lat, meta.Lon)
+	if meta.HasCoords || meta.Lat != 0 || meta.Lon != 0 {
+		fmt.Fprintf(w, "  Coords:    %s\n", meta.formatCoord(meta.Lat, meta.Lon))
 	}
 	if meta.Period != "" {
 		fmt.Fprintf(w, "  Period:    %s\n", meta.Period)
@@ -126,13 +143,52 @@ func PrintText(summary *Summary, meta ReportMeta, w *os.File) {
 			fmt.Fprintf(w, "  ~%.0fm x %.0fm", d.EstimatedLengthM, d.EstimatedWidthM)
 		}
 		if d.GeoCenter != nil && (d.GeoCenter.Latitude != 0 || d.GeoCenter.Longitude != 0) {
-			fmt.Fprintf(w, "  @ (%.6f, %.6f)", d.GeoCenter.Latitude, d.GeoCenter.Longitude)
+			fmt.Fprintf(w, "  @ (%s)", meta.formatCoord(d.GeoCenter.Latitude, d.GeoCenter.Longitude))
 		}
 		fmt.Fprintf(w, "\n")
 	}
+
+	if len(summary.Groupings) > 0 {
+		fmt.Fprintf(w, "\n── Groupings ──────────────────────────────────────────\n")
+		for _, g := range summary.Groupings {
+			fmt.Fprintf(w, "  [G%d] %d objects within %.2fkm @ (%s)\n",
+				g.ID, len(g.Members), g.RadiusKm, meta.formatCoord(g.Centroid.Latitude, g.Centroid.Longitude))
+			fmt.Fprintf(w, "        %s\n", formatClassCounts(g.ClassCounts))
+		}
+	}
+
+	if len(summary.PatternOfLife) > 0 {
+		fmt.Fprintf(w, "\n── Pattern of Life ────────────────────────────────────\n")
+		for _, sc := range summary.PatternOfLife {
+			fmt.Fprintf(w, "  %-20s %3d  expected %5.1f (%s, n=%d)  z=%+.1f", sc.Class, sc.Count, sc.Expected, sc.Basis, sc.History, sc.Z)
+			if sc.Anomaly {
+				fmt.Fprintf(w, "  ⚠️  ANOMALY (%s)", sc.Direction())
+			}
+			fmt.Fprintf(w, "\n")
+		}
+	}
 	fmt.Fprintf(w, "\n═══════════════════════════════════════════════════════\n")
 }
 
+// formatClassCounts renders counts as "3 aircraft, 1 vehicle", largest first.
+func formatClassCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%d %s", counts[name], name)
+	}
+	return strings.Join(parts, ", ")
+}
+
 // GeoJSON types
 type geojsonCollection struct {
 	Type     string           `json:"type"`
@@ -189,6 +245,10 @@ func WriteGeoJSON(summary *Summary, outPath string) error {
 		})
 	}
 
+	for _, g := range summary.Groupings {
+		fc.Features = append(fc.Features, groupingFeature(g))
+	}
+
 	f, err := os.Create(outPath)
 	if err != nil {
 		return err
@@ -200,6 +260,38 @@ func WriteGeoJSON(summary *Summary, outPath string) error {
 	return enc.Encode(fc)
 }
 
+// groupingFeature renders a grouping as a Polygon of its hull, degrading to
+// a LineString or Point when it has fewer than three distinct vertices.
+func groupingFeature(g Grouping) geojsonFeature {
+	ring := make([][2]float64, 0, len(g.Hull)+1)
+	for _, p := range g.Hull {
+		ring = append(ring, [2]float64{p.Longitude, p.Latitude})
+	}
+
+	var geom geojsonGeometry
+	switch {
+	case len(ring) >= 3:
+		ring = append(ring, ring[0])
+		geom = geojsonGeometry{Type: "Polygon", Coordinates: [][][2]float64{ring}}
+	case len(ring) == 2:
+		geom = geojsonGeometry{Type: "LineString", Coordinates: ring}
+	default:
+		geom = geojsonGeometry{Type: "Point", Coordinates: [2]float64{g.Centroid.Longitude, g.Centroid.Latitude}}
+	}
+
+	return geojsonFeature{
+		Type:     "Feature",
+		Geometry: geom,
+		Properties: map[string]interface{}{
+			"grouping":     g.ID,
+			"members":      g.Members,
+			"count":        len(g.Members),
+			"radius_km":    g.RadiusKm,
+			"class_counts": g.ClassCounts,
+		},
+	}
+}
+
 // LoadDetectResult reads a DetectResult from a JSON file.
 func LoadDetectResult(path string) (*DetectResult, error) {
 	data, err := os.ReadFile(path)
@@ -212,3 +304,19 @@ func LoadDetectResult(path string) (*DetectResult, error) {
 	}
 	return &result, nil
 }
+
+// FilterToAOI keeps only detections whose geo center lies inside aoi and
+// returns the number removed. Detections without a geo center are kept,
+// since they cannot be placed.
+func FilterToAOI(result *DetectResult, aoi geo.MultiPolygon) int {
+	kept := result.Detections[:0]
+	for _, d := range result.Detections {
+		if hasGeo(d) && !aoi.Contains(geo.Point{Lat: d.GeoCenter.Latitude, Lon: d.GeoCenter.Longitude}) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	removed := len(result.Detections) - len(kept)
+	result.Detections = kept
+	return removed
+}
@@ -0,0 +1,480 @@
+package report
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	stixSpecVersion = "2.1"
+	stixTimeFormat  = "2006-01-02T15:04:05.000Z"
+
+	// DefaultSTIXProducer names the identity that authors exported objects.
+	DefaultSTIXProducer = "Orbital Eye"
+)
+
+var (
+	// stixSCONamespace is the namespace STIX 2.1 mandates for deterministic
+	// cyber-observable IDs (section 2.9).
+	stixSCONamespace = mustParseUUID("00abedb4-aa42-466c-9c01-fed23315a9b7")
+	// stixNamespace scopes IDs of the domain objects orbital-eye produces, so
+	// re-exporting the same scene, facility or note yields the same ID.
+	stixNamespace = mustParseUUID("6a4b3c1e-2f0d-5e8a-9b7c-0d1e2f3a4b5c")
+)
+
+// STIXObject is a single STIX 2.1 object as a property map.
+type STIXObject map[string]interface{}
+
+// STIXBundle is a STIX 2.1 bundle.
+type STIXBundle struct {
+	Type    string       `json:"type"`
+	ID      string       `json:"id"`
+	Objects []STIXObject `json:"objects"`
+}
+
+// STIXScene describes one analysed image for export as observed-data.
+type STIXScene struct {
+	ID       string // provider scene/item ID, e.g. a Sentinel-2 product ID
+	Acquired time.Time
+	Platform string
+	Summary  *Summary
+	Meta     ReportMeta
+}
+
+// STIXFacility is a site exported as an infrastructure object located at a
+// location object.
+type STIXFacility struct {
+	ID       string // stable facility identifier, e.g. "facility-001"
+	Name     string
+	Type     string // e.g. "naval_base"
+	Country  string // ISO 3166-1 alpha-2
+	Lat, Lon float64
+}
+
+// AnalystNote is free-text commentary attached to scenes and facilities.
+// Refs holds scene or facility IDs as given in STIXScene.ID and
+// STIXFacility.ID; an empty Refs attaches the note to every scene.
+type AnalystNote struct {
+	Abstract string
+	Content  string
+	Author   string
+	Refs     []string
+}
+
+// STIXExport is everything that goes into one bundle.
+type STIXExport struct {
+	Producer   string    // identity name; defaults to DefaultSTIXProducer
+	Created    time.Time // creation timestamp for all SDOs; defaults to now
+	Scenes     []STIXScene
+	Facilities []STIXFacility
+	Notes      []AnalystNote
+}
+
+// BuildSTIXBundle converts scenes, facilities and notes into a validated
+// STIX 2.1 bundle. Object IDs are UUIDv5 values derived from natural keys
+// so that repeated exports of the same findings deduplicate downstream.
+func BuildSTIXBundle(exp STIXExport) (*STIXBundle, error) {
+	producer := exp.Producer
+	if producer == "" {
+		producer = DefaultSTIXProducer
+	}
+	created := exp.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	ts := stixTime(created)
+
+	identityID := stixID("identity", "identity:"+producer)
+	objects := []STIXObject{{
+		"type":           "identity",
+		"spec_version":   stixSpecVersion,
+		"id":             identityID,
+		"created":        ts,
+		"modified":       ts,
+		"name":           producer,
+		"identity_class": "organization",
+	}}
+	sdo := func(typ, key string) STIXObject {
+		return STIXObject{
+			"type":           typ,
+			"spec_version":   stixSpecVersion,
+			"id":             stixID(typ, key),
+			"created":        ts,
+			"modified":       ts,
+			"created_by_ref": identityID,
+		}
+	}
+
+	refs := make(map[string]string) // scene/facility ID -> STIX ID
+	var sceneIDs []string
+
+	for _, sc := range exp.Scenes {
+		if sc.ID == "" {
+			return nil, errors.New("stix: scene without ID")
+		}
+		file := STIXObject{
+			"type":         "file",
+			"spec_version": stixSpecVersion,
+			"id":           stixSCOID("file", map[string]string{"name": sc.ID}),
+			"name":         sc.ID,
+		}
+
+		obs := sdo("observed-data", "scene:"+sc.ID)
+		seen := sc.Acquired
+		if seen.IsZero() {
+			seen = created
+		}
+		obs["first_observed"] = stixTime(seen)
+		obs["last_observed"] = stixTime(seen)
+		obs["number_observed"] = 1
+		obs["object_refs"] = []string{file["id"].(string)}
+		if sc.Platform != "" {
+			obs["x_orbital_eye_platform"] = sc.Platform
+		}
+		if sc.Meta.Source != "" {
+			obs["x_orbital_eye_model"] = sc.Meta.Source
+		}
+		if sc.Meta.HasCoords || sc.Meta.Lat != 0 || sc.Meta.Lon != 0 {
+			obs["x_orbital_eye_center"] = map[string]float64{"latitude": sc.Meta.Lat, "longitude": sc.Meta.Lon}
+		}
+		if sc.Summary != nil {
+			obs["x_orbital_eye_total_detections"] = sc.Summary.TotalDetections
+			obs["x_orbital_eye_class_counts"] = sc.Summary.ClassCounts
+		}
+
+		objects = append(objects, file, obs)
+		refs[sc.ID] = obs["id"].(string)
+		sceneIDs = append(sceneIDs, obs["id"].(string))
+	}
+
+	for _, fac := range exp.Facilities {
+		if fac.ID == "" || fac.Name == "" {
+			return nil, fmt.Errorf("stix: facility %q needs both ID and name", fac.Name)
+		}
+		loc := sdo("location", "facility-location:"+fac.ID)
+		loc["name"] = fac.Name
+		loc["latitude"] = fac.Lat
+		loc["longitude"] = fac.Lon
+		if fac.Country != "" {
+			loc["country"] = fac.Country
+		}
+
+		infra := sdo("infrastructure", "facility:"+fac.ID)
+		infra["name"] = fac.Name
+		if fac.Type != "" {
+			infra["x_orbital_eye_facility_type"] = fac.Type
+		}
+		infra["external_references"] = []map[string]string{{
+			"source_name": "orbital-eye-facilities",
+			"external_id": fac.ID,
+		}}
+
+		rel := sdo("relationship", fmt.Sprintf("relationship:%s|located-at|%s", infra["id"], loc["id"]))
+		rel["relationship_type"] = "located-at"
+		rel["source_ref"] = infra["id"]
+		rel["target_ref"] = loc["id"]
+
+		objects = append(objects, loc, infra, rel)
+		refs[fac.ID] = infra["id"].(string)
+	}
+
+	for i, n := range exp.Notes {
+		var objRefs []string
+		for _, r := range n.Refs {
+			id, ok := refs[r]
+			if !ok {
+				return nil, fmt.Errorf("stix: note %d references unknown scene or facility %q", i+1, r)
+			}
+			objRefs = append(objRefs, id)
+		}
+		if len(n.Refs) == 0 {
+			objRefs = append(objRefs, sceneIDs...)
+		}
+		sort.Strings(objRefs)
+
+		note := sdo("note", "note:"+n.Content+"|"+strings.Join(objRefs, ","))
+		note["content"] = n.Content
+		note["object_refs"] = objRefs
+		if n.Abstract != "" {
+			note["abstract"] = n.Abstract
+		}
+		if n.Author != "" {
+			note["authors"] = []string{n.Author}
+		}
+		objects = append(objects, note)
+	}
+
+	ids := make([]string, len(objects))
+	for i, o := range objects {
+		ids[i] = o["id"].(string)
+	}
+	sort.Strings(ids)
+	bundle := &STIXBundle{
+		Type:    "bundle",
+		ID:      stixID("bundle", strings.Join(ids, ",")),
+		Objects: objects,
+	}
+	if err := bundle.Validate(); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// WriteSTIX writes a bundle as indented JSON.
+func WriteSTIX(bundle *STIXBundle, outPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bundle)
+}
+
+// stixRequired lists the required properties of each object type beyond
+// the common type/id (and spec_version/created/modified for SDOs and SROs).
+var stixRequired = map[string][]string{
+	"identity":       {"name"},
+	"observed-data":  {"first_observed", "last_observed", "number_observed", "object_refs"},
+	"location":       {},
+	"infrastructure": {"name"},
+	"relationship":   {"relationship_type", "source_ref", "target_ref"},
+	"note":           {"content", "object_refs"},
+	"file":           {},
+}
+
+var stixIDPattern = regexp.MustCompile(`^([a-z0-9][a-z0-9-]*[a-z0-9])--[0-9a-f]{8}-[0-9a-f]{4}-[1-5][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// Validate checks the bundle against the STIX 2.1 required properties and
+// value constraints for the object types the exporter emits.
+func (b *STIXBundle) Validate() error {
+	if b.Type != "bundle" || !validSTIXID(b.ID, "bundle") {
+		return fmt.Errorf("stix: invalid bundle header %q", b.ID)
+	}
+	ids := make(map[string]bool, len(b.Objects))
+	for _, o := range b.Objects {
+		id, _ := o["id"].(string)
+		if ids[id] {
+			return fmt.Errorf("stix: duplicate object %s", id)
+		}
+		ids[id] = true
+	}
+	for _, o := range b.Objects {
+		if err := validateSTIXObject(o, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSTIXObject(o STIXObject, ids map[string]bool) error {
+	typ, _ := o["type"].(string)
+	id, _ := o["id"].(string)
+	required, known := stixRequired[typ]
+	if !known {
+		return fmt.Errorf("stix: unsupported object type %q", typ)
+	}
+	if !validSTIXID(id, typ) {
+		return fmt.Errorf("stix: invalid id %q for type %s", id, typ)
+	}
+	if o["spec_version"] != stixSpecVersion {
+		return fmt.Errorf("stix: %s: spec_version must be %q", id, stixSpecVersion)
+	}
+
+	if typ != "file" {
+		required = append([]string{"created", "modified"}, required...)
+	}
+	for _, p := range required {
+		if v, ok := o[p]; !ok || v == "" || v == nil {
+			return fmt.Errorf("stix: %s: missing required property %q", id, p)
+		}
+	}
+
+	times := make(map[string]time.Time)
+	for _, p := range []string{"created", "modified", "first_observed", "last_observed"} {
+		v, ok := o[p]
+		if !ok {
+			continue
+		}
+		str, _ := v.(string)
+		t, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return fmt.Errorf("stix: %s: %s is not an RFC 3339 timestamp", id, p)
+		}
+		times[p] = t
+	}
+	if c, ok := times["created"]; ok && times["modified"].Before(c) {
+		return fmt.Errorf("stix: %s: modified precedes created", id)
+	}
+
+	for p, v := range o {
+		switch {
+		case strings.HasSuffix(p, "_ref"):
+			ref, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("stix: %s: %s must be an identifier", id, p)
+			}
+			if !ids[ref] {
+				return fmt.Errorf("stix: %s: %s points to %s, which is not in the bundle", id, p, ref)
+			}
+		case strings.HasSuffix(p, "_refs"):
+			refs, ok := stixStrings(v)
+			if !ok {
+				return fmt.Errorf("stix: %s: %s must be a list of identifiers", id, p)
+			}
+			if len(refs) == 0 {
+				return fmt.Errorf("stix: %s: %s must not be empty", id, p)
+			}
+			for _, r := range refs {
+				if !ids[r] {
+					return fmt.Errorf("stix: %s: %s points to %s, which is not in the bundle", id, p, r)
+				}
+			}
+		}
+	}
+
+	switch typ {
+	case "observed-data":
+		n, ok := stixInt(o["number_observed"])
+		if !ok || n < 1 || n > 999999999 {
+			return fmt.Errorf("stix: %s: number_observed must be an integer from 1 to 999999999", id)
+		}
+		if times["last_observed"].Before(times["first_observed"]) {
+			return fmt.Errorf("stix: %s: last_observed precedes first_observed", id)
+		}
+		refs, _ := stixStrings(o["object_refs"])
+		for _, r := range refs {
+			if t := strings.SplitN(r, "--", 2)[0]; t != "file" {
+				return fmt.Errorf("stix: %s: object_refs must reference observables, got %s", id, t)
+			}
+		}
+	case "location":
+		latV, hasLat := o["latitude"]
+		lonV, hasLon := o["longitude"]
+		_, hasRegion := o["region"]
+		_, hasCountry := o["country"]
+		if hasLat != hasLon {
+			return fmt.Errorf("stix: %s: latitude and longitude must be given together", id)
+		}
+		lat, okLat := latV.(float64)
+		lon, okLon := lonV.(float64)
+		if hasLat && (!okLat || !okLon) {
+			return fmt.Errorf("stix: %s: latitude and longitude must be numbers", id)
+		}
+		if !hasLat && !hasRegion && !hasCountry {
+			return fmt.Errorf("stix: %s: location needs region, country or latitude/longitude", id)
+		}
+		if hasLat && (lat < -90 || lat > 90 || lon < -180 || lon > 180) {
+			return fmt.Errorf("stix: %s: coordinates out of range", id)
+		}
+	case "file":
+		if _, ok := o["name"]; !ok {
+			if _, ok := o["hashes"]; !ok {
+				return fmt.Errorf("stix: %s: file needs name or hashes", id)
+			}
+		}
+	}
+	return nil
+}
+
+// stixStrings returns a list of strings as built ([]string) or as decoded
+// from JSON ([]interface{}).
+func stixStrings(v interface{}) ([]string, bool) {
+	switch v := v.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		out := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// stixInt returns an integer as built (int) or as decoded from JSON
+// (float64 or json.Number).
+func stixInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > math.MaxInt32 {
+			return 0, false
+		}
+		return int(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err == nil && n == int64(int(n))
+	}
+	return 0, false
+}
+
+func validSTIXID(id, typ string) bool {
+	m := stixIDPattern.FindStringSubmatch(id)
+	return m != nil && m[1] == typ
+}
+
+func stixTime(t time.Time) string {
+	return t.UTC().Format(stixTimeFormat)
+}
+
+// stixID returns a deterministic identifier for a domain object.
+func stixID(typ, key string) string {
+	return typ + "--" + uuidV5(stixNamespace, typ+"|"+key)
+}
+
+// stixSCOID derives a cyber-observable ID from its ID-contributing
+// properties, serialized as canonical (sorted-key) JSON per the spec.
+func stixSCOID(typ string, props map[string]string) string {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(props) // encoding/json sorts map keys
+	return typ + "--" + uuidV5(stixSCONamespace, strings.TrimSuffix(buf.String(), "\n"))
+}
+
+// uuidV5 implements RFC 4122 name-based UUIDs with SHA-1.
+func uuidV5(ns [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(ns[:])
+	h.Write([]byte(name))
+	var u [16]byte
+	copy(u[:], h.Sum(nil))
+	u[6] = (u[6] & 0x0f) | 0x50
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+func mustParseUUID(s string) [16]byte {
+	var u [16]byte
+	hex := strings.ReplaceAll(s, "-", "")
+	if len(hex) != 32 {
+		panic("invalid UUID " + s)
+	}
+	for i := range u {
+		if _, err := fmt.Sscanf(hex[i*2:i*2+2], "%02x", &u[i]); err != nil {
+			panic("invalid UUID " + s)
+		}
+	}
+	return u
+}
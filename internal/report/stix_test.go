@@ -0,0 +1,172 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSceneID = "S2B_MSIL2A_20240115T023109_N0510_R046_T51RTP_20240115T050356"
+
+func testSTIXExport(created time.Time) STIXExport {
+	return STIXExport{
+		Created: created,
+		Scenes: []STIXScene{{
+			ID:       testSceneID,
+			Acquired: time.Date(2024, 1, 15, 2, 31, 9, 0, time.UTC),
+			Platform: "sentinel-2b",
+			Summary:  &Summary{TotalDetections: 3, ClassCounts: map[string]int{"ship": 3}},
+		}},
+		Facilities: []STIXFacility{{ID: "facility-001", Name: "Example Naval Base", Type: "naval_base", Country: "JP", Lat: 35.29, Lon: 139.67}},
+		Notes: []AnalystNote{
+			{Abstract: "Berths", Content: "Three hulls alongside.", Refs: []string{"facility-001", testSceneID}},
+			{Content: "Cloud free."},
+		},
+	}
+}
+
+func bundleIDs(t *testing.T, exp STIXExport) []string {
+	t.Helper()
+	b, err := BuildSTIXBundle(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{b.ID}
+	for _, o := range b.Objects {
+		ids = append(ids, o["id"].(string))
+	}
+	return ids
+}
+
+func TestSTIXIDsAreDeterministic(t *testing.T) {
+	first := bundleIDs(t, testSTIXExport(time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)))
+	// A later export of the same findings must reuse every ID.
+	second := bundleIDs(t, testSTIXExport(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)))
+	if strings.Join(first, "\n") != strings.Join(second, "\n") {
+		t.Fatalf("IDs differ between exports:\n%v\n%v", first, second)
+	}
+	for _, id := range first {
+		// xxxxxxxx-xxxx-5xxx-[89ab]xxx-xxxxxxxxxxxx: version 5, RFC 4122 variant.
+		u := id[strings.Index(id, "--")+2:]
+		if !stixIDPattern.MatchString(id) || u[14] != '5' || !strings.ContainsRune("89ab", rune(u[19])) {
+			t.Errorf("%s is not a UUIDv5 STIX identifier", id)
+		}
+	}
+
+	changed := testSTIXExport(time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC))
+	changed.Notes[1].Content = "Partly cloudy."
+	if third := bundleIDs(t, changed); third[0] == first[0] {
+		t.Errorf("bundle ID %s unchanged after a note changed", third[0])
+	}
+}
+
+func TestUUIDv5(t *testing.T) {
+	// RFC 4122 DNS namespace; the same value as Python's uuid.uuid5.
+	dns := mustParseUUID("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	if got, want := uuidV5(dns, "python.org"), "886313e1-3b8a-5372-9b90-0c9aee199e5d"; got != want {
+		t.Errorf("uuidV5(DNS, python.org) = %s, want %s", got, want)
+	}
+	// STIX 2.1 §2.9: the namespace over canonical JSON of the ID properties.
+	if got, want := stixSCOID("file", map[string]string{"name": testSceneID}), "file--a3e53783-0ccc-550e-809e-40a948a491a8"; got != want {
+		t.Errorf("file SCO ID = %s, want %s", got, want)
+	}
+}
+
+func TestSTIXValidateRejectsMissingRequired(t *testing.T) {
+	b, err := BuildSTIXBundle(testSTIXExport(time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, o := range b.Objects {
+		typ := o["type"].(string)
+		props := append([]string{"type", "id", "spec_version"}, stixRequired[typ]...)
+		if typ != "file" {
+			props = append(props, "created", "modified")
+		}
+		for _, p := range props {
+			v := o[p]
+			delete(o, p)
+			if err := b.Validate(); err == nil {
+				t.Errorf("object %d (%s) without %s validated", i, typ, p)
+			}
+			o[p] = v
+		}
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("restored bundle: %v", err)
+	}
+
+	// Type-specific requirements.
+	loc := findSTIXObject(t, b, "location")
+	lat := loc["latitude"]
+	delete(loc, "latitude")
+	if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "latitude and longitude") {
+		t.Errorf("location with longitude only: %v", err)
+	}
+	loc["latitude"] = lat
+
+	file := findSTIXObject(t, b, "file")
+	delete(file, "name")
+	if err := b.Validate(); err == nil || !strings.Contains(err.Error(), "name or hashes") {
+		t.Errorf("file without name or hashes: %v", err)
+	}
+}
+
+func findSTIXObject(t *testing.T, b *STIXBundle, typ string) STIXObject {
+	t.Helper()
+	for _, o := range b.Objects {
+		if o["type"] == typ {
+			return o
+		}
+	}
+	t.Fatalf("no %s object in bundle", typ)
+	return nil
+}
+
+// TestSTIXValidateDecoded validates a bundle as a consumer would see it,
+// decoded from JSON, where lists are []interface{} and numbers float64.
+func TestSTIXValidateDecoded(t *testing.T) {
+	b, err := BuildSTIXBundle(testSTIXExport(time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded STIXBundle
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Validate(); err != nil {
+		t.Fatalf("decoded bundle: %v", err)
+	}
+
+	// Wrong types must be reported, not panic.
+	obs := findSTIXObject(t, &decoded, "observed-data")
+	for _, c := range []struct {
+		prop string
+		v    interface{}
+	}{
+		{"number_observed", 1.5},
+		{"number_observed", "1"},
+		{"object_refs", "file--a3e53783-0ccc-550e-809e-40a948a491a8"},
+		{"object_refs", []interface{}{1}},
+		{"last_observed", 20240115},
+		{"modified", nil},
+		{"created_by_ref", []interface{}{}},
+	} {
+		old := obs[c.prop]
+		obs[c.prop] = c.v
+		if err := decoded.Validate(); err == nil {
+			t.Errorf("%s = %#v validated", c.prop, c.v)
+		}
+		obs[c.prop] = old
+	}
+	loc := findSTIXObject(t, &decoded, "location")
+	loc["latitude"] = "35.29"
+	if err := decoded.Validate(); err == nil {
+		t.Error("string latitude validated")
+	}
+}
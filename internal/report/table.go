@@ -0,0 +1,115 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// AttributePrefix is prepended to flattened Detection.Attributes columns.
+const AttributePrefix = "attr_"
+
+// baseColumns are the fixed leading columns of every tabular export, in order.
+var baseColumns = []string{
+	"id", "class", "confidence",
+	"x_min", "y_min", "x_max", "y_max",
+	"latitude", "longitude",
+	"length_m", "width_m",
+	"wkt",
+}
+
+// cell is a single table value; numeric cells keep their number so
+// spreadsheet writers can store them as numbers rather than text.
+type cell struct {
+	text    string
+	num     float64
+	numeric bool
+}
+
+func textCell(s string) cell { return cell{text: s} }
+
+func numCell(v float64, prec int) cell {
+	return cell{text: strconv.FormatFloat(v, 'f', prec, 64), num: v, numeric: true}
+}
+
+// table is a flattened, column-stable view of detections.
+type table struct {
+	header []string
+	rows   [][]cell
+}
+
+// attributeKeys returns the sorted union of attribute keys across detections.
+func attributeKeys(dets []Detection) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, d := range dets {
+		for k := range d.Attributes {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// buildTable flattens detections into rows. ids are the 1-based positions
+// of the detections in the full result so that per-class subsets keep the
+// same identifiers as the text report.
+func buildTable(dets []Detection, ids []int) *table {
+	keys := attributeKeys(dets)
+	t := &table{header: append([]string(nil), baseColumns...)}
+	for _, k := range keys {
+		t.header = append(t.header, AttributePrefix+k)
+	}
+
+	for i, d := range dets {
+		row := []cell{
+			numCell(float64(ids[i]), 0),
+			textCell(d.ClassName),
+			numCell(float64(d.Confidence), 4),
+			numCell(float64(d.Bbox.XMin), 1),
+			numCell(float64(d.Bbox.YMin), 1),
+			numCell(float64(d.Bbox.XMax), 1),
+			numCell(float64(d.Bbox.YMax), 1),
+		}
+		if hasGeo(d) {
+			row = append(row,
+				numCell(d.GeoCenter.Latitude, 6),
+				numCell(d.GeoCenter.Longitude, 6),
+			)
+		} else {
+			row = append(row, textCell(""), textCell(""))
+		}
+		if d.EstimatedLengthM > 0 {
+			row = append(row,
+				numCell(float64(d.EstimatedLengthM), 1),
+				numCell(float64(d.EstimatedWidthM), 1),
+			)
+		} else {
+			row = append(row, textCell(""), textCell(""))
+		}
+		row = append(row, textCell(DetectionWKT(d)))
+		for _, k := range keys {
+			row = append(row, textCell(d.Attributes[k]))
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// DetectionWKT returns the detection's geo center as a WKT POINT in
+// lon/lat order, or "" if the detection is not georeferenced.
+func DetectionWKT(d Detection) string {
+	if !hasGeo(d) {
+		return ""
+	}
+	return fmt.Sprintf("POINT (%s %s)",
+		strconv.FormatFloat(d.GeoCenter.Longitude, 'f', -1, 64),
+		strconv.FormatFloat(d.GeoCenter.Latitude, 'f', -1, 64))
+}
+
+func hasGeo(d Detection) bool {
+	return d.GeoCenter != nil && (d.GeoCenter.Latitude != 0 || d.GeoCenter.Longitude != 0)
+}
@@ -0,0 +1,231 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// xlsxMaxSheetName is Excel's limit on worksheet name length.
+const xlsxMaxSheetName = 31
+
+// WriteXLSX writes detections as an Excel workbook with one worksheet per
+// class, sorted by class name. Columns match WriteCSV, except that each
+// sheet only carries the attribute columns used by that class.
+func WriteXLSX(summary *Summary, outPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if err := writeXLSX(summary, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type xlsxSheet struct {
+	name  string
+	table *table
+}
+
+func writeXLSX(summary *Summary, w io.Writer) error {
+	byClass := make(map[string][]int)
+	for i, d := range summary.Detections {
+		byClass[d.ClassName] = append(byClass[d.ClassName], i)
+	}
+	classes := make([]string, 0, len(byClass))
+	for c := range byClass {
+		classes = append(classes, c)
+	}
+	sort.Strings(classes)
+
+	var sheets []xlsxSheet
+	used := make(map[string]bool)
+	for _, c := range classes {
+		var dets []Detection
+		var ids []int
+		for _, i := range byClass[c] {
+			dets = append(dets, summary.Detections[i])
+			ids = append(ids, i+1)
+		}
+		sheets = append(sheets, xlsxSheet{name: sheetName(c, used), table: buildTable(dets, ids)})
+	}
+	if len(sheets) == 0 {
+		// A workbook must contain at least one sheet.
+		sheets = append(sheets, xlsxSheet{name: "detections", table: buildTable(nil, nil)})
+	}
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		body []byte
+	}{
+		{"[Content_Types].xml", xlsxContentTypes(len(sheets))},
+		{"_rels/.rels", []byte(xlsxRootRels)},
+		{"xl/workbook.xml", xlsxWorkbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(sheets))},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+	}
+	for i, s := range sheets {
+		files = append(files, struct {
+			name string
+			body []byte
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), xlsxWorksheet(s.table)})
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// sheetName derives a valid, unique worksheet name from a class name.
+func sheetName(class string, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, class)
+	name = strings.Trim(name, "'")
+	if name == "" {
+		name = "unclassified"
+	}
+	name = truncateRunes(name, xlsxMaxSheetName)
+
+	base := name
+	for n := 2; used[strings.ToLower(name)]; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		name = truncateRunes(base, xlsxMaxSheetName-len(suffix)) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// columnName converts a 0-based column index to spreadsheet letters (A, B, ..., AA).
+func columnName(i int) string {
+	var b []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		b = append([]byte{byte('A' + (i-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func xlsxWorksheet(t *table) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<sheetData>`)
+
+	writeRow := func(r int, cells []cell, style int) {
+		fmt.Fprintf(&b, `<row r="%d">`, r)
+		for c, v := range cells {
+			ref := fmt.Sprintf("%s%d", columnName(c), r)
+			switch {
+			case v.numeric:
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, v.text)
+			case v.text != "":
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr" s="%d"><is><t>%s</t></is></c>`, ref, style, xmlEscape(v.text))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+
+	header := make([]cell, len(t.header))
+	for i, h := range t.header {
+		header[i] = textCell(h)
+	}
+	writeRow(1, header, 1)
+	for i, row := range t.rows {
+		writeRow(i+2, row, 0)
+	}
+
+	b.WriteString(`</sheetData>`)
+	if n := len(t.rows); n > 0 {
+		fmt.Fprintf(&b, `<autoFilter ref="A1:%s%d"/>`, columnName(len(t.header)-1), n+1)
+	}
+	b.WriteString(`</worksheet>`)
+	return b.Bytes()
+}
+
+func xlsxContentTypes(sheets int) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.Bytes()
+}
+
+const xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// xlsxStyles defines style 0 (default) and style 1 (bold, for headers).
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+func xlsxWorkbook(sheets []xlsxSheet) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, s := range sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(s.name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.Bytes()
+}
+
+func xlsxWorkbookRels(sheets int) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	return b.Bytes()
+}
@@ -0,0 +1,136 @@
+package track
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PrintText writes the tracks and events as a human-readable report.
+func PrintText(res *Result, w io.Writer) {
+	fmt.Fprintf(w, "═══════════════════════════════════════════════════════\n")
+	fmt.Fprintf(w, "  ORBITAL EYE — Tracking Report\n")
+	fmt.Fprintf(w, "  Generated: %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "═══════════════════════════════════════════════════════\n\n")
+
+	fmt.Fprintf(w, "── Scenes ─────────────────────────────────────────────\n")
+	for i, sc := range res.Scenes {
+		fmt.Fprintf(w, "  [%d] %s  %3d detections  %s\n", i+1, sc.Time.Format("2006-01-02 15:04"), len(sc.Result.Detections), sc.Name)
+	}
+
+	fmt.Fprintf(w, "\n── Tracks ─────────────────────────────────────────────\n")
+	fmt.Fprintf(w, "  %-6s %-14s %-10s %-10s %8s %6s %7s  %s\n", "ID", "Class", "First", "Last", "Dwell", "Seen", "Persist", "Status")
+	for _, t := range res.Tracks {
+		status := "present"
+		if !t.Present {
+			status = "departed " + t.Departed.Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "  %-6s %-14s %-10s %-10s %8s %6d %6.0f%%  %s\n",
+			t.ID, t.Class, t.FirstSeen.Format("2006-01-02"), t.LastSeen.Format("2006-01-02"),
+			formatDwell(t.Dwell()), len(t.Observations), t.Persistence*100, status)
+	}
+
+	if len(res.Events) > 0 {
+		fmt.Fprintf(w, "\n── Events ─────────────────────────────────────────────\n")
+		for _, e := range res.Events {
+			fmt.Fprintf(w, "  %s  %-9s %s %s @ (%.5f, %.5f)\n", e.Time.Format("2006-01-02 15:04"), e.Type, e.TrackID, e.Class, e.Point.Lat, e.Point.Lon)
+		}
+	}
+	fmt.Fprintf(w, "\n═══════════════════════════════════════════════════════\n")
+}
+
+// formatDwell renders a duration in days and hours, e.g. "3d04h".
+func formatDwell(d time.Duration) string {
+	h := int(d.Round(time.Hour).Hours())
+	if h < 24 {
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dd%02dh", h/24, h%24)
+}
+
+type trackJSON struct {
+	ID           string            `json:"id"`
+	Class        string            `json:"class"`
+	FirstSeen    time.Time         `json:"first_seen"`
+	LastSeen     time.Time         `json:"last_seen"`
+	DwellHours   float64           `json:"dwell_hours"`
+	Sightings    int               `json:"sightings"`
+	Persistence  float64           `json:"persistence"`
+	Present      bool              `json:"present"`
+	Departed     *time.Time        `json:"departed,omitempty"`
+	Lat          float64           `json:"lat"`
+	Lon          float64           `json:"lon"`
+	LengthM      float64           `json:"length_m,omitempty"`
+	Observations []observationJSON `json:"observations"`
+}
+
+type observationJSON struct {
+	Scene     string    `json:"scene"`
+	Time      time.Time `json:"time"`
+	Detection int       `json:"detection"`
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+}
+
+type eventJSON struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	TrackID string    `json:"track_id"`
+	Class   string    `json:"class"`
+	Lat     float64   `json:"lat"`
+	Lon     float64   `json:"lon"`
+}
+
+// WriteJSON writes the tracks and events to a JSON file.
+func WriteJSON(res *Result, outPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	out := struct {
+		Tracks []trackJSON `json:"tracks"`
+		Events []eventJSON `json:"events"`
+	}{Tracks: []trackJSON{}, Events: []eventJSON{}}
+	for _, t := range res.Tracks {
+		tj := trackJSON{
+			ID:          t.ID,
+			Class:       t.Class,
+			FirstSeen:   t.FirstSeen,
+			LastSeen:    t.LastSeen,
+			DwellHours:  t.Dwell().Hours(),
+			Sightings:   len(t.Observations),
+			Persistence: t.Persistence,
+			Present:     t.Present,
+			Lat:         t.Position.Lat,
+			Lon:         t.Position.Lon,
+			LengthM:     t.LengthM,
+		}
+		if !t.Departed.IsZero() {
+			tj.Departed = &t.Departed
+		}
+		for _, o := range t.Observations {
+			tj.Observations = append(tj.Observations, observationJSON{
+				Scene:     res.Scenes[o.Scene].Name,
+				Time:      o.Time,
+				Detection: o.Detection,
+				Lat:       o.Point.Lat,
+				Lon:       o.Point.Lon,
+			})
+		}
+		out.Tracks = append(out.Tracks, tj)
+	}
+	for _, e := range res.Events {
+		out.Events = append(out.Events, eventJSON{Time: e.Time, Type: e.Type, TrackID: e.TrackID, Class: e.Class, Lat: e.Point.Lat, Lon: e.Point.Lon})
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,290 @@
+// Package track associates detections across a time-ordered series of
+// scenes of the same area, so that an object seen in several scenes gets
+// one stable track ID, and derives dwell times, persistence and
+// arrival/departure events from the tracks.
+package track
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/assign"
+	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/report"
+)
+
+// AttrTrackID is the attribute holding a detection's track ID.
+const AttrTrackID = "track_id"
+
+// Event types.
+const (
+	EventArrival   = "arrival"
+	EventDeparture = "departure"
+)
+
+// Scene is one detection result with its acquisition time.
+type Scene struct {
+	Name   string
+	Time   time.Time
+	Result *report.DetectResult
+}
+
+// Options configures Run.
+type Options struct {
+	// MaxDistanceM is the largest distance between a track's last position
+	// and a detection it continues; defaults to 150 m.
+	MaxDistanceM float64
+	// MaxSizeDiff is the largest relative length difference between a
+	// track and a detection it continues; defaults to 0.35.
+	MaxSizeDiff float64
+	// MaxMissed is how many consecutive scenes a track may be missing
+	// from (clouds, missed detections) and still be continued; defaults
+	// to 1. Use a negative value for none.
+	MaxMissed int
+}
+
+func (o *Options) defaults() {
+	if o.MaxDistanceM <= 0 {
+		o.MaxDistanceM = 150
+	}
+	if o.MaxSizeDiff <= 0 {
+		o.MaxSizeDiff = 0.35
+	}
+	if o.MaxMissed == 0 {
+		o.MaxMissed = 1
+	} else if o.MaxMissed < 0 {
+		o.MaxMissed = 0
+	}
+}
+
+// Observation is a track's detection in one scene.
+type Observation struct {
+	Scene     int // index into Result.Scenes
+	Detection int // index into the scene's detections
+	Time      time.Time
+	Point     geo.Point
+	LengthM   float64
+}
+
+// Track is one object followed across scenes.
+type Track struct {
+	ID           string
+	Class        string
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	Observations []Observation
+	// Present is true if the track was seen in the last scene.
+	Present bool
+	// Departed is the time of the first scene after the last sighting, if
+	// the track was not seen again.
+	Departed time.Time
+	// Persistence is the share of scenes from the first sighting to the
+	// last scene (or departure) in which the track was seen.
+	Persistence float64
+	// Position is the mean position of the observations.
+	Position geo.Point
+	// LengthM is the mean detected length, 0 if never measured.
+	LengthM float64
+
+	last   Observation
+	missed int
+}
+
+// Dwell is the time between the first and last sightings.
+func (t *Track) Dwell() time.Duration { return t.LastSeen.Sub(t.FirstSeen) }
+
+// Event is an arrival or departure of a track.
+type Event struct {
+	Time    time.Time
+	Type    string
+	TrackID string
+	Class   string
+	Point   geo.Point
+}
+
+// Result holds the tracks and events of a series.
+type Result struct {
+	Scenes []Scene
+	Tracks []*Track
+	Events []Event
+}
+
+// Run sorts scenes by time and links their detections into tracks. Each
+// scene is matched against the tracks still open with a minimum-cost
+// assignment (Hungarian algorithm) over geodesic distance and relative
+// length difference; detections of different classes never match. Every
+// tracked detection gets AttrTrackID set.
+func Run(scenes []Scene, opts Options) *Result {
+	opts.defaults()
+	sort.SliceStable(scenes, func(i, j int) bool { return scenes[i].Time.Before(scenes[j].Time) })
+	res := &Result{Scenes: scenes}
+
+	var open []*Track
+	for si, sc := range scenes {
+		var obs []Observation
+		var classes []string
+		for di, d := range sc.Result.Detections {
+			if d.GeoCenter == nil || (d.GeoCenter.Latitude == 0 && d.GeoCenter.Longitude == 0) {
+				continue
+			}
+			obs = append(obs, Observation{
+				Scene:     si,
+				Detection: di,
+				Time:      sc.Time,
+				Point:     geo.Point{Lat: d.GeoCenter.Latitude, Lon: d.GeoCenter.Longitude},
+				LengthM:   float64(d.EstimatedLengthM),
+			})
+			classes = append(classes, strings.ToLower(d.ClassName))
+		}
+
+		cost := make([][]float64, len(open))
+		for i, t := range open {
+			cost[i] = make([]float64, len(obs))
+			for j, o := range obs {
+				cost[i][j] = pairCost(t, o, classes[j], opts)
+			}
+		}
+		assigned := assign.Hungarian(cost)
+
+		taken := make([]bool, len(obs))
+		var still []*Track
+		for i, t := range open {
+			if j := assigned[i]; j >= 0 {
+				taken[j] = true
+				t.observe(obs[j])
+				still = append(still, t)
+				continue
+			}
+			t.missed++
+			if t.missed > opts.MaxMissed {
+				continue // closed: no longer matched against new scenes
+			}
+			still = append(still, t)
+		}
+		for j, o := range obs {
+			if taken[j] {
+				continue
+			}
+			t := &Track{
+				ID:    fmt.Sprintf("T%04d", len(res.Tracks)+1),
+				Class: sc.Result.Detections[o.Detection].ClassName,
+			}
+			t.observe(o)
+			res.Tracks = append(res.Tracks, t)
+			still = append(still, t)
+		}
+		open = still
+	}
+
+	for _, t := range res.Tracks {
+		t.finish(scenes)
+		for _, o := range t.Observations {
+			d := &scenes[o.Scene].Result.Detections[o.Detection]
+			if d.Attributes == nil {
+				d.Attributes = make(map[string]string)
+			}
+			d.Attributes[AttrTrackID] = t.ID
+		}
+		// Objects already there in the first scene did not arrive within
+		// the series.
+		if t.Observations[0].Scene > 0 {
+			res.Events = append(res.Events, Event{Time: t.FirstSeen, Type: EventArrival, TrackID: t.ID, Class: t.Class, Point: t.Observations[0].Point})
+		}
+		if !t.Departed.IsZero() {
+			res.Events = append(res.Events, Event{Time: t.Departed, Type: EventDeparture, TrackID: t.ID, Class: t.Class, Point: t.last.Point})
+		}
+	}
+	sort.SliceStable(res.Events, func(i, j int) bool { return res.Events[i].Time.Before(res.Events[j].Time) })
+	return res
+}
+
+// pairCost scores continuing track t with observation o, +Inf if the pair
+// is implausible.
+func pairCost(t *Track, o Observation, class string, opts Options) float64 {
+	if strings.ToLower(t.Class) != class {
+		return math.Inf(1)
+	}
+	d := geo.DistanceM(t.last.Point, o.Point) / opts.MaxDistanceM
+	if d > 1 {
+		return math.Inf(1)
+	}
+	cost := d * d
+	if a, b := t.last.LengthM, o.LengthM; a > 0 && b > 0 {
+		s := math.Abs(a-b) / math.Max(a, b) / opts.MaxSizeDiff
+		if s > 1 {
+			return math.Inf(1)
+		}
+		cost += s * s
+	}
+	// A track that missed scenes is a weaker continuation.
+	return cost + 0.1*float64(t.missed)
+}
+
+func (t *Track) observe(o Observation) {
+	if len(t.Observations) == 0 {
+		t.FirstSeen = o.Time
+	}
+	t.Observations = append(t.Observations, o)
+	t.LastSeen = o.Time
+	t.missed = 0
+	// Keep the last measured length for matching when a scene's box was
+	// too small to measure.
+	if o.LengthM <= 0 {
+		o.LengthM = t.last.LengthM
+	}
+	t.last = o
+}
+
+// finish derives the summary fields once all scenes are processed.
+func (t *Track) finish(scenes []Scene) {
+	last := t.last.Scene
+	t.Present = last == len(scenes)-1
+	end := len(scenes) - 1
+	if !t.Present {
+		t.Departed = scenes[last+1].Time
+		end = last + 1
+	}
+	t.Persistence = float64(len(t.Observations)) / float64(end-t.Observations[0].Scene+1)
+
+	pts := make([]geo.Point, len(t.Observations))
+	var lenSum float64
+	var lenN int
+	for i, o := range t.Observations {
+		pts[i] = o.Point
+		if o.LengthM > 0 {
+			lenSum += o.LengthM
+			lenN++
+		}
+	}
+	t.Position = geo.Centroid(pts)
+	if lenN > 0 {
+		t.LengthM = lenSum / float64(lenN)
+	}
+}
+
+// dateInName matches a date such as 20240115 or 2024-01-15 in a file name.
+var dateInName = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})(?:T(\d{2}):?(\d{2}):?(\d{2}))?`)
+
+// SceneTime returns a result's acquisition time from its scene_time field,
+// or else from a date (and optional time) in its file name.
+func SceneTime(path string, r *report.DetectResult) (time.Time, error) {
+	if r.SceneTime != "" {
+		return time.Parse(time.RFC3339, r.SceneTime)
+	}
+	for _, m := range dateInName.FindAllStringSubmatch(path, -1) {
+		s := m[1] + m[2] + m[3]
+		layout := "20060102"
+		if m[4] != "" {
+			s += m[4] + m[5] + m[6]
+			layout += "150405"
+		}
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s: no scene_time and no date in the file name", path)
+}
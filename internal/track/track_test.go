@@ -0,0 +1,225 @@
+package track
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clearclown/orbital-eye/internal/geo"
+	"github.com/clearclown/orbital-eye/internal/report"
+)
+
+var (
+	port = geo.Point{Lat: 1.25, Lon: 103.8}
+	day0 = time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC)
+)
+
+func det(class string, p geo.Point, lengthM float32) report.Detection {
+	return report.Detection{
+		ClassName:        class,
+		GeoCenter:        &report.GeoPoint{Latitude: p.Lat, Longitude: p.Lon},
+		EstimatedLengthM: lengthM,
+	}
+}
+
+// series returns four daily scenes, given out of order:
+//
+//	A  ship, seen throughout, drifting 20 m a day
+//	B  ship, arrives on day 1
+//	C  ship, seen on days 0-1, departs
+//	D  ship, hidden on day 1, seen again on days 2-3
+//	F  ship, 50 m long; a 300 m ship at the same spot on day 1 is another object
+//	P  airplane at A's position on day 3
+func series() []Scene {
+	a := func(day int) report.Detection {
+		return det("ship", geo.Destination(port, 90, 20*float64(day)), 200)
+	}
+	b := det("ship", geo.Destination(port, 90, 1000), 120)
+	c := det("Ship", geo.Destination(port, 0, 1000), 90)
+	d := det("ship", geo.Destination(port, 180, 1000), 0)
+	f := det("ship", geo.Destination(port, 270, 1000), 50)
+	big := det("ship", geo.Destination(port, 270, 1000), 300)
+	plane := det("airplane", geo.Destination(port, 90, 60), 40)
+	unplaced := report.Detection{ClassName: "ship"}
+
+	scene := func(day int, dets ...report.Detection) Scene {
+		return Scene{
+			Name:   day0.AddDate(0, 0, day).Format("20060102") + ".json",
+			Time:   day0.AddDate(0, 0, day),
+			Result: &report.DetectResult{Detections: dets},
+		}
+	}
+	return []Scene{
+		scene(2, a(2), b, d),
+		scene(0, a(0), c, d, f, unplaced),
+		scene(3, a(3), b, d, plane),
+		scene(1, a(1), b, c, big),
+	}
+}
+
+func TestRun(t *testing.T) {
+	res := Run(series(), Options{})
+	for i, sc := range res.Scenes {
+		if want := day0.AddDate(0, 0, i); !sc.Time.Equal(want) {
+			t.Fatalf("scene %d at %v, want %v", i, sc.Time, want)
+		}
+	}
+
+	type want struct {
+		class       string
+		sightings   int
+		present     bool
+		departed    int // day, or -1
+		persistence float64
+	}
+	wants := map[string]want{
+		"T0001": {"ship", 4, true, -1, 1},       // A
+		"T0002": {"Ship", 2, false, 2, 2.0 / 3}, // C
+		"T0003": {"ship", 3, true, -1, 3.0 / 4}, // D
+		"T0004": {"ship", 1, false, 1, 1.0 / 2}, // F
+		"T0005": {"ship", 3, true, -1, 1},       // B
+		"T0006": {"ship", 1, false, 2, 1.0 / 2}, // the 300 m ship
+		"T0007": {"airplane", 1, true, -1, 1},   // P
+	}
+	if len(res.Tracks) != len(wants) {
+		t.Errorf("%d tracks, want %d", len(res.Tracks), len(wants))
+	}
+	for _, tr := range res.Tracks {
+		w, ok := wants[tr.ID]
+		if !ok {
+			t.Errorf("unexpected track %s", tr.ID)
+			continue
+		}
+		departed := time.Time{}
+		if w.departed >= 0 {
+			departed = day0.AddDate(0, 0, w.departed)
+		}
+		if tr.Class != w.class || len(tr.Observations) != w.sightings || tr.Present != w.present ||
+			!tr.Departed.Equal(departed) || math.Abs(tr.Persistence-w.persistence) > 1e-9 {
+			t.Errorf("%s: %s, %d sightings, present %t, departed %v, persistence %.2f; want %+v",
+				tr.ID, tr.Class, len(tr.Observations), tr.Present, tr.Departed, tr.Persistence, w)
+		}
+	}
+
+	a := res.Tracks[0]
+	if a.Dwell() != 72*time.Hour || a.LengthM != 200 {
+		t.Errorf("A: dwell %v, length %v, want 72h, 200", a.Dwell(), a.LengthM)
+	}
+	if d := geo.DistanceM(a.Position, geo.Destination(port, 90, 30)); d > 0.5 {
+		t.Errorf("A: mean position %.2f m off", d)
+	}
+	if d := res.Tracks[2]; d.LengthM != 0 {
+		t.Errorf("D: length %v, want 0 (never measured)", d.LengthM)
+	}
+
+	var events []string
+	for _, e := range res.Events {
+		events = append(events, e.Time.Format("Jan 2")+" "+e.Type+" "+e.TrackID)
+	}
+	wantEvents := []string{
+		"Mar 2 departure T0004", "Mar 2 arrival T0005", "Mar 2 arrival T0006",
+		"Mar 3 departure T0002", "Mar 3 departure T0006",
+		"Mar 4 arrival T0007",
+	}
+	if strings.Join(events, ", ") != strings.Join(wantEvents, ", ") {
+		t.Errorf("events:\n  %s\nwant\n  %s", strings.Join(events, "\n  "), strings.Join(wantEvents, "\n  "))
+	}
+
+	// Every placed detection is labeled with its track.
+	for _, sc := range res.Scenes {
+		for _, d := range sc.Result.Detections {
+			if got := d.Attributes[AttrTrackID]; (got == "") != (d.GeoCenter == nil) {
+				t.Errorf("%s: %s detection has track ID %q", sc.Name, d.ClassName, got)
+			}
+		}
+	}
+	if got := res.Scenes[3].Result.Detections[0].Attributes[AttrTrackID]; got != "T0001" {
+		t.Errorf("A on day 3 is %s, want T0001", got)
+	}
+}
+
+func TestRunOptions(t *testing.T) {
+	// With no missed scenes allowed, D splits into two tracks.
+	if res := Run(series(), Options{MaxMissed: -1}); len(res.Tracks) != 8 {
+		t.Errorf("MaxMissed -1: %d tracks, want 8", len(res.Tracks))
+	}
+	// A 10 m gate loses A as it drifts 20 m a day.
+	res := Run(series(), Options{MaxDistanceM: 10})
+	ids := make(map[string]bool)
+	for _, sc := range res.Scenes {
+		ids[sc.Result.Detections[0].Attributes[AttrTrackID]] = true // A comes first
+	}
+	if len(ids) != 4 {
+		t.Errorf("MaxDistanceM 10: A in %d tracks, want 4", len(ids))
+	}
+	// A generous size gate lets the 300 m ship continue F's track.
+	if res := Run(series(), Options{MaxSizeDiff: 0.9}); len(res.Tracks) != 6 {
+		t.Errorf("MaxSizeDiff 0.9: %d tracks, want 6", len(res.Tracks))
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	res := Run(series(), Options{})
+	path := filepath.Join(t.TempDir(), "out", "tracks.json")
+	if err := WriteJSON(res, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Tracks []trackJSON `json:"tracks"`
+		Events []eventJSON `json:"events"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Tracks) != 7 || len(out.Events) != 6 {
+		t.Fatalf("%d tracks, %d events", len(out.Tracks), len(out.Events))
+	}
+	c := out.Tracks[1]
+	if c.Departed == nil || !c.Departed.Equal(day0.AddDate(0, 0, 2)) || c.DwellHours != 24 || c.Sightings != 2 {
+		t.Errorf("C: %+v", c)
+	}
+	if len(c.Observations) != 2 || c.Observations[1].Scene != "20240302.json" || c.Observations[1].Detection != 2 {
+		t.Errorf("C observations: %+v", c.Observations)
+	}
+	if out.Tracks[0].Departed != nil {
+		t.Errorf("A departed %v", out.Tracks[0].Departed)
+	}
+
+	var buf bytes.Buffer
+	PrintText(res, &buf)
+	for _, s := range []string{"T0001", "3d00h", "departed 2024-03-03", "arrival   T0007 airplane"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("text report lacks %q", s)
+		}
+	}
+}
+
+func TestSceneTime(t *testing.T) {
+	for _, c := range []struct {
+		path      string
+		sceneTime string
+		want      time.Time
+		ok        bool
+	}{
+		{"a.json", "2024-03-01T03:04:05Z", time.Date(2024, 3, 1, 3, 4, 5, 0, time.UTC), true},
+		{"runs/S2A_20240115.json", "", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), true},
+		{"2024-01-15T101559.json", "", time.Date(2024, 1, 15, 10, 15, 59, 0, time.UTC), true},
+		{"v1/20241399/20240116.json", "", time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), true},
+		{"latest.json", "", time.Time{}, false},
+		{"a.json", "yesterday", time.Time{}, false},
+	} {
+		got, err := SceneTime(c.path, &report.DetectResult{SceneTime: c.sceneTime})
+		if (err == nil) != c.ok || !got.Equal(c.want) {
+			t.Errorf("%s %q: got %v, %v, want %v", c.path, c.sceneTime, got, err, c.want)
+		}
+	}
+}